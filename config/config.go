@@ -1,30 +1,750 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"net/url"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
 )
 
+const (
+	// DefaultArchiveNameTemplate names archives after their task ID.
+	DefaultArchiveNameTemplate = "{{.TaskID}}.zip"
+	DefaultUploadDir           = "uploads"
+	DefaultMaxUploadSize       = 1 << 30
+	DefaultUploadExpirySeconds = 3600
+	DefaultMaxUploadsPerTask   = 3
+	DefaultMaxSSESubscribers   = 100
+	DefaultMaxSSEPerTask       = 10
+	DefaultMaxGitRepoSize      = 100 << 20
+	DefaultMaxPrefetches       = 4
+	DefaultStatsDPrefix        = "archiver"
+	DefaultURLExpiryMargin     = 60
+	DefaultMaxResponseHeader   = 64 << 10
+	DefaultShutdownGrace       = 30
+	DefaultArchiveMaxAge       = 600
+	DefaultMaxFileMetadata     = 4 << 10
+	DefaultMaxPagesPerSource   = 100
+	DefaultDownloadTimeout     = 30
+	DefaultTextTransformLine   = 64 << 10
+	DefaultArchiveDir          = "."
+	DefaultMaxExpectedFiles    = 1000
+	DefaultMaxExpandedEntries  = 10000
+	DefaultMaxExpandedSize     = 1 << 30
+	DefaultProgressCallback    = 1000
+	DefaultCleanupInterval     = 60
+	DefaultBusyRetryAfter      = 5
+	DefaultMaxURLLength        = 2048
+	DefaultRetryBackoff        = 500
+
+	// NamingSnake and NamingCamel are the supported JSON field namings.
+	NamingSnake = "snake"
+	NamingCamel = "camel"
+
+	// CompressionDeflate, CompressionBzip2 and CompressionZstd are the
+	// supported archive entry compression methods.
+	CompressionDeflate = "deflate"
+	CompressionBzip2   = "bzip2"
+	CompressionZstd    = "zstd"
+
+	// PublisherNone and PublisherNATS are the supported event publishers.
+	PublisherNone = "none"
+	PublisherNATS = "nats"
+
+	// LogLevelDebug, LogLevelInfo, LogLevelWarn and LogLevelError are the
+	// supported log levels.
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+
+	// LogFormatJSON and LogFormatText are the supported log formats.
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
+// DefaultAllowedMethods are the methods cross-origin requests may use
+// unless allowed_methods says otherwise.
+var DefaultAllowedMethods = []string{"GET", "POST", "HEAD", "PATCH", "DELETE", "OPTIONS"}
+
 type Config struct {
-	Port               string   `json:"port"`
-	AllowedExtensions  []string `json:"allowed_extensions"`
-	MaxFilesPerTask    int      `json:"max_files_per_task"`
-	MaxConcurrentTasks int      `json:"max_concurrent_tasks"`
+	Port                   string   `json:"port"`
+	AllowedExtensions      []string `json:"allowed_extensions"`
+	MaxFilesPerTask        int      `json:"max_files_per_task"`
+	MaxConcurrentTasks     int      `json:"max_concurrent_tasks"`
+	ComputeArchiveChecksum bool     `json:"compute_archive_checksum"`
+	// ArchiveNameTemplate is a text/template evaluated with .TaskID, .Date,
+	// .Time and .Format to name each archive.
+	ArchiveNameTemplate string `json:"archive_name_template"`
+	// UploadDir holds files uploaded through the tus endpoints until their
+	// task is processed.
+	UploadDir     string `json:"upload_dir"`
+	MaxUploadSize int64  `json:"max_upload_size"`
+	// UploadExpirySeconds is how long an incomplete upload may stay idle
+	// before it is removed.
+	UploadExpirySeconds int `json:"upload_expiry_seconds"`
+	// MaxUploadsPerTask caps the incomplete uploads a task may have open.
+	MaxUploadsPerTask int `json:"max_uploads_per_task"`
+	// PoliteMode spaces successive requests to the same host by
+	// PoliteDelayMs (or a per-host override) and honors Retry-After on any
+	// response.
+	PoliteMode         bool           `json:"polite_mode"`
+	PoliteDelayMs      int            `json:"polite_delay_ms"`
+	PoliteHostDelaysMs map[string]int `json:"polite_host_delays_ms"`
+	// DedupeContent stores byte-identical files only once per archive and
+	// records later copies as duplicates of the first.
+	DedupeContent bool `json:"dedupe_content"`
+	// JSONFieldNaming is the default naming of response fields, "snake"
+	// or "camel". Clients may override it with an Accept parameter such as
+	// "application/json; naming=camel".
+	JSONFieldNaming string `json:"json_field_naming"`
+	// MaxSSESubscribers caps concurrent event stream clients across all
+	// tasks, MaxSSESubscribersPerTask caps them for a single task.
+	MaxSSESubscribers        int `json:"max_sse_subscribers"`
+	MaxSSESubscribersPerTask int `json:"max_sse_subscribers_per_task"`
+	// EnableGitSources allows git://, git+https:// and git+http:// URLs,
+	// which are shallow-cloned and archived up to MaxGitRepoSize bytes.
+	EnableGitSources bool  `json:"enable_git_sources"`
+	MaxGitRepoSize   int64 `json:"max_git_repo_size"`
+	// ResolveEntryNames names entries after the Content-Disposition
+	// filename when present and appends an extension derived from the
+	// Content-Type to names that have none.
+	ResolveEntryNames bool `json:"resolve_entry_names"`
+	// EventPublisher selects where task completion messages are sent:
+	// "none" (default) or "nats", which publishes to NATSSubject on the
+	// server at NATSAddr (host:port).
+	EventPublisher string `json:"event_publisher"`
+	NATSAddr       string `json:"nats_addr"`
+	NATSSubject    string `json:"nats_subject"`
+	// SigningKeyFile is a PEM-encoded PKCS#8 ed25519 private key. When set,
+	// finished archives are signed with Ed25519ph (SHA-512 prehash).
+	SigningKeyFile string             `json:"signing_key_file"`
+	SigningKey     ed25519.PrivateKey `json:"-"`
+	// PrefetchOnAdd starts downloading each remote file as soon as it is
+	// added, staging it on disk for processing. At most MaxPrefetches
+	// downloads run at once.
+	PrefetchOnAdd bool `json:"prefetch_on_add"`
+	MaxPrefetches int  `json:"max_prefetches"`
+	// DeadLetterFile, when set, receives a JSON line for every task that
+	// ends in the error state.
+	DeadLetterFile string `json:"dead_letter_file"`
+	// TaskByteBudget and TaskTimeBudgetSeconds limit the bytes downloaded
+	// and the time spent downloading across all files of a task; files
+	// left once either is spent are skipped. Zero means unlimited, and
+	// tasks may override both when they are created.
+	TaskByteBudget        int64 `json:"task_byte_budget"`
+	TaskTimeBudgetSeconds int   `json:"task_time_budget_seconds"`
+	// DownloadPageTemplate is an html/template file that replaces the
+	// built-in download page of GET /tasks/{id}/download.
+	DownloadPageTemplate string `json:"download_page_template"`
+	// MaxFileSize caps the size of each downloaded file in bytes, zero
+	// meaning unlimited. MaxFileSizeByExtension overrides it for entries
+	// with the given extensions, e.g. {".mp4": 1073741824}.
+	MaxFileSize            int64            `json:"max_file_size"`
+	MaxFileSizeByExtension map[string]int64 `json:"max_file_size_by_extension"`
+	// CompressionMethod compresses archive entries with "deflate"
+	// (default), "bzip2" or "zstd". Only deflate is understood by every
+	// extractor; bzip2 and zstd need a tool such as 7-Zip or a recent
+	// Info-ZIP unzip.
+	CompressionMethod string `json:"compression_method"`
+	// RestoreArchivesOnStartup registers a finished task for every
+	// "<task id>.zip" archive found at startup, so archives written by an
+	// earlier run stay downloadable.
+	RestoreArchivesOnStartup bool `json:"restore_archives_on_startup"`
+	// MaxDownloadPhaseDuration caps, in seconds, how long a task may spend
+	// downloading its files. Downloads still running at the deadline are
+	// aborted, the remaining files are skipped and the archive is finished
+	// with what completed. Zero means no limit.
+	MaxDownloadPhaseDuration int `json:"max_download_phase_seconds"`
+	// CoalesceIdenticalTasks lets a task whose URL set matches one that is
+	// processing or finished share that task's run and archive instead of
+	// downloading everything again.
+	CoalesceIdenticalTasks bool `json:"coalesce_identical_tasks"`
+	// ResponseHeaders are set on every response.
+	ResponseHeaders map[string]string `json:"response_headers"`
+	// AllowedOrigins enables CORS for the listed origins ("*" for any);
+	// AllowedMethods are the methods their requests may use and default to
+	// GET, POST, HEAD, PATCH, DELETE and OPTIONS.
+	AllowedOrigins []string `json:"allowed_origins"`
+	AllowedMethods []string `json:"allowed_methods"`
+	// ParallelDownloads is how many files of a task are downloaded at once
+	// into temporary files before being written to the archive in order.
+	// At most this many files are staged on disk per task. Zero or one
+	// downloads each file while it is written.
+	ParallelDownloads int `json:"parallel_downloads"`
+	// ClientIDHeader names a request header carrying the client identity,
+	// e.g. set by an authenticating proxy. When set, requests to create
+	// tasks or download archives must carry it, each client's archives are
+	// stored under clients/<id>/ and clients only see their own archives.
+	ClientIDHeader string `json:"client_id_header"`
+	// VerifyArchives rereads each finished archive and checks the CRC of
+	// every entry before it is served. This doubles the read I/O.
+	VerifyArchives bool `json:"verify_archives"`
+	// RebuildOnVerifyFailure builds an archive that failed verification
+	// once more from scratch before failing the task.
+	RebuildOnVerifyFailure bool `json:"rebuild_on_verify_failure"`
+	// StatsDAddr (host:port) enables sending task and file metrics to
+	// StatsD over UDP, with names prefixed by StatsDPrefix.
+	StatsDAddr   string `json:"statsd_addr"`
+	StatsDPrefix string `json:"statsd_prefix"`
+	// MaxDistinctHostsPerTask, when positive, rejects files that would make
+	// a task's URLs span more distinct hosts, so the service cannot be used
+	// as a general-purpose crawler.
+	MaxDistinctHostsPerTask int `json:"max_distinct_hosts_per_task"`
+	// SLODefaultMs is the latency budget of every route in milliseconds;
+	// slower requests are logged and counted. SLORoutesMs overrides it per
+	// route pattern, e.g. "/tasks/{id}". Zero disables the check.
+	SLODefaultMs int            `json:"slo_default_ms"`
+	SLORoutesMs  map[string]int `json:"slo_routes_ms"`
+	// URLExpiryMarginSeconds is how long before its expiry a presigned URL
+	// draws a warning when added, as it may expire before it is processed.
+	URLExpiryMarginSeconds int `json:"url_expiry_margin_seconds"`
+	// SmallFileBundleThreshold, when positive, stores files smaller than it
+	// (in bytes) together in one small_files.tar entry instead of one
+	// entry each.
+	SmallFileBundleThreshold int64 `json:"small_file_bundle_threshold"`
+	// MaxResponseHeaderBytes caps the size of the response headers of
+	// origins; downloads with larger headers fail.
+	MaxResponseHeaderBytes int64 `json:"max_response_header_bytes"`
+	// ShutdownGraceSeconds is how long shutdown waits for tasks being
+	// processed before recording them as interrupted.
+	ShutdownGraceSeconds int `json:"shutdown_grace_seconds"`
+	// ArchiveMaxAgeSeconds is how long archives are kept. Older archives
+	// are answered with 410 Gone, and removed right away with
+	// DeleteExpiredOnServe, before the cleanup sweep deletes them.
+	ArchiveMaxAgeSeconds int  `json:"archive_max_age_seconds"`
+	DeleteExpiredOnServe bool `json:"delete_expired_on_serve"`
+	// DownloadProxy is an http://, https:// or socks5:// proxy URL for
+	// downloads. Hosts matching NoProxy (NO_PROXY syntax) are reached
+	// directly.
+	DownloadProxy string   `json:"download_proxy"`
+	NoProxy       []string `json:"no_proxy"`
+	// MaxFileMetadataBytes caps the encoded size of the metadata a file
+	// may carry into the manifest.
+	MaxFileMetadataBytes int `json:"max_file_metadata_bytes"`
+	// TaskStoreFile, when set, is a JSON file the tasks are saved to and
+	// loaded from at startup, so they survive restarts.
+	TaskStoreFile string `json:"task_store_file"`
+	// EnablePagedSources allows paged+https:// and paged+http:// URLs,
+	// paginated APIs whose pages are followed through Link rel="next"
+	// headers, at most MaxPagesPerSource pages each.
+	EnablePagedSources bool `json:"enable_paged_sources"`
+	MaxPagesPerSource  int  `json:"max_pages_per_source"`
+	// SSEHeartbeatSeconds, when positive, is the interval of ": ping"
+	// comments sent on event streams to keep idle connections open.
+	SSEHeartbeatSeconds int `json:"sse_heartbeat_seconds"`
+	// DownloadTimeout is how many seconds a single download may take,
+	// reading the body included, before it fails with TIMEOUT.
+	DownloadTimeout int `json:"download_timeout_seconds"`
+	// EnableTextTransforms applies TextTransforms to text files line by
+	// line before they are archived. Lines longer than
+	// TextTransformMaxLineBytes are transformed in pieces of that size.
+	EnableTextTransforms      bool            `json:"enable_text_transforms"`
+	TextTransforms            []TextTransform `json:"text_transforms"`
+	TextTransformMaxLineBytes int             `json:"text_transform_max_line_bytes"`
+	// MaxConcurrentExtractions caps the single files served at once from
+	// one archive; further requests wait. Zero means no limit.
+	MaxConcurrentExtractions int `json:"max_concurrent_extractions_per_archive"`
+	// ExtractCacheSize is how many opened archives are kept for serving
+	// single files. Zero opens the archive for every request.
+	ExtractCacheSize int `json:"extract_cache_size"`
+	// ExtendTTLOnAccess restarts the archive_max_age_seconds clock of an
+	// archive every time it is served, so only idle archives expire.
+	ExtendTTLOnAccess bool `json:"extend_ttl_on_access"`
+	// ArchiveDir is where archives are written, served from and cleaned
+	// up. It is created on startup if missing.
+	ArchiveDir string `json:"archive_dir"`
+	// MinExpectedFiles and MaxExpectedFiles bound the expected_files a
+	// task may declare to start processing at instead of MaxFilesPerTask.
+	MinExpectedFiles int `json:"min_expected_files"`
+	MaxExpectedFiles int `json:"max_expected_files"`
+	// ExpandArchives unpacks .zip, .tar.gz and .tgz sources into a folder
+	// of the output archive. MaxExpandedEntries and MaxExpandedSize bound
+	// the entries and uncompressed bytes of each such source.
+	ExpandArchives     bool  `json:"expand_archives"`
+	MaxExpandedEntries int   `json:"max_expanded_entries"`
+	MaxExpandedSize    int64 `json:"max_expanded_size"`
+	// CallbackAllowedHosts restricts callback_url and
+	// progress_callback_url to these hosts; ".example.com" also allows
+	// its subdomains. Empty allows any host.
+	CallbackAllowedHosts []string `json:"callback_allowed_hosts"`
+	// ProgressCallbackIntervalMs is the minimum time between two POSTs to
+	// a progress_callback_url; file results in between are batched.
+	ProgressCallbackIntervalMs int `json:"progress_callback_interval_ms"`
+	// TaskRetentionSeconds is how long the records of finished tasks are
+	// kept, reporting archive_expired once the archive itself is gone.
+	// Zero keeps them for as long as the server runs.
+	TaskRetentionSeconds int `json:"task_retention_seconds"`
+	// CleanupIntervalSeconds is how often expired archives, task records
+	// and stale uploads are swept.
+	CleanupIntervalSeconds int `json:"cleanup_interval_seconds"`
+	// PreservePaths keeps the directories of the URL path in entry names,
+	// so https://host/a/b/file.pdf becomes a/b/file.pdf.
+	PreservePaths bool `json:"preserve_paths"`
+	// SniffEntryContentType types single files served from an archive by
+	// their content when their extension does not tell.
+	SniffEntryContentType bool `json:"sniff_entry_content_type"`
+	// ResumeInterruptedTasks keeps the finished downloads of processing
+	// tasks on disk, so a task interrupted by a crash is processed again
+	// on restart, fetching only the files that had not finished.
+	ResumeInterruptedTasks bool `json:"resume_interrupted_tasks"`
+	// BusyRetryAfterSeconds is the Retry-After sent with 503 responses
+	// when every task slot is taken.
+	BusyRetryAfterSeconds int `json:"busy_retry_after_seconds"`
+	// APIKeys are the keys clients must send in X-API-Key. Empty disables
+	// authentication.
+	APIKeys []string `json:"api_keys"`
+	// CompressionLevel is the flate level, 0 to 9, of deflated zip entries
+	// and of tar.gz archives. 0 stores zip entries uncompressed whatever
+	// the compression method. Unset means the flate default.
+	CompressionLevel *int `json:"compression_level"`
+	// BlockPrivateIPs refuses file URLs, redirects and connections that
+	// reach loopback, link-local or private (RFC 1918) addresses, such as
+	// cloud metadata services.
+	BlockPrivateIPs bool `json:"block_private_ips"`
+	// MaxURLLength caps the length of a file URL added to a task.
+	MaxURLLength int `json:"max_url_length"`
+	// AllowedDomains, when not empty, limits file URLs and their redirects
+	// to these hosts. An entry with a leading dot, such as ".example.com",
+	// also covers the subdomains of the domain.
+	AllowedDomains []string `json:"allowed_domains"`
+	// LogLevel is the least severe level logged: "debug", "info" (the
+	// default), "warn" or "error".
+	LogLevel string `json:"log_level"`
+	// LogFormat writes log records as JSON objects ("json", the default)
+	// or as human-readable key=value lines ("text").
+	LogFormat string `json:"log_format"`
+	// DownloadRetries is how many times a download that failed with a
+	// network error or a 429, 502, 503 or 504 response is tried again.
+	// RetryBackoffMs is the wait before the first retry, doubled for each
+	// further one; a Retry-After header takes precedence.
+	DownloadRetries int `json:"download_retries"`
+	RetryBackoffMs  int `json:"retry_backoff_ms"`
+	// PublicMetrics serves GET /metrics without an API key, for scrapers
+	// that cannot send one. By default it requires a key like the API.
+	PublicMetrics bool `json:"public_metrics"`
 }
 
-func LoadConfig(path string) (*Config, error) {
-	file, err := os.Open(path)
+// TextTransform replaces every match of Pattern with Replace, which may
+// refer to groups as $1 or ${name}.
+type TextTransform struct {
+	Pattern string         `json:"pattern"`
+	Replace string         `json:"replace"`
+	Regexp  *regexp.Regexp `json:"-"`
+}
+
+// profileFile is a config file holding several named configurations under
+// a top-level "profiles" object.
+type profileFile struct {
+	Profiles map[string]json.RawMessage `json:"profiles"`
+}
+
+// LoadConfig reads the config at path. If the file has a top-level
+// "profiles" object, profile selects which entry is used; a flat file is
+// used as is and profile must then be empty.
+//
+// The environment variables EnvPort, EnvMaxConcurrentTasks,
+// EnvMaxFilesPerTask and EnvAllowedExtensions take precedence over the
+// file. Unless a profile is requested, a missing file is not an error: the
+// config is then built from the environment alone and must still pass
+// Validate.
+func LoadConfig(path, profile string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && profile == "" {
+		data = []byte("{}")
+	} else if err != nil {
+		return nil, err
+	}
+	data, err = selectProfile(data, profile)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
 	cfg := &Config{}
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(cfg)
+	err = json.Unmarshal(data, cfg)
 	if err != nil {
 		return nil, err
 	}
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
 
+	cfg.applyDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.resolve(); err != nil {
+		return nil, err
+	}
 	return cfg, nil
 }
+
+// applyDefaults fills in the settings left unset and normalizes the
+// spelling of extensions, domains, methods and the log level.
+func (cfg *Config) applyDefaults() {
+	if cfg.ArchiveNameTemplate == "" {
+		cfg.ArchiveNameTemplate = DefaultArchiveNameTemplate
+	}
+	if cfg.UploadDir == "" {
+		cfg.UploadDir = DefaultUploadDir
+	}
+	if cfg.ArchiveDir == "" {
+		cfg.ArchiveDir = DefaultArchiveDir
+	}
+	if cfg.ProgressCallbackIntervalMs <= 0 {
+		cfg.ProgressCallbackIntervalMs = DefaultProgressCallback
+	}
+	if cfg.MaxExpandedEntries <= 0 {
+		cfg.MaxExpandedEntries = DefaultMaxExpandedEntries
+	}
+	if cfg.MaxExpandedSize <= 0 {
+		cfg.MaxExpandedSize = DefaultMaxExpandedSize
+	}
+	if cfg.MinExpectedFiles <= 0 {
+		cfg.MinExpectedFiles = 1
+	}
+	if cfg.MaxExpectedFiles <= 0 {
+		cfg.MaxExpectedFiles = DefaultMaxExpectedFiles
+	}
+	if cfg.MaxUploadSize <= 0 {
+		cfg.MaxUploadSize = DefaultMaxUploadSize
+	}
+	if cfg.UploadExpirySeconds <= 0 {
+		cfg.UploadExpirySeconds = DefaultUploadExpirySeconds
+	}
+	if cfg.MaxUploadsPerTask <= 0 {
+		cfg.MaxUploadsPerTask = DefaultMaxUploadsPerTask
+	}
+	if cfg.MaxSSESubscribers <= 0 {
+		cfg.MaxSSESubscribers = DefaultMaxSSESubscribers
+	}
+	if cfg.MaxSSESubscribersPerTask <= 0 {
+		cfg.MaxSSESubscribersPerTask = DefaultMaxSSEPerTask
+	}
+	if cfg.MaxGitRepoSize <= 0 {
+		cfg.MaxGitRepoSize = DefaultMaxGitRepoSize
+	}
+	if len(cfg.MaxFileSizeByExtension) > 0 {
+		limits := make(map[string]int64, len(cfg.MaxFileSizeByExtension))
+		for ext, limit := range cfg.MaxFileSizeByExtension {
+			limits[strings.ToLower(ext)] = limit
+		}
+		cfg.MaxFileSizeByExtension = limits
+	}
+	if len(cfg.AllowedMethods) == 0 {
+		cfg.AllowedMethods = append([]string(nil), DefaultAllowedMethods...)
+	}
+	for i, method := range cfg.AllowedMethods {
+		cfg.AllowedMethods[i] = strings.ToUpper(method)
+	}
+	for i, ext := range cfg.AllowedExtensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext != "" && !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		cfg.AllowedExtensions[i] = ext
+	}
+	for i, domain := range cfg.AllowedDomains {
+		cfg.AllowedDomains[i] = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	}
+	if cfg.StatsDPrefix == "" {
+		cfg.StatsDPrefix = DefaultStatsDPrefix
+	}
+	if cfg.MaxPrefetches <= 0 {
+		cfg.MaxPrefetches = DefaultMaxPrefetches
+	}
+	if cfg.TextTransformMaxLineBytes <= 0 {
+		cfg.TextTransformMaxLineBytes = DefaultTextTransformLine
+	}
+	if cfg.DownloadTimeout <= 0 {
+		cfg.DownloadTimeout = DefaultDownloadTimeout
+	}
+	if cfg.MaxPagesPerSource <= 0 {
+		cfg.MaxPagesPerSource = DefaultMaxPagesPerSource
+	}
+	if cfg.MaxFileMetadataBytes <= 0 {
+		cfg.MaxFileMetadataBytes = DefaultMaxFileMetadata
+	}
+	if cfg.ArchiveMaxAgeSeconds <= 0 {
+		cfg.ArchiveMaxAgeSeconds = DefaultArchiveMaxAge
+	}
+	if cfg.BusyRetryAfterSeconds <= 0 {
+		cfg.BusyRetryAfterSeconds = DefaultBusyRetryAfter
+	}
+	if cfg.MaxURLLength <= 0 {
+		cfg.MaxURLLength = DefaultMaxURLLength
+	}
+	if cfg.RetryBackoffMs <= 0 {
+		cfg.RetryBackoffMs = DefaultRetryBackoff
+	}
+	if cfg.CleanupIntervalSeconds <= 0 {
+		cfg.CleanupIntervalSeconds = DefaultCleanupInterval
+	}
+	if cfg.ShutdownGraceSeconds <= 0 {
+		cfg.ShutdownGraceSeconds = DefaultShutdownGrace
+	}
+	if cfg.MaxResponseHeaderBytes <= 0 {
+		cfg.MaxResponseHeaderBytes = DefaultMaxResponseHeader
+	}
+	if cfg.URLExpiryMarginSeconds <= 0 {
+		cfg.URLExpiryMarginSeconds = DefaultURLExpiryMargin
+	}
+	if cfg.EventPublisher == "" {
+		cfg.EventPublisher = PublisherNone
+	}
+	if cfg.CompressionMethod == "" {
+		cfg.CompressionMethod = CompressionDeflate
+	}
+	if cfg.JSONFieldNaming == "" {
+		cfg.JSONFieldNaming = NamingSnake
+	}
+	if cfg.LogLevel = strings.ToLower(cfg.LogLevel); cfg.LogLevel == "" {
+		cfg.LogLevel = LogLevelInfo
+	}
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = LogFormatJSON
+	}
+}
+
+// Validate reports settings the server cannot run with, such as a
+// max_concurrent_tasks of 0, which would block every task from starting.
+// It checks the config as LoadConfig leaves it, with defaults applied.
+func (cfg *Config) Validate() error {
+	if cfg.Port == "" {
+		return fmt.Errorf("invalid port: must not be empty")
+	}
+	if cfg.MaxConcurrentTasks < 1 {
+		return fmt.Errorf("invalid max_concurrent_tasks: %d, must be at least 1", cfg.MaxConcurrentTasks)
+	}
+	if cfg.MaxFilesPerTask < 1 {
+		return fmt.Errorf("invalid max_files_per_task: %d, must be at least 1", cfg.MaxFilesPerTask)
+	}
+	if len(cfg.AllowedExtensions) == 0 {
+		return fmt.Errorf("invalid allowed_extensions: must list at least one extension")
+	}
+	for _, ext := range cfg.AllowedExtensions {
+		if ext == "" || ext == "." {
+			return fmt.Errorf("invalid allowed_extensions entry %q", ext)
+		}
+	}
+	if cfg.MinExpectedFiles > cfg.MaxExpectedFiles {
+		return fmt.Errorf("invalid min_expected_files: %d is over max_expected_files %d", cfg.MinExpectedFiles, cfg.MaxExpectedFiles)
+	}
+	if cfg.TaskByteBudget < 0 || cfg.TaskTimeBudgetSeconds < 0 {
+		return fmt.Errorf("invalid task budget: task_byte_budget and task_time_budget_seconds must not be negative")
+	}
+	if cfg.MaxDownloadPhaseDuration < 0 {
+		return fmt.Errorf("invalid max_download_phase_seconds: must not be negative")
+	}
+	if cfg.ParallelDownloads < 0 {
+		return fmt.Errorf("invalid parallel_downloads: must not be negative")
+	}
+	if cfg.MaxDistinctHostsPerTask < 0 {
+		return fmt.Errorf("invalid max_distinct_hosts_per_task: must not be negative")
+	}
+	if cfg.SLODefaultMs < 0 {
+		return fmt.Errorf("invalid slo_default_ms: must not be negative")
+	}
+	for route, ms := range cfg.SLORoutesMs {
+		if ms < 0 {
+			return fmt.Errorf("invalid slo_routes_ms entry %q: %d", route, ms)
+		}
+	}
+	if cfg.SmallFileBundleThreshold < 0 {
+		return fmt.Errorf("invalid small_file_bundle_threshold: must not be negative")
+	}
+	if cfg.MaxFileSize < 0 {
+		return fmt.Errorf("invalid max_file_size: must not be negative")
+	}
+	for ext, limit := range cfg.MaxFileSizeByExtension {
+		if !strings.HasPrefix(ext, ".") || limit < 0 {
+			return fmt.Errorf("invalid max_file_size_by_extension entry %q: %d", ext, limit)
+		}
+	}
+	for i, rule := range cfg.TextTransforms {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("invalid text_transforms[%d]: %w", i, err)
+		}
+	}
+	if cfg.DownloadProxy != "" {
+		u, err := url.Parse(cfg.DownloadProxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid download_proxy %q", cfg.DownloadProxy)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("invalid download_proxy %q: unsupported scheme %q", cfg.DownloadProxy, u.Scheme)
+		}
+	}
+	if cfg.TaskRetentionSeconds < 0 || cfg.TaskRetentionSeconds > 0 && cfg.TaskRetentionSeconds < cfg.ArchiveMaxAgeSeconds {
+		return fmt.Errorf("invalid task_retention_seconds: must be 0 or at least archive_max_age_seconds")
+	}
+	switch cfg.EventPublisher {
+	case PublisherNone:
+	case PublisherNATS:
+		if cfg.NATSAddr == "" || cfg.NATSSubject == "" {
+			return fmt.Errorf("event_publisher %q requires nats_addr and nats_subject", PublisherNATS)
+		}
+	default:
+		return fmt.Errorf("invalid event_publisher %q", cfg.EventPublisher)
+	}
+	switch cfg.CompressionMethod {
+	case CompressionDeflate, CompressionBzip2, CompressionZstd:
+	default:
+		return fmt.Errorf("invalid compression_method %q: must be %q, %q or %q", cfg.CompressionMethod, CompressionDeflate, CompressionBzip2, CompressionZstd)
+	}
+	switch cfg.JSONFieldNaming {
+	case NamingSnake, NamingCamel:
+	default:
+		return fmt.Errorf("invalid json_field_naming %q: must be %q or %q", cfg.JSONFieldNaming, NamingSnake, NamingCamel)
+	}
+	switch cfg.LogLevel {
+	case LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError:
+	default:
+		return fmt.Errorf("invalid log_level %q: must be %q, %q, %q or %q", cfg.LogLevel, LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError)
+	}
+	switch cfg.LogFormat {
+	case LogFormatJSON, LogFormatText:
+	default:
+		return fmt.Errorf("invalid log_format %q: must be %q or %q", cfg.LogFormat, LogFormatJSON, LogFormatText)
+	}
+	if _, err := template.New("archive_name").Parse(cfg.ArchiveNameTemplate); err != nil {
+		return fmt.Errorf("invalid archive_name_template: %w", err)
+	}
+	if cfg.DownloadPageTemplate != "" {
+		if _, err := htmltemplate.ParseFiles(cfg.DownloadPageTemplate); err != nil {
+			return fmt.Errorf("invalid download_page_template: %w", err)
+		}
+	}
+	if cfg.DownloadRetries < 0 {
+		return fmt.Errorf("invalid download_retries: %d, must not be negative", cfg.DownloadRetries)
+	}
+	for _, domain := range cfg.AllowedDomains {
+		if domain == "" || domain == "." {
+			return fmt.Errorf("invalid allowed_domains entry %q", domain)
+		}
+	}
+	if level := cfg.CompressionLevel; level != nil && (*level < 0 || *level > 9) {
+		return fmt.Errorf("invalid compression_level: %d, must be between 0 and 9", *level)
+	}
+	return nil
+}
+
+// resolve fills in what a valid config refers to: the compiled
+// text_transforms patterns and the key in signing_key_file.
+func (cfg *Config) resolve() error {
+	for i := range cfg.TextTransforms {
+		rule := &cfg.TextTransforms[i]
+		// Validate has compiled the pattern once already.
+		rule.Regexp = regexp.MustCompile(rule.Pattern)
+	}
+	if cfg.SigningKeyFile != "" {
+		key, err := loadSigningKey(cfg.SigningKeyFile)
+		if err != nil {
+			return fmt.Errorf("invalid signing_key_file: %w", err)
+		}
+		cfg.SigningKey = key
+	}
+	return nil
+}
+
+// These environment variables override the settings of the same name in
+// the config file.
+const (
+	EnvPort               = "ARCHIVER_PORT"
+	EnvMaxConcurrentTasks = "ARCHIVER_MAX_CONCURRENT_TASKS"
+	EnvMaxFilesPerTask    = "ARCHIVER_MAX_FILES_PER_TASK"
+	EnvAllowedExtensions  = "ARCHIVER_ALLOWED_EXTENSIONS"
+)
+
+// applyEnv overlays the settings given by the environment variables that
+// lookup finds. ARCHIVER_ALLOWED_EXTENSIONS is a comma-separated list.
+func (cfg *Config) applyEnv(lookup func(string) (string, bool)) error {
+	if port, ok := lookup(EnvPort); ok {
+		cfg.Port = strings.TrimSpace(port)
+	}
+	for name, field := range map[string]*int{
+		EnvMaxConcurrentTasks: &cfg.MaxConcurrentTasks,
+		EnvMaxFilesPerTask:    &cfg.MaxFilesPerTask,
+	} {
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: must be an integer", name, value)
+		}
+		*field = n
+	}
+	if exts, ok := lookup(EnvAllowedExtensions); ok {
+		cfg.AllowedExtensions = nil
+		for _, ext := range strings.Split(exts, ",") {
+			if ext = strings.TrimSpace(ext); ext != "" {
+				cfg.AllowedExtensions = append(cfg.AllowedExtensions, ext)
+			}
+		}
+	}
+	return nil
+}
+
+// selectProfile returns the JSON of the requested profile in data, or data
+// itself for a flat config.
+func selectProfile(data []byte, profile string) ([]byte, error) {
+	var file profileFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Profiles == nil {
+		if profile != "" {
+			return nil, fmt.Errorf("invalid profile %q: config file has no profiles", profile)
+		}
+		return data, nil
+	}
+
+	names := make([]string, 0, len(file.Profiles))
+	for name := range file.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if profile == "" {
+		return nil, fmt.Errorf("config file has profiles (%s) but none was selected", strings.Join(names, ", "))
+	}
+	selected, ok := file.Profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("invalid profile %q: must be one of %s", profile, strings.Join(names, ", "))
+	}
+	return selected, nil
+}
+
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key is not ed25519")
+	}
+	return edKey, nil
+}
@@ -0,0 +1,338 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// minimalConfig is the smallest config file LoadConfig accepts.
+const minimalConfig = `{"port": "8080", "allowed_extensions": [".pdf", "JPG"], "max_files_per_task": 3, "max_concurrent_tasks": 3}`
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, minimalConfig), "")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"archive_name_template", cfg.ArchiveNameTemplate, DefaultArchiveNameTemplate},
+		{"archive_dir", cfg.ArchiveDir, DefaultArchiveDir},
+		{"upload_dir", cfg.UploadDir, DefaultUploadDir},
+		{"max_uploads_per_task", cfg.MaxUploadsPerTask, DefaultMaxUploadsPerTask},
+		{"event_publisher", cfg.EventPublisher, PublisherNone},
+		{"compression_method", cfg.CompressionMethod, CompressionDeflate},
+		{"json_field_naming", cfg.JSONFieldNaming, NamingSnake},
+		{"log_level", cfg.LogLevel, LogLevelInfo},
+		{"log_format", cfg.LogFormat, LogFormatJSON},
+		{"allowed_extensions[1]", cfg.AllowedExtensions[1], ".jpg"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	data := `{"profiles": {"dev": ` + minimalConfig + `, "prod": ` + strings.Replace(minimalConfig, `"8080"`, `"80"`, 1) + `}}`
+	path := writeConfig(t, data)
+	cfg, err := LoadConfig(path, "prod")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Port != "80" || cfg.ArchiveNameTemplate != DefaultArchiveNameTemplate {
+		t.Fatalf("prod profile loaded as port %q, template %q", cfg.Port, cfg.ArchiveNameTemplate)
+	}
+	if _, err := LoadConfig(path, ""); err == nil {
+		t.Fatal("LoadConfig without a profile succeeded")
+	}
+}
+
+func TestSelectProfile(t *testing.T) {
+	const profiles = `{"profiles": {"dev": {"port": "8081"}, "prod": {"port": "80"}}}`
+	tests := []struct {
+		name    string
+		data    string
+		profile string
+		want    string
+		wantErr string
+	}{
+		{"flat", minimalConfig, "", minimalConfig, ""},
+		{"flat with profile", minimalConfig, "dev", "", "config file has no profiles"},
+		{"selected", profiles, "prod", `{"port": "80"}`, ""},
+		{"none selected", profiles, "", "", "has profiles (dev, prod)"},
+		{"unknown", profiles, "staging", "", "must be one of dev, prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectProfile([]byte(tt.data), tt.profile)
+			switch {
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("selectProfile error %v, want one containing %q", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("selectProfile: %v", err)
+			case string(got) != tt.want:
+				t.Fatalf("selectProfile = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigTextTransforms(t *testing.T) {
+	withRules := func(rules string) string {
+		return strings.Replace(minimalConfig, `{`, `{"text_transforms": `+rules+`, `, 1)
+	}
+	cfg, err := LoadConfig(writeConfig(t, withRules(`[{"pattern": "a(b)", "replace": "$1"}]`)), "")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if got := cfg.TextTransforms[0].Regexp.ReplaceAllString("ab", cfg.TextTransforms[0].Replace); got != "b" {
+		t.Fatalf("compiled rule turns %q into %q", "ab", got)
+	}
+	if cfg.TextTransformMaxLineBytes != DefaultTextTransformLine {
+		t.Fatalf("text_transform_max_line_bytes defaults to %d", cfg.TextTransformMaxLineBytes)
+	}
+	if _, err := LoadConfig(writeConfig(t, withRules(`[{"pattern": "("}]`)), ""); err == nil || !strings.Contains(err.Error(), "text_transforms[0]") {
+		t.Fatalf("LoadConfig with a bad pattern: %v", err)
+	}
+}
+
+func TestLoadConfigExpectedFiles(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, minimalConfig), "")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.MinExpectedFiles != 1 || cfg.MaxExpectedFiles != DefaultMaxExpectedFiles {
+		t.Fatalf("expected files bounded to [%d, %d] by default", cfg.MinExpectedFiles, cfg.MaxExpectedFiles)
+	}
+	data := strings.Replace(minimalConfig, `{`, `{"min_expected_files": 5, "max_expected_files": 4, `, 1)
+	if _, err := LoadConfig(writeConfig(t, data), ""); err == nil || !strings.Contains(err.Error(), "min_expected_files") {
+		t.Fatalf("LoadConfig with min over max: %v", err)
+	}
+}
+
+func TestLoadConfigTaskRetention(t *testing.T) {
+	tests := []struct {
+		retention string
+		wantErr   bool
+	}{
+		{"0", false},
+		{"7200", false},
+		{"60", true},
+		{"-1", true},
+	}
+	for _, tt := range tests {
+		data := strings.Replace(minimalConfig, `{`, `{"archive_max_age_seconds": 3600, "task_retention_seconds": `+tt.retention+`, `, 1)
+		if _, err := LoadConfig(writeConfig(t, data), ""); (err != nil) != tt.wantErr {
+			t.Errorf("task_retention_seconds %s: error %v, want error %v", tt.retention, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadConfigNormalizesExtensions(t *testing.T) {
+	data := strings.Replace(minimalConfig, `[".pdf", "JPG"]`, `[".PDF", " jpg "]`, 1)
+	cfg, err := LoadConfig(writeConfig(t, data), "")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if got := strings.Join(cfg.AllowedExtensions, ","); got != ".pdf,.jpg" {
+		t.Fatalf("allowed_extensions %q, want .pdf,.jpg", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*Config)
+		wantErr   string
+	}{
+		{"defaults", func(*Config) {}, ""},
+		{"no port", func(c *Config) { c.Port = "" }, "invalid port"},
+		{"no concurrency", func(c *Config) { c.MaxConcurrentTasks = 0 }, "invalid max_concurrent_tasks"},
+		{"no files", func(c *Config) { c.MaxFilesPerTask = 0 }, "invalid max_files_per_task"},
+		{"no extensions", func(c *Config) { c.AllowedExtensions = nil }, "invalid allowed_extensions"},
+		{"empty extension", func(c *Config) { c.AllowedExtensions = []string{"."} }, "invalid allowed_extensions entry"},
+		{"min over max", func(c *Config) { c.MinExpectedFiles, c.MaxExpectedFiles = 5, 2 }, "invalid min_expected_files"},
+		{"negative byte budget", func(c *Config) { c.TaskByteBudget = -1 }, "invalid task budget"},
+		{"negative parallel downloads", func(c *Config) { c.ParallelDownloads = -1 }, "invalid parallel_downloads"},
+		{"negative route slo", func(c *Config) { c.SLORoutesMs = map[string]int{"/tasks": -1} }, "invalid slo_routes_ms"},
+		{"negative max file size", func(c *Config) { c.MaxFileSize = -1 }, "invalid max_file_size"},
+		{"extension without dot", func(c *Config) { c.MaxFileSizeByExtension = map[string]int64{"pdf": 10} }, "invalid max_file_size_by_extension"},
+		{"bad transform", func(c *Config) { c.TextTransforms = []TextTransform{{Pattern: "("}} }, "invalid text_transforms[0]"},
+		{"bad proxy scheme", func(c *Config) { c.DownloadProxy = "ftp://proxy:21" }, "unsupported scheme"},
+		{"socks proxy", func(c *Config) { c.DownloadProxy = "socks5://proxy:1080" }, ""},
+		{"short retention", func(c *Config) { c.TaskRetentionSeconds = 1 }, "invalid task_retention_seconds"},
+		{"nats without address", func(c *Config) { c.EventPublisher = PublisherNATS }, "requires nats_addr"},
+		{"unknown publisher", func(c *Config) { c.EventPublisher = "kafka" }, "invalid event_publisher"},
+		{"unknown compression", func(c *Config) { c.CompressionMethod = "lzma" }, "invalid compression_method"},
+		{"unknown naming", func(c *Config) { c.JSONFieldNaming = "kebab" }, "invalid json_field_naming"},
+		{"unknown log level", func(c *Config) { c.LogLevel = "trace" }, "invalid log_level"},
+		{"unknown log format", func(c *Config) { c.LogFormat = "xml" }, "invalid log_format"},
+		{"bad name template", func(c *Config) { c.ArchiveNameTemplate = "{{.TaskID" }, "invalid archive_name_template"},
+		{"missing page template", func(c *Config) { c.DownloadPageTemplate = "missing.html" }, "invalid download_page_template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(writeConfig(t, minimalConfig), "")
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+			tt.configure(cfg)
+			err = cfg.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Validate: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Validate = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigRunsValidate(t *testing.T) {
+	data := strings.Replace(minimalConfig, `"port": "8080"`, `"port": "8080", "log_level": "Trace"`, 1)
+	_, err := LoadConfig(writeConfig(t, data), "")
+	if err == nil || !strings.Contains(err.Error(), `invalid log_level "trace"`) {
+		t.Fatalf("LoadConfig = %v, want an invalid log_level error", err)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(*Config) bool
+		wantErr bool
+	}{
+		{"none", nil, func(c *Config) bool { return c.Port == "8080" && c.MaxConcurrentTasks == 3 }, false},
+		{"port", map[string]string{EnvPort: " 9090 "}, func(c *Config) bool { return c.Port == "9090" }, false},
+		{"numbers", map[string]string{EnvMaxConcurrentTasks: "7", EnvMaxFilesPerTask: "11"}, func(c *Config) bool {
+			return c.MaxConcurrentTasks == 7 && c.MaxFilesPerTask == 11
+		}, false},
+		{"extensions", map[string]string{EnvAllowedExtensions: ".zip, .png,,"}, func(c *Config) bool {
+			return strings.Join(c.AllowedExtensions, " ") == ".zip .png"
+		}, false},
+		{"not a number", map[string]string{EnvMaxFilesPerTask: "many"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Port: "8080", MaxConcurrentTasks: 3, MaxFilesPerTask: 3}
+			err := cfg.applyEnv(func(name string) (string, bool) {
+				value, ok := tt.env[name]
+				return value, ok
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyEnv error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.check != nil && !tt.check(cfg) {
+				t.Fatalf("applyEnv left %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFromEnvironment(t *testing.T) {
+	t.Setenv(EnvPort, "9090")
+	t.Setenv(EnvMaxConcurrentTasks, "2")
+	t.Setenv(EnvMaxFilesPerTask, "4")
+	t.Setenv(EnvAllowedExtensions, "pdf,.JPG")
+	missing := filepath.Join(t.TempDir(), "config.json")
+	cfg, err := LoadConfig(missing, "")
+	if err != nil {
+		t.Fatalf("LoadConfig without a file: %v", err)
+	}
+	if cfg.Port != "9090" || cfg.MaxConcurrentTasks != 2 || cfg.MaxFilesPerTask != 4 || strings.Join(cfg.AllowedExtensions, ",") != ".pdf,.jpg" {
+		t.Fatalf("config from the environment %+v", cfg)
+	}
+
+	// The environment wins over the file.
+	cfg, err = LoadConfig(writeConfig(t, minimalConfig), "")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Port != "9090" {
+		t.Fatalf("port %q, want the environment's 9090", cfg.Port)
+	}
+	if _, err := LoadConfig(missing, "prod"); err == nil {
+		t.Fatal("LoadConfig of a missing profile file succeeded")
+	}
+}
+
+func TestLoadConfigCompressionLevel(t *testing.T) {
+	tests := []struct {
+		level   string
+		wantErr bool
+	}{
+		{"0", false},
+		{"9", false},
+		{"-1", true},
+		{"10", true},
+	}
+	for _, tt := range tests {
+		data := strings.Replace(minimalConfig, `{`, `{"compression_level": `+tt.level+`, `, 1)
+		if _, err := LoadConfig(writeConfig(t, data), ""); (err != nil) != tt.wantErr {
+			t.Errorf("compression_level %s: error %v, want error %v", tt.level, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadConfigAllowedDomains(t *testing.T) {
+	data := strings.Replace(minimalConfig, `{`, `{"allowed_domains": [" Example.COM. ", ".cdn.example.net"], `, 1)
+	cfg, err := LoadConfig(writeConfig(t, data), "")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if got := strings.Join(cfg.AllowedDomains, ","); got != "example.com,.cdn.example.net" {
+		t.Fatalf("allowed_domains %q", got)
+	}
+	data = strings.Replace(minimalConfig, `{`, `{"allowed_domains": ["."], `, 1)
+	if _, err := LoadConfig(writeConfig(t, data), ""); err == nil || !strings.Contains(err.Error(), "allowed_domains") {
+		t.Fatalf("LoadConfig with an empty domain: %v", err)
+	}
+}
+
+func TestLoadConfigLogging(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, minimalConfig), "")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.LogLevel != LogLevelInfo || cfg.LogFormat != LogFormatJSON {
+		t.Fatalf("logging defaults to level %q, format %q", cfg.LogLevel, cfg.LogFormat)
+	}
+	for _, setting := range []string{`"log_level": "verbose"`, `"log_format": "xml"`} {
+		data := strings.Replace(minimalConfig, `{`, `{`+setting+`, `, 1)
+		if _, err := LoadConfig(writeConfig(t, data), ""); err == nil {
+			t.Errorf("LoadConfig with %s succeeded", setting)
+		}
+	}
+}
+
+func TestLoadConfigRetries(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, minimalConfig), "")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.DownloadRetries != 0 || cfg.RetryBackoffMs != DefaultRetryBackoff {
+		t.Fatalf("%d retries after %dms by default", cfg.DownloadRetries, cfg.RetryBackoffMs)
+	}
+	data := strings.Replace(minimalConfig, `{`, `{"download_retries": -1, `, 1)
+	if _, err := LoadConfig(writeConfig(t, data), ""); err == nil || !strings.Contains(err.Error(), "download_retries") {
+		t.Fatalf("LoadConfig with negative retries: %v", err)
+	}
+}
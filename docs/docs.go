@@ -15,11 +15,55 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/admin/dead-letters": {
+            "get": {
+                "description": "returns the most recent records of tasks that ended in the error state, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List dead-letter records",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of records (default 50)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/queue.DeadLetter"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid limit",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "failed to read dead letters",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/archives/{filename}": {
             "get": {
-                "description": "downloads the zip file for a given task ID",
+                "description": "downloads the zip or tar.gz file for a given task ID",
                 "produces": [
-                    "application/zip"
+                    "application/zip",
+                    "application/gzip"
                 ],
                 "tags": [
                     "archives"
@@ -28,7 +72,7 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Archive filename (e.g., taskID.zip)",
+                        "description": "Archive filename as returned in result_url (e.g., taskID.zip)",
                         "name": "filename",
                         "in": "path",
                         "required": true
@@ -39,6 +83,29 @@ const docTemplate = `{
                         "description": "Archive file",
                         "schema": {
                             "type": "file"
+                        },
+                        "headers": {
+                            "Digest": {
+                                "type": "string",
+                                "description": "sha-256 digest of the archive, when checksums are enabled"
+                            },
+                            "ETag": {
+                                "type": "string",
+                                "description": "quoted hex SHA-256 of the archive, when checksums are enabled"
+                            },
+                            "Signature": {
+                                "type": "string",
+                                "description": "base64 Ed25519ph signature of the archive, when signing is enabled"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "archive matches If-None-Match"
+                    },
+                    "401": {
+                        "description": "missing client identity",
+                        "schema": {
+                            "type": "string"
                         }
                     },
                     "404": {
@@ -46,13 +113,19 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
+                    },
+                    "410": {
+                        "description": "archive has expired",
+                        "schema": {
+                            "type": "string"
+                        }
                     }
                 }
             }
         },
-        "/tasks": {
+        "/batch": {
             "post": {
-                "description": "creates a new task for archiving files",
+                "description": "executes create_task, add_file, process and get_task operations in order and returns one result per operation; an operation referring to a failed create_task is skipped with status 424",
                 "consumes": [
                     "application/json"
                 ],
@@ -62,16 +135,33 @@ const docTemplate = `{
                 "tags": [
                     "tasks"
                 ],
-                "summary": "Create a new task",
+                "summary": "Run a batch of operations",
+                "parameters": [
+                    {
+                        "description": "Operations",
+                        "name": "operations",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.BatchOperation"
+                            }
+                        }
+                    }
+                ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/task.Task"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/handlers.BatchResult"
+                            }
                         }
                     },
-                    "503": {
-                        "description": "server is busy, please try again later",
+                    "400": {
+                        "description": "invalid request body",
                         "schema": {
                             "type": "string"
                         }
@@ -79,37 +169,121 @@ const docTemplate = `{
                 }
             }
         },
-        "/tasks/{id}": {
+        "/groups/{id}": {
             "get": {
-                "description": "get the status of a task by ID",
-                "consumes": [
+                "description": "reports the status of every task in a group and whether all of them have finished",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "groups"
+                ],
+                "summary": "Get group status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Group ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.GroupStatus"
+                        }
+                    },
+                    "404": {
+                        "description": "group not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/groups/{id}/archive": {
+            "get": {
+                "description": "streams one zip containing the entries of every done task in the group, each under a folder named after its task ID; entries of tar.gz archives are compressed anew",
                 "produces": [
-                    "application/json"
+                    "application/zip"
                 ],
                 "tags": [
-                    "tasks"
+                    "groups"
                 ],
-                "summary": "Get task status",
+                "summary": "Download a group archive",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Task ID",
+                        "description": "Group ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     }
                 ],
+                "responses": {
+                    "200": {
+                        "description": "Combined archive",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "group not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "no completed tasks in group",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "liveness and readiness probe; reports uptime, active tasks and task slot usage without taking locks",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Health check",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/task.Task"
+                            "$ref": "#/definitions/handlers.Health"
+                        }
+                    }
+                }
+            }
+        },
+        "/signing-key": {
+            "get": {
+                "description": "returns the PEM-encoded ed25519 public key; its ID matches signing_key_id in task status",
+                "produces": [
+                    "application/x-pem-file"
+                ],
+                "tags": [
+                    "archives"
+                ],
+                "summary": "Get the archive signing key",
+                "responses": {
+                    "200": {
+                        "description": "PEM public key",
+                        "schema": {
+                            "type": "string"
                         }
                     },
                     "404": {
-                        "description": "task not found",
+                        "description": "signing is disabled",
                         "schema": {
                             "type": "string"
                         }
@@ -117,9 +291,70 @@ const docTemplate = `{
                 }
             }
         },
-        "/tasks/{id}/files": {
+        "/tasks": {
+            "get": {
+                "description": "returns the tasks of the requesting client ordered by ID, optionally filtered by status and paged with limit and offset; X-Total-Count holds the number of matching tasks",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "List tasks",
+                "parameters": [
+                    {
+                        "enum": [
+                            "created",
+                            "processing",
+                            "done",
+                            "partial",
+                            "error",
+                            "canceled"
+                        ],
+                        "type": "string",
+                        "description": "Only tasks with this status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of tasks (default all)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of matching tasks to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/task.Task"
+                            }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "int",
+                                "description": "number of matching tasks before paging"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid status, limit or offset",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "adds a file URL to a task for archiving",
+                "description": "creates a new task for archiving files, optionally as a member of a group",
                 "consumes": [
                     "application/json"
                 ],
@@ -129,7 +364,59 @@ const docTemplate = `{
                 "tags": [
                     "tasks"
                 ],
-                "summary": "Add a file to a task",
+                "summary": "Create a new task",
+                "parameters": [
+                    {
+                        "description": "Task options",
+                        "name": "task",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CreateTaskRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/task.Task"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request body",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "missing client identity",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "server is busy or shutting down",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}": {
+            "get": {
+                "description": "get the status of a task by ID, as JSON or as protobuf (taskpb.Task) when Accept is application/x-protobuf",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/x-protobuf"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Get task status",
                 "parameters": [
                     {
                         "type": "string",
@@ -137,73 +424,1295 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/task.Task"
+                        }
                     },
-                    {
-                        "description": "File URL",
-                        "name": "url",
-                        "in": "body",
-                        "required": true,
+                    "404": {
+                        "description": "task not found",
                         "schema": {
                             "type": "string"
                         }
                     }
+                }
+            },
+            "delete": {
+                "description": "forgets a task that is not being processed and deletes its archive, unless a coalesced task still shares it; a task still collecting files is canceled first",
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Delete a task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
                 "responses": {
-                    "202": {
-                        "description": "Accepted"
+                    "204": {
+                        "description": "No Content"
                     },
-                    "400": {
-                        "description": "invalid request body",
+                    "404": {
+                        "description": "task not found",
                         "schema": {
                             "type": "string"
                         }
                     },
-                    "404": {
-                        "description": "task not found",
+                    "409": {
+                        "description": "task is being processed",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "task.Status": {
-            "type": "string",
-            "enum": [
-                "created",
-                "processing",
-                "done",
-                "error"
-            ],
-            "x-enum-varnames": [
-                "StatusCreated",
-                "StatusProcessing",
-                "StatusDone",
-                "StatusError"
-            ]
         },
-        "task.Task": {
+        "/tasks/{id}/archive": {
+            "get": {
+                "description": "serves the zip or tar.gz archive of a finished task by task ID",
+                "produces": [
+                    "application/zip",
+                    "application/gzip"
+                ],
+                "tags": [
+                    "archives"
+                ],
+                "summary": "Download a task's archive",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Archive",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "task not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "task is not done",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "archive has expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/archive.sig": {
+            "get": {
+                "description": "returns the raw 64-byte Ed25519ph (SHA-512 prehash) signature of the task's archive",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "archives"
+                ],
+                "summary": "Download an archive signature",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Signature",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "signature not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/contents": {
+            "get": {
+                "description": "lists the files in a finished task's archive, as a flat list or, with tree=true, as a nested directory tree built from the entry names",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "archives"
+                ],
+                "summary": "List archive contents",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return a directory tree",
+                        "name": "tree",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "with tree=true",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ContentsNode"
+                        }
+                    },
+                    "404": {
+                        "description": "task not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "task is not done",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "archive has expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/download": {
+            "get": {
+                "description": "renders an HTML page listing the archive's files, sizes and checksums with a download link, or serves the zip itself; format=html|zip takes precedence over Accept",
+                "produces": [
+                    "text/html",
+                    "application/zip"
+                ],
+                "tags": [
+                    "archives"
+                ],
+                "summary": "Download page of an archive",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "html or zip",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Download page or archive",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "task not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "task is not done",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "archive has expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/events": {
+            "get": {
+                "description": "streams status and per-file progress events for a task until it finishes",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Stream task events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/task.Event"
+                        }
+                    },
+                    "404": {
+                        "description": "task not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "too many subscribers",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/files": {
+            "post": {
+                "description": "adds a file URL to a task for archiving",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Add a file to a task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "File URL, optional entry filename and optional metadata object",
+                        "name": "url",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "headers": {
+                            "Warning": {
+                                "type": "string",
+                                "description": "set when a presigned URL expires soon"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid request body, filename or metadata, invalid, blocked, expired or too long URL, host outside allowed_domains, or too many distinct hosts",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "task not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "task no longer accepts files or already has all its files",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "server is shutting down, or busy so the task with all its files could not start",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "seconds after which to start the task again"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/files/{name}": {
+            "get": {
+                "description": "extracts a single named entry from the task's archive; supports Range requests",
+                "produces": [
+                    "application/octet-stream"
+                ],
+                "tags": [
+                    "archives"
+                ],
+                "summary": "Download one file from an archive",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Entry name inside the archive",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Entry contents",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Requested range of the entry",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "entry not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "task is not done or its archive is not a zip",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "410": {
+                        "description": "archive has expired",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/process": {
+            "post": {
+                "description": "starts archiving the files added so far, for tasks with fewer files than max_files_per_task or their expected_files",
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Start processing a task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
+                    "404": {
+                        "description": "task not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "task has no files or is already processing or finished",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "server is busy or shutting down",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "seconds after which to try again"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/progress": {
+            "get": {
+                "description": "returns file and byte counters of a task, updated live while its files download",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Get task progress",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/task.Progress"
+                        }
+                    },
+                    "404": {
+                        "description": "task not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/stream": {
+            "post": {
+                "description": "archives the files added so far and streams the archive in the response as it is built, without storing it; the task ends up done without a result_url, or failed if the stream was cut short",
+                "produces": [
+                    "application/zip",
+                    "application/gzip"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Stream a task's archive",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Archive",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "task not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "task has no files or is already processing or finished",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "server is busy or shutting down",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "headers": {
+                            "Retry-After": {
+                                "type": "integer",
+                                "description": "seconds after which to try again"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/tasks/{id}/uploads": {
+            "post": {
+                "description": "creates a tus upload whose contents become an entry of the task's archive",
+                "tags": [
+                    "uploads"
+                ],
+                "summary": "Create a resumable upload",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "tus protocol version (1.0.0)",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Total upload size in bytes",
+                        "name": "Upload-Length",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "tus metadata, e.g. filename \u003cbase64\u003e",
+                        "name": "Upload-Metadata",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the created upload"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid Upload-Length",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "task not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "task no longer accepts files, is full or has too many uploads in progress",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "412": {
+                        "description": "unsupported tus version",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "upload exceeds max size",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "options": {
+                "description": "returns the supported tus version, extensions and maximum upload size",
+                "tags": [
+                    "uploads"
+                ],
+                "summary": "Discover tus upload support",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/uploads/{uid}": {
+            "head": {
+                "description": "returns the current Upload-Offset so an interrupted upload can be resumed",
+                "tags": [
+                    "uploads"
+                ],
+                "summary": "Get upload offset",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "uid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "tus protocol version (1.0.0)",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "headers": {
+                            "Upload-Length": {
+                                "type": "int",
+                                "description": "Total upload size"
+                            },
+                            "Upload-Offset": {
+                                "type": "int",
+                                "description": "Bytes received so far"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "upload not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "appends the request body at Upload-Offset; the file is added to the task once complete",
+                "consumes": [
+                    "application/offset+octet-stream"
+                ],
+                "tags": [
+                    "uploads"
+                ],
+                "summary": "Upload a chunk",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Upload ID",
+                        "name": "uid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "tus protocol version (1.0.0)",
+                        "name": "Tus-Resumable",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset the chunk starts at",
+                        "name": "Upload-Offset",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "headers": {
+                            "Upload-Offset": {
+                                "type": "int",
+                                "description": "Bytes received so far"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "upload not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "offset mismatch or upload in progress",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "415": {
+                        "description": "unsupported content type",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handlers.ArchiveEntry": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.BatchOperation": {
+            "type": "object",
+            "properties": {
+                "op": {
+                    "description": "Op is \"create_task\", \"add_file\", \"process\" or \"get_task\".",
+                    "type": "string"
+                },
+                "params": {
+                    "description": "Params is the request body of the operation: a CreateTaskRequest for\ncreate_task and {\"url\": ...} for add_file.",
+                    "type": "object"
+                },
+                "ref": {
+                    "type": "integer"
+                },
+                "task_id": {
+                    "description": "TaskID names the task of add_file, process and get_task. Alternatively\nRef is the index of an earlier create_task operation whose task is\nused.",
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.BatchResult": {
+            "type": "object",
+            "properties": {
+                "body": {
+                    "type": "object"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.ContentsNode": {
+            "type": "object",
+            "properties": {
+                "children": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.ContentsNode"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "type": {
+                    "description": "\"dir\" or \"file\"",
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.CreateTaskRequest": {
+            "type": "object",
+            "properties": {
+                "archive_format": {
+                    "description": "ArchiveFormat is \"zip\" (the default) or \"targz\".",
+                    "type": "string",
+                    "enum": [
+                        "zip",
+                        "targz"
+                    ]
+                },
+                "byte_budget": {
+                    "description": "ByteBudget and TimeBudgetSeconds override the configured download\nbudget of the task.",
+                    "type": "integer"
+                },
+                "callback_url": {
+                    "description": "CallbackURL receives a POST with the task's final status once it is\ndone or failed.",
+                    "type": "string"
+                },
+                "exclude": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "expected_files": {
+                    "description": "ExpectedFiles is the number of files the client will add; processing\nstarts once the task has that many instead of max_files_per_task.",
+                    "type": "integer"
+                },
+                "group_id": {
+                    "type": "string"
+                },
+                "include": {
+                    "description": "Include and Exclude are glob patterns for entry names; patterns\nwithout a slash match the last element of a name. Exclude wins.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "modified_since": {
+                    "description": "ModifiedSince (RFC 3339) leaves files last modified before it out of\nthe archive.",
+                    "type": "string"
+                },
+                "progress_callback_url": {
+                    "description": "ProgressCallbackURL receives batched POSTs with the result of each\nfile while the task is processed.",
+                    "type": "string"
+                },
+                "time_budget_seconds": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.GroupStatus": {
+            "type": "object",
+            "properties": {
+                "complete": {
+                    "type": "boolean"
+                },
+                "completed": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "tasks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.GroupTaskStatus"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.GroupTaskStatus": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/task.Status"
+                }
+            }
+        },
+        "handlers.Health": {
+            "type": "object",
+            "properties": {
+                "active_tasks": {
+                    "description": "ActiveTasks counts the tasks being processed or sharing the run of\nan identical task.",
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "task_slots": {
+                    "type": "integer"
+                },
+                "task_slots_used": {
+                    "description": "TaskSlotsUsed of TaskSlots max_concurrent_tasks slots are taken.",
+                    "type": "integer"
+                },
+                "uptime_seconds": {
+                    "type": "number"
+                }
+            }
+        },
+        "queue.DeadLetter": {
+            "type": "object",
+            "properties": {
+                "error_codes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "error_details": {
+                    "type": "string"
+                },
+                "failed_at": {
+                    "type": "string"
+                },
+                "task_id": {
+                    "type": "string"
+                },
+                "urls": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "task.ErrorCode": {
+            "type": "string",
+            "enum": [
+                "EXTENSION_NOT_ALLOWED",
+                "DOWNLOAD_FAILED",
+                "HTTP_STATUS",
+                "DNS_FAILURE",
+                "CONN_REFUSED",
+                "TLS_ERROR",
+                "TIMEOUT",
+                "SOURCE_DISABLED",
+                "TOO_LARGE",
+                "WRITE_FAILED",
+                "ARCHIVE_FAILED",
+                "BUDGET_EXCEEDED",
+                "URL_EXPIRED",
+                "HEADERS_TOO_LARGE",
+                "INTERRUPTED",
+                "CANCELED",
+                "INVALID_ARCHIVE",
+                "INVALID_PATH",
+                "NESTED_ARCHIVE_TOO_LARGE",
+                "NOT_MODIFIED",
+                "EXCLUDED",
+                "INVALID_URL",
+                "BLOCKED_TARGET",
+                "DOMAIN_NOT_ALLOWED"
+            ],
+            "x-enum-varnames": [
+                "CodeExtensionNotAllowed",
+                "CodeDownloadFailed",
+                "CodeHTTPStatus",
+                "CodeDNSFailure",
+                "CodeConnRefused",
+                "CodeTLSError",
+                "CodeTimeout",
+                "CodeSourceDisabled",
+                "CodeTooLarge",
+                "CodeWriteFailed",
+                "CodeArchiveFailed",
+                "CodeBudgetExceeded",
+                "CodeURLExpired",
+                "CodeHeadersTooLarge",
+                "CodeInterrupted",
+                "CodeCanceled",
+                "CodeInvalidArchive",
+                "CodeInvalidPath",
+                "CodeNestedTooLarge",
+                "CodeNotModified",
+                "CodeExcluded",
+                "CodeInvalidURL",
+                "CodeBlockedTarget",
+                "CodeDomainNotAllowed"
+            ]
+        },
+        "task.Event": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/task.ErrorCode"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "error_details": {
+                    "type": "string"
+                },
+                "file": {
+                    "type": "string"
+                },
+                "name": {
+                    "description": "entry name of an archived file",
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/task.Status"
+                },
+                "type": {
+                    "description": "\"status\" or \"file\"",
+                    "type": "string"
+                }
+            }
+        },
+        "task.FileError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/task.ErrorCode"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "task.FileInfo": {
+            "type": "object",
+            "properties": {
+                "bundle": {
+                    "description": "Bundle names the entry, BundleEntryName, that holds the file when\nsmall files are bundled.",
+                    "type": "string"
+                },
+                "duplicate_of": {
+                    "description": "DuplicateOf names the entry holding identical content when the file\nwas skipped by deduplication.",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "skipped": {
+                    "description": "Skipped is set when the file was deliberately left out of the\narchive, e.g. NOT_MODIFIED for files older than modified_since.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/task.ErrorCode"
+                        }
+                    ]
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "task.FileState": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "downloading",
+                "done",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "FilePending",
+                "FileDownloading",
+                "FileDone",
+                "FileFailed"
+            ]
+        },
+        "task.FileStatus": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/task.FileState"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "task.Progress": {
+            "type": "object",
+            "properties": {
+                "bytes_downloaded": {
+                    "type": "integer"
+                },
+                "files_archived": {
+                    "type": "integer"
+                },
+                "files_downloaded": {
+                    "type": "integer"
+                },
+                "files_failed": {
+                    "type": "integer"
+                },
+                "files_total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "task.Status": {
+            "type": "string",
+            "enum": [
+                "created",
+                "processing",
+                "done",
+                "partial",
+                "error",
+                "canceled"
+            ],
+            "x-enum-varnames": [
+                "StatusCreated",
+                "StatusProcessing",
+                "StatusDone",
+                "StatusPartial",
+                "StatusError",
+                "StatusCanceled"
+            ]
+        },
+        "task.Task": {
             "type": "object",
             "properties": {
+                "archive_expired": {
+                    "description": "ArchiveExpired is set once the archive has been deleted; the task\nrecord itself is kept until task_retention_seconds have passed.",
+                    "type": "boolean"
+                },
+                "archive_format": {
+                    "description": "ArchiveFormat is FormatZip or FormatTarGz; empty means zip.",
+                    "type": "string"
+                },
+                "byte_budget": {
+                    "description": "ByteBudget and TimeBudgetSeconds override the configured aggregate\ndownload budget of the task when positive.",
+                    "type": "integer"
+                },
+                "callback_url": {
+                    "description": "CallbackURL is notified with a POST when the task is done or failed.",
+                    "type": "string"
+                },
+                "coalesced_with": {
+                    "description": "CoalescedWith is the ID of an identical task whose processing run\nand archive this task shares.",
+                    "type": "string"
+                },
                 "error_details": {
                     "type": "string"
                 },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/task.FileError"
+                    }
+                },
+                "exclude": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "expected_files": {
+                    "description": "ExpectedFiles, when positive, is the number of files after which\nthe task starts processing, in place of the configured maximum.",
+                    "type": "integer"
+                },
+                "expires_at": {
+                    "description": "ExpiresAt is when the archive becomes due for cleanup.",
+                    "type": "string"
+                },
+                "file_names": {
+                    "description": "FileNames holds the entry names clients chose for file URLs, in\nplace of the names derived from the URL or response.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "file_statuses": {
+                    "description": "FileStatuses tracks each file URL through processing, so polling\nthe status shows how far the archive has come.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/task.FileStatus"
+                    }
+                },
                 "file_urls": {
                     "type": "array",
                     "items": {
                         "type": "string"
                     }
                 },
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/task.FileInfo"
+                    }
+                },
+                "finished_at": {
+                    "description": "FinishedAt is when the task became done or failed.",
+                    "type": "string"
+                },
+                "group_id": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "string"
                 },
+                "include": {
+                    "description": "Include and Exclude are glob patterns applied to entry names before\nthey are written; exclude wins over include.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "metadata": {
+                    "description": "Metadata holds the metadata clients attached to file URLs, written\nto the archive's manifest.json.",
+                    "type": "object"
+                },
+                "modified_since": {
+                    "description": "ModifiedSince, when set, limits the archive to files last modified\nat or after it; older files are listed with skipped NOT_MODIFIED.",
+                    "type": "string"
+                },
+                "progress_callback_url": {
+                    "description": "ProgressCallbackURL is sent the result of every file, in batches.",
+                    "type": "string"
+                },
+                "request_id": {
+                    "description": "RequestID is the X-Request-ID of the request that created the task.",
+                    "type": "string"
+                },
+                "result_checksum": {
+                    "type": "string"
+                },
                 "result_url": {
                     "type": "string"
                 },
+                "signing_key_id": {
+                    "type": "string"
+                },
                 "status": {
                     "$ref": "#/definitions/task.Status"
+                },
+                "streamed": {
+                    "description": "Streamed is set when the archive was sent in the response of a\nstream request and never stored.",
+                    "type": "boolean"
+                },
+                "time_budget_seconds": {
+                    "type": "integer"
                 }
             }
         }
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAddFileAfterStart(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	id := createTask(t, tm, `{}`, nil)
+	vars := map[string]string{"id": id}
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+
+	if w := serve(tm.AddFileHandler, http.MethodPost, `{"url": "`+files.URL+`/b.pdf"}`, vars, nil); w.Code != http.StatusConflict {
+		t.Fatalf("add file: status %d, want %d", w.Code, http.StatusConflict)
+	}
+	w := serve(tm.CreateUploadHandler, http.MethodPost, "", vars, map[string]string{"Tus-Resumable": tusVersion, "Upload-Length": "1"})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("create upload: status %d, want %d", w.Code, http.StatusConflict)
+	}
+	if status := getStatus(t, tm, id); status.Status != "done" {
+		t.Fatalf("task is %s after the rejected adds", status.Status)
+	}
+}
+
+func TestAddFileFilename(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 2 })
+	id := createTask(t, tm, `{}`, nil)
+	vars := map[string]string{"id": id}
+
+	tests := []struct {
+		name     string
+		filename string
+		want     int
+	}{
+		{"dot", ".", http.StatusBadRequest},
+		{"path", `../reports\Q3 report.pdf`, http.StatusAccepted},
+		{"plain", "contract.pdf", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		body := `{"url": "` + files.URL + `/` + tt.name + `.pdf", "filename": ` + strconv.Quote(tt.filename) + `}`
+		if w := serve(tm.AddFileHandler, http.MethodPost, body, vars, nil); w.Code != tt.want {
+			t.Fatalf("add file named %q: status %d, want %d: %s", tt.filename, w.Code, tt.want, w.Body)
+		}
+	}
+	waitDone(t, tm, id)
+
+	want := map[string]string{
+		"Q3_report.pdf": "%PDF-1.4 /path.pdf",
+		"contract.pdf":  "%PDF-1.4 /plain.pdf",
+	}
+	if got := readArchive(t, id+".zip"); !maps.Equal(got, want) {
+		t.Fatalf("archive %q, want %q", got, want)
+	}
+}
+
+func TestAddFileCheckedURL(t *testing.T) {
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.BlockPrivateIPs = true })
+	id := createTask(t, tm, `{}`, nil)
+	for _, url := range []string{"ftp://example.com/a.pdf", "http://169.254.169.254/latest/meta-data"} {
+		if w := serve(tm.AddFileHandler, http.MethodPost, `{"url": "`+url+`"}`, map[string]string{"id": id}, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("add %s: status %d, want %d", url, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestAddFileGuards(t *testing.T) {
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxURLLength = 64 })
+	id := createTask(t, tm, `{}`, nil)
+	tests := []struct {
+		name   string
+		taskID string
+		body   string
+		want   int
+	}{
+		{"valid", id, `{"url": "https://example.com/a.pdf"}`, http.StatusAccepted},
+		{"too long", id, `{"url": "https://example.com/` + strings.Repeat("a", 64) + `.pdf"}`, http.StatusBadRequest},
+		{"malformed body", id, `{"url": `, http.StatusBadRequest},
+		{"unknown task", "missing", `{"url": "https://example.com/a.pdf"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tm.AddFileHandler, http.MethodPost, tt.body, map[string]string{"id": tt.taskID}, nil)
+			if w.Code != tt.want {
+				t.Fatalf("status %d (%s), want %d", w.Code, strings.TrimSpace(w.Body.String()), tt.want)
+			}
+		})
+	}
+}
+
+func TestAddFileCount(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 2 })
+	id := createTask(t, tm, `{}`, nil)
+	vars := map[string]string{"id": id}
+	// With every task slot taken, the full task stays created.
+	for range cap(tm.concurrentTaskSema) {
+		tm.concurrentTaskSema <- struct{}{}
+	}
+	want := []int{http.StatusAccepted, http.StatusServiceUnavailable, http.StatusConflict}
+	for i, status := range want {
+		body := `{"url": "` + files.URL + `/` + string(rune('a'+i)) + `.pdf"}`
+		if w := serve(tm.AddFileHandler, http.MethodPost, body, vars, nil); w.Code != status {
+			t.Fatalf("file %d: status %d (%s), want %d", i+1, w.Code, strings.TrimSpace(w.Body.String()), status)
+		}
+	}
+	if got := tm.Tasks[id].FileCount(); got != 2 {
+		t.Fatalf("task has %d files, want 2", got)
+	}
+	for range cap(tm.concurrentTaskSema) {
+		<-tm.concurrentTaskSema
+	}
+}
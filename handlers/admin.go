@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"2025-08-02/queue"
+	"net/http"
+	"strconv"
+)
+
+// defaultDeadLetterLimit is the number of records DeadLettersHandler returns
+// when no limit is given.
+const defaultDeadLetterLimit = 50
+
+// DeadLettersHandler lists recently failed tasks
+// @Summary      List dead-letter records
+// @Description  returns the most recent records of tasks that ended in the error state, newest first
+// @Tags         admin
+// @Produce      json
+// @Param        limit  query     int  false  "Maximum number of records (default 50)"
+// @Success      200 {array}  queue.DeadLetter
+// @Failure      400 {string} string "invalid limit"
+// @Failure      500 {string} string "failed to read dead letters"
+// @Router       /admin/dead-letters [get]
+func (tm *TaskManager) DeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDeadLetterLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	records, err := tm.deadLetters.RecentDeadLetters(limit)
+	if err != nil {
+		http.Error(w, "failed to read dead letters", http.StatusInternalServerError)
+		return
+	}
+	if records == nil {
+		records = []queue.DeadLetter{}
+	}
+	tm.writeJSON(w, r, http.StatusOK, records)
+}
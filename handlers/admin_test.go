@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/queue"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeadLetters(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.ArchiveDir = "archives"
+		cfg.MaxFilesPerTask = 1
+	})
+	tm.deadLetters = queue.NewFileDeadLetterSink(filepath.Join(t.TempDir(), "dead-letters.jsonl"))
+	// A file in the way of the archive directory fails the task.
+	if err := os.WriteFile("archives", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	if status := waitFinished(t, tm, id); status.Status != "error" {
+		t.Fatalf("task is %s, want error", status.Status)
+	}
+	next := createTask(t, tm, `{}`, nil)
+	os.Remove("archives")
+	addFile(t, tm, next, files.URL+"/b.pdf")
+	waitDone(t, tm, next)
+	waitIdle(t, tm)
+
+	w := httptest.NewRecorder()
+	tm.DeadLettersHandler(w, httptest.NewRequest(http.MethodGet, "/admin/dead-letters", nil))
+	var records []queue.DeadLetter
+	if err := json.Unmarshal(w.Body.Bytes(), &records); err != nil {
+		t.Fatalf("dead letters %q: %v", w.Body, err)
+	}
+	if len(records) != 1 || records[0].TaskID != id || len(records[0].URLs) != 1 || records[0].ErrorDetails == "" {
+		t.Fatalf("dead letters %+v, want only the failed task", records)
+	}
+
+	w = httptest.NewRecorder()
+	tm.DeadLettersHandler(w, httptest.NewRequest(http.MethodGet, "/admin/dead-letters?limit=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("limit=0: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestServeExpiredArchive(t *testing.T) {
+	tests := []struct {
+		name        string
+		age         time.Duration
+		deleteOnGet bool
+		wantStatus  int
+		wantKept    bool
+	}{
+		{"fresh", time.Minute, false, http.StatusOK, true},
+		{"expired", 2 * time.Hour, false, http.StatusGone, true},
+		{"expired and deleted", 2 * time.Hour, true, http.StatusGone, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := fileServer(t)
+			tm := newTestManager(t, func(cfg *config.Config) {
+				cfg.MaxFilesPerTask = 1
+				cfg.ArchiveMaxAgeSeconds = 3600
+				cfg.DeleteExpiredOnServe = tt.deleteOnGet
+			})
+			id := createTask(t, tm, `{}`, nil)
+			addFile(t, tm, id, files.URL+"/a.pdf")
+			waitDone(t, tm, id)
+			modified := time.Now().Add(-tt.age)
+			if err := os.Chtimes(id+".zip", modified, modified); err != nil {
+				t.Fatal(err)
+			}
+
+			w := serve(tm.ServeArchiveHandler, http.MethodGet, "", map[string]string{"filename": id + ".zip"}, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if _, err := os.Stat(id + ".zip"); (err == nil) != tt.wantKept {
+				t.Fatalf("archive kept: %v, want %v", err == nil, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestReadExpiredArchive(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.MaxFilesPerTask = 1
+		cfg.ArchiveMaxAgeSeconds = 3600
+	})
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+	modified := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(id+".zip", modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		headers map[string]string
+	}{
+		{"entry", tm.ServeArchiveEntryHandler, nil},
+		{"contents", tm.ArchiveContentsHandler, nil},
+		{"download page", tm.DownloadHandler, map[string]string{"Accept": "text/html"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.handler, http.MethodGet, "", map[string]string{"id": id, "name": "a.pdf"}, tt.headers)
+			if w.Code != http.StatusGone {
+				t.Fatalf("status %d, want %d", w.Code, http.StatusGone)
+			}
+		})
+	}
+}
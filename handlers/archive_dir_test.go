@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveDir(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.MaxFilesPerTask = 1
+		cfg.ArchiveDir = "archives"
+	})
+	if err := os.Mkdir("archives", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+
+	if _, err := os.Stat(filepath.Join("archives", id+".zip")); err != nil {
+		t.Fatalf("archive not written to archive_dir: %v", err)
+	}
+	if _, err := os.Stat(id + ".zip"); !os.IsNotExist(err) {
+		t.Fatalf("archive written to the working directory: %v", err)
+	}
+	w := serve(tm.ServeArchiveHandler, http.MethodGet, "", map[string]string{"filename": id + ".zip"}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("download: status %d", w.Code)
+	}
+	if got := readZip(t, w.Body.Bytes()); got["a.pdf"] != "%PDF-1.4 /a.pdf" {
+		t.Fatalf("downloaded archive %q", got)
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveNameTemplate(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.ArchiveNameTemplate = "report"
+		cfg.MaxFilesPerTask = 1
+	})
+	if err := os.WriteFile("report.zip", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The existing report.zip and the first task's archive both push the
+	// next name along.
+	for _, want := range []string{"report-1.zip", "report-2.zip"} {
+		id := createTask(t, tm, `{}`, nil)
+		addFile(t, tm, id, files.URL+"/a.pdf")
+		status := waitDone(t, tm, id)
+		if status.ResultURL != "/archives/"+want {
+			t.Fatalf("result_url %q, want /archives/%s", status.ResultURL, want)
+		}
+		w := serve(tm.ServeArchiveHandler, http.MethodGet, "", map[string]string{"filename": want}, nil)
+		if w.Code != http.StatusOK || w.Body.Len() == 0 {
+			t.Fatalf("download %s: status %d, %d bytes", want, w.Code, w.Body.Len())
+		}
+	}
+}
+
+func TestDefaultArchiveNameIsInArchiveDir(t *testing.T) {
+	tm := newTestManager(t, nil)
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"zip", `{}`, ".zip"},
+		{"targz", `{"archive_format": "targz"}`, ".tar.gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := createTask(t, tm, tt.body, nil)
+			want := filepath.Join(tm.config.ArchiveDir, id+tt.want)
+			if got := tm.Tasks[id].ArchiveName(); got != want {
+				t.Fatalf("ArchiveName = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestArchiveNameReleasedWhenStartFails(t *testing.T) {
+	tm := newTestManager(t, nil)
+	id := createTask(t, tm, `{}`, nil)
+	tk := tm.Tasks[id]
+	if err := tk.Cancel("test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.startProcessing(tk); err == nil {
+		t.Fatal("startProcessing of a canceled task succeeded")
+	}
+	if len(tm.archives) != 0 {
+		t.Fatalf("archive names still reserved: %v", tm.archives)
+	}
+	if len(tm.concurrentTaskSema) != 0 {
+		t.Fatalf("task slot still taken")
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBatchOperations bounds the number of operations in one batch.
+const maxBatchOperations = 100
+
+// BatchOperation is one step of a batch request.
+type BatchOperation struct {
+	// Op is "create_task", "add_file", "process" or "get_task".
+	Op string `json:"op"`
+	// TaskID names the task of add_file, process and get_task. Alternatively
+	// Ref is the index of an earlier create_task operation whose task is
+	// used.
+	TaskID string `json:"task_id,omitempty"`
+	Ref    *int   `json:"ref,omitempty"`
+	// Params is the request body of the operation: a CreateTaskRequest for
+	// create_task and {"url": ...} for add_file.
+	Params json.RawMessage `json:"params,omitempty" swaggertype:"object"`
+}
+
+// BatchResult is the outcome of one batch operation: the status code and
+// the JSON body, or the error text, its endpoint would have returned.
+type BatchResult struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty" swaggertype:"object"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// BatchHandler runs several operations in one request
+// @Summary      Run a batch of operations
+// @Description  executes create_task, add_file, process and get_task operations in order and returns one result per operation; an operation referring to a failed create_task is skipped with status 424
+// @Tags         tasks
+// @Accept       json
+// @Produce      json
+// @Param        operations  body      []BatchOperation  true  "Operations"
+// @Success      200 {array}  BatchResult
+// @Failure      400 {string} string "invalid request body"
+// @Router       /batch [post]
+func (tm *TaskManager) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	var ops []BatchOperation
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(ops) == 0 || len(ops) > maxBatchOperations {
+		http.Error(w, fmt.Sprintf("a batch must have 1 to %d operations", maxBatchOperations), http.StatusBadRequest)
+		return
+	}
+	tm.requestLogger(r).Debug("BatchHandler called", "operations", len(ops))
+
+	results := make([]BatchResult, len(ops))
+	taskIDs := make([]string, len(ops))
+	for i, op := range ops {
+		taskID := op.TaskID
+		if op.Ref != nil {
+			ref := *op.Ref
+			if ref < 0 || ref >= i || ops[ref].Op != "create_task" {
+				results[i] = BatchResult{Status: http.StatusBadRequest, Error: fmt.Sprintf("ref %d is not an earlier create_task operation", ref)}
+				continue
+			}
+			if taskIDs[ref] == "" {
+				results[i] = BatchResult{Status: http.StatusFailedDependency, Error: fmt.Sprintf("operation %d failed", ref)}
+				continue
+			}
+			taskID = taskIDs[ref]
+		}
+
+		results[i] = tm.runBatchOperation(r, op, taskID)
+		if op.Op == "create_task" && results[i].Status == http.StatusCreated {
+			var created struct {
+				ID string `json:"id"`
+			}
+			json.Unmarshal(results[i].Body, &created)
+			taskIDs[i] = created.ID
+		}
+	}
+	tm.writeJSON(w, r, http.StatusOK, results)
+}
+
+// runBatchOperation serves op with the handler of its endpoint. The
+// operation sees the headers of the batch request, so client identity and
+// content negotiation apply to it as well.
+func (tm *TaskManager) runBatchOperation(r *http.Request, op BatchOperation, taskID string) BatchResult {
+	var handler http.HandlerFunc
+	method, path := http.MethodPost, ""
+	switch op.Op {
+	case "create_task":
+		handler, path = tm.CreateTaskHandler, "/tasks"
+	case "add_file":
+		handler, path = tm.AddFileHandler, "/tasks/"+taskID+"/files"
+	case "process":
+		handler, path = tm.StartTaskHandler, "/tasks/"+taskID+"/process"
+	case "get_task":
+		handler, method, path = tm.GetTaskStatusHandler, http.MethodGet, "/tasks/"+taskID
+	default:
+		return BatchResult{Status: http.StatusBadRequest, Error: fmt.Sprintf("unknown operation %q", op.Op)}
+	}
+	if op.Op != "create_task" && taskID == "" {
+		return BatchResult{Status: http.StatusBadRequest, Error: "task_id or ref is required"}
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), method, path, bytes.NewReader(op.Params))
+	if err != nil {
+		return BatchResult{Status: http.StatusBadRequest, Error: err.Error()}
+	}
+	req.Header = r.Header.Clone()
+	req.Header.Del("Accept")
+	if accept := r.Header.Get("Accept"); strings.Contains(accept, "naming=") {
+		req.Header.Set("Accept", accept)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": taskID})
+
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	result := BatchResult{Status: rec.Code}
+	if body := bytes.TrimSpace(rec.Body.Bytes()); json.Valid(body) {
+		result.Body = body
+	} else {
+		result.Error = string(body)
+	}
+	return result
+}
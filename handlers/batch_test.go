@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// runBatch serves batch and returns its results.
+func runBatch(t *testing.T, tm *TaskManager, batch string) []BatchResult {
+	t.Helper()
+	w := serve(tm.BatchHandler, http.MethodPost, batch, nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("batch: status %d: %s", w.Code, w.Body)
+	}
+	var results []BatchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	return results
+}
+
+func TestBatch(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, nil)
+	results := runBatch(t, tm, `[
+		{"op": "create_task"},
+		{"op": "add_file", "ref": 0, "params": {"url": "`+files.URL+`/a.pdf"}},
+		{"op": "get_task", "ref": 0},
+		{"op": "create_task", "params": {"group_id": "../bad"}},
+		{"op": "add_file", "ref": 3, "params": {"url": "`+files.URL+`/b.pdf"}},
+		{"op": "get_task", "ref": 2},
+		{"op": "get_task", "ref": 9},
+		{"op": "get_task"},
+		{"op": "delete_task", "task_id": "x"},
+		{"op": "get_task", "task_id": "missing"}
+	]`)
+
+	want := []int{
+		http.StatusCreated, http.StatusAccepted, http.StatusOK,
+		http.StatusBadRequest, http.StatusFailedDependency,
+		http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest,
+		http.StatusNotFound,
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("operation %d: status %d (%s), want %d", i, result.Status, result.Error, want[i])
+		}
+	}
+
+	var created, got struct {
+		ID    string   `json:"id"`
+		Files []string `json:"file_urls"`
+	}
+	json.Unmarshal(results[0].Body, &created)
+	json.Unmarshal(results[2].Body, &got)
+	if created.ID == "" || got.ID != created.ID || len(got.Files) != 1 {
+		t.Fatalf("get_task returned %+v for created task %q", got, created.ID)
+	}
+	if results[9].Body != nil || !strings.Contains(results[9].Error, "task not found") {
+		t.Fatalf("plain text error returned as %+v", results[9])
+	}
+}
+
+func TestBatchInvalid(t *testing.T) {
+	tm := newTestManager(t, nil)
+	tooMany := "[" + strings.Repeat(`{"op": "create_task"},`, maxBatchOperations) + `{"op": "create_task"}]`
+	for _, body := range []string{`not json`, `[]`, tooMany} {
+		if w := serve(tm.BatchHandler, http.MethodPost, body, nil, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("batch of %.20q: status %d, want %d", body, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestBatchCreateAddProcess(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, nil)
+	results := runBatch(t, tm, `[
+		{"op": "create_task"},
+		{"op": "add_file", "ref": 0, "params": {"url": "`+files.URL+`/a.pdf"}},
+		{"op": "process", "ref": 0},
+		{"op": "process", "ref": 0},
+		{"op": "process"},
+		{"op": "process", "task_id": "missing"}
+	]`)
+
+	want := []int{http.StatusCreated, http.StatusAccepted, http.StatusAccepted, http.StatusConflict, http.StatusBadRequest, http.StatusNotFound}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("operation %d: status %d (%s), want %d", i, result.Status, result.Error, want[i])
+		}
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(results[0].Body, &created)
+	if status := waitDone(t, tm, created.ID); status.ResultURL == "" {
+		t.Fatal("processed task has no result URL")
+	}
+}
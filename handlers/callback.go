@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"2025-08-02/queue"
+	"2025-08-02/task"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// callbackAttempts is how many times a completion callback is tried.
+	callbackAttempts = 3
+	// callbackBackoff is the wait before the first retry; it doubles for
+	// every further one.
+	callbackBackoff = time.Second
+	callbackTimeout = 10 * time.Second
+)
+
+// validCallbackURL reports whether raw is an absolute http or https URL
+// on a host permitted by callback_allowed_hosts.
+func (tm *TaskManager) validCallbackURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	return tm.callbackHostAllowed(u.Hostname())
+}
+
+// callbackHostAllowed reports whether callbacks may be sent to host,
+// which is the case for every host when callback_allowed_hosts is empty.
+func (tm *TaskManager) callbackHostAllowed(host string) bool {
+	if len(tm.config.CallbackAllowedHosts) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, allowed := range tm.config.CallbackAllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasPrefix(allowed, ".") && (strings.HasSuffix(host, allowed) || host == allowed[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyCallback POSTs c to the callback URL of its task in the background,
+// retrying failed attempts with backoff. Failures are logged, never
+// surfaced to the task. Shutdown waits for pending callbacks like for
+// running tasks, so callers must be inside an in-flight goroutine.
+func (tm *TaskManager) notifyCallback(callbackURL string, c queue.Completion) {
+	body, err := json.Marshal(c)
+	if err != nil {
+		tm.logger.Error("failed to encode callback", "task_id", c.TaskID, "error", err)
+		return
+	}
+
+	tm.inFlight.Add(1)
+	go func() {
+		defer tm.inFlight.Done()
+		delay := callbackBackoff
+		for attempt := 1; ; attempt++ {
+			err := tm.postCallback(callbackURL, body)
+			if err == nil {
+				tm.logger.Info("delivered callback", "task_id", c.TaskID, "callback_url", callbackURL, "attempt", attempt)
+				return
+			}
+			if attempt == callbackAttempts {
+				tm.logger.Error("giving up on callback", "task_id", c.TaskID, "callback_url", callbackURL, "attempt", attempt, "error", err)
+				return
+			}
+			tm.logger.Warn("callback failed, retrying", "task_id", c.TaskID, "callback_url", callbackURL, "attempt", attempt, "retry_in", delay, "error", err)
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}()
+}
+
+// postCallback sends one callback attempt; any non-2xx answer is an error.
+func (tm *TaskManager) postCallback(callbackURL string, body []byte) error {
+	resp, err := tm.callbackClient.Post(callbackURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// progressCallback is the body of a POST to a progress callback URL.
+type progressCallback struct {
+	TaskID   string        `json:"task_id"`
+	Files    []task.Event  `json:"files"`
+	Progress task.Progress `json:"progress"`
+}
+
+// forwardProgress subscribes to the file events of t and POSTs them to its
+// progress callback URL, at most once per progress_callback_interval_ms.
+// Events arriving while a POST is under way are batched into the next one,
+// and the last batch is sent once t has finished. Progress callbacks are
+// tried once; failures are logged.
+func (tm *TaskManager) forwardProgress(t *task.Task) {
+	events, cancel, err := t.Subscribe(0)
+	if err != nil {
+		tm.logger.Error("failed to subscribe to task progress", "task_id", t.ID, "error", err)
+		return
+	}
+
+	batches := make(chan []task.Event)
+	tm.inFlight.Add(2)
+	go func() {
+		defer tm.inFlight.Done()
+		for batch := range batches {
+			tm.postProgress(t, batch)
+		}
+	}()
+	go func() {
+		defer tm.inFlight.Done()
+		defer cancel()
+		ticker := time.NewTicker(time.Duration(tm.config.ProgressCallbackIntervalMs) * time.Millisecond)
+		defer ticker.Stop()
+
+		var pending []task.Event
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					if len(pending) > 0 {
+						batches <- pending
+					}
+					close(batches)
+					return
+				}
+				if e.Type == "file" {
+					pending = append(pending, e)
+				}
+			case <-ticker.C:
+				if len(pending) == 0 {
+					continue
+				}
+				// The subscription must keep being drained, so a batch
+				// is only handed over when no POST is running.
+				select {
+				case batches <- pending:
+					pending = nil
+				default:
+				}
+			}
+		}
+	}()
+}
+
+func (tm *TaskManager) postProgress(t *task.Task, files []task.Event) {
+	body, err := json.Marshal(progressCallback{TaskID: t.ID, Files: files, Progress: t.Progress()})
+	if err != nil {
+		tm.logger.Error("failed to encode progress callback", "task_id", t.ID, "error", err)
+		return
+	}
+	if err := tm.postCallback(t.ProgressCallbackURL, body); err != nil {
+		tm.logger.Warn("progress callback failed", "task_id", t.ID, "callback_url", t.ProgressCallbackURL, "error", err)
+	}
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/queue"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallback(t *testing.T) {
+	files := fileServer(t)
+	var attempts atomic.Int32
+	received := make(chan queue.Completion, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first attempt fails, so the callback is retried.
+		if attempts.Add(1) == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		var c queue.Completion
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			t.Errorf("callback body: %v", err)
+		}
+		received <- c
+	}))
+	defer receiver.Close()
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+
+	id := createTask(t, tm, `{"callback_url": "`+receiver.URL+`/done"}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	status := waitDone(t, tm, id)
+	select {
+	case c := <-received:
+		if c.TaskID != id || c.Status != "done" || c.ResultURL != status.ResultURL {
+			t.Fatalf("callback %+v, want the done status of task %s", c, id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback not delivered")
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Fatalf("callback tried %d times, want 2", n)
+	}
+}
+
+func TestCreateTaskInvalidCallback(t *testing.T) {
+	tm := newTestManager(t, nil)
+	for _, callbackURL := range []string{"/done", "ftp://example.com/done", "https://"} {
+		w := serve(tm.CreateTaskHandler, http.MethodPost, `{"callback_url": "`+callbackURL+`"}`, nil, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("callback_url %q: status %d, want %d", callbackURL, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestForwardProgress(t *testing.T) {
+	const files = 6
+	const interval = 100 * time.Millisecond
+	downloads := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			time.Sleep(60 * time.Millisecond)
+		}
+		if r.URL.Path == "/missing.pdf" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	defer downloads.Close()
+
+	type post struct {
+		at   time.Time
+		body progressCallback
+	}
+	var (
+		mutex sync.Mutex
+		posts []post
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body progressCallback
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("progress body: %v", err)
+		}
+		mutex.Lock()
+		posts = append(posts, post{time.Now(), body})
+		mutex.Unlock()
+	}))
+	defer receiver.Close()
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.MaxFilesPerTask = files
+		cfg.ProgressCallbackIntervalMs = int(interval / time.Millisecond)
+	})
+
+	id := createTask(t, tm, `{"progress_callback_url": "`+receiver.URL+`/progress"}`, nil)
+	for i := range files - 1 {
+		addFile(t, tm, id, fmt.Sprintf("%s/%d.pdf", downloads.URL, i))
+	}
+	addFile(t, tm, id, downloads.URL+"/missing.pdf")
+	waitFinished(t, tm, id)
+	tm.inFlight.Wait()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(posts) == 0 || len(posts) >= files {
+		t.Fatalf("%d progress callbacks for %d files, want them batched", len(posts), files)
+	}
+	var got []string
+	for i, p := range posts {
+		if p.body.TaskID != id {
+			t.Fatalf("callback %d for task %q", i, p.body.TaskID)
+		}
+		// Only the final flush may follow its predecessor closely.
+		if i > 0 && i < len(posts)-1 && p.at.Sub(posts[i-1].at) < interval*8/10 {
+			t.Fatalf("callbacks %d and %d only %v apart", i-1, i, p.at.Sub(posts[i-1].at))
+		}
+		for _, e := range p.body.Files {
+			got = append(got, e.File+" "+string(e.Status)+" "+e.Name)
+		}
+	}
+	var want []string
+	for i := range files - 1 {
+		want = append(want, fmt.Sprintf("%s/%d.pdf done %d.pdf", downloads.URL, i, i))
+	}
+	want = append(want, downloads.URL+"/missing.pdf error ")
+	if !slices.Equal(got, want) {
+		t.Fatalf("file results %q, want %q", got, want)
+	}
+	if last := posts[len(posts)-1].body.Progress; last.FilesArchived != files-1 || last.FilesFailed != 1 {
+		t.Fatalf("final progress %+v, want every file settled", last)
+	}
+}
+
+func TestValidCallbackURL(t *testing.T) {
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.CallbackAllowedHosts = []string{"hooks.example.com", ".example.org"}
+	})
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://hooks.example.com/done", true},
+		{"https://HOOKS.example.com/done", true},
+		{"https://example.org/done", true},
+		{"https://a.example.org/done", true},
+		{"https://example.com/done", false},
+		{"https://evil-example.org/done", false},
+		{"ftp://hooks.example.com/done", false},
+		{"/done", false},
+	}
+	for _, tt := range tests {
+		if got := tm.validCallbackURL(tt.url); got != tt.want {
+			t.Errorf("validCallbackURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestPostCallbackGuards(t *testing.T) {
+	var received atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	defer receiver.Close()
+	// The receiver is reachable as 127.0.0.1 and as localhost; the
+	// redirector sends callbacks on to localhost.
+	localhostURL := strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)
+	redirector := httptest.NewServer(http.RedirectHandler(localhostURL, http.StatusTemporaryRedirect))
+	defer redirector.Close()
+
+	tests := []struct {
+		name         string
+		url          string
+		allowedHosts []string
+		blockPrivate bool
+		wantErr      bool
+	}{
+		{"direct", receiver.URL, nil, false, false},
+		{"redirect to any host", redirector.URL, nil, false, false},
+		{"redirect to allowed host", redirector.URL, []string{"127.0.0.1", "localhost"}, false, false},
+		{"redirect to other host", redirector.URL, []string{"127.0.0.1"}, false, true},
+		{"private address", receiver.URL, nil, true, true},
+		{"redirect to private address", redirector.URL, nil, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := newTestManager(t, func(cfg *config.Config) {
+				cfg.CallbackAllowedHosts = tt.allowedHosts
+				cfg.BlockPrivateIPs = tt.blockPrivate
+			})
+			received.Store(0)
+			err := tm.postCallback(tt.url, []byte(`{}`))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("callback was sent")
+				}
+				if n := received.Load(); n != 0 {
+					t.Errorf("receiver got %d callbacks, want 0", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("postCallback: %v", err)
+			}
+			if n := received.Load(); n != 1 {
+				t.Errorf("receiver got %d callbacks, want 1", n)
+			}
+		})
+	}
+}
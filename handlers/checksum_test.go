@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestArchiveChecksum(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.ComputeArchiveChecksum = true
+		cfg.MaxFilesPerTask = 1
+	})
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	status := waitDone(t, tm, id)
+
+	data, err := os.ReadFile(id + ".zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	want := hex.EncodeToString(sum[:])
+	if status.ResultChecksum != want {
+		t.Fatalf("checksum %q, want %q", status.ResultChecksum, want)
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        int
+	}{
+		{"download", "", http.StatusOK},
+		{"cached", `"` + want + `"`, http.StatusNotModified},
+		{"stale cache", `"other"`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{}
+			if tt.ifNoneMatch != "" {
+				headers["If-None-Match"] = tt.ifNoneMatch
+			}
+			w := serve(tm.ServeArchiveHandler, http.MethodGet, "", map[string]string{"filename": id + ".zip"}, headers)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+			if got := w.Header().Get("ETag"); got != `"`+want+`"` {
+				t.Fatalf("ETag %q, want the checksum", got)
+			}
+			if got := w.Header().Get("Digest"); got != digestHeader(want) {
+				t.Fatalf("Digest %q, want %q", got, digestHeader(want))
+			}
+		})
+	}
+}
+
+func TestArchiveChecksumDisabled(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	if status := waitDone(t, tm, id); status.ResultChecksum != "" {
+		t.Fatalf("checksum %q without compute_archive_checksum", status.ResultChecksum)
+	}
+	w := serve(tm.ServeArchiveHandler, http.MethodGet, "", map[string]string{"filename": id + ".zip"}, nil)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != "" {
+		t.Fatalf("download: status %d with ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestDigestHeader(t *testing.T) {
+	tests := []struct {
+		checksum string
+		want     string
+	}{
+		{"", ""},
+		{"not hex", ""},
+		// SHA-256 of the empty string.
+		{"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", "sha-256=47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+	}
+	for _, tt := range tests {
+		if got := digestHeader(tt.checksum); got != tt.want {
+			t.Errorf("digestHeader(%q) = %q, want %q", tt.checksum, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"os"
+)
+
+// coalesceLeader returns an earlier task with the same URL set as t that is
+// still processing or has an archive on disk, when CoalesceIdenticalTasks
+// is set. Otherwise t becomes the task later identical ones coalesce with,
+// and nil is returned.
+func (tm *TaskManager) coalesceLeader(t *task.Task) *task.Task {
+	if !tm.config.CoalesceIdenticalTasks {
+		return nil
+	}
+	key := t.CoalesceKey()
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	if leader, ok := tm.Tasks[tm.coalesced[key]]; ok && leader != t && tm.canCoalesceWith(leader) {
+		return leader
+	}
+	tm.coalesced[key] = t.ID
+	return nil
+}
+
+// dropCoalesceLeader stops later tasks from coalescing with t, which could
+// not be started after all.
+func (tm *TaskManager) dropCoalesceLeader(t *task.Task) {
+	if !tm.config.CoalesceIdenticalTasks {
+		return
+	}
+	key := t.CoalesceKey()
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	if tm.coalesced[key] == t.ID {
+		delete(tm.coalesced, key)
+	}
+}
+
+// canCoalesceWith reports whether leader's outcome can still be shared:
+// failed tasks are retried and finished ones need their archive.
+func (tm *TaskManager) canCoalesceWith(leader *task.Task) bool {
+	switch leader.State() {
+	case task.StatusError, task.StatusCanceled:
+		return false
+	case task.StatusDone, task.StatusPartial:
+		_, err := os.Stat(leader.ArchiveName())
+		return err == nil
+	default:
+		return true
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCoalesceIdenticalTasks(t *testing.T) {
+	release := make(chan struct{})
+	var downloads atomic.Int32
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			downloads.Add(1)
+			<-release
+		}
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	defer files.Close()
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.MaxFilesPerTask = 1
+		cfg.CoalesceIdenticalTasks = true
+	})
+	publisher := &memPublisher{}
+	tm.publisher = publisher
+
+	leader := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, leader, files.URL+"/a.pdf")
+	// The host is case insensitive, so this is the same URL set.
+	follower := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, follower, "HTTP://"+files.Listener.Addr().String()+"/a.pdf")
+	close(release)
+
+	leaderStatus := waitDone(t, tm, leader)
+	followerStatus := waitDone(t, tm, follower)
+	if n := downloads.Load(); n != 1 {
+		t.Fatalf("file downloaded %d times, want once for both tasks", n)
+	}
+	if followerStatus.ResultURL != leaderStatus.ResultURL {
+		t.Fatalf("follower result %q, want the leader's %q", followerStatus.ResultURL, leaderStatus.ResultURL)
+	}
+	tm.mutex.Lock()
+	coalescedWith := tm.Tasks[follower].CoalescedWith
+	tm.mutex.Unlock()
+	if coalescedWith != leader {
+		t.Fatalf("coalesced_with %q, want %q", coalescedWith, leader)
+	}
+
+	// Each task announces its own completion exactly once.
+	waitIdle(t, tm)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(publisher.published(follower)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, id := range []string{leader, follower} {
+		if got := publisher.published(id); len(got) != 1 || got[0].ResultURL != leaderStatus.ResultURL {
+			t.Fatalf("task %s published %+v, want one completion with the shared archive", id, got)
+		}
+	}
+}
+
+func TestCoalesceDisabled(t *testing.T) {
+	var downloads atomic.Int32
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			downloads.Add(1)
+		}
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	defer files.Close()
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+
+	first := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, first, files.URL+"/a.pdf")
+	waitDone(t, tm, first)
+	second := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, second, files.URL+"/a.pdf")
+	if a, b := waitDone(t, tm, first), waitDone(t, tm, second); a.ResultURL == b.ResultURL {
+		t.Fatalf("both tasks share %q without coalesce_identical_tasks", a.ResultURL)
+	}
+	if n := downloads.Load(); n != 2 {
+		t.Fatalf("file downloaded %d times, want once per task", n)
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ArchiveEntry is one file of an archive in the contents listing.
+type ArchiveEntry struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// ContentsNode is a directory or file in the tree form of the contents
+// listing. Directories carry the total size of the files below them.
+type ContentsNode struct {
+	Name     string          `json:"name"`
+	Type     string          `json:"type"` // "dir" or "file"
+	Size     int64           `json:"size"`
+	Children []*ContentsNode `json:"children,omitempty"`
+}
+
+// ArchiveContentsHandler lists the entries of a task's archive
+// @Summary      List archive contents
+// @Description  lists the files in a finished task's archive, as a flat list or, with tree=true, as a nested directory tree built from the entry names
+// @Tags         archives
+// @Produce      json
+// @Param        id    path      string  true   "Task ID"
+// @Param        tree  query     bool    false  "Return a directory tree"
+// @Success      200 {array}   ArchiveEntry
+// @Success      200 {object}  ContentsNode "with tree=true"
+// @Failure      404 {string} string "task not found"
+// @Failure      409 {string} string "task is not done"
+// @Failure      410 {string} string "archive has expired"
+// @Router       /tasks/{id}/contents [get]
+func (tm *TaskManager) ArchiveContentsHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+	tm.requestLogger(r).Debug("ArchiveContentsHandler called", "task_id", taskID)
+
+	tm.mutex.Lock()
+	t, ok := tm.Tasks[taskID]
+	tm.mutex.Unlock()
+	if !ok || !tm.ownedBy(r, t) {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+	if !t.State().HasArchive() {
+		http.Error(w, "task is not done", http.StatusConflict)
+		return
+	}
+
+	if tm.taskArchiveExpired(r, t) {
+		http.Error(w, "archive has expired", http.StatusGone)
+		return
+	}
+	files, err := task.ListArchive(t.ArchiveName(), t.Format())
+	if err != nil {
+		tm.requestLogger(r).Error("failed to open archive", "task_id", taskID, "error", err)
+		http.Error(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	entries := make([]ArchiveEntry, 0, len(files))
+	for _, f := range files {
+		entries = append(entries, ArchiveEntry{Name: f.Name, Size: f.Size})
+	}
+
+	if r.URL.Query().Get("tree") == "true" {
+		tm.writeJSON(w, r, http.StatusOK, contentsTree(entries))
+		return
+	}
+	tm.writeJSON(w, r, http.StatusOK, entries)
+}
+
+// contentsTree nests entries by the "/"-separated elements of their names
+// under an unnamed root. Directories sort before files, each by name.
+func contentsTree(entries []ArchiveEntry) *ContentsNode {
+	root := &ContentsNode{Type: "dir"}
+	for _, e := range entries {
+		parts := strings.Split(strings.Trim(e.Name, "/"), "/")
+		dir := root
+		dir.Size += e.Size
+		for _, name := range parts[:len(parts)-1] {
+			dir = childDir(dir, name)
+			dir.Size += e.Size
+		}
+		dir.Children = append(dir.Children, &ContentsNode{Name: parts[len(parts)-1], Type: "file", Size: e.Size})
+	}
+	sortTree(root)
+	return root
+}
+
+// childDir returns the subdirectory name of dir, creating it if needed.
+func childDir(dir *ContentsNode, name string) *ContentsNode {
+	for _, child := range dir.Children {
+		if child.Type == "dir" && child.Name == name {
+			return child
+		}
+	}
+	child := &ContentsNode{Name: name, Type: "dir"}
+	dir.Children = append(dir.Children, child)
+	return child
+}
+
+func sortTree(node *ContentsNode) {
+	sort.SliceStable(node.Children, func(i, j int) bool {
+		a, b := node.Children[i], node.Children[j]
+		if a.Type != b.Type {
+			return a.Type == "dir"
+		}
+		return a.Name < b.Name
+	})
+	for _, child := range node.Children {
+		sortTree(child)
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestArchiveContents(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 2 })
+	id := createTask(t, tm, `{}`, nil)
+	vars := map[string]string{"id": id}
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	if w := serve(tm.ArchiveContentsHandler, http.MethodGet, "", vars, nil); w.Code != http.StatusConflict {
+		t.Fatalf("contents before done: status %d, want %d", w.Code, http.StatusConflict)
+	}
+	addFile(t, tm, id, files.URL+"/bb.pdf")
+	waitDone(t, tm, id)
+
+	w := serve(tm.ArchiveContentsHandler, http.MethodGet, "", vars, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("contents: status %d", w.Code)
+	}
+	var entries []ArchiveEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	want := []ArchiveEntry{{Name: "a.pdf", Size: int64(len("%PDF-1.4 /a.pdf"))}, {Name: "bb.pdf", Size: int64(len("%PDF-1.4 /bb.pdf"))}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("entries %+v, want %+v", entries, want)
+	}
+
+	w = serveURL(tm.ArchiveContentsHandler, "/tasks/"+id+"/contents?tree=true", vars, nil)
+	var root ContentsNode
+	if err := json.Unmarshal(w.Body.Bytes(), &root); err != nil {
+		t.Fatal(err)
+	}
+	if root.Type != "dir" || root.Size != want[0].Size+want[1].Size || len(root.Children) != 2 {
+		t.Fatalf("tree root %+v", root)
+	}
+
+	if w := serve(tm.ArchiveContentsHandler, http.MethodGet, "", map[string]string{"id": "missing"}, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown task: status %d", w.Code)
+	}
+}
+
+func TestContentsTree(t *testing.T) {
+	got := contentsTree([]ArchiveEntry{
+		{Name: "z.pdf", Size: 1},
+		{Name: "docs/b.pdf", Size: 2},
+		{Name: "docs/a/c.pdf", Size: 4},
+		{Name: "a.pdf", Size: 8},
+	})
+	want := &ContentsNode{Type: "dir", Size: 15, Children: []*ContentsNode{
+		{Name: "docs", Type: "dir", Size: 6, Children: []*ContentsNode{
+			{Name: "a", Type: "dir", Size: 4, Children: []*ContentsNode{{Name: "c.pdf", Type: "file", Size: 4}}},
+			{Name: "b.pdf", Type: "file", Size: 2},
+		}},
+		{Name: "a.pdf", Type: "file", Size: 8},
+		{Name: "z.pdf", Type: "file", Size: 1},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		t.Fatalf("tree %s", gotJSON)
+	}
+}
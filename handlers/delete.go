@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/gorilla/mux"
+)
+
+// DeleteTaskHandler deletes a task and its archive
+// @Summary      Delete a task
+// @Description  forgets a task that is not being processed and deletes its archive, unless a coalesced task still shares it; a task still collecting files is canceled first
+// @Tags         tasks
+// @Param        id   path      string  true  "Task ID"
+// @Success      204
+// @Failure      404 {string} string "task not found"
+// @Failure      409 {string} string "task is being processed"
+// @Router       /tasks/{id} [delete]
+func (tm *TaskManager) DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+	tm.requestLogger(r).Debug("DeleteTaskHandler called", "task_id", taskID)
+
+	tm.mutex.Lock()
+	t, ok := tm.Tasks[taskID]
+	if !ok || !tm.ownedBy(r, t) {
+		tm.mutex.Unlock()
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+	// Canceling under tm.mutex keeps the task from starting to process
+	// between the check and its removal. Cancel fails for tasks that are
+	// already processing or finished, which the status check sorts out.
+	t.Cancel("task deleted")
+	if t.State() == task.StatusProcessing {
+		tm.mutex.Unlock()
+		http.Error(w, "task is being processed", http.StatusConflict)
+		return
+	}
+	archiveName := tm.forgetTask(t)
+	tm.mutex.Unlock()
+
+	tm.discardTask(t, archiveName)
+	tm.requestLogger(r).Info("deleted task", "task_id", taskID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// discardTask releases what a task removed by forgetTask still holds: its
+// prefetches, uploads, stored record and archiveName unless empty.
+func (tm *TaskManager) discardTask(t *task.Task, archiveName string) {
+	t.DiscardPrefetches()
+	tm.releaseUploads(t.ID)
+	if err := tm.taskStore.Delete(t.ID); err != nil {
+		tm.logger.Error("failed to delete task from the task store", "task_id", t.ID, "error", err)
+	}
+	if archiveName != "" {
+		tm.archiveReaders.evict(archiveName)
+		if err := os.Remove(archiveName); err != nil && !errors.Is(err, os.ErrNotExist) {
+			tm.logger.Error("failed to delete archive", "task_id", t.ID, "path", archiveName, "error", err)
+		}
+	}
+}
+
+// forgetTask removes t from every index of tm and returns the archive to
+// delete, or "" when another task still serves the same archive. Callers
+// must hold tm.mutex.
+func (tm *TaskManager) forgetTask(t *task.Task) string {
+	delete(tm.Tasks, t.ID)
+	if members, ok := tm.groups[t.GroupID]; ok {
+		members = slices.DeleteFunc(members, func(id string) bool { return id == t.ID })
+		if len(members) == 0 {
+			delete(tm.groups, t.GroupID)
+		} else {
+			tm.groups[t.GroupID] = members
+		}
+	}
+	for key, id := range tm.coalesced {
+		if id == t.ID {
+			delete(tm.coalesced, key)
+		}
+	}
+
+	archiveName := t.ArchiveName()
+	for _, other := range tm.Tasks {
+		if other.ArchiveName() == archiveName {
+			// A coalesced task shares the archive; it takes it over.
+			tm.archives[archiveName] = other.ID
+			return ""
+		}
+	}
+	delete(tm.archives, archiveName)
+	return archiveName
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestDeleteTask(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	id := createTask(t, tm, `{}`, nil)
+	vars := map[string]string{"id": id}
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+	waitIdle(t, tm)
+	archive := tm.Tasks[id].ArchiveName()
+
+	if w := serve(tm.DeleteTaskHandler, http.MethodDelete, "", vars, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: status %d: %s", w.Code, w.Body)
+	}
+	if _, err := os.Stat(archive); !os.IsNotExist(err) {
+		t.Fatalf("archive still on disk after delete: %v", err)
+	}
+	if w := serve(tm.GetTaskStatusHandler, http.MethodGet, "", vars, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("status after delete: %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := serve(tm.DeleteTaskHandler, http.MethodDelete, "", vars, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("second delete: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestDeleteCollectingTask(t *testing.T) {
+	tm := newTestManager(t, nil)
+	id := createTask(t, tm, `{}`, nil)
+	tk := tm.Tasks[id]
+
+	if w := serve(tm.DeleteTaskHandler, http.MethodDelete, "", map[string]string{"id": id}, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: status %d: %s", w.Code, w.Body)
+	}
+	// The canceled task cannot start processing any more.
+	if err := tk.MarkProcessing(); err == nil {
+		t.Fatal("deleted task could still start processing")
+	}
+}
+
+func TestDeleteProcessingTask(t *testing.T) {
+	release := make(chan struct{})
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			<-release
+		}
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer files.Close()
+	defer close(release)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+
+	w := serve(tm.DeleteTaskHandler, http.MethodDelete, "", map[string]string{"id": id}, nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("delete while processing: status %d, want %d", w.Code, http.StatusConflict)
+	}
+	if status := getStatus(t, tm, id); status.Status != "processing" {
+		t.Fatalf("task is %s after the rejected delete", status.Status)
+	}
+}
+
+func TestDeleteCoalescedTask(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.MaxFilesPerTask = 1
+		cfg.CoalesceIdenticalTasks = true
+	})
+	leader := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, leader, files.URL+"/a.pdf")
+	waitDone(t, tm, leader)
+	follower := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, follower, files.URL+"/a.pdf")
+	waitDone(t, tm, follower)
+	waitIdle(t, tm)
+	archive := tm.Tasks[leader].ArchiveName()
+	if got := tm.Tasks[follower].ArchiveName(); got != archive {
+		t.Fatalf("follower archive %q, want the shared %q", got, archive)
+	}
+
+	if w := serve(tm.DeleteTaskHandler, http.MethodDelete, "", map[string]string{"id": leader}, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete leader: status %d: %s", w.Code, w.Body)
+	}
+	// The follower takes the shared archive over.
+	if _, err := os.Stat(archive); err != nil {
+		t.Fatalf("shared archive removed with the leader: %v", err)
+	}
+	if owner := tm.taskByArchive(archive); owner == nil || owner.ID != follower {
+		t.Fatalf("archive owned by %v, want the follower", owner)
+	}
+	if w := serve(tm.ServeArchiveHandler, http.MethodGet, "", map[string]string{"filename": archive}, nil); w.Code != http.StatusOK {
+		t.Fatalf("download after leader delete: status %d", w.Code)
+	}
+
+	if w := serve(tm.DeleteTaskHandler, http.MethodDelete, "", map[string]string{"id": follower}, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete follower: status %d: %s", w.Code, w.Body)
+	}
+	if _, err := os.Stat(archive); !os.IsNotExist(err) {
+		t.Fatalf("archive still on disk after the last task is deleted: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultDownloadPage is the built-in landing page of a finished archive.
+const defaultDownloadPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.ArchiveName}}</title>
+</head>
+<body>
+<h1>{{.ArchiveName}}</h1>
+{{if .Checksum}}<p>SHA-256: <code>{{.Checksum}}</code></p>{{end}}
+<table>
+<tr><th>File</th><th>Size</th><th>SHA-256</th></tr>
+{{range .Entries}}<tr><td>{{if .URL}}<a href="{{.URL}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</td><td>{{.Size}}</td><td><code>{{.SHA256}}</code></td></tr>
+{{end}}</table>
+<p><a href="{{.DownloadURL}}">Download {{.ArchiveName}}</a></p>
+</body>
+</html>
+`
+
+// downloadPage is the data the download page template is rendered with.
+type downloadPage struct {
+	TaskID      string
+	ArchiveName string
+	Checksum    string
+	DownloadURL string
+	Entries     []downloadEntry
+}
+
+// downloadEntry is one file listed on the download page. SHA256 is only
+// known when content deduplication is enabled, and URL is empty for
+// entries of tar.gz archives.
+type downloadEntry struct {
+	Name   string
+	Size   uint64
+	SHA256 string
+	URL    string
+}
+
+// loadDownloadPage parses the configured download page template, or the
+// built-in one.
+func loadDownloadPage(path string) *template.Template {
+	if path != "" {
+		return template.Must(template.ParseFiles(path))
+	}
+	return template.Must(template.New("download").Parse(defaultDownloadPage))
+}
+
+// DownloadHandler serves a task's archive or an HTML page describing it
+// @Summary      Download page of an archive
+// @Description  renders an HTML page listing the archive's files, sizes and checksums with a download link, or serves the zip itself; format=html|zip takes precedence over Accept
+// @Tags         archives
+// @Produce      html
+// @Produce      application/zip
+// @Param        id      path      string  true   "Task ID"
+// @Param        format  query     string  false  "html or zip"
+// @Success      200 {file}  file "Download page or archive"
+// @Failure      400 {string} string "invalid format"
+// @Failure      404 {string} string "task not found"
+// @Failure      409 {string} string "task is not done"
+// @Failure      410 {string} string "archive has expired"
+// @Router       /tasks/{id}/download [get]
+func (tm *TaskManager) DownloadHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+	tm.requestLogger(r).Debug("DownloadHandler called", "task_id", taskID)
+
+	tm.mutex.Lock()
+	t, ok := tm.Tasks[taskID]
+	tm.mutex.Unlock()
+	if !ok || !tm.ownedBy(r, t) {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		format = "zip"
+		if strings.Contains(r.Header.Get("Accept"), "text/html") {
+			format = "html"
+		}
+	case "html", "zip":
+	default:
+		http.Error(w, "invalid format", http.StatusBadRequest)
+		return
+	}
+
+	result := t.Result()
+	if !result.Status.HasArchive() {
+		http.Error(w, "task is not done", http.StatusConflict)
+		return
+	}
+	if format == "zip" {
+		tm.serveArchive(w, r, t.ArchiveName())
+		return
+	}
+	if tm.taskArchiveExpired(r, t) {
+		http.Error(w, "archive has expired", http.StatusGone)
+		return
+	}
+
+	page, err := buildDownloadPage(t, result)
+	if err != nil {
+		tm.requestLogger(r).Error("failed to open archive", "task_id", taskID, "error", err)
+		http.Error(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tm.downloadPage.Execute(w, page); err != nil {
+		tm.requestLogger(r).Error("failed to render download page", "task_id", taskID, "error", err)
+	}
+}
+
+// buildDownloadPage lists the entries of t's archive.
+func buildDownloadPage(t *task.Task, result task.Result) (downloadPage, error) {
+	page := downloadPage{
+		TaskID:      t.ID,
+		ArchiveName: filepath.Base(t.ArchiveName()),
+		Checksum:    result.ResultChecksum,
+		DownloadURL: fmt.Sprintf("/tasks/%s/download?format=zip", t.ID),
+	}
+
+	files, err := task.ListArchive(t.ArchiveName(), t.Format())
+	if err != nil {
+		return page, err
+	}
+	// Single entries are only served from zip archives.
+	linkEntries := t.Format() == task.FormatZip
+
+	hashes := make(map[string]string, len(result.Files))
+	for _, f := range result.Files {
+		hashes[f.Name] = f.SHA256
+	}
+	for _, f := range files {
+		entry := downloadEntry{Name: f.Name, Size: uint64(f.Size), SHA256: hashes[f.Name]}
+		if linkEntries {
+			entry.URL = fmt.Sprintf("/tasks/%s/files/%s", t.ID, (&url.URL{Path: f.Name}).EscapedPath())
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+	return page, nil
+}
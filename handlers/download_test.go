@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadPage(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.DedupeContent = true
+		cfg.ComputeArchiveChecksum = true
+		cfg.MaxFilesPerTask = 1
+	})
+	pending := createTask(t, tm, `{}`, nil)
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/my report.pdf")
+	status := waitDone(t, tm, id)
+	entrySum := tm.Tasks[id].Result().Files[0].SHA256
+
+	tests := []struct {
+		name     string
+		taskID   string
+		query    string
+		accept   string
+		want     int
+		wantType string
+		wantBody []string
+	}{
+		{"html by Accept", id, "", "text/html,application/xhtml+xml", http.StatusOK, "text/html; charset=utf-8", []string{
+			`<a href="/tasks/` + id + `/files/my%20report.pdf">my report.pdf</a>`,
+			"<code>" + entrySum + "</code>",
+			"SHA-256: <code>" + status.ResultChecksum + "</code>",
+			`href="/tasks/` + id + `/download?format=zip"`,
+		}},
+		{"zip by default", id, "", "", http.StatusOK, "application/zip", nil},
+		{"format overrides Accept", id, "?format=zip", "text/html", http.StatusOK, "application/zip", nil},
+		{"invalid format", id, "?format=rar", "", http.StatusBadRequest, "", nil},
+		{"not done", pending, "?format=html", "", http.StatusConflict, "", nil},
+		{"unknown task", "missing", "", "", http.StatusNotFound, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveURL(tm.DownloadHandler, "/tasks/"+tt.taskID+"/download"+tt.query, map[string]string{"id": tt.taskID}, map[string]string{"Accept": tt.accept})
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+			if tt.wantType != "" && w.Header().Get("Content-Type") != tt.wantType {
+				t.Fatalf("Content-Type %q, want %q", w.Header().Get("Content-Type"), tt.wantType)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("page has no %s:\n%s", want, w.Body)
+				}
+			}
+			if tt.wantType == "application/zip" {
+				if entries := readZip(t, w.Body.Bytes()); len(entries) != 1 {
+					t.Fatalf("archive has %d entries", len(entries))
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadPageTemplate(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		path := filepath.Join(t.TempDir(), "page.html")
+		os.WriteFile(path, []byte(`{{.TaskID}}:{{range .Entries}} {{.Name}}={{.Size}}{{end}}`), 0o644)
+		cfg.DownloadPageTemplate = path
+		cfg.MaxFilesPerTask = 1
+	})
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+
+	w := serveURL(tm.DownloadHandler, "/tasks/"+id+"/download?format=html", map[string]string{"id": id}, nil)
+	if want := id + ": a.pdf=15"; w.Body.String() != want {
+		t.Fatalf("page %q, want %q", w.Body, want)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// TaskEventsHandler streams task progress as server-sent events
+// @Summary      Stream task events
+// @Description  streams status and per-file progress events for a task until it finishes
+// @Tags         tasks
+// @Produce      text/event-stream
+// @Param        id   path      string  true  "Task ID"
+// @Success      200 {object} task.Event
+// @Failure      404 {string} string "task not found"
+// @Failure      503 {string} string "too many subscribers"
+// @Router       /tasks/{id}/events [get]
+func (tm *TaskManager) TaskEventsHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+	tm.requestLogger(r).Debug("TaskEventsHandler called", "task_id", taskID)
+
+	tm.mutex.Lock()
+	t, ok := tm.Tasks[taskID]
+	tm.mutex.Unlock()
+	if !ok || !tm.ownedBy(r, t) {
+		tm.requestLogger(r).Debug("task not found", "task_id", taskID)
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	if !tm.acquireSubscriber() {
+		tm.requestLogger(r).Warn("too many event subscribers", "task_id", taskID)
+		http.Error(w, "too many subscribers", http.StatusServiceUnavailable)
+		return
+	}
+	defer tm.releaseSubscriber()
+
+	events, cancel, err := t.Subscribe(tm.config.MaxSSESubscribersPerTask)
+	if err != nil {
+		tm.requestLogger(r).Warn("rejecting event subscriber", "task_id", taskID, "error", err)
+		http.Error(w, "too many subscribers", http.StatusServiceUnavailable)
+		return
+	}
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	writeEvent(w, task.Event{Type: "status", Status: t.State()})
+	flusher.Flush()
+
+	// Comments keep proxies from closing a connection that is idle
+	// between events; clients ignore them.
+	var heartbeat <-chan time.Time
+	if tm.config.SSEHeartbeatSeconds > 0 {
+		ticker := time.NewTicker(time.Duration(tm.config.SSEHeartbeatSeconds) * time.Second)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			writeEvent(w, e)
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e task.Event) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		slog.Error("failed to encode event", "type", e.Type, "error", err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+}
+
+// acquireSubscriber reserves a slot under the global subscriber limit.
+func (tm *TaskManager) acquireSubscriber() bool {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	if tm.sseSubscribers >= tm.config.MaxSSESubscribers {
+		return false
+	}
+	tm.sseSubscribers++
+	return true
+}
+
+func (tm *TaskManager) releaseSubscriber() {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	tm.sseSubscribers--
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestTaskEventsSubscriberLimits(t *testing.T) {
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.MaxSSESubscribers = 2
+		cfg.MaxSSESubscribersPerTask = 1
+	})
+	first := createTask(t, tm, `{}`, nil)
+	second := createTask(t, tm, `{}`, nil)
+
+	if _, _, err := tm.Tasks[first].Subscribe(1); err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(tm.TaskEventsHandler, http.MethodGet, "", map[string]string{"id": first}, nil); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("over the per-task limit: status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if tm.sseSubscribers != 0 {
+		t.Fatalf("rejected subscriber kept a global slot")
+	}
+
+	tm.acquireSubscriber()
+	tm.acquireSubscriber()
+	if w := serve(tm.TaskEventsHandler, http.MethodGet, "", map[string]string{"id": second}, nil); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("over the global limit: status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestTaskEventsStream(t *testing.T) {
+	tm := newTestManager(t, nil)
+	id := createTask(t, tm, `{}`, nil)
+	r := mux.NewRouter()
+	r.HandleFunc("/tasks/{id}/events", tm.TaskEventsHandler)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/tasks/" + id + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Content-Type %q", got)
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	var got []string
+	for lines.Scan() && len(got) < 2 {
+		got = append(got, lines.Text())
+	}
+	want := []string{"event: status", `data: {"type":"status","status":"created"}`}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("stream starts with %q, want %q", got, want)
+	}
+}
+
+func TestTaskEventsHeartbeat(t *testing.T) {
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.SSEHeartbeatSeconds = 1 })
+	id := createTask(t, tm, `{}`, nil)
+	r := mux.NewRouter()
+	r.HandleFunc("/tasks/{id}/events", tm.TaskEventsHandler)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/tasks/" + id + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// The task stays idle, so the only thing after its status is a ping.
+	done := make(chan string, 1)
+	go func() {
+		lines := bufio.NewScanner(resp.Body)
+		for lines.Scan() {
+			if strings.HasPrefix(lines.Text(), ":") {
+				done <- lines.Text()
+				return
+			}
+		}
+		done <- ""
+	}()
+	select {
+	case got := <-done:
+		if got != ": ping" {
+			t.Fatalf("heartbeat %q, want %q", got, ": ping")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no heartbeat on an idle stream")
+	}
+}
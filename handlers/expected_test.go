@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestExpectedFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected int
+	}{
+		{"below max_files_per_task", 2},
+		{"above max_files_per_task", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := fileServer(t)
+			tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 3 })
+			id := createTask(t, tm, fmt.Sprintf(`{"expected_files": %d}`, tt.expected), nil)
+			for i := range tt.expected - 1 {
+				addFile(t, tm, id, fmt.Sprintf("%s/%d.pdf", files.URL, i))
+			}
+			if status := getStatus(t, tm, id); status.Status != "created" {
+				t.Fatalf("task is %s one file short of expected_files", status.Status)
+			}
+			addFile(t, tm, id, files.URL+"/last.pdf")
+			waitDone(t, tm, id)
+			if n := len(tm.Tasks[id].Files); n != tt.expected {
+				t.Fatalf("archived %d files, want %d", n, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpectedFilesInvalid(t *testing.T) {
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.MinExpectedFiles = 2
+		cfg.MaxExpectedFiles = 10
+	})
+	for _, expected := range []int{1, 11, -1} {
+		w := serve(tm.CreateTaskHandler, http.MethodPost, fmt.Sprintf(`{"expected_files": %d}`, expected), nil, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected_files %d: status %d, want %d", expected, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAddFileURLExpiry(t *testing.T) {
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.URLExpiryMarginSeconds = 600 })
+	id := createTask(t, tm, `{}`, nil)
+	vars := map[string]string{"id": id}
+	signed := func(offset time.Duration) string {
+		return fmt.Sprintf("https://b.s3.amazonaws.com/a.pdf?Expires=%d&Signature=x", time.Now().Add(offset).Unix())
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		wantStatus  int
+		wantWarning bool
+	}{
+		{"expired", signed(-time.Minute), http.StatusBadRequest, false},
+		{"expires soon", signed(time.Minute), http.StatusAccepted, true},
+		{"valid", signed(time.Hour), http.StatusAccepted, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tm.AddFileHandler, http.MethodPost, `{"url": "`+tt.url+`"}`, vars, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusBadRequest && !strings.Contains(w.Body.String(), "URL_EXPIRED") {
+				t.Fatalf("body %q does not name URL_EXPIRED", w.Body)
+			}
+			if got := w.Header().Get("Warning") != ""; got != tt.wantWarning {
+				t.Fatalf("Warning %q, want one: %v", w.Header().Get("Warning"), tt.wantWarning)
+			}
+		})
+	}
+}
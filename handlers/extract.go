@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"archive/zip"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/gorilla/mux"
+)
+
+// ServeArchiveEntryHandler serves a single file from a task's archive
+// @Summary      Download one file from an archive
+// @Description  extracts a single named entry from the task's archive; supports Range requests
+// @Tags         archives
+// @Produce      octet-stream
+// @Param        id     path      string  true  "Task ID"
+// @Param        name   path      string  true  "Entry name inside the archive"
+// @Success      200 {file}  file "Entry contents"
+// @Success      206 {file}  file "Requested range of the entry"
+// @Failure      404 {string} string "entry not found"
+// @Failure      409 {string} string "task is not done or its archive is not a zip"
+// @Failure      410 {string} string "archive has expired"
+// @Router       /tasks/{id}/files/{name} [get]
+func (tm *TaskManager) ServeArchiveEntryHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID, name := vars["id"], vars["name"]
+	tm.requestLogger(r).Debug("ServeArchiveEntryHandler called", "task_id", taskID, "entry", name)
+
+	tm.mutex.Lock()
+	t, ok := tm.Tasks[taskID]
+	tm.mutex.Unlock()
+	if !ok || !tm.ownedBy(r, t) {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+	if !t.State().HasArchive() {
+		http.Error(w, "task is not done", http.StatusConflict)
+		return
+	}
+
+	if tm.taskArchiveExpired(r, t) {
+		http.Error(w, "archive has expired", http.StatusGone)
+		return
+	}
+	if t.Format() != task.FormatZip {
+		http.Error(w, "entries can only be served from zip archives", http.StatusConflict)
+		return
+	}
+	reader, release, err := tm.archiveReaders.acquire(r.Context(), t.ArchiveName())
+	if err != nil && r.Context().Err() != nil {
+		// The client went away while waiting for an extraction slot.
+		return
+	}
+	if err != nil {
+		tm.requestLogger(r).Error("failed to open archive", "task_id", taskID, "error", err)
+		http.Error(w, "archive not found", http.StatusNotFound)
+		return
+	}
+	defer release()
+
+	var entry *zip.File
+	for _, f := range reader.File {
+		if f.Name == name {
+			entry = f
+			break
+		}
+	}
+	if entry == nil || entry.FileInfo().IsDir() {
+		http.Error(w, "entry not found", http.StatusNotFound)
+		return
+	}
+
+	content := &entryReadSeeker{file: entry, size: int64(entry.UncompressedSize64)}
+	defer content.Close()
+	w.Header().Set("Content-Type", entryContentType(name, content, tm.config.SniffEntryContentType))
+	http.ServeContent(w, r, path.Base(name), entry.Modified, content)
+	tm.extendArchiveTTL(t, t.ArchiveName())
+}
+
+// entryContentType picks the Content-Type of the entry called name from
+// its extension. With sniff, entries whose extension is unknown or only maps
+// to application/octet-stream are typed by their first 512 bytes instead;
+// content is rewound afterwards.
+func entryContentType(name string, content io.ReadSeeker, sniff bool) string {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if !sniff || contentType != "application/octet-stream" {
+		return contentType
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if _, seekErr := content.Seek(0, io.SeekStart); seekErr != nil {
+		return contentType
+	}
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return contentType
+	}
+	return http.DetectContentType(head[:n])
+}
+
+// entryReadSeeker adapts a compressed zip entry to io.ReadSeeker. Forward
+// seeks skip decompressed bytes and backward seeks reopen the entry, which
+// is enough for http.ServeContent's single-range requests.
+type entryReadSeeker struct {
+	file  *zip.File
+	size  int64
+	rc    io.ReadCloser
+	pos   int64 // position the caller asked for
+	rcPos int64 // position of rc in the decompressed stream
+}
+
+func (e *entryReadSeeker) Read(p []byte) (int, error) {
+	if e.pos >= e.size {
+		return 0, io.EOF
+	}
+	if e.rc == nil || e.rcPos > e.pos {
+		if err := e.reopen(); err != nil {
+			return 0, err
+		}
+	}
+	if e.rcPos < e.pos {
+		skipped, err := io.CopyN(io.Discard, e.rc, e.pos-e.rcPos)
+		e.rcPos += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := e.rc.Read(p)
+	e.rcPos += int64(n)
+	e.pos = e.rcPos
+	return n, err
+}
+
+func (e *entryReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += e.pos
+	case io.SeekEnd:
+		offset += e.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	e.pos = offset
+	return offset, nil
+}
+
+func (e *entryReadSeeker) reopen() error {
+	e.Close()
+	rc, err := e.file.Open()
+	if err != nil {
+		return err
+	}
+	e.rc, e.rcPos = rc, 0
+	return nil
+}
+
+func (e *entryReadSeeker) Close() error {
+	if e.rc == nil {
+		return nil
+	}
+	err := e.rc.Close()
+	e.rc = nil
+	return err
+}
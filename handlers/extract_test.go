@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestServeArchiveEntry(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	pending := createTask(t, tm, `{}`, nil)
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+
+	const content = "%PDF-1.4 /a.pdf"
+	tests := []struct {
+		name      string
+		taskID    string
+		entry     string
+		rangeSpec string
+		want      int
+		wantBody  string
+	}{
+		{"whole entry", id, "a.pdf", "", http.StatusOK, content},
+		{"range", id, "a.pdf", "bytes=1-3", http.StatusPartialContent, content[1:4]},
+		{"suffix range", id, "a.pdf", "bytes=-5", http.StatusPartialContent, content[len(content)-5:]},
+		{"missing entry", id, "b.pdf", "", http.StatusNotFound, ""},
+		{"task not done", pending, "a.pdf", "", http.StatusConflict, ""},
+		{"unknown task", "missing", "a.pdf", "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{}
+			if tt.rangeSpec != "" {
+				headers["Range"] = tt.rangeSpec
+			}
+			w := serve(tm.ServeArchiveEntryHandler, http.MethodGet, "", map[string]string{"id": tt.taskID, "name": tt.entry}, headers)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Fatalf("body %q, want %q", w.Body, tt.wantBody)
+			}
+			if tt.want == http.StatusOK && w.Header().Get("Content-Type") != "application/pdf" {
+				t.Fatalf("Content-Type %q", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestEntryReadSeeker(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	entry, _ := zipWriter.Create("data.txt")
+	entry.Write([]byte(content))
+	zipWriter.Close()
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &entryReadSeeker{file: r.File[0], size: int64(len(content))}
+	defer e.Close()
+	read := func(offset int64, whence, n int) string {
+		t.Helper()
+		if _, err := e.Seek(offset, whence); err != nil {
+			t.Fatal(err)
+		}
+		p := make([]byte, n)
+		if _, err := io.ReadFull(e, p); err != nil {
+			t.Fatal(err)
+		}
+		return string(p)
+	}
+	// Forward, backward and end-relative seeks all land on the right byte.
+	got := []string{read(10, io.SeekStart, 3), read(2, io.SeekStart, 3), read(-4, io.SeekEnd, 4), read(-10, io.SeekCurrent, 2)}
+	if want := []string{"abc", "234", "ghij", "ab"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("reads %q, want %q", got, want)
+	}
+	if _, err := e.Seek(-1, io.SeekStart); err == nil {
+		t.Fatal("negative seek succeeded")
+	}
+}
+
+func TestEntryContentType(t *testing.T) {
+	const html = "<!DOCTYPE html><html><body>report</body></html>"
+	tests := []struct {
+		name    string
+		entry   string
+		content string
+		sniff   bool
+		want    string
+	}{
+		{"known extension", "a.pdf", html, true, "application/pdf"},
+		{"unknown without sniffing", "report", html, false, "application/octet-stream"},
+		{"unknown sniffed", "report", html, true, "text/html; charset=utf-8"},
+		{"octet-stream extension sniffed", "report.bin", "%PDF-1.4", true, "application/pdf"},
+		{"empty sniffed", "empty", "", true, "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := strings.NewReader(tt.content)
+			if got := entryContentType(tt.entry, content, tt.sniff); got != tt.want {
+				t.Fatalf("entryContentType = %q, want %q", got, tt.want)
+			}
+			// The sniffed bytes are still served.
+			rest, _ := io.ReadAll(content)
+			if string(rest) != tt.content {
+				t.Fatalf("content after sniffing %q, want %q", rest, tt.content)
+			}
+		})
+	}
+}
+
+func TestServeArchiveEntrySniffed(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.MaxFilesPerTask = 1
+		cfg.SniffEntryContentType = true
+	})
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+	const html = "<!DOCTYPE html><html><body>report</body></html>"
+	writeZip(t, id+".zip", "report", html)
+
+	w := serve(tm.ServeArchiveEntryHandler, http.MethodGet, "", map[string]string{"id": id, "name": "report"}, nil)
+	if w.Code != http.StatusOK || w.Body.String() != html {
+		t.Fatalf("status %d, body %q", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Fatalf("Content-Type %q, want the sniffed text/html", got)
+	}
+}
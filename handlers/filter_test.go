@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestCreateTaskPatterns(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, nil)
+	if w := serve(tm.CreateTaskHandler, http.MethodPost, `{"exclude": ["[a-"]}`, nil, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("malformed pattern: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	id := createTask(t, tm, `{"include": ["*.pdf"], "exclude": ["draft-*"]}`, nil)
+	for _, path := range []string{"/report.pdf", "/draft-1.pdf", "/photo.jpg"} {
+		addFile(t, tm, id, files.URL+path)
+	}
+	waitDone(t, tm, id)
+
+	w := serve(tm.GetTaskStatusHandler, http.MethodGet, "", map[string]string{"id": id}, nil)
+	var status struct {
+		Files []struct {
+			Name    string `json:"name"`
+			Skipped string `json:"skipped"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	skipped := make(map[string]string)
+	for _, f := range status.Files {
+		skipped[f.Name] = f.Skipped
+	}
+	want := map[string]string{"report.pdf": "", "draft-1.pdf": "EXCLUDED", "photo.jpg": "EXCLUDED"}
+	for name, code := range want {
+		if got, ok := skipped[name]; !ok || got != code {
+			t.Errorf("%s: skipped %q (listed %v), want %q", name, got, ok, code)
+		}
+	}
+	if entries := readArchive(t, id+".zip"); len(entries) != 1 || entries["report.pdf"] == "" {
+		t.Fatalf("archive entries %v, want only report.pdf", entries)
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// GroupTaskStatus is the status of one member of a group.
+type GroupTaskStatus struct {
+	ID     string      `json:"id"`
+	Status task.Status `json:"status"`
+}
+
+// GroupStatus reports the progress of all tasks in a group.
+type GroupStatus struct {
+	ID        string            `json:"id"`
+	Tasks     []GroupTaskStatus `json:"tasks"`
+	Total     int               `json:"total"`
+	Completed int               `json:"completed"`
+	Complete  bool              `json:"complete"`
+}
+
+// GetGroupStatusHandler returns the status of a task group
+// @Summary      Get group status
+// @Description  reports the status of every task in a group and whether all of them have finished
+// @Tags         groups
+// @Produce      json
+// @Param        id   path      string  true  "Group ID"
+// @Success      200 {object} GroupStatus
+// @Failure      404 {string} string "group not found"
+// @Router       /groups/{id} [get]
+func (tm *TaskManager) GetGroupStatusHandler(w http.ResponseWriter, r *http.Request) {
+	groupID := mux.Vars(r)["id"]
+	tm.requestLogger(r).Debug("GetGroupStatusHandler called", "group_id", groupID)
+
+	summaries, ok := tm.groupSnapshot(r, groupID)
+	if !ok {
+		http.Error(w, "group not found", http.StatusNotFound)
+		return
+	}
+
+	status := GroupStatus{ID: groupID, Tasks: []GroupTaskStatus{}, Total: len(summaries)}
+	for _, summary := range summaries {
+		if summary.Status.Finished() {
+			status.Completed++
+		}
+		status.Tasks = append(status.Tasks, GroupTaskStatus{ID: summary.ID, Status: summary.Status})
+	}
+	status.Complete = status.Completed == status.Total
+
+	tm.writeJSON(w, r, http.StatusOK, status)
+}
+
+// ServeGroupArchiveHandler streams a combined archive of a group
+// @Summary      Download a group archive
+// @Description  streams one zip containing the entries of every done task in the group, each under a folder named after its task ID; entries of tar.gz archives are compressed anew
+// @Tags         groups
+// @Produce      application/zip
+// @Param        id   path      string  true  "Group ID"
+// @Success      200 {file}  file "Combined archive"
+// @Failure      404 {string} string "group not found"
+// @Failure      409 {string} string "no completed tasks in group"
+// @Router       /groups/{id}/archive [get]
+func (tm *TaskManager) ServeGroupArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	groupID := mux.Vars(r)["id"]
+	tm.requestLogger(r).Debug("ServeGroupArchiveHandler called", "group_id", groupID)
+
+	summaries, ok := tm.groupSnapshot(r, groupID)
+	if !ok {
+		http.Error(w, "group not found", http.StatusNotFound)
+		return
+	}
+
+	var done []*task.Task
+	for _, summary := range summaries {
+		if summary.Status.HasArchive() {
+			done = append(done, summary.Task)
+		}
+	}
+	if len(done) == 0 {
+		http.Error(w, "no completed tasks in group", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"group-%s.zip\"", groupID))
+
+	zipWriter := zip.NewWriter(w)
+	for _, t := range done {
+		copyEntries := copyArchiveEntries
+		if t.Format() == task.FormatTarGz {
+			copyEntries = copyTarGzEntries
+		}
+		if err := copyEntries(zipWriter, t.ArchiveName(), t.ID+"/"); err != nil {
+			// Headers are already sent, so the best we can do is log and
+			// cut the stream short.
+			tm.requestLogger(r).Error("failed to add task to group archive", "task_id", t.ID, "group_id", groupID, "error", err)
+			return
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		tm.requestLogger(r).Error("failed to finish group archive", "group_id", groupID, "error", err)
+	}
+}
+
+// copyArchiveEntries copies every entry of the archive at path into dst
+// without recompressing, prefixing entry names with prefix.
+func copyArchiveEntries(dst *zip.Writer, path, prefix string) error {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for _, f := range reader.File {
+		header := f.FileHeader
+		header.Name = prefix + f.Name
+		entry, err := dst.CreateRaw(&header)
+		if err != nil {
+			return err
+		}
+		raw, err := f.OpenRaw()
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(entry, raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyTarGzEntries adds the files of the tar.gz archive at path to dst,
+// which compresses them anew, prefixing entry names with prefix.
+func copyTarGzEntries(dst *zip.Writer, path, prefix string) error {
+	return task.WalkTarGz(path, func(name string, content io.Reader) error {
+		entry, err := dst.Create(prefix + name)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(entry, content)
+		return err
+	})
+}
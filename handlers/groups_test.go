@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestGroupArchive(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	group := map[string]string{"id": "g1"}
+
+	if w := serve(tm.ServeGroupArchiveHandler, http.MethodGet, "", group, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown group: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	createTask(t, tm, `{"group_id": "g1"}`, nil)
+	if w := serve(tm.ServeGroupArchiveHandler, http.MethodGet, "", group, nil); w.Code != http.StatusConflict {
+		t.Fatalf("nothing done: status %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	// The entries of tar.gz members are copied into the zip as well.
+	var done []string
+	for _, member := range []struct{ name, body string }{
+		{"a.pdf", `{"group_id": "g1"}`},
+		{"b.pdf", `{"group_id": "g1", "archive_format": "targz"}`},
+	} {
+		id := createTask(t, tm, member.body, nil)
+		addFile(t, tm, id, files.URL+"/"+member.name)
+		waitDone(t, tm, id)
+		done = append(done, id)
+	}
+	createTask(t, tm, `{"group_id": "g2"}`, nil)
+
+	w := serve(tm.GetGroupStatusHandler, http.MethodGet, "", group, nil)
+	var status GroupStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Total != 3 || status.Completed != 2 || status.Complete {
+		t.Fatalf("group status %+v, want 2 of 3 completed", status)
+	}
+
+	w = serve(tm.ServeGroupArchiveHandler, http.MethodGet, "", group, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("group archive: status %d: %s", w.Code, w.Body)
+	}
+	entries := readZip(t, w.Body.Bytes())
+	want := map[string]string{
+		done[0] + "/a.pdf": "%PDF-1.4 /a.pdf",
+		done[1] + "/b.pdf": "%PDF-1.4 /b.pdf",
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("group archive has %d entries, want %d", len(entries), len(want))
+	}
+	for name, data := range want {
+		if entries[name] != data {
+			t.Errorf("entry %s = %q, want %q", name, entries[name], data)
+		}
+	}
+}
+
+func TestCreateTaskInvalidGroup(t *testing.T) {
+	tm := newTestManager(t, nil)
+	if w := serve(tm.CreateTaskHandler, http.MethodPost, `{"group_id": "../g"}`, nil, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -2,59 +2,218 @@ package handlers
 
 import (
 	"2025-08-02/config"
+	"2025-08-02/metrics"
+	"2025-08-02/queue"
+	"2025-08-02/store"
 	"2025-08-02/task"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	htmltemplate "html/template"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 type TaskManager struct {
 	Tasks              map[string]*task.Task
+	archives           map[string]string // archive file name -> task ID
+	uploads            map[string]*upload
+	groups             map[string][]string // group ID -> member task IDs
+	coalesced          map[string]string   // coalesce key -> leader task ID
+	starting           map[string]bool     // IDs of tasks being started or processed
+	sseSubscribers     int
+	draining           bool           // set by Drain; no new work is accepted
+	inFlight           sync.WaitGroup // running Process and Mirror goroutines
 	mutex              sync.Mutex
 	config             *config.Config
+	env                *task.Env
+	publisher          queue.Publisher
+	deadLetters        queue.DeadLetterSink
+	taskStore          store.TaskStore
+	archiveNameTmpl    *template.Template
+	downloadPage       *htmltemplate.Template
 	concurrentTaskSema chan struct{}
+	archiveReaders     *archiveReaders
+	startedAt          time.Time
+	activeTasks        atomic.Int64 // tasks being processed or mirrored, for HealthHandler
+	prometheus         *metrics.Prometheus
+	logger             *slog.Logger
+	callbackClient     *http.Client // sends completion and progress callbacks
 }
 
-func NewTaskManager(cfg *config.Config) *TaskManager {
-	return &TaskManager{
+func NewTaskManager(cfg *config.Config, logger *slog.Logger) *TaskManager {
+	nameTemplate := cfg.ArchiveNameTemplate
+	if nameTemplate == "" {
+		nameTemplate = config.DefaultArchiveNameTemplate
+	}
+	tm := &TaskManager{
 		Tasks:              make(map[string]*task.Task),
+		archives:           make(map[string]string),
+		uploads:            make(map[string]*upload),
+		groups:             make(map[string][]string),
+		coalesced:          make(map[string]string),
+		starting:           make(map[string]bool),
 		config:             cfg,
+		env:                task.NewEnv(cfg),
+		publisher:          queue.New(cfg),
+		deadLetters:        queue.NewDeadLetterSink(cfg),
+		taskStore:          store.New(cfg),
+		archiveNameTmpl:    template.Must(template.New("archive_name").Parse(nameTemplate)),
+		downloadPage:       loadDownloadPage(cfg.DownloadPageTemplate),
 		concurrentTaskSema: make(chan struct{}, cfg.MaxConcurrentTasks),
+		archiveReaders:     newArchiveReaders(cfg.MaxConcurrentExtractions, cfg.ExtractCacheSize),
+		startedAt:          time.Now(),
+		prometheus:         metrics.NewPrometheus(),
+		logger:             logger,
 	}
+	tm.env.Metrics = metrics.Multi{tm.env.Metrics, tm.prometheus}
+	tm.callbackClient = task.NewCallbackClient(cfg, callbackTimeout, tm.callbackHostAllowed)
+	tm.loadTasks()
+	return tm
+}
+
+// Metrics returns the recorder tasks report their measurements to.
+func (tm *TaskManager) Metrics() metrics.Recorder {
+	return tm.env.Metrics
+}
+
+// MetricsHandler serves the task and file metrics for Prometheus to scrape.
+func (tm *TaskManager) MetricsHandler() http.Handler {
+	return tm.prometheus.Handler()
+}
+
+// CreateTaskRequest is the optional body of CreateTaskHandler.
+type CreateTaskRequest struct {
+	GroupID string `json:"group_id,omitempty"`
+	// ByteBudget and TimeBudgetSeconds override the configured download
+	// budget of the task.
+	ByteBudget        int64 `json:"byte_budget,omitempty"`
+	TimeBudgetSeconds int   `json:"time_budget_seconds,omitempty"`
+	// ModifiedSince (RFC 3339) leaves files last modified before it out of
+	// the archive.
+	ModifiedSince *time.Time `json:"modified_since,omitempty"`
+	// Include and Exclude are glob patterns for entry names; patterns
+	// without a slash match the last element of a name. Exclude wins.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// ExpectedFiles is the number of files the client will add; processing
+	// starts once the task has that many instead of max_files_per_task.
+	ExpectedFiles int `json:"expected_files,omitempty"`
+	// CallbackURL receives a POST with the task's final status once it is
+	// done or failed.
+	CallbackURL string `json:"callback_url,omitempty"`
+	// ProgressCallbackURL receives batched POSTs with the result of each
+	// file while the task is processed.
+	ProgressCallbackURL string `json:"progress_callback_url,omitempty"`
+	// ArchiveFormat is "zip" (the default) or "targz".
+	ArchiveFormat string `json:"archive_format,omitempty" enums:"zip,targz"`
 }
 
 // CreateTaskHandler creates a new task
 // @Summary      Create a new task
-// @Description  creates a new task for archiving files
+// @Description  creates a new task for archiving files, optionally as a member of a group
 // @Tags         tasks
 // @Accept       json
 // @Produce      json
+// @Param        task  body      CreateTaskRequest  false  "Task options"
 // @Success      201 {object} task.Task
-// @Failure      503 {string} string "server is busy, please try again later"
+// @Failure      400 {string} string "invalid request body"
+// @Failure      401 {string} string "missing client identity"
+// @Failure      503 {string} string "server is busy or shutting down"
 // @Router       /tasks [post]
 func (tm *TaskManager) CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("CreateTaskHandler called")
-	if len(tm.concurrentTaskSema) >= tm.config.MaxConcurrentTasks {
-		log.Println("Server is busy")
-		http.Error(w, "server is busy, please try again later", http.StatusServiceUnavailable)
+	tm.requestLogger(r).Debug("CreateTaskHandler called")
+	if tm.isDraining() {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	if !tm.hasFreeTaskSlot() {
+		tm.requestLogger(r).Warn("server is busy")
+		tm.writeBusy(w, errBusy.Error())
+		return
+	}
+
+	clientID, ok := tm.requireClientID(w, r)
+	if !ok {
+		return
+	}
+
+	var body CreateTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		tm.requestLogger(r).Debug("invalid create task body", "error", err)
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if body.GroupID != "" && task.SanitizeFileName(body.GroupID) != body.GroupID {
+		http.Error(w, "invalid group_id", http.StatusBadRequest)
+		return
+	}
+	if body.ByteBudget < 0 || body.TimeBudgetSeconds < 0 {
+		http.Error(w, "invalid budget", http.StatusBadRequest)
+		return
+	}
+	if err := task.ValidatePatterns(append(body.Include, body.Exclude...)); err != nil {
+		http.Error(w, fmt.Sprintf("invalid pattern: %v", err), http.StatusBadRequest)
+		return
+	}
+	if body.ExpectedFiles != 0 && (body.ExpectedFiles < tm.config.MinExpectedFiles || body.ExpectedFiles > tm.config.MaxExpectedFiles) {
+		http.Error(w, fmt.Sprintf("invalid expected_files: must be between %d and %d", tm.config.MinExpectedFiles, tm.config.MaxExpectedFiles), http.StatusBadRequest)
+		return
+	}
+	if body.CallbackURL != "" && !tm.validCallbackURL(body.CallbackURL) {
+		http.Error(w, "invalid callback_url: must be an absolute http or https URL on an allowed host", http.StatusBadRequest)
+		return
+	}
+	if body.ProgressCallbackURL != "" && !tm.validCallbackURL(body.ProgressCallbackURL) {
+		http.Error(w, "invalid progress_callback_url: must be an absolute http or https URL on an allowed host", http.StatusBadRequest)
+		return
+	}
+	if !task.ValidFormat(body.ArchiveFormat) {
+		http.Error(w, "invalid archive_format: must be zip or targz", http.StatusBadRequest)
 		return
 	}
 
 	t := task.NewTask()
-	log.Printf("Created new task with ID: %s", t.ID)
+	t.ClientID = clientID
+	t.SetArchiveDir(tm.config.ArchiveDir)
+	t.GroupID = body.GroupID
+	t.ByteBudget = body.ByteBudget
+	t.TimeBudgetSeconds = body.TimeBudgetSeconds
+	t.ModifiedSince = body.ModifiedSince
+	t.Include = body.Include
+	t.Exclude = body.Exclude
+	t.ExpectedFiles = body.ExpectedFiles
+	t.CallbackURL = body.CallbackURL
+	t.ProgressCallbackURL = body.ProgressCallbackURL
+	t.ArchiveFormat = body.ArchiveFormat
+	t.RequestID = requestIDFrom(r)
+	t.SetLogger(tm.logger)
+	tm.requestLogger(r).Info("created task", "task_id", t.ID)
 	tm.mutex.Lock()
 	tm.Tasks[t.ID] = t
+	if t.GroupID != "" {
+		tm.groups[t.GroupID] = append(tm.groups[t.GroupID], t.ID)
+	}
 	tm.mutex.Unlock()
+	tm.saveTask(t)
+	tm.env.Metrics.Count("tasks.created", 1)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(t)
+	tm.writeJSON(w, r, http.StatusCreated, t)
 }
 
 // AddFileHandler adds a file to a task
@@ -64,58 +223,191 @@ func (tm *TaskManager) CreateTaskHandler(w http.ResponseWriter, r *http.Request)
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "Task ID"
-// @Param        url  body      string  true  "File URL"
+// @Param        url  body      string  true  "File URL, optional entry filename and optional metadata object"
 // @Success      202
-// @Failure      400 {string} string "invalid request body"
+// @Header       202 {string} Warning "set when a presigned URL expires soon"
+// @Failure      400 {string} string "invalid request body, filename or metadata, invalid, blocked, expired or too long URL, host outside allowed_domains, or too many distinct hosts"
 // @Failure      404 {string} string "task not found"
+// @Failure      409 {string} string "task no longer accepts files or already has all its files"
+// @Failure      503 {string} string "server is shutting down, or busy so the task with all its files could not start"
+// @Header       503 {integer} Retry-After "seconds after which to start the task again"
 // @Router       /tasks/{id}/files [post]
 func (tm *TaskManager) AddFileHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	taskID := vars["id"]
-	log.Printf("AddFileHandler called for task ID: %s", taskID)
+	tm.requestLogger(r).Debug("AddFileHandler called", "task_id", taskID)
 
 	tm.mutex.Lock()
 	t, ok := tm.Tasks[taskID]
 	tm.mutex.Unlock()
 
-	if !ok {
-		log.Printf("Task with ID: %s not found", taskID)
+	if !ok || !tm.ownedBy(r, t) {
+		tm.requestLogger(r).Debug("task not found", "task_id", taskID)
 		http.Error(w, "task not found", http.StatusNotFound)
 		return
 	}
+	if tm.isDraining() {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
 
 	var body struct {
-		URL string `json:"url"`
+		URL      string          `json:"url"`
+		Filename string          `json:"filename"`
+		Metadata json.RawMessage `json:"metadata"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		log.Printf("Invalid request body for task ID: %s", taskID)
+		tm.requestLogger(r).Debug("invalid request body", "task_id", taskID)
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Adding file %s to task ID: %s", body.URL, taskID)
-	t.AddFile(body.URL)
+	if string(body.Metadata) == "null" {
+		body.Metadata = nil
+	}
+	if len(body.Metadata) > 0 {
+		if body.Metadata[0] != '{' {
+			http.Error(w, "metadata must be a JSON object", http.StatusBadRequest)
+			return
+		}
+		if len(body.Metadata) > tm.config.MaxFileMetadataBytes {
+			http.Error(w, fmt.Sprintf("metadata is %d bytes, over the %d byte limit", len(body.Metadata), tm.config.MaxFileMetadataBytes), http.StatusBadRequest)
+			return
+		}
+	}
 
-	if len(t.FileURLs) >= tm.config.MaxFilesPerTask {
-		log.Printf("Task %s reached max files, starting processing", taskID)
-		t.SetResultURL()
-		tm.concurrentTaskSema <- struct{}{}
-		go func() {
-			defer func() { <-tm.concurrentTaskSema }()
-			t.Process(tm.config.AllowedExtensions)
-		}()
+	// Only the last element of the name is kept, so it cannot lead out
+	// of the archive.
+	filename := task.SanitizeFileName(path.Base(strings.ReplaceAll(body.Filename, `\`, "/")))
+	if body.Filename != "" && filename == "" {
+		http.Error(w, fmt.Sprintf("invalid filename %q", body.Filename), http.StatusBadRequest)
+		return
+	}
+
+	if len(body.URL) > tm.config.MaxURLLength {
+		http.Error(w, fmt.Sprintf("URL is %d characters, over the %d character limit", len(body.URL), tm.config.MaxURLLength), http.StatusBadRequest)
+		return
+	}
+	if fe := task.CheckSourceURL(r.Context(), body.URL, tm.config); fe != nil {
+		tm.requestLogger(r).Warn("rejected file URL", "task_id", taskID, "file_url", body.URL, "code", fe.Code, "error", fe.Message)
+		http.Error(w, fmt.Sprintf("%s: %s", fe.Code, fe.Message), http.StatusBadRequest)
+		return
+	}
+
+	expiry, presigned := task.URLExpiry(body.URL)
+	if presigned && !time.Now().Before(expiry) {
+		tm.requestLogger(r).Warn("rejected expired file URL", "task_id", taskID, "file_url", body.URL, "expired_at", expiry)
+		http.Error(w, fmt.Sprintf("%s: URL expired at %s", task.CodeURLExpired, expiry.UTC().Format(time.RFC3339)), http.StatusBadRequest)
+		return
+	}
+
+	tm.requestLogger(r).Info("adding file", "task_id", taskID, "file_url", body.URL)
+	if err := t.AddFile(body.URL, filename, body.Metadata, tm.config.MaxFilesPerTask, tm.config.MaxDistinctHostsPerTask); err != nil {
+		tm.requestLogger(r).Warn("rejected file", "task_id", taskID, "file_url", body.URL, "error", err)
+		if errors.Is(err, task.ErrTooManyHosts) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, task.ErrTaskFull) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, fmt.Sprintf("task is %s and no longer accepts files", t.State()), http.StatusConflict)
+		return
+	}
+	tm.saveTask(t)
+	tm.env.Metrics.Count("files.added", 1)
+	t.Prefetch(tm.env, body.URL)
+
+	if presigned && time.Until(expiry) < time.Duration(tm.config.URLExpiryMarginSeconds)*time.Second {
+		w.Header().Set("Warning", fmt.Sprintf(`299 - "URL expires at %s and may be expired by the time it is downloaded"`, expiry.UTC().Format(time.RFC3339)))
+	}
+	if err := tm.maybeStartProcessing(t); errors.Is(err, errBusy) {
+		tm.writeBusy(w, fmt.Sprintf("file was added, but %v; start the task with POST /tasks/%s/process", err, t.ID))
+		return
 	}
 
 	w.WriteHeader(http.StatusAccepted)
 }
 
+// maybeStartProcessing launches processing once t has reached its
+// expected number of files, or the configured number without one. It
+// returns why a task with all its files did not start.
+func (tm *TaskManager) maybeStartProcessing(t *task.Task) error {
+	if !t.HasAllFiles(tm.config.MaxFilesPerTask) {
+		return nil
+	}
+	tm.logger.Info("task has all its files, starting processing", "task_id", t.ID)
+	err := tm.startProcessing(t)
+	if err != nil {
+		tm.logger.Warn("not starting task", "task_id", t.ID, "error", err)
+	}
+	return err
+}
+
+// ListTasksHandler lists the tasks of the server
+// @Summary      List tasks
+// @Description  returns the tasks of the requesting client ordered by ID, optionally filtered by status and paged with limit and offset; X-Total-Count holds the number of matching tasks
+// @Tags         tasks
+// @Produce      json
+// @Param        status  query     string  false  "Only tasks with this status"  Enums(created, processing, done, partial, error, canceled)
+// @Param        limit   query     int     false  "Maximum number of tasks (default all)"
+// @Param        offset  query     int     false  "Number of matching tasks to skip"
+// @Success      200 {array}  task.Task
+// @Header       200 {int} X-Total-Count "number of matching tasks before paging"
+// @Failure      400 {string} string "invalid status, limit or offset"
+// @Router       /tasks [get]
+func (tm *TaskManager) ListTasksHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	status := task.Status(query.Get("status"))
+	switch status {
+	case "", task.StatusCreated, task.StatusProcessing, task.StatusDone, task.StatusPartial, task.StatusError, task.StatusCanceled:
+	default:
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	limit, offset := 0, 0
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	if value := query.Get("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	tasks := []*task.Task{}
+	for _, summary := range tm.snapshot() {
+		if (status == "" || summary.Status == status) && tm.ownedBy(r, summary.Task) {
+			tasks = append(tasks, summary.Task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(tasks)))
+	tasks = tasks[min(offset, len(tasks)):]
+	if limit > 0 && limit < len(tasks) {
+		tasks = tasks[:limit]
+	}
+	tm.writeJSON(w, r, http.StatusOK, tasks)
+}
+
 // GetTaskStatusHandler returns the status of a task
 // @Summary      Get task status
-// @Description  get the status of a task by ID
+// @Description  get the status of a task by ID, as JSON or as protobuf (taskpb.Task) when Accept is application/x-protobuf
 // @Tags         tasks
 // @Accept       json
 // @Produce      json
+// @Produce      application/x-protobuf
 // @Param        id   path      string  true  "Task ID"
 // @Success      200 {object} task.Task
 // @Failure      404 {string} string "task not found"
@@ -123,52 +415,311 @@ func (tm *TaskManager) AddFileHandler(w http.ResponseWriter, r *http.Request) {
 func (tm *TaskManager) GetTaskStatusHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	taskID := vars["id"]
-	log.Printf("GetTaskStatusHandler called for task ID: %s", taskID)
+	tm.requestLogger(r).Debug("GetTaskStatusHandler called", "task_id", taskID)
 
 	tm.mutex.Lock()
 	t, ok := tm.Tasks[taskID]
 	tm.mutex.Unlock()
 
-	if !ok {
-		log.Printf("Task with ID: %s not found", taskID)
+	if !ok || !tm.ownedBy(r, t) {
+		tm.requestLogger(r).Debug("task not found", "task_id", taskID)
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+
+	if acceptsProtobuf(r) {
+		writeProtobuf(w, t.ToProto())
+		return
+	}
+
+	tm.writeJSON(w, r, http.StatusOK, t)
+}
+
+// GetTaskProgressHandler returns the progress counters of a task
+// @Summary      Get task progress
+// @Description  returns file and byte counters of a task, updated live while its files download
+// @Tags         tasks
+// @Produce      json
+// @Param        id   path      string  true  "Task ID"
+// @Success      200 {object} task.Progress
+// @Failure      404 {string} string "task not found"
+// @Router       /tasks/{id}/progress [get]
+func (tm *TaskManager) GetTaskProgressHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+
+	tm.mutex.Lock()
+	t, ok := tm.Tasks[taskID]
+	tm.mutex.Unlock()
+	if !ok || !tm.ownedBy(r, t) {
 		http.Error(w, "task not found", http.StatusNotFound)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(t)
+	tm.writeJSON(w, r, http.StatusOK, t.Progress())
 }
 
-// ServeArchiveHandler serves the archived zip file
+// ServeArchiveHandler serves the archived file
 // @Summary      Download an archived file
-// @Description  downloads the zip file for a given task ID
+// @Description  downloads the zip or tar.gz file for a given task ID
 // @Tags         archives
 // @Produce      application/zip
-// @Param        filename   path      string  true  "Archive filename (e.g., taskID.zip)"
+// @Produce      application/gzip
+// @Param        filename   path      string  true  "Archive filename as returned in result_url (e.g., taskID.zip)"
 // @Success      200 {file}  file "Archive file"
+// @Header       200 {string} Digest "sha-256 digest of the archive, when checksums are enabled"
+// @Header       200 {string} ETag "quoted hex SHA-256 of the archive, when checksums are enabled"
+// @Header       200 {string} Signature "base64 Ed25519ph signature of the archive, when signing is enabled"
+// @Failure      401 {string} string "missing client identity"
 // @Failure      404 {string} string "archive not found"
+// @Success      304 "archive matches If-None-Match"
+// @Failure      410 {string} string "archive has expired"
 // @Router       /archives/{filename} [get]
 func (tm *TaskManager) ServeArchiveHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	filename := vars["filename"]
-	log.Printf("ServeArchiveHandler called for filename: %s", filename)
+	tm.requestLogger(r).Debug("ServeArchiveHandler called", "filename", filename)
 
 	// Basic security check to prevent directory traversal
 	if strings.Contains(filename, "..") || strings.Contains(filename, "/") {
 		http.Error(w, "invalid filename", http.StatusBadRequest)
 		return
 	}
+	clientID, ok := tm.requireClientID(w, r)
+	if !ok {
+		return
+	}
 
-	filePath := filename // Assuming archives are in the current directory
+	tm.serveArchive(w, r, filepath.Join(tm.config.ArchiveDir, clientArchiveDir(clientID), filename))
+}
+
+// ServeTaskArchiveHandler serves the archive of a task
+// @Summary      Download a task's archive
+// @Description  serves the zip or tar.gz archive of a finished task by task ID
+// @Tags         archives
+// @Produce      application/zip
+// @Produce      application/gzip
+// @Param        id   path      string  true  "Task ID"
+// @Success      200 {file}  file "Archive"
+// @Failure      404 {string} string "task not found"
+// @Failure      409 {string} string "task is not done"
+// @Failure      410 {string} string "archive has expired"
+// @Router       /tasks/{id}/archive [get]
+func (tm *TaskManager) ServeTaskArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+	tm.requestLogger(r).Debug("ServeTaskArchiveHandler called", "task_id", taskID)
 
-	_, err := os.Stat(filePath)
+	tm.mutex.Lock()
+	t, ok := tm.Tasks[taskID]
+	tm.mutex.Unlock()
+	if !ok || !tm.ownedBy(r, t) {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+	if !t.State().HasArchive() {
+		http.Error(w, "task is not done", http.StatusConflict)
+		return
+	}
+	tm.serveArchive(w, r, t.ArchiveName())
+}
+
+// serveArchive sends the archive at filePath with its digest and signature
+// headers.
+func (tm *TaskManager) serveArchive(w http.ResponseWriter, r *http.Request, filePath string) {
+	info, err := os.Stat(filePath)
+	t := tm.taskByArchive(filePath)
 	if os.IsNotExist(err) {
-		log.Printf("Archive file %s not found", filePath)
+		tm.requestLogger(r).Warn("archive file not found", "path", filePath)
+		if t != nil && t.IsArchiveExpired() {
+			http.Error(w, "archive has expired", http.StatusGone)
+			return
+		}
 		http.Error(w, "archive not found", http.StatusNotFound)
 		return
 	}
+	if err == nil && tm.archiveTooOld(r, filePath, info) {
+		http.Error(w, "archive has expired", http.StatusGone)
+		return
+	}
+
+	if t != nil {
+		if checksum := t.ArchiveChecksum(); checksum != "" {
+			if digest := digestHeader(checksum); digest != "" {
+				w.Header().Set("Digest", digest)
+			}
+			// http.ServeFile answers a matching If-None-Match with 304.
+			w.Header().Set("ETag", `"`+checksum+`"`)
+		}
+		if signature := t.Signature(); signature != nil {
+			w.Header().Set("Signature", base64.StdEncoding.EncodeToString(signature))
+		}
+	}
 
-	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	format, _ := task.FormatOf(filePath)
+	w.Header().Set("Content-Type", task.FormatContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filepath.Base(filePath)))
 	http.ServeFile(w, r, filePath)
+	tm.extendArchiveTTL(t, filePath)
+}
+
+// archiveTooOld reports whether the archive at filePath, described by info,
+// is past its maximum age. Such archives are gone even if the cleanup sweep
+// has not removed them yet; with delete_expired_on_serve the file is
+// removed right away.
+func (tm *TaskManager) archiveTooOld(r *http.Request, filePath string, info os.FileInfo) bool {
+	if time.Since(info.ModTime()) <= time.Duration(tm.config.ArchiveMaxAgeSeconds)*time.Second {
+		return false
+	}
+	tm.requestLogger(r).Info("archive file has expired", "path", filePath)
+	if tm.config.DeleteExpiredOnServe {
+		if os.Remove(filePath) == nil {
+			tm.ArchiveRemoved(filePath)
+		}
+	}
+	return true
+}
+
+// taskArchiveExpired reports whether the archive of t has been removed by
+// the cleanup sweep or is past its maximum age, applying the same check as
+// serveArchive to handlers that read the archive themselves.
+func (tm *TaskManager) taskArchiveExpired(r *http.Request, t *task.Task) bool {
+	if t.IsArchiveExpired() {
+		return true
+	}
+	info, err := os.Stat(t.ArchiveName())
+	return err == nil && tm.archiveTooOld(r, t.ArchiveName(), info)
+}
+
+// extendArchiveTTL restarts the expiry clock of the archive at filePath
+// after it was served, if extend_ttl_on_access is set. The cleanup sweep
+// goes by the file's modification time, so the file is touched and the
+// new expiry recorded on t, which may be nil.
+func (tm *TaskManager) extendArchiveTTL(t *task.Task, filePath string) {
+	if !tm.config.ExtendTTLOnAccess {
+		return
+	}
+	now := time.Now()
+	if err := os.Chtimes(filePath, now, now); err != nil {
+		tm.logger.Error("failed to extend archive lifetime", "path", filePath, "error", err)
+		return
+	}
+	if t != nil {
+		t.SetExpiresAt(now.Add(time.Duration(tm.config.ArchiveMaxAgeSeconds) * time.Second))
+	}
+}
+
+// publishCompletion sends the final state of a processed task to the
+// configured publisher and the task's callback URL. Failures are logged,
+// never surfaced to the task.
+func (tm *TaskManager) publishCompletion(t *task.Task) {
+	result := t.Result()
+	completion := queue.Completion{
+		TaskID:       t.ID,
+		Status:       string(result.Status),
+		ResultURL:    result.ResultURL,
+		Checksum:     result.ResultChecksum,
+		ErrorDetails: result.ErrorDetails,
+	}
+	if err := tm.publisher.PublishCompletion(completion); err != nil {
+		tm.logger.Error("failed to publish task completion", "task_id", t.ID, "error", err)
+	}
+	if t.CallbackURL != "" {
+		tm.notifyCallback(t.CallbackURL, completion)
+	}
+	if result.Status == task.StatusError {
+		tm.recordDeadLetter(t.ID, result)
+	}
+}
+
+// recordDeadLetter stores a failed task in the dead-letter sink.
+func (tm *TaskManager) recordDeadLetter(taskID string, result task.Result) {
+	codes := make([]string, 0, len(result.Errors))
+	for _, e := range result.Errors {
+		codes = append(codes, string(e.Code))
+	}
+	err := tm.deadLetters.RecordDeadLetter(queue.DeadLetter{
+		TaskID:       taskID,
+		URLs:         result.FileURLs,
+		ErrorCodes:   codes,
+		ErrorDetails: result.ErrorDetails,
+		FailedAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		tm.logger.Error("failed to record dead letter", "task_id", taskID, "error", err)
+	}
+}
+
+// taskByArchive returns the task that produced the given archive filename,
+// or nil if it is unknown.
+func (tm *TaskManager) taskByArchive(filename string) *task.Task {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	return tm.Tasks[tm.archives[filename]]
+}
+
+// assignArchiveName renders the configured name template for t, places it
+// in the client's namespace below the archive directory, makes the result
+// unique among known and on-disk archives and reserves it.
+func (tm *TaskManager) assignArchiveName(t *task.Task) {
+	format := t.Format()
+	name, err := task.RenderArchiveName(tm.archiveNameTmpl, t.ID, format, time.Now())
+	if err != nil {
+		tm.logger.Error("failed to render archive name", "task_id", t.ID, "error", err)
+		name = t.ID + task.FormatExtension(format)
+	}
+
+	name = filepath.Join(tm.config.ArchiveDir, clientArchiveDir(t.ClientID), name)
+
+	tm.mutex.Lock()
+	name = tm.uniqueArchiveName(name)
+	tm.archives[name] = t.ID
+	tm.mutex.Unlock()
+
+	t.SetArchiveName(name)
+}
+
+// releaseArchiveName drops the reservation assignArchiveName made for t,
+// so the name is free again when t never gets to write its archive.
+func (tm *TaskManager) releaseArchiveName(t *task.Task) {
+	name := t.ArchiveName()
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	if tm.archives[name] == t.ID {
+		delete(tm.archives, name)
+	}
+}
+
+// uniqueArchiveName appends "-N" before the extension, ".tar.gz" as a
+// whole, until name collides with neither a reserved archive nor an
+// existing file. Callers must hold tm.mutex.
+func (tm *TaskManager) uniqueArchiveName(name string) string {
+	ext := filepath.Ext(name)
+	if format, ok := task.FormatOf(name); ok {
+		ext = task.FormatExtension(format)
+	}
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 1; tm.archiveNameTaken(candidate); i++ {
+		candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+	return candidate
+}
+
+func (tm *TaskManager) archiveNameTaken(name string) bool {
+	if _, ok := tm.archives[name]; ok {
+		return true
+	}
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// digestHeader converts a hex-encoded SHA-256 into an RFC 3230 Digest value.
+func digestHeader(checksum string) string {
+	if checksum == "" {
+		return ""
+	}
+	sum, err := hex.DecodeString(checksum)
+	if err != nil {
+		return ""
+	}
+	return "sha-256=" + base64.StdEncoding.EncodeToString(sum)
 }
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/task"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// testConfig is the smallest config file LoadConfig accepts.
+const testConfig = `{"port": "8080", "allowed_extensions": [".pdf", ".jpg"], "max_files_per_task": 3, "max_concurrent_tasks": 3}`
+
+// newTestManager returns a TaskManager with the default configuration,
+// running in a temporary directory that receives the archives. configure,
+// if not nil, can adjust the configuration first.
+func newTestManager(t *testing.T, configure func(*config.Config)) *TaskManager {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(path, []byte(testConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadConfig(path, "")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	t.Chdir(dir)
+	return NewTaskManager(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// serve runs handler on a request with the given method, body, path
+// variables and headers and returns the recorded response.
+func serve(handler http.HandlerFunc, method, body string, vars, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", strings.NewReader(body))
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// serveURL is serve for a GET of target, which may carry a query string.
+func serveURL(handler http.HandlerFunc, target string, vars, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	r = mux.SetURLVars(r, vars)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// createTask creates a task with body as headers' client and returns its ID.
+func createTask(t *testing.T, tm *TaskManager, body string, headers map[string]string) string {
+	t.Helper()
+	w := serve(tm.CreateTaskHandler, http.MethodPost, body, nil, headers)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create task: status %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	return created.ID
+}
+
+// addFile adds url to the task and fails t unless it is accepted.
+func addFile(t *testing.T, tm *TaskManager, id, url string) {
+	t.Helper()
+	w := serve(tm.AddFileHandler, http.MethodPost, `{"url": "`+url+`"}`, map[string]string{"id": id}, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("add file %s: status %d: %s", url, w.Code, w.Body)
+	}
+}
+
+// taskStatus is the part of a task's status response the tests look at.
+type taskStatus struct {
+	Status         string `json:"status"`
+	ResultURL      string `json:"result_url"`
+	ResultChecksum string `json:"result_checksum"`
+	ErrorDetails   string `json:"error_details"`
+}
+
+// getStatus returns the status of the task with the given ID.
+func getStatus(t *testing.T, tm *TaskManager, id string) taskStatus {
+	t.Helper()
+	w := serve(tm.GetTaskStatusHandler, http.MethodGet, "", map[string]string{"id": id}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("get status: status %d: %s", w.Code, w.Body)
+	}
+	var status taskStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("get status: %v", err)
+	}
+	return status
+}
+
+// waitFinished waits for the task to end up done or failed and returns its
+// final status.
+func waitFinished(t *testing.T, tm *TaskManager, id string) taskStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	status := getStatus(t, tm, id)
+	for !task.Status(status.Status).Finished() {
+		if time.Now().After(deadline) {
+			t.Fatalf("task is still %s", status.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+		status = getStatus(t, tm, id)
+	}
+	return status
+}
+
+// waitDone waits for the task to finish and fails t unless it ends up done.
+func waitDone(t *testing.T, tm *TaskManager, id string) taskStatus {
+	t.Helper()
+	status := waitFinished(t, tm, id)
+	if status.Status != "done" {
+		t.Fatalf("task is %s (%s), want done", status.Status, status.ErrorDetails)
+	}
+	return status
+}
+
+// waitIdle waits until no task holds a processing slot, which every task
+// releases only after its completion work is done.
+func waitIdle(t *testing.T, tm *TaskManager) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(tm.concurrentTaskSema) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("tasks still running")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// fileServer returns a server answering every path with a small PDF body.
+func fileServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	t.Cleanup(files.Close)
+	return files
+}
+
+// readArchive returns the entries of the zip archive at path by name.
+func readArchive(t *testing.T, path string) map[string]string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	return readZip(t, data)
+}
+
+// readZip returns the entries of a zip archive by name.
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("open archive: %v", err)
+	}
+	entries := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", f.Name, err)
+		}
+		entries[f.Name] = string(data)
+	}
+	return entries
+}
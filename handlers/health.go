@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+)
+
+// Health is the body of a health check response.
+type Health struct {
+	Status        string  `json:"status"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+	// ActiveTasks counts the tasks being processed or sharing the run of
+	// an identical task.
+	ActiveTasks int64 `json:"active_tasks"`
+	// TaskSlotsUsed of TaskSlots max_concurrent_tasks slots are taken.
+	TaskSlotsUsed int `json:"task_slots_used"`
+	TaskSlots     int `json:"task_slots"`
+}
+
+// HealthHandler reports that the server is up
+// @Summary      Health check
+// @Description  liveness and readiness probe; reports uptime, active tasks and task slot usage without taking locks
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} Health
+// @Router       /healthz [get]
+func (tm *TaskManager) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	tm.writeJSON(w, r, http.StatusOK, Health{
+		Status:        "ok",
+		UptimeSeconds: time.Since(tm.startedAt).Seconds(),
+		ActiveTasks:   tm.activeTasks.Load(),
+		TaskSlotsUsed: len(tm.concurrentTaskSema),
+		TaskSlots:     cap(tm.concurrentTaskSema),
+	})
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestHealthHandler(t *testing.T) {
+	tm := newTestManager(t, nil)
+	tm.concurrentTaskSema <- struct{}{}
+	defer func() { <-tm.concurrentTaskSema }()
+
+	w := serve(tm.HealthHandler, http.MethodGet, "", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	var health Health
+	if err := json.Unmarshal(w.Body.Bytes(), &health); err != nil {
+		t.Fatal(err)
+	}
+	if health.Status != "ok" || health.ActiveTasks != 0 || health.TaskSlotsUsed != 1 || health.TaskSlots != tm.config.MaxConcurrentTasks {
+		t.Fatalf("health %+v", health)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// listTasks serves GET target and returns the IDs of the listed tasks and
+// the X-Total-Count header.
+func listTasks(t *testing.T, tm *TaskManager, target string) ([]string, string) {
+	t.Helper()
+	w := serveURL(tm.ListTasksHandler, target, nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list %s: status %d: %s", target, w.Code, w.Body)
+	}
+	var tasks []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &tasks); err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, tk := range tasks {
+		ids = append(ids, tk.ID)
+	}
+	return ids, w.Header().Get("X-Total-Count")
+}
+
+func TestListTasks(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, nil)
+	var ids []string
+	for range 3 {
+		ids = append(ids, createTask(t, tm, `{}`, nil))
+	}
+	done := ids[0]
+	for _, path := range []string{"/a.pdf", "/b.pdf", "/c.pdf"} {
+		addFile(t, tm, done, files.URL+path)
+	}
+	waitDone(t, tm, done)
+	sort.Strings(ids)
+	created := slices.DeleteFunc(slices.Clone(ids), func(id string) bool { return id == done })
+
+	tests := []struct {
+		target    string
+		want      []string
+		wantTotal string
+	}{
+		{"/tasks", ids, "3"},
+		{"/tasks?limit=2", ids[:2], "3"},
+		{"/tasks?limit=2&offset=2", ids[2:], "3"},
+		{"/tasks?offset=5", []string{}, "3"},
+		{"/tasks?status=created", created, "2"},
+		{"/tasks?status=done", []string{done}, "1"},
+		{"/tasks?status=error", []string{}, "0"},
+		{"/tasks?status=partial", []string{}, "0"},
+	}
+	for _, tt := range tests {
+		got, total := listTasks(t, tm, tt.target)
+		if !slices.Equal(got, tt.want) || total != tt.wantTotal {
+			t.Errorf("%s: listed %q of %s, want %q of %s", tt.target, got, total, tt.want, tt.wantTotal)
+		}
+	}
+
+	for _, target := range []string{"/tasks?status=finished", "/tasks?limit=0", "/tasks?limit=x", "/tasks?offset=-1"} {
+		if w := serveURL(tm.ListTasksHandler, target, nil, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", target, w.Code, http.StatusBadRequest)
+		}
+	}
+}
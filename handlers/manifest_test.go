@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/task"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, nil)
+	id := createTask(t, tm, `{}`, nil)
+	vars := map[string]string{"id": id}
+	add := func(body string) {
+		t.Helper()
+		if w := serve(tm.AddFileHandler, http.MethodPost, body, vars, nil); w.Code != http.StatusAccepted {
+			t.Fatalf("add %s: status %d: %s", body, w.Code, w.Body)
+		}
+	}
+	add(`{"url": "` + files.URL + `/a.pdf", "metadata": {"invoice": 17, "tags": ["q3"]}}`)
+	add(`{"url": "` + files.URL + `/b.pdf", "metadata": null}`)
+	add(`{"url": "` + files.URL + `/c.pdf"}`)
+	waitDone(t, tm, id)
+
+	var m struct {
+		TaskID  string `json:"task_id"`
+		Entries []struct {
+			Name     string          `json:"name"`
+			Size     int64           `json:"size"`
+			Metadata json.RawMessage `json:"metadata"`
+		} `json:"entries"`
+	}
+	entries := readArchive(t, id+".zip")
+	if err := json.Unmarshal([]byte(entries[task.ManifestEntryName]), &m); err != nil {
+		t.Fatalf("manifest %q: %v", entries[task.ManifestEntryName], err)
+	}
+	if m.TaskID != id || len(m.Entries) != 3 {
+		t.Fatalf("manifest %+v, want 3 entries of task %s", m, id)
+	}
+	var got bytes.Buffer
+	json.Compact(&got, m.Entries[0].Metadata)
+	if m.Entries[0].Name != "a.pdf" || got.String() != `{"invoice":17,"tags":["q3"]}` {
+		t.Fatalf("first entry %s carries %s", m.Entries[0].Name, got.String())
+	}
+	if m.Entries[1].Metadata != nil || m.Entries[2].Metadata != nil || m.Entries[1].Size == 0 {
+		t.Fatalf("entries without metadata: %+v", m.Entries[1:])
+	}
+}
+
+func TestManifestOnlyWithMetadata(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+	if entries := readArchive(t, id+".zip"); len(entries) != 1 {
+		t.Fatalf("entries %v, want no manifest", entries)
+	}
+}
+
+func TestAddFileInvalidMetadata(t *testing.T) {
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFileMetadataBytes = 32 })
+	id := createTask(t, tm, `{}`, nil)
+	vars := map[string]string{"id": id}
+	for _, metadata := range []string{`["not", "an", "object"]`, `"text"`, `{"note": "` + strings.Repeat("x", 32) + `"}`} {
+		body := `{"url": "https://example.com/a.pdf", "metadata": ` + metadata + `}`
+		if w := serve(tm.AddFileHandler, http.MethodPost, body, vars, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("metadata %s: status %d, want %d", metadata, w.Code, http.StatusBadRequest)
+		}
+	}
+}
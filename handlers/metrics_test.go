@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+
+	w := httptest.NewRecorder()
+	tm.MetricsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		"archiver_tasks_created_total 1",
+		"archiver_tasks_completed_total 1",
+		"archiver_tasks_in_flight 0",
+		"archiver_files_added_total 1",
+		"archiver_files_downloaded_total 1",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics have no line %q", want)
+		}
+	}
+}
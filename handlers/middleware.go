@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the ID of a request in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// APIKeyHeader carries the client's API key.
+const APIKeyHeader = "X-API-Key"
+
+// unauthenticatedPrefixes are the paths of the router served without an
+// API key. Health checks are served outside the router.
+var unauthenticatedPrefixes = []string{"/swagger/"}
+
+type requestIDKey struct{}
+
+// RequestID tags every request with an ID, taken from the X-Request-ID
+// header when the client sent one, and echoes it in the response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = uuid.New().String()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestIDFrom returns the ID assigned by RequestID, if any.
+func requestIDFrom(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestLogger returns the logger for records about r, which carry its
+// request ID.
+func (tm *TaskManager) requestLogger(r *http.Request) *slog.Logger {
+	if id := requestIDFrom(r); id != "" {
+		return tm.logger.With("request_id", id)
+	}
+	return tm.logger
+}
+
+// APIKey answers 401 to requests whose X-API-Key header is not one of
+// keys, except for the API documentation. Without keys every request is
+// let through.
+func APIKey(keys []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(keys) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, prefix := range unauthenticatedPrefixes {
+				if strings.HasPrefix(r.URL.Path, prefix) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			if !validAPIKey(keys, r.Header.Get(APIKeyHeader)) {
+				slog.Warn("rejected request with missing or invalid API key", "method", r.Method, "path", r.URL.Path, "request_id", requestIDFrom(r))
+				http.Error(w, "missing or invalid API key", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// validAPIKey compares key with every configured key in constant time.
+func validAPIKey(keys []string, key string) bool {
+	valid := false
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return key != "" && valid
+}
+
+// Recover turns a panic in next into a logged stack trace and a JSON 500,
+// so a programming error fails one request instead of the connection.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// ErrAbortHandler is net/http's way to abort a response on
+			// purpose and must keep propagating.
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			slog.Error("panic serving request", "method", r.Method, "path", r.URL.Path, "request_id", requestIDFrom(r), "error", err, "stack", string(debug.Stack()))
+			writeJSONError(w, http.StatusInternalServerError, "internal server error")
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ResponseHeaders sets headers on every response, e.g. security headers
+// such as X-Content-Type-Options.
+func ResponseHeaders(headers map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(headers) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for name, value := range headers {
+				w.Header().Set(name, value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// exposedHeaders are the response headers cross-origin clients may read.
+const exposedHeaders = "Digest, ETag, Signature, Location, Upload-Offset, Upload-Length, Tus-Resumable, X-Request-ID, Warning, X-Total-Count"
+
+// CORS allows cross-origin requests from allowedOrigins ("*" for any) using
+// allowedMethods, and answers their preflight requests. Requests from other
+// origins are passed through without CORS headers.
+func CORS(allowedOrigins, allowedMethods []string) func(http.Handler) http.Handler {
+	methods := strings.Join(allowedMethods, ", ")
+	return func(next http.Handler) http.Handler {
+		if len(allowedOrigins) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+			if origin == "" || !(slices.Contains(allowedOrigins, "*") || slices.Contains(allowedOrigins, origin)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", exposedHeaders)
+
+			// A preflight carries Access-Control-Request-Method, which
+			// tells it apart from a plain OPTIONS such as tus discovery.
+			requested := r.Header.Get("Access-Control-Request-Method")
+			if r.Method != http.MethodOptions || requested == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !slices.Contains(allowedMethods, requested) {
+				http.Error(w, "method not allowed by CORS policy", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+			}
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
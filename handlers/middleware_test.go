@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	w := httptest.NewRecorder()
+	Recover(panicking).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+		t.Fatalf("Content-Type %q, want JSON", got)
+	}
+}
+
+func TestRecoverKeepsAbortHandler(t *testing.T) {
+	aborting := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", err)
+		}
+	}()
+	Recover(aborting).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/tasks", nil))
+}
+
+func TestCORS(t *testing.T) {
+	methods := []string{http.MethodGet, http.MethodPost}
+	tests := []struct {
+		name       string
+		origins    []string
+		origin     string
+		method     string
+		requested  string
+		wantStatus int
+		wantOrigin string
+	}{
+		{"disabled", nil, "https://app.example.com", http.MethodGet, "", http.StatusOK, ""},
+		{"allowed origin", []string{"https://app.example.com"}, "https://app.example.com", http.MethodGet, "", http.StatusOK, "https://app.example.com"},
+		{"any origin", []string{"*"}, "https://other.example.com", http.MethodGet, "", http.StatusOK, "https://other.example.com"},
+		{"other origin", []string{"https://app.example.com"}, "https://evil.example.com", http.MethodGet, "", http.StatusOK, ""},
+		{"preflight", []string{"*"}, "https://app.example.com", http.MethodOptions, http.MethodPost, http.StatusNoContent, "https://app.example.com"},
+		{"preflight of other method", []string{"*"}, "https://app.example.com", http.MethodOptions, http.MethodDelete, http.StatusForbidden, "https://app.example.com"},
+		{"plain options", []string{"*"}, "https://app.example.com", http.MethodOptions, "", http.StatusOK, "https://app.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/tasks", nil)
+			r.Header.Set("Origin", tt.origin)
+			if tt.requested != "" {
+				r.Header.Set("Access-Control-Request-Method", tt.requested)
+			}
+			w := httptest.NewRecorder()
+			CORS(tt.origins, methods)(okHandler).ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Fatalf("Access-Control-Allow-Origin %q, want %q", got, tt.wantOrigin)
+			}
+		})
+	}
+}
+
+func TestResponseHeaders(t *testing.T) {
+	headers := map[string]string{"X-Content-Type-Options": "nosniff", "Cache-Control": "no-store"}
+	w := httptest.NewRecorder()
+	ResponseHeaders(headers)(okHandler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+	for name, want := range headers {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		path string
+		key  string
+		want int
+	}{
+		{"no keys configured", nil, "/tasks", "", http.StatusOK},
+		{"valid key", []string{"one", "two"}, "/tasks", "two", http.StatusOK},
+		{"missing key", []string{"one"}, "/tasks", "", http.StatusUnauthorized},
+		{"wrong key", []string{"one"}, "/tasks", "on", http.StatusUnauthorized},
+		{"empty key", []string{""}, "/tasks", "", http.StatusUnauthorized},
+		{"swagger", []string{"one"}, "/swagger/index.html", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.key != "" {
+				r.Header.Set(APIKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			APIKey(tt.keys)(okHandler).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		sent string
+	}{
+		{"generated", ""},
+		{"kept", "req-42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = requestIDFrom(r)
+			})
+			r := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+			if tt.sent != "" {
+				r.Header.Set(RequestIDHeader, tt.sent)
+			}
+			w := httptest.NewRecorder()
+			RequestID(next).ServeHTTP(w, r)
+			got := w.Header().Get(RequestIDHeader)
+			if got == "" || got != seen {
+				t.Fatalf("response ID %q, handler saw %q", got, seen)
+			}
+			if tt.sent != "" && got != tt.sent {
+				t.Fatalf("response ID %q, want %q", got, tt.sent)
+			}
+		})
+	}
+}
+
+// logBuffer collects log output written from several goroutines.
+type logBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func TestRequestIDOnTask(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	logs := &logBuffer{}
+	tm.logger = slog.New(slog.NewJSONHandler(logs, nil))
+
+	r := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{}`))
+	r.Header.Set(RequestIDHeader, "req-7")
+	w := httptest.NewRecorder()
+	RequestID(http.HandlerFunc(tm.CreateTaskHandler)).ServeHTTP(w, r)
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("create task: status %d: %v", w.Code, err)
+	}
+	addFile(t, tm, created.ID, files.URL+"/a.pdf")
+	waitDone(t, tm, created.ID)
+
+	if got := tm.Tasks[created.ID].RequestID; got != "req-7" {
+		t.Fatalf("task request ID %q, want req-7", got)
+	}
+	// The task's own records carry the ID of the request that created it.
+	var tagged int
+	for line := range strings.Lines(logs.String()) {
+		var record struct {
+			TaskID    string `json:"task_id"`
+			RequestID string `json:"request_id"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log record %q: %v", line, err)
+		}
+		if record.TaskID == created.ID && record.RequestID == "req-7" {
+			tagged++
+		}
+	}
+	if tagged < 2 {
+		t.Fatalf("%d records tagged with the request ID in\n%s", tagged, logs.String())
+	}
+}
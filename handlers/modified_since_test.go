@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestModifiedSince(t *testing.T) {
+	since := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	modified := map[string]time.Time{
+		"/old.pdf": since.Add(-24 * time.Hour),
+		"/new.pdf": since.Add(24 * time.Hour),
+	}
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ignores-ims.pdf" {
+			// A server that always answers 200 but reports an old Last-Modified.
+			w.Header().Set("Last-Modified", since.Add(-time.Hour).Format(http.TimeFormat))
+			w.Write([]byte("%PDF-1.4 stale"))
+			return
+		}
+		http.ServeContent(w, r, r.URL.Path, modified[r.URL.Path], bytes.NewReader([]byte("%PDF-1.4 "+r.URL.Path)))
+	}))
+	defer files.Close()
+	tm := newTestManager(t, nil)
+
+	id := createTask(t, tm, `{"modified_since": "2025-08-01T00:00:00Z"}`, nil)
+	for _, path := range []string{"/old.pdf", "/new.pdf", "/ignores-ims.pdf"} {
+		addFile(t, tm, id, files.URL+path)
+	}
+	waitDone(t, tm, id)
+
+	w := serve(tm.GetTaskStatusHandler, http.MethodGet, "", map[string]string{"id": id}, nil)
+	var status struct {
+		ResultURL string `json:"result_url"`
+		Files     []struct {
+			Name    string `json:"name"`
+			Skipped string `json:"skipped"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	skipped := make(map[string]string)
+	for _, f := range status.Files {
+		skipped[f.Name] = f.Skipped
+	}
+	want := map[string]string{"old.pdf": "NOT_MODIFIED", "new.pdf": "", "ignores-ims.pdf": "NOT_MODIFIED"}
+	for name, code := range want {
+		if got, ok := skipped[name]; !ok || got != code {
+			t.Errorf("%s: skipped %q (listed %v), want %q", name, got, ok, code)
+		}
+	}
+
+	entries := readArchive(t, id+".zip")
+	if len(entries) != 1 || entries["new.pdf"] == "" {
+		t.Fatalf("archive entries %v, want only new.pdf", entries)
+	}
+}
+
+func TestModifiedSinceInCoalesceKey(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.CoalesceIdenticalTasks = true })
+	a := createTask(t, tm, `{}`, nil)
+	b := createTask(t, tm, `{"modified_since": "2025-08-01T00:00:00Z"}`, nil)
+	for _, id := range []string{a, b} {
+		for _, name := range []string{"/a.pdf", "/b.pdf", "/c.pdf"} {
+			addFile(t, tm, id, files.URL+name)
+		}
+	}
+	if sa, sb := waitDone(t, tm, a), waitDone(t, tm, b); sa.ResultURL == sb.ResultURL {
+		t.Fatalf("tasks with different modified_since share %q", sa.ResultURL)
+	}
+}
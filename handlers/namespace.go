@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// clientArchiveRoot holds one directory of archives per client when
+// ClientIDHeader is set.
+const clientArchiveRoot = "clients"
+
+// requestClientID returns the client identity of r: the hex SHA-256 of the
+// ClientIDHeader value, so distinct header values never collide and the
+// identity is safe to use as a directory name. Without a configured
+// ClientIDHeader every request belongs to the unnamed client "" and ok is
+// always true; otherwise ok reports whether the header was set.
+func (tm *TaskManager) requestClientID(r *http.Request) (id string, ok bool) {
+	if tm.config.ClientIDHeader == "" {
+		return "", true
+	}
+	raw := r.Header.Get(tm.config.ClientIDHeader)
+	if raw == "" {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:]), true
+}
+
+// requireClientID is requestClientID that answers 401 for requests without
+// an identity.
+func (tm *TaskManager) requireClientID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id, ok := tm.requestClientID(r)
+	if !ok {
+		http.Error(w, "missing client identity", http.StatusUnauthorized)
+	}
+	return id, ok
+}
+
+// ownedBy reports whether t belongs to the client that sent r. Tasks of
+// other clients are reported as not found.
+func (tm *TaskManager) ownedBy(r *http.Request, t *task.Task) bool {
+	id, ok := tm.requestClientID(r)
+	return ok && t.ClientID == id
+}
+
+// clientArchiveDir is the directory holding the archives of clientID,
+// relative to the archive directory.
+func clientArchiveDir(clientID string) string {
+	if clientID == "" {
+		return "."
+	}
+	return filepath.Join(clientArchiveRoot, clientID)
+}
+
+// restoreClientArchives runs RestoreArchives for every client namespace
+// and returns the total number of restored tasks.
+func (tm *TaskManager) restoreClientArchives(root string) (int, error) {
+	entries, err := os.ReadDir(filepath.Join(root, clientArchiveRoot))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	restored := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		n, err := tm.restoreArchives(root, entry.Name())
+		if err != nil {
+			return restored, err
+		}
+		restored += n
+	}
+	return restored, nil
+}
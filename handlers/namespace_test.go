@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestClientID(t *testing.T) {
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.ClientIDHeader = "X-Client-ID" })
+
+	idOf := func(value string) (string, bool) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if value != "" {
+			r.Header.Set("X-Client-ID", value)
+		}
+		return tm.requestClientID(r)
+	}
+
+	if _, ok := idOf(""); ok {
+		t.Error("request without the header has an identity")
+	}
+
+	tests := []struct{ a, b string }{
+		{"a/b", "a_b"},
+		{"alice@x", "alice_x"},
+		{"..", "_"},
+		{"Alice", "alice"},
+	}
+	for _, tt := range tests {
+		a, okA := idOf(tt.a)
+		b, okB := idOf(tt.b)
+		if !okA || !okB {
+			t.Errorf("%q, %q: missing identity", tt.a, tt.b)
+			continue
+		}
+		if a == b {
+			t.Errorf("%q and %q share the identity %q", tt.a, tt.b, a)
+		}
+		if clientArchiveDir(a) == clientArchiveDir(b) {
+			t.Errorf("%q and %q share the archive directory %q", tt.a, tt.b, clientArchiveDir(a))
+		}
+	}
+}
+
+func TestRequestClientIDWithoutHeader(t *testing.T) {
+	tm := newTestManager(t, nil)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Client-ID", "alice")
+	if id, ok := tm.requestClientID(r); id != "" || !ok {
+		t.Errorf("requestClientID = %q, %v; want \"\", true", id, ok)
+	}
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.ClientIDHeader = "X-Client-ID" })
+	alice := map[string]string{"X-Client-ID": "alice", "Tus-Resumable": tusVersion}
+
+	taskID := createTask(t, tm, `{"group_id":"shared"}`, alice)
+	w := serve(tm.CreateUploadHandler, http.MethodPost, "", map[string]string{"id": taskID},
+		map[string]string{"X-Client-ID": "alice", "Tus-Resumable": tusVersion, "Upload-Length": "4"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create upload: status %d: %s", w.Code, w.Body)
+	}
+	uploadID := w.Header().Get("Location")[len("/uploads/"):]
+
+	taskVars := map[string]string{"id": taskID}
+	uploadVars := map[string]string{"uid": uploadID}
+	groupVars := map[string]string{"id": "shared"}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		body    string
+		vars    map[string]string
+	}{
+		{"status", tm.GetTaskStatusHandler, http.MethodGet, "", taskVars},
+		{"progress", tm.GetTaskProgressHandler, http.MethodGet, "", taskVars},
+		{"add file", tm.AddFileHandler, http.MethodPost, `{"url":"https://example.com/a.pdf"}`, taskVars},
+		{"events", tm.TaskEventsHandler, http.MethodGet, "", taskVars},
+		{"start", tm.StartTaskHandler, http.MethodPost, "", taskVars},
+		{"contents", tm.ArchiveContentsHandler, http.MethodGet, "", taskVars},
+		{"archive", tm.ServeTaskArchiveHandler, http.MethodGet, "", taskVars},
+		{"signature", tm.ServeSignatureHandler, http.MethodGet, "", taskVars},
+		{"create upload", tm.CreateUploadHandler, http.MethodPost, "", taskVars},
+		{"upload offset", tm.UploadOffsetHandler, http.MethodHead, "", uploadVars},
+		{"upload patch", tm.UploadPatchHandler, http.MethodPatch, "data", uploadVars},
+		{"group status", tm.GetGroupStatusHandler, http.MethodGet, "", groupVars},
+		{"group archive", tm.ServeGroupArchiveHandler, http.MethodGet, "", groupVars},
+		{"delete", tm.DeleteTaskHandler, http.MethodDelete, "", taskVars},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{
+				"X-Client-ID":   "bob",
+				"Tus-Resumable": tusVersion,
+				"Upload-Length": "4",
+				"Upload-Offset": "0",
+				"Content-Type":  "application/offset+octet-stream",
+			}
+			if w := serve(tt.handler, tt.method, tt.body, tt.vars, headers); w.Code != http.StatusNotFound {
+				t.Errorf("other client: status %d, want %d", w.Code, http.StatusNotFound)
+			}
+		})
+	}
+
+	for _, tt := range []struct {
+		name    string
+		handler http.HandlerFunc
+		vars    map[string]string
+	}{
+		{"status", tm.GetTaskStatusHandler, taskVars},
+		{"progress", tm.GetTaskProgressHandler, taskVars},
+		{"upload offset", tm.UploadOffsetHandler, uploadVars},
+		{"group status", tm.GetGroupStatusHandler, groupVars},
+	} {
+		if w := serve(tt.handler, http.MethodGet, "", tt.vars, alice); w.Code != http.StatusOK {
+			t.Errorf("%s: owner gets status %d, want %d", tt.name, w.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestGroupMembersAreScopedPerClient(t *testing.T) {
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.ClientIDHeader = "X-Client-ID" })
+	alice := map[string]string{"X-Client-ID": "alice"}
+	bob := map[string]string{"X-Client-ID": "bob"}
+
+	aliceTask := createTask(t, tm, `{"group_id":"g"}`, alice)
+	bobTask := createTask(t, tm, `{"group_id":"g"}`, bob)
+
+	tests := []struct {
+		headers map[string]string
+		want    string
+	}{
+		{alice, aliceTask},
+		{bob, bobTask},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for name, value := range tt.headers {
+			r.Header.Set(name, value)
+		}
+		summaries, ok := tm.groupSnapshot(r, "g")
+		if !ok || len(summaries) != 1 || summaries[0].ID != tt.want {
+			t.Errorf("client %s: group members %v, want only %s", tt.headers["X-Client-ID"], summaries, tt.want)
+		}
+	}
+}
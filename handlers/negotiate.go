@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const protobufContentType = "application/x-protobuf"
+
+// acceptsProtobuf reports whether the client asked for protobuf via Accept.
+// JSON stays the default for any other or missing Accept header.
+func acceptsProtobuf(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == protobufContentType {
+			return true
+		}
+	}
+	return false
+}
+
+func writeProtobuf(w http.ResponseWriter, msg proto.Message) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		slog.Error("failed to marshal protobuf response", "error", err)
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", protobufContentType)
+	w.Write(data)
+}
+
+// fieldNaming returns the JSON field naming for a response: a naming
+// parameter on an application/json Accept entry overrides the configured
+// default.
+func (tm *TaskManager) fieldNaming(r *http.Request) string {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != "application/json" {
+			continue
+		}
+		switch params["naming"] {
+		case config.NamingSnake, config.NamingCamel:
+			return params["naming"]
+		}
+	}
+	if tm.config.JSONFieldNaming == config.NamingCamel {
+		return config.NamingCamel
+	}
+	return config.NamingSnake
+}
+
+// writeJSON encodes v with the field naming requested by r.
+func (tm *TaskManager) writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	var data []byte
+	var err error
+	if tm.fieldNaming(r) == config.NamingCamel {
+		data, err = camelCaseJSON(v)
+	} else {
+		data, err = json.Marshal(v)
+	}
+	if err != nil {
+		tm.requestLogger(r).Error("failed to encode JSON response", "error", err)
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+// camelCaseJSON marshals v and rewrites the snake_case names of struct
+// fields to camelCase. Keys that are data rather than field names, such as
+// those of file_names and metadata, keep their spelling.
+func camelCaseJSON(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic any
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(camelCaseFields(generic, reflect.TypeOf(v)))
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// camelCaseFields renames the keys of the objects in v that encode fields
+// of a struct, following t, the Go type v was encoded from. Objects
+// encoded from maps or json.RawMessage keep their keys, but struct values
+// of maps are still renamed.
+func camelCaseFields(v any, t reflect.Type) any {
+	if t == nil {
+		return v
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == rawMessageType {
+		return v
+	}
+
+	switch value := v.(type) {
+	case map[string]any:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := jsonFields(t)
+			out := make(map[string]any, len(value))
+			for key, item := range value {
+				if fieldType, ok := fields[key]; ok {
+					out[snakeToCamel(key)] = camelCaseFields(item, fieldType)
+				} else {
+					out[key] = item
+				}
+			}
+			return out
+		case reflect.Map:
+			for key, item := range value {
+				value[key] = camelCaseFields(item, t.Elem())
+			}
+		}
+	case []any:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, item := range value {
+				value[i] = camelCaseFields(item, t.Elem())
+			}
+		}
+	}
+	return v
+}
+
+// jsonFields maps the JSON names of the fields of struct type t, including
+// those promoted from embedded structs, to their types.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				// Fields of the outer struct take precedence.
+				for promoted, fieldType := range jsonFields(embedded) {
+					if _, ok := fields[promoted]; !ok {
+						fields[promoted] = fieldType
+					}
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func snakeToCamel(key string) string {
+	parts := strings.Split(key, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/task"
+	"2025-08-02/taskpb"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAcceptsProtobuf(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"application/x-protobuf", true},
+		{"application/json;q=0.9, application/x-protobuf", true},
+		{"APPLICATION/X-PROTOBUF; q=1", true},
+		{"application/x-protobuf-text", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		if got := acceptsProtobuf(r); got != tt.want {
+			t.Errorf("acceptsProtobuf(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestTaskStatusProtobuf(t *testing.T) {
+	tm := newTestManager(t, nil)
+	id := createTask(t, tm, `{"group_id": "g1"}`, nil)
+	addFile(t, tm, id, "https://example.com/a.pdf")
+
+	vars := map[string]string{"id": id}
+	w := serve(tm.GetTaskStatusHandler, http.MethodGet, "", vars, map[string]string{"Accept": "application/x-protobuf"})
+	if got := w.Header().Get("Content-Type"); got != protobufContentType {
+		t.Fatalf("Content-Type %q, want %q", got, protobufContentType)
+	}
+	var msg taskpb.Task
+	if err := proto.Unmarshal(w.Body.Bytes(), &msg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if msg.GetId() != id || msg.GetStatus() != "created" || msg.GetGroupId() != "g1" {
+		t.Fatalf("message %v does not describe the task", &msg)
+	}
+	if urls := strings.Join(msg.GetFileUrls(), " "); urls != "https://example.com/a.pdf" {
+		t.Fatalf("file_urls %q", urls)
+	}
+
+	w = serve(tm.GetTaskStatusHandler, http.MethodGet, "", vars, nil)
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("default Content-Type %q, want application/json", got)
+	}
+}
+
+func TestFieldNaming(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		accept     string
+		wantField  string
+	}{
+		{"default", config.NamingSnake, "", `"file_urls"`},
+		{"configured camel", config.NamingCamel, "", `"fileUrls"`},
+		{"requested camel", config.NamingSnake, "application/json; naming=camel", `"fileUrls"`},
+		{"requested snake", config.NamingCamel, "text/html, application/json;naming=snake", `"file_urls"`},
+		{"unknown naming", config.NamingCamel, "application/json; naming=kebab", `"fileUrls"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := newTestManager(t, func(cfg *config.Config) { cfg.JSONFieldNaming = tt.configured })
+			id := createTask(t, tm, `{}`, nil)
+			w := serve(tm.GetTaskStatusHandler, http.MethodGet, "", map[string]string{"id": id}, map[string]string{"Accept": tt.accept})
+			if !strings.Contains(w.Body.String(), tt.wantField) {
+				t.Fatalf("body %s has no %s field", w.Body, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestCamelCaseJSON(t *testing.T) {
+	withData := task.NewTask()
+	withData.ID = "t1"
+	withData.GroupID = "g1"
+	withData.FileURLs = []string{"https://example.com/a_b.pdf"}
+	withData.FileNames = map[string]string{"https://example.com/a_b.pdf": "my_file.pdf"}
+	withData.Metadata = map[string]json.RawMessage{"user_id": json.RawMessage(`{"nested_key":[{"deep_key":1}]}`)}
+	withData.FileStatuses = []task.FileStatus{{URL: "https://example.com/a_b.pdf", State: task.FilePending}}
+
+	type inner struct {
+		SnakeName string `json:"snake_name"`
+	}
+	type embedding struct {
+		inner
+		OwnField string           `json:"own_field"`
+		ByName   map[string]inner `json:"by_name"`
+		Skipped  string           `json:"-"`
+	}
+
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{
+			name: "task",
+			v:    withData,
+			want: `{"id":"t1","status":"created","fileUrls":["https://example.com/a_b.pdf"],"groupId":"g1",` +
+				`"metadata":{"user_id":{"nested_key":[{"deep_key":1}]}},` +
+				`"fileStatuses":[{"url":"https://example.com/a_b.pdf","state":"pending"}],` +
+				`"fileNames":{"https://example.com/a_b.pdf":"my_file.pdf"}}`,
+		},
+		{
+			name: "raw message",
+			v:    BatchResult{Status: 200, Body: json.RawMessage(`{"file_urls":["x_y"]}`)},
+			want: `{"status":200,"body":{"file_urls":["x_y"]}}`,
+		},
+		{
+			name: "embedded struct and map of structs",
+			v:    embedding{inner: inner{SnakeName: "a"}, OwnField: "b", ByName: map[string]inner{"key_name": {SnakeName: "c"}}},
+			want: `{"snakeName":"a","ownField":"b","byName":{"key_name":{"snakeName":"c"}}}`,
+		},
+		{
+			name: "plain map",
+			v:    map[string]int{"snake_key": 1},
+			want: `{"snake_key":1}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := camelCaseJSON(tt.v)
+			if err != nil {
+				t.Fatalf("camelCaseJSON: %v", err)
+			}
+			var got, want any
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("camelCaseJSON =\n%s\nwant\n%s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnakeToCamel(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"id", "id"},
+		{"file_urls", "fileUrls"},
+		{"result_checksum", "resultChecksum"},
+		{"trailing_", "trailing"},
+		{"double__underscore", "doubleUnderscore"},
+	}
+	for _, tt := range tests {
+		if got := snakeToCamel(tt.key); got != tt.want {
+			t.Errorf("snakeToCamel(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"errors"
+	"os"
+)
+
+// saveTask records the current state of t in the task store. Failures are
+// logged; the task carries on in memory.
+func (tm *TaskManager) saveTask(t *task.Task) {
+	if err := tm.taskStore.Save(t); err != nil {
+		tm.logger.Error("failed to save task", "task_id", t.ID, "error", err)
+	}
+}
+
+// loadTasks registers the tasks of the task store. Tasks that were still
+// processing when the server stopped are settled first: done if their
+// archive was completed, failed as interrupted otherwise. With
+// resume_interrupted_tasks the unfinished ones are processed again instead.
+func (tm *TaskManager) loadTasks() {
+	tasks, err := tm.taskStore.List()
+	if err != nil {
+		tm.logger.Error("failed to load tasks", "error", err)
+		return
+	}
+
+	var resumed []*task.Task
+	for _, t := range tasks {
+		t.SetLogger(tm.logger)
+		t.SetArchiveDir(tm.config.ArchiveDir)
+		if tm.config.ResumeInterruptedTasks && t.PrepareResume() {
+			resumed = append(resumed, t)
+		} else if t.RecoverInterrupted() {
+			tm.logger.Info("task was processing at restart", "task_id", t.ID, "status", t.State())
+			tm.saveTask(t)
+		}
+		if t.State().HasArchive() && !t.IsArchiveExpired() {
+			// The cleanup may have deleted the archive while the server
+			// was down.
+			if _, err := os.Stat(t.ArchiveName()); errors.Is(err, os.ErrNotExist) {
+				t.MarkArchiveExpired()
+				tm.saveTask(t)
+			}
+		}
+		tm.mutex.Lock()
+		tm.Tasks[t.ID] = t
+		if t.ResultURL != "" {
+			tm.archives[t.ArchiveName()] = t.ID
+		}
+		if t.GroupID != "" {
+			tm.groups[t.GroupID] = append(tm.groups[t.GroupID], t.ID)
+		}
+		tm.mutex.Unlock()
+	}
+	if len(tasks) > 0 {
+		tm.logger.Info("loaded tasks from the task store", "tasks", len(tasks))
+	}
+	for _, t := range resumed {
+		tm.resumeTask(t)
+	}
+}
+
+// resumeTask processes a loaded task again that PrepareResume readied. It
+// waits for a task slot in the background like any other task.
+func (tm *TaskManager) resumeTask(t *task.Task) {
+	tm.inFlight.Add(1)
+	tm.activeTasks.Add(1)
+	if t.ProgressCallbackURL != "" {
+		tm.forwardProgress(t)
+	}
+	go func() {
+		defer tm.inFlight.Done()
+		defer tm.activeTasks.Add(-1)
+		tm.concurrentTaskSema <- struct{}{}
+		defer func() { <-tm.concurrentTaskSema }()
+		t.Process(tm.env)
+		tm.saveTask(t)
+		tm.releaseUploads(t.ID)
+		tm.publishCompletion(t)
+	}()
+}
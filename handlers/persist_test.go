@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/store"
+	"2025-08-02/task"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTasksSurviveRestart(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "tasks.json")
+	release := make(chan struct{})
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow.pdf" {
+			<-release
+		}
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	defer files.Close()
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.MaxFilesPerTask = 1
+		cfg.TaskStoreFile = storePath
+	})
+
+	done := createTask(t, tm, `{"group_id": "batch"}`, nil)
+	addFile(t, tm, done, files.URL+"/a.pdf")
+	doneStatus := waitDone(t, tm, done)
+	created := createTask(t, tm, `{}`, nil)
+	interrupted := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, interrupted, files.URL+"/slow.pdf")
+
+	// A task that finished its archive but not its record is done after a
+	// restart.
+	archived := task.NewTask()
+	archived.FileURLs = []string{files.URL + "/b.pdf"}
+	archived.SetArchiveName(archived.ID + ".zip")
+	archived.MarkProcessing()
+	if err := store.NewFileTaskStore(storePath).Save(archived); err != nil {
+		t.Fatal(err)
+	}
+	writeZip(t, archived.ID+".zip", "b.pdf", "%PDF-1.4 /b.pdf")
+
+	restarted := NewTaskManager(tm.config, tm.logger)
+	want := map[string]string{done: "done", created: "created", interrupted: "canceled", archived.ID: "done"}
+	for id, status := range want {
+		if got := getStatus(t, restarted, id); got.Status != status {
+			t.Errorf("task %s is %s after the restart, want %s", id, got.Status, status)
+		}
+	}
+	if got := getStatus(t, restarted, done); got.ResultURL != doneStatus.ResultURL {
+		t.Errorf("result URL %q after the restart, want %q", got.ResultURL, doneStatus.ResultURL)
+	}
+	if got := getStatus(t, restarted, archived.ID); got.ResultChecksum == "" {
+		t.Error("recovered archive has no checksum")
+	}
+	if w := serve(restarted.ServeArchiveHandler, http.MethodGet, "", map[string]string{"filename": done + ".zip"}, nil); w.Code != http.StatusOK {
+		t.Errorf("archive of the done task: status %d", w.Code)
+	}
+
+	close(release)
+	waitFinished(t, tm, interrupted)
+}
+
+func TestNopTaskStore(t *testing.T) {
+	tm := newTestManager(t, nil)
+	id := createTask(t, tm, `{}`, nil)
+	if _, err := os.Stat("tasks.json"); !os.IsNotExist(err) {
+		t.Fatalf("task store written without task_store_file: %v", err)
+	}
+	w := serve(NewTaskManager(tm.config, tm.logger).GetTaskStatusHandler, http.MethodGet, "", map[string]string{"id": id}, nil)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("task %s after a restart without a store: status %d, want %d", id, w.Code, http.StatusNotFound)
+	}
+}
+
+func TestResumeInterruptedTasks(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "tasks.json")
+	release := make(chan struct{})
+	stalled := make(chan struct{})
+	var gets sync.Map
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := gets.LoadOrStore(r.URL.Path, new(atomic.Int32))
+		// The first run stalls on slow.pdf, as if the server crashed.
+		if n.(*atomic.Int32).Add(1) == 1 && r.URL.Path == "/slow.pdf" {
+			close(stalled)
+			<-release
+			return
+		}
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	defer files.Close()
+	defer close(release)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.MaxFilesPerTask = 2
+		cfg.ParallelDownloads = 0
+		cfg.TaskStoreFile = storePath
+		cfg.ResumeInterruptedTasks = true
+	})
+
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	addFile(t, tm, id, files.URL+"/slow.pdf")
+	<-stalled
+
+	restarted := NewTaskManager(tm.config, tm.logger)
+	waitDone(t, restarted, id)
+	want := map[string]string{"a.pdf": "%PDF-1.4 /a.pdf", "slow.pdf": "%PDF-1.4 /slow.pdf"}
+	if got := readArchive(t, id+".zip"); !maps.Equal(got, want) {
+		t.Fatalf("resumed archive %q, want %q", got, want)
+	}
+	for path, want := range map[string]int32{"/a.pdf": 1, "/slow.pdf": 2} {
+		if n, _ := gets.Load(path); n.(*atomic.Int32).Load() != want {
+			t.Errorf("%s requested %d times, want %d", path, n.(*atomic.Int32).Load(), want)
+		}
+	}
+	waitIdle(t, restarted)
+	if _, err := os.Stat(id + ".zip.parts"); !os.IsNotExist(err) {
+		t.Fatalf("kept downloads not removed after the resumed run: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrefetchServesProcessingFromCache(t *testing.T) {
+	var mutex sync.Mutex
+	requests := map[string]int{}
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.URL.Path]++
+		mutex.Unlock()
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	defer files.Close()
+	fetched := func(path string) int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return requests[path]
+	}
+
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.PrefetchOnAdd = true
+		cfg.MaxFilesPerTask = 2
+	})
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for fetched("/a.pdf") == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if fetched("/a.pdf") != 1 {
+		t.Fatal("a.pdf was not prefetched when it was added")
+	}
+
+	addFile(t, tm, id, files.URL+"/b.pdf")
+	waitDone(t, tm, id)
+
+	for _, path := range []string{"/a.pdf", "/b.pdf"} {
+		if n := fetched(path); n != 1 {
+			t.Errorf("%s downloaded %d times, want once", path, n)
+		}
+	}
+	entries := readArchive(t, id+".zip")
+	if entries["a.pdf"] != "%PDF-1.4 /a.pdf" || entries["b.pdf"] != "%PDF-1.4 /b.pdf" {
+		t.Fatalf("archive entries %q", entries)
+	}
+	if staged, _ := filepath.Glob(filepath.Join(tmp, "prefetch-*")); len(staged) != 0 {
+		t.Fatalf("prefetched copies left behind: %v", staged)
+	}
+}
+
+func TestPrefetchDisabled(t *testing.T) {
+	calls := make(chan string, 1)
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls <- r.URL.Path
+	}))
+	defer files.Close()
+
+	tm := newTestManager(t, nil)
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	select {
+	case path := <-calls:
+		t.Fatalf("%s was fetched without prefetch_on_add", path)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/task"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func getProgress(t *testing.T, tm *TaskManager, id string) task.Progress {
+	t.Helper()
+	w := serve(tm.GetTaskProgressHandler, http.MethodGet, "", map[string]string{"id": id}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("progress: status %d", w.Code)
+	}
+	var p task.Progress
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestTaskProgress(t *testing.T) {
+	release := make(chan struct{})
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow.pdf":
+			w.Write([]byte("0123456789"))
+			w.(http.Flusher).Flush()
+			<-release
+			w.Write([]byte("0123456789"))
+		case "/missing.pdf":
+			http.NotFound(w, r)
+		default:
+			w.Write([]byte("0123456789"))
+		}
+	}))
+	defer files.Close()
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+	tm := newTestManager(t, nil)
+
+	id := createTask(t, tm, `{}`, nil)
+	for _, path := range []string{"/a.pdf", "/slow.pdf", "/missing.pdf"} {
+		addFile(t, tm, id, files.URL+path)
+	}
+
+	// While slow.pdf stalls halfway, its bytes already count.
+	want := task.Progress{FilesTotal: 3, FilesDownloaded: 1, FilesArchived: 1, BytesDownloaded: 20}
+	deadline := time.Now().Add(5 * time.Second)
+	p := getProgress(t, tm, id)
+	for p != want && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		p = getProgress(t, tm, id)
+	}
+	if p != want {
+		t.Fatalf("progress during the download %+v, want %+v", p, want)
+	}
+
+	close(release)
+	if status := waitFinished(t, tm, id); status.Status != "partial" {
+		t.Fatalf("task with a missing file is %s, want partial", status.Status)
+	}
+	want = task.Progress{FilesTotal: 3, FilesDownloaded: 2, FilesArchived: 2, FilesFailed: 1, BytesDownloaded: 30}
+	if p := getProgress(t, tm, id); p != want {
+		t.Fatalf("final progress %+v, want %+v", p, want)
+	}
+
+	if w := serve(tm.GetTaskProgressHandler, http.MethodGet, "", map[string]string{"id": "missing"}, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown task: status %d", w.Code)
+	}
+}
+
+func TestTaskProgressCoalesced(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.MaxFilesPerTask = 1
+		cfg.CoalesceIdenticalTasks = true
+	})
+	leader := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, leader, files.URL+"/a.pdf")
+	waitDone(t, tm, leader)
+	follower := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, follower, files.URL+"/a.pdf")
+	waitDone(t, tm, follower)
+
+	if a, b := getProgress(t, tm, leader), getProgress(t, tm, follower); a != b || a.FilesArchived != 1 {
+		t.Fatalf("follower progress %+v, want the leader's %+v", b, a)
+	}
+}
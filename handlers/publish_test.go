@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/queue"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// memPublisher records every published completion.
+type memPublisher struct {
+	mutex       sync.Mutex
+	completions []queue.Completion
+}
+
+func (p *memPublisher) PublishCompletion(c queue.Completion) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.completions = append(p.completions, c)
+	return nil
+}
+
+// published returns the completions recorded for taskID.
+func (p *memPublisher) published(taskID string) []queue.Completion {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var out []queue.Completion
+	for _, c := range p.completions {
+		if c.TaskID == taskID {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func TestCompletionPublishedOnce(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	publisher := &memPublisher{}
+	tm.publisher = publisher
+
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	status := waitDone(t, tm, id)
+
+	waitIdle(t, tm)
+	got := publisher.published(id)
+	if len(got) != 1 {
+		t.Fatalf("published %d completions, want 1: %+v", len(got), got)
+	}
+	if got[0].Status != "done" || got[0].ResultURL != status.ResultURL {
+		t.Fatalf("completion %+v does not match the task status %+v", got[0], status)
+	}
+}
+
+func TestCompletionPublishedOnceAfterRetries(t *testing.T) {
+	var calls atomic.Int32
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer files.Close()
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.MaxFilesPerTask = 1
+		cfg.DownloadRetries = 2
+		cfg.RetryBackoffMs = 1
+	})
+	publisher := &memPublisher{}
+	tm.publisher = publisher
+
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+
+	waitIdle(t, tm)
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("%d download attempts, want 3", got)
+	}
+	if got := publisher.published(id); len(got) != 1 || got[0].Status != "done" {
+		t.Fatalf("published %+v, want one done completion", got)
+	}
+}
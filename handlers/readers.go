@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"archive/zip"
+	"container/list"
+	"context"
+	"os"
+	"sync"
+)
+
+// archiveReaders hands out opened archives for single-file extraction. At
+// most perArchive extractions run at once on one archive, and up to
+// capacity opened archives are kept in LRU order, so repeated extractions
+// do not re-read the central directory. A capacity of zero opens the
+// archive for every extraction.
+type archiveReaders struct {
+	perArchive int
+	capacity   int
+	mutex      sync.Mutex
+	slots      map[string]*archiveSlots
+	cached     map[string]*list.Element // of *cachedArchive
+	lru        *list.List               // most recently used first
+}
+
+type archiveSlots struct {
+	sem   chan struct{}
+	users int
+}
+
+// cachedArchive is an opened archive. It is closed once it is evicted and
+// no extraction uses it any more.
+type cachedArchive struct {
+	path    string
+	reader  *zip.ReadCloser
+	info    os.FileInfo
+	refs    int
+	evicted bool
+}
+
+func newArchiveReaders(perArchive, capacity int) *archiveReaders {
+	return &archiveReaders{
+		perArchive: perArchive,
+		capacity:   capacity,
+		slots:      make(map[string]*archiveSlots),
+		cached:     make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// acquire waits for an extraction slot on the archive at path and returns
+// it opened. release must be called once the extraction is finished.
+func (a *archiveReaders) acquire(ctx context.Context, path string) (*zip.Reader, func(), error) {
+	leave, err := a.enter(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entry, err := a.open(path)
+	if err != nil {
+		leave()
+		return nil, nil, err
+	}
+	release := func() {
+		a.mutex.Lock()
+		entry.refs--
+		closeNow := entry.evicted && entry.refs == 0
+		a.mutex.Unlock()
+		if closeNow {
+			entry.reader.Close()
+		}
+		leave()
+	}
+	return &entry.reader.Reader, release, nil
+}
+
+// enter takes one of the perArchive slots of path, waiting until one is
+// free or ctx is done.
+func (a *archiveReaders) enter(ctx context.Context, path string) (func(), error) {
+	if a.perArchive <= 0 {
+		return func() {}, nil
+	}
+
+	a.mutex.Lock()
+	s, ok := a.slots[path]
+	if !ok {
+		s = &archiveSlots{sem: make(chan struct{}, a.perArchive)}
+		a.slots[path] = s
+	}
+	s.users++
+	a.mutex.Unlock()
+
+	leave := func() {
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+		if s.users--; s.users == 0 {
+			delete(a.slots, path)
+		}
+	}
+	select {
+	case s.sem <- struct{}{}:
+		return func() {
+			<-s.sem
+			leave()
+		}, nil
+	case <-ctx.Done():
+		leave()
+		return nil, ctx.Err()
+	}
+}
+
+// open returns the cached archive at path, reopening it if the file has
+// changed or is gone, and takes a reference on it.
+func (a *archiveReaders) open(path string) (*cachedArchive, error) {
+	info, statErr := os.Stat(path)
+
+	a.mutex.Lock()
+	if elem, ok := a.cached[path]; ok {
+		entry := elem.Value.(*cachedArchive)
+		if statErr == nil && entry.matches(info) {
+			entry.refs++
+			a.lru.MoveToFront(elem)
+			a.mutex.Unlock()
+			return entry, nil
+		}
+		a.evictLocked(elem)
+	}
+	a.mutex.Unlock()
+	if statErr != nil {
+		return nil, statErr
+	}
+
+	reader, err := task.OpenArchive(path)
+	if err != nil {
+		return nil, err
+	}
+	entry := &cachedArchive{path: path, reader: reader, info: info, refs: 1}
+	if a.capacity <= 0 {
+		entry.evicted = true
+		return entry, nil
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if elem, ok := a.cached[path]; ok {
+		// Another extraction opened it meanwhile; keep the newer handle.
+		a.evictLocked(elem)
+	}
+	a.cached[path] = a.lru.PushFront(entry)
+	for a.lru.Len() > a.capacity {
+		a.evictLocked(a.lru.Back())
+	}
+	return entry, nil
+}
+
+// evict closes the cached handle of path, once it is no longer in use.
+func (a *archiveReaders) evict(path string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if elem, ok := a.cached[path]; ok {
+		a.evictLocked(elem)
+	}
+}
+
+// prune evicts the handles of archives that were deleted or replaced, so
+// their disk space is freed.
+func (a *archiveReaders) prune() {
+	a.mutex.Lock()
+	paths := make([]string, 0, len(a.cached))
+	for path := range a.cached {
+		paths = append(paths, path)
+	}
+	a.mutex.Unlock()
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		a.mutex.Lock()
+		if elem, ok := a.cached[path]; ok {
+			entry := elem.Value.(*cachedArchive)
+			if err != nil || !entry.matches(info) {
+				a.evictLocked(elem)
+			}
+		}
+		a.mutex.Unlock()
+	}
+}
+
+// matches reports whether info is still the file entry was opened from.
+// Modification times are not compared, as serving an archive may extend
+// its lifetime by touching it.
+func (entry *cachedArchive) matches(info os.FileInfo) bool {
+	return os.SameFile(entry.info, info) && info.Size() == entry.info.Size()
+}
+
+// evictLocked drops elem from the cache and closes its archive unless an
+// extraction still reads it. Callers must hold a.mutex.
+func (a *archiveReaders) evictLocked(elem *list.Element) {
+	entry := elem.Value.(*cachedArchive)
+	a.lru.Remove(elem)
+	delete(a.cached, entry.path)
+	entry.evicted = true
+	if entry.refs == 0 {
+		entry.reader.Close()
+	}
+}
+
+// PruneArchiveReaders closes cached archive handles whose files have been
+// removed or replaced, e.g. by the archive cleanup.
+func (tm *TaskManager) PruneArchiveReaders() {
+	tm.archiveReaders.prune()
+}
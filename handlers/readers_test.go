@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestArchiveReadersPerArchiveLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.zip")
+	writeZip(t, path, "a.pdf", "%PDF-1.4")
+	readers := newArchiveReaders(2, 1)
+
+	_, releaseFirst, err := readers.acquire(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, releaseSecond, err := readers.acquire(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseSecond()
+
+	// Both slots are taken, so a third extraction waits.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := readers.acquire(ctx, path); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("third acquire = %v, want it to wait for a slot", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		_, release, err := readers.acquire(context.Background(), path)
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+	releaseFirst()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting extraction did not get the released slot")
+	}
+}
+
+func TestArchiveReadersCache(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.zip"), filepath.Join(dir, "b.zip")
+	writeZip(t, a, "a.pdf", "first")
+	writeZip(t, b, "b.pdf", "b")
+	readers := newArchiveReaders(0, 1)
+	open := func(path string) *cachedArchive {
+		t.Helper()
+		_, release, err := readers.acquire(context.Background(), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+		readers.mutex.Lock()
+		defer readers.mutex.Unlock()
+		if elem, ok := readers.cached[path]; ok {
+			return elem.Value.(*cachedArchive)
+		}
+		return nil
+	}
+
+	first := open(a)
+	if open(a) != first {
+		t.Fatal("archive reopened although it is cached")
+	}
+	// The capacity is one, so opening b evicts a.
+	open(b)
+	if !first.evicted {
+		t.Fatal("least recently used archive not evicted")
+	}
+
+	// A replaced file is reopened instead of served from the stale handle.
+	cached := open(a)
+	time.Sleep(10 * time.Millisecond)
+	writeZip(t, a, "a.pdf", "second, longer")
+	if reopened := open(a); reopened == cached {
+		t.Fatal("replaced archive served from the stale handle")
+	}
+
+	if err := os.Remove(a); err != nil {
+		t.Fatal(err)
+	}
+	readers.prune()
+	readers.mutex.Lock()
+	_, ok := readers.cached[a]
+	readers.mutex.Unlock()
+	if ok {
+		t.Fatal("deleted archive still cached after prune")
+	}
+}
+
+func TestServeArchiveEntryConcurrent(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.MaxFilesPerTask = 1
+		cfg.MaxConcurrentExtractions = 2
+		cfg.ExtractCacheSize = 1
+	})
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 16)
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := serve(tm.ServeArchiveEntryHandler, http.MethodGet, "", map[string]string{"id": id, "name": "a.pdf"}, nil)
+			if w.Body.String() != "%PDF-1.4 /a.pdf" {
+				codes <- -1
+				return
+			}
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("concurrent extraction answered %d", code)
+		}
+	}
+}
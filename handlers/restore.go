@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"os"
+	"path/filepath"
+)
+
+// RestoreArchives registers a finished task for every "<task id>.zip" or
+// "<task id>.tar.gz" in dir that no known task owns, so archives left by an earlier run stay
+// downloadable. Temporary and otherwise named files are skipped. With
+// ClientIDHeader set the client namespaces below dir are restored too. It
+// returns the number of restored tasks.
+func (tm *TaskManager) RestoreArchives(dir string) (int, error) {
+	restored, err := tm.restoreArchives(dir, "")
+	if err != nil || tm.config.ClientIDHeader == "" {
+		return restored, err
+	}
+	n, err := tm.restoreClientArchives(dir)
+	return restored + n, err
+}
+
+// restoreArchives restores the archives of clientID found under root.
+func (tm *TaskManager) restoreArchives(root, clientID string) (int, error) {
+	dir := filepath.Join(root, clientArchiveDir(clientID))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	restored := 0
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, ok := task.FormatOf(name); !entry.Type().IsRegular() || !ok {
+			continue
+		}
+		name = filepath.Join(dir, name)
+		t, err := task.Restore(name)
+		if err != nil {
+			tm.logger.Warn("skipping archive", "path", name, "error", err)
+			continue
+		}
+		if _, ok := tm.Tasks[t.ID]; ok {
+			continue
+		}
+		if _, ok := tm.archives[name]; ok {
+			continue
+		}
+		t.ClientID = clientID
+		t.SetLogger(tm.logger)
+		tm.Tasks[t.ID] = t
+		tm.archives[name] = t.ID
+		restored++
+	}
+	return restored, nil
+}
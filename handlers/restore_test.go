@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"archive/zip"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// writeZip writes a zip archive with a single entry to path.
+func writeZip(t *testing.T, path, entry, content string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipWriter := zip.NewWriter(file)
+	w, err := zipWriter.Create(entry)
+	if err == nil {
+		_, err = w.Write([]byte(content))
+	}
+	if err == nil {
+		err = zipWriter.Close()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestoreArchives(t *testing.T) {
+	tm := newTestManager(t, nil)
+	owned := createTask(t, tm, `{}`, nil)
+	tm.assignArchiveName(tm.Tasks[owned])
+
+	id := uuid.New().String()
+	writeZip(t, id+".zip", "a.pdf", "restored")
+	writeZip(t, owned+".zip", "b.pdf", "owned")
+	writeZip(t, uuid.New().String()+".zip.tmp", "c.pdf", "partial")
+	writeZip(t, "report.zip", "d.pdf", "not a task")
+
+	restored, err := tm.RestoreArchives(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != 1 || len(tm.Tasks) != 2 {
+		t.Fatalf("restored %d tasks, now %d known, want 1 and 2", restored, len(tm.Tasks))
+	}
+	if status := getStatus(t, tm, id); status.Status != "done" || status.ResultURL != "/archives/"+id+".zip" {
+		t.Fatalf("restored task status %+v", status)
+	}
+	w := serve(tm.ServeTaskArchiveHandler, http.MethodGet, "", map[string]string{"id": id}, nil)
+	if w.Code != http.StatusOK || readZip(t, w.Body.Bytes())["a.pdf"] != "restored" {
+		t.Fatalf("restored archive: status %d", w.Code)
+	}
+
+	if again, err := tm.RestoreArchives("."); err != nil || again != 0 {
+		t.Fatalf("second restore = %d, %v, want nothing new", again, err)
+	}
+}
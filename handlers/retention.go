@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"time"
+)
+
+// ArchiveRemoved marks the tasks served by the archive at path, which has
+// been deleted, as expired. Their records stay until task retention
+// purges them.
+func (tm *TaskManager) ArchiveRemoved(path string) {
+	tm.archiveReaders.evict(path)
+
+	tm.mutex.Lock()
+	var expired []*task.Task
+	for _, t := range tm.Tasks {
+		if t.ArchiveName() == path {
+			expired = append(expired, t)
+		}
+	}
+	tm.mutex.Unlock()
+
+	for _, t := range expired {
+		t.MarkArchiveExpired()
+		tm.saveTask(t)
+	}
+}
+
+// PurgeExpiredTasks forgets tasks that finished more than
+// task_retention_seconds ago, along with their archives if still present.
+// Without a task retention records are kept for as long as the server
+// runs.
+func (tm *TaskManager) PurgeExpiredTasks() {
+	if tm.config.TaskRetentionSeconds <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-time.Duration(tm.config.TaskRetentionSeconds) * time.Second)
+
+	tm.mutex.Lock()
+	var purged []*task.Task
+	var archives []string
+	for _, t := range tm.Tasks {
+		if t.FinishedBefore(cutoff) {
+			purged = append(purged, t)
+		}
+	}
+	for _, t := range purged {
+		archives = append(archives, tm.forgetTask(t))
+	}
+	tm.mutex.Unlock()
+
+	for i, t := range purged {
+		tm.logger.Info("purging task", "task_id", t.ID, "retention", time.Duration(tm.config.TaskRetentionSeconds)*time.Second)
+		tm.discardTask(t, archives[i])
+	}
+}
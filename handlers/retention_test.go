@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestArchiveRemoved(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+	waitIdle(t, tm)
+
+	if err := os.Remove(id + ".zip"); err != nil {
+		t.Fatal(err)
+	}
+	tm.ArchiveRemoved(id + ".zip")
+
+	// The task is still known and reports its archive as expired.
+	w := serve(tm.GetTaskStatusHandler, http.MethodGet, "", map[string]string{"id": id}, nil)
+	var status struct {
+		Status         string `json:"status"`
+		ArchiveExpired bool   `json:"archive_expired"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("status %d %q: %v", w.Code, w.Body, err)
+	}
+	if status.Status != "done" || !status.ArchiveExpired {
+		t.Fatalf("status %+v, want done with an expired archive", status)
+	}
+	if w := serve(tm.ServeArchiveHandler, http.MethodGet, "", map[string]string{"filename": id + ".zip"}, nil); w.Code != http.StatusGone {
+		t.Fatalf("download: status %d, want %d", w.Code, http.StatusGone)
+	}
+	if w := serve(tm.ServeArchiveEntryHandler, http.MethodGet, "", map[string]string{"id": id, "name": "a.pdf"}, nil); w.Code != http.StatusGone {
+		t.Fatalf("extract: status %d, want %d", w.Code, http.StatusGone)
+	}
+}
+
+func TestPurgeExpiredTasks(t *testing.T) {
+	tests := []struct {
+		name       string
+		retention  int
+		wantPurged bool
+	}{
+		{"retention", 60, true},
+		{"kept forever", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := fileServer(t)
+			tm := newTestManager(t, func(cfg *config.Config) {
+				cfg.MaxFilesPerTask = 1
+				cfg.TaskRetentionSeconds = tt.retention
+			})
+			old := createTask(t, tm, `{}`, nil)
+			addFile(t, tm, old, files.URL+"/a.pdf")
+			waitDone(t, tm, old)
+			recent := createTask(t, tm, `{}`, nil)
+			addFile(t, tm, recent, files.URL+"/b.pdf")
+			waitDone(t, tm, recent)
+			waitIdle(t, tm)
+			finishedAt := time.Now().Add(-2 * time.Minute)
+			tm.Tasks[old].FinishedAt = &finishedAt
+
+			tm.PurgeExpiredTasks()
+			w := serve(tm.GetTaskStatusHandler, http.MethodGet, "", map[string]string{"id": old}, nil)
+			if purged := w.Code == http.StatusNotFound; purged != tt.wantPurged {
+				t.Fatalf("old task purged: %v, want %v", purged, tt.wantPurged)
+			}
+			if _, err := os.Stat(old + ".zip"); os.IsNotExist(err) != tt.wantPurged {
+				t.Fatalf("old archive removed: %v, want %v", os.IsNotExist(err), tt.wantPurged)
+			}
+			getStatus(t, tm, recent)
+		})
+	}
+}
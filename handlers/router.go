@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeJSONError writes a JSON error body with the given status code.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: message})
+}
+
+// NotFoundHandler returns a JSON 404 for requests that match no route.
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slog.Debug("no route", "method", r.Method, "path", r.URL.Path, "request_id", requestIDFrom(r))
+		writeJSONError(w, http.StatusNotFound, "not found")
+	})
+}
+
+// MethodNotAllowedHandler returns a JSON 405 and an Allow header listing the
+// methods registered on router for the requested path.
+func MethodNotAllowedHandler(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slog.Debug("method not allowed", "method", r.Method, "path", r.URL.Path, "request_id", requestIDFrom(r))
+		if allowed := allowedMethods(router, r); len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+		}
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+	})
+}
+
+// allowedMethods collects the methods of every route whose path matches r.
+func allowedMethods(router *mux.Router, r *http.Request) []string {
+	var allowed []string
+	seen := make(map[string]bool)
+
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			if seen[method] {
+				continue
+			}
+			req := r.Clone(r.Context())
+			req.Method = method
+			var match mux.RouteMatch
+			if route.Match(req, &match) {
+				seen[method] = true
+				allowed = append(allowed, method)
+			}
+		}
+		return nil
+	})
+
+	return allowed
+}
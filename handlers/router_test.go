@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// okHandler answers 200 to every request.
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestRouterErrors(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/tasks/{id}", okHandler).Methods("GET")
+	r.HandleFunc("/tasks/{id}", okHandler).Methods("DELETE")
+	r.HandleFunc("/tasks/{id}/process", okHandler).Methods("POST")
+	r.NotFoundHandler = NotFoundHandler()
+	r.MethodNotAllowedHandler = MethodNotAllowedHandler(r)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		want      int
+		wantAllow string
+	}{
+		{"match", http.MethodGet, "/tasks/1", http.StatusOK, ""},
+		{"wrong method", http.MethodPost, "/tasks/1", http.StatusMethodNotAllowed, "GET, DELETE"},
+		{"wrong method on subresource", http.MethodGet, "/tasks/1/process", http.StatusMethodNotAllowed, "POST"},
+		{"no route", http.MethodGet, "/jobs", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+			if got := w.Header().Get("Allow"); got != tt.wantAllow {
+				t.Fatalf("Allow %q, want %q", got, tt.wantAllow)
+			}
+			if tt.want == http.StatusOK {
+				return
+			}
+			var body errorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Error == "" {
+				t.Fatalf("body %q is not a JSON error: %v", w.Body, err)
+			}
+		})
+	}
+}
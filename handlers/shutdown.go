@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"2025-08-02/queue"
+	"2025-08-02/task"
+	"time"
+)
+
+// Drain stops tm from accepting new tasks and files, then waits up to
+// grace for tasks being processed to finish. Tasks still processing after
+// that fail with code INTERRUPTED, their partial archives are removed and
+// they are recorded in the dead-letter sink, so they can be resubmitted
+// after a restart. It returns the number of such tasks.
+func (tm *TaskManager) Drain(grace time.Duration) int {
+	tm.mutex.Lock()
+	tm.draining = true
+	tm.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		tm.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return 0
+	case <-time.After(grace):
+	}
+
+	interrupted := 0
+	for _, s := range tm.snapshot() {
+		if s.Status != task.StatusProcessing {
+			continue
+		}
+		tm.logger.Warn("task is still processing at shutdown", "task_id", s.ID)
+		if err := s.Task.Interrupt("processing interrupted by shutdown"); err != nil {
+			// It finished after all.
+			tm.logger.Info("not interrupting task", "task_id", s.ID, "error", err)
+			continue
+		}
+		s.Task.RemovePartialArchives()
+		tm.saveTask(s.Task)
+		err := tm.deadLetters.RecordDeadLetter(queue.DeadLetter{
+			TaskID:       s.ID,
+			URLs:         s.Task.Result().FileURLs,
+			ErrorCodes:   []string{string(task.CodeInterrupted)},
+			ErrorDetails: "processing interrupted by shutdown",
+			FailedAt:     time.Now().UTC(),
+		})
+		if err != nil {
+			tm.logger.Error("failed to record dead letter", "task_id", s.ID, "error", err)
+		}
+		interrupted++
+	}
+	return interrupted
+}
+
+// isDraining reports whether Drain has been called.
+func (tm *TaskManager) isDraining() bool {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	return tm.draining
+}
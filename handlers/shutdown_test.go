@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/queue"
+	"2025-08-02/task"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	release := make(chan struct{})
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	defer files.Close()
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	tm.deadLetters = queue.NewFileDeadLetterSink(filepath.Join(t.TempDir(), "dead-letters.jsonl"))
+
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/slow.pdf")
+	deadline := time.Now().Add(5 * time.Second)
+	for partialArchives(t) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("no partial archive while the download is blocked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := tm.Drain(50 * time.Millisecond); n != 1 {
+		t.Fatalf("Drain interrupted %d tasks, want 1", n)
+	}
+	if w := serve(tm.CreateTaskHandler, http.MethodPost, `{}`, nil, nil); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("create task while draining: status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	w := httptest.NewRecorder()
+	tm.DeadLettersHandler(w, httptest.NewRequest(http.MethodGet, "/admin/dead-letters", nil))
+	var records []queue.DeadLetter
+	if err := json.Unmarshal(w.Body.Bytes(), &records); err != nil {
+		t.Fatalf("dead letters %q: %v", w.Body, err)
+	}
+	if len(records) != 1 || records[0].TaskID != id || !slices.Equal(records[0].ErrorCodes, []string{string(task.CodeInterrupted)}) {
+		t.Fatalf("dead letters %+v, want the interrupted task", records)
+	}
+
+	status := getStatus(t, tm, id)
+	if status.Status != "canceled" {
+		t.Fatalf("interrupted task is %s, want canceled", status.Status)
+	}
+	if partial := partialArchives(t); partial != nil {
+		t.Fatalf("partial archives %v left after Drain", partial)
+	}
+
+	// The blocked download finishing cannot complete the task any more.
+	close(release)
+	waitIdle(t, tm)
+	if status := getStatus(t, tm, id); status.Status != "canceled" {
+		t.Fatalf("interrupted task became %s", status.Status)
+	}
+	if _, err := os.Stat(id + ".zip"); !os.IsNotExist(err) {
+		t.Fatalf("interrupted task produced an archive: %v", err)
+	}
+}
+
+// partialArchives returns the archives being written in the working
+// directory.
+func partialArchives(t *testing.T) []string {
+	t.Helper()
+	matches, err := filepath.Glob("*.zip.*.tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestDrainIdle(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+	waitIdle(t, tm)
+	if n := tm.Drain(time.Second); n != 0 {
+		t.Fatalf("Drain interrupted %d tasks with none running", n)
+	}
+}
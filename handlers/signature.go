@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+)
+
+// ServeSignatureHandler serves the detached signature of a task's archive
+// @Summary      Download an archive signature
+// @Description  returns the raw 64-byte Ed25519ph (SHA-512 prehash) signature of the task's archive
+// @Tags         archives
+// @Produce      octet-stream
+// @Param        id   path      string  true  "Task ID"
+// @Success      200 {file}  file "Signature"
+// @Failure      404 {string} string "signature not found"
+// @Router       /tasks/{id}/archive.sig [get]
+func (tm *TaskManager) ServeSignatureHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+	tm.requestLogger(r).Debug("ServeSignatureHandler called", "task_id", taskID)
+
+	tm.mutex.Lock()
+	t, ok := tm.Tasks[taskID]
+	tm.mutex.Unlock()
+	if !ok || !tm.ownedBy(r, t) {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+
+	signature := t.Signature()
+	if signature == nil {
+		http.Error(w, "signature not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.sig\"", filepath.Base(t.ArchiveName())))
+	w.Write(signature)
+}
+
+// SigningKeyHandler serves the public key used to sign archives
+// @Summary      Get the archive signing key
+// @Description  returns the PEM-encoded ed25519 public key; its ID matches signing_key_id in task status
+// @Tags         archives
+// @Produce      application/x-pem-file
+// @Success      200 {string} string "PEM public key"
+// @Failure      404 {string} string "signing is disabled"
+// @Router       /signing-key [get]
+func (tm *TaskManager) SigningKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if tm.config.SigningKey == nil {
+		http.Error(w, "signing is disabled", http.StatusNotFound)
+		return
+	}
+
+	pub := tm.config.SigningKey.Public().(ed25519.PublicKey)
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		tm.requestLogger(r).Error("failed to encode signing key", "error", err)
+		http.Error(w, "failed to encode key", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("X-Signing-Key-ID", task.SigningKeyID(pub))
+	pem.Encode(w, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestArchiveSignature(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) {
+		cfg.SigningKey = key
+		cfg.MaxFilesPerTask = 1
+	})
+	id := createTask(t, tm, `{}`, nil)
+	vars := map[string]string{"id": id}
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+
+	w := serve(tm.SigningKeyHandler, http.MethodGet, "", nil, nil)
+	block, _ := pem.Decode(w.Body.Bytes())
+	if block == nil {
+		t.Fatalf("signing key %q is not PEM", w.Body)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := parsed.(ed25519.PublicKey)
+
+	w = serve(tm.ServeSignatureHandler, http.MethodGet, "", vars, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("signature: status %d", w.Code)
+	}
+	signature := w.Body.Bytes()
+
+	archive, err := os.ReadFile(id + ".zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func(data []byte) error {
+		digest := sha512.Sum512(data)
+		return ed25519.VerifyWithOptions(pub, digest[:], signature, &ed25519.Options{Hash: crypto.SHA512})
+	}
+	if err := verify(archive); err != nil {
+		t.Fatalf("signature does not verify: %v", err)
+	}
+	archive[len(archive)/2] ^= 0xff
+	if err := verify(archive); err == nil {
+		t.Fatal("signature verifies a modified archive")
+	}
+}
+
+func TestArchiveSignatureDisabled(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	id := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	waitDone(t, tm, id)
+
+	if w := serve(tm.ServeSignatureHandler, http.MethodGet, "", map[string]string{"id": id}, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("signature: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := serve(tm.SigningKeyHandler, http.MethodGet, "", nil, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("signing key: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"2025-08-02/metrics"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// SLO returns router middleware that logs a warning and counts an
+// http.slo_breaches metric for every request slower than the latency budget
+// of its route. routesMs maps route patterns such as "/tasks/{id}" to their
+// budget in milliseconds, overriding defaultMs; a zero budget disables the
+// check.
+func SLO(defaultMs int, routesMs map[string]int, recorder metrics.Recorder) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if defaultMs <= 0 && len(routesMs) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routePattern(r)
+			ms, ok := routesMs[route]
+			if !ok {
+				ms = defaultMs
+			}
+			if ms <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			budget := time.Duration(ms) * time.Millisecond
+
+			started := time.Now()
+			next.ServeHTTP(w, r)
+			elapsed := time.Since(started)
+			if elapsed <= budget {
+				return
+			}
+			slog.Warn("SLO breach", "method", r.Method, "route", route, "request_id", requestIDFrom(r), "duration", elapsed.Round(time.Millisecond), "budget", budget)
+			recorder.Count("http.slo_breaches", 1, "route:"+route, "method:"+r.Method)
+		})
+	}
+}
+
+// routePattern returns the path template of the route matched for r, or
+// the request path outside the router.
+func routePattern(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if pattern, err := route.GetPathTemplate(); err == nil {
+			return pattern
+		}
+	}
+	return r.URL.Path
+}
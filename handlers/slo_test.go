@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// countRecorder records the counters it receives as "name tag,tag".
+type countRecorder struct {
+	mutex  sync.Mutex
+	counts []string
+}
+
+func (c *countRecorder) Count(name string, value int64, tags ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts = append(c.counts, name+" "+strings.Join(tags, ","))
+}
+
+func (c *countRecorder) Timing(string, time.Duration, ...string) {}
+
+func TestSLO(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+	})
+	recorder := &countRecorder{}
+	r := mux.NewRouter()
+	r.Handle("/tasks/{id}", slow).Methods("GET")
+	r.Handle("/archives/{filename}", slow).Methods("GET")
+	r.Handle("/healthz", slow).Methods("GET")
+	r.Handle("/fast", okHandler).Methods("GET")
+	r.Use(SLO(10, map[string]int{"/archives/{filename}": 1000, "/healthz": 0}, recorder))
+
+	for _, path := range []string{"/tasks/1", "/archives/a.zip", "/healthz", "/fast"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	// Only /tasks/{id} is over its budget.
+	want := []string{"http.slo_breaches route:/tasks/{id},method:GET"}
+	if !slices.Equal(recorder.counts, want) {
+		t.Fatalf("counted %q, want %q", recorder.counts, want)
+	}
+}
+
+func TestSLODisabled(t *testing.T) {
+	recorder := &countRecorder{}
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+	})
+	SLO(0, nil, recorder)(slow).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/tasks", nil))
+	if len(recorder.counts) != 0 {
+		t.Fatalf("counted %q with no budgets", recorder.counts)
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"net/http"
+)
+
+// taskSummary is a point-in-time view of a task that can be used without
+// holding any lock.
+type taskSummary struct {
+	ID      string
+	GroupID string
+	Status  task.Status
+	Task    *task.Task
+}
+
+// snapshot returns summaries of every task. tm.mutex is held only while the
+// task pointers are copied; per-task state is read afterwards, so slow work
+// on the result never blocks other requests.
+func (tm *TaskManager) snapshot() []taskSummary {
+	tm.mutex.Lock()
+	tasks := make([]*task.Task, 0, len(tm.Tasks))
+	for _, t := range tm.Tasks {
+		tasks = append(tasks, t)
+	}
+	tm.mutex.Unlock()
+
+	return summarize(tasks)
+}
+
+// groupSnapshot returns summaries of the group's tasks that belong to the
+// client of r, in creation order, and whether the group has any. Group IDs
+// are chosen by clients, so members of other clients are left out and a
+// group made only of them does not exist for r.
+func (tm *TaskManager) groupSnapshot(r *http.Request, groupID string) ([]taskSummary, bool) {
+	tm.mutex.Lock()
+	ids := tm.groups[groupID]
+	tasks := make([]*task.Task, 0, len(ids))
+	for _, id := range ids {
+		if t, found := tm.Tasks[id]; found && tm.ownedBy(r, t) {
+			tasks = append(tasks, t)
+		}
+	}
+	tm.mutex.Unlock()
+
+	return summarize(tasks), len(tasks) > 0
+}
+
+func summarize(tasks []*task.Task) []taskSummary {
+	summaries := make([]taskSummary, len(tasks))
+	for i, t := range tasks {
+		summaries[i] = taskSummary{ID: t.ID, GroupID: t.GroupID, Status: t.State(), Task: t}
+	}
+	return summaries
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/task"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestGroupSnapshot(t *testing.T) {
+	tm := newTestManager(t, nil)
+	var want []string
+	for range 3 {
+		want = append(want, createTask(t, tm, `{"group_id": "g1"}`, nil))
+	}
+	createTask(t, tm, `{"group_id": "g2"}`, nil)
+
+	summaries, ok := tm.groupSnapshot(httptest.NewRequest(http.MethodGet, "/groups/g1", nil), "g1")
+	if !ok || len(summaries) != len(want) {
+		t.Fatalf("groupSnapshot = %d summaries, %v, want %d", len(summaries), ok, len(want))
+	}
+	for i, summary := range summaries {
+		if summary.ID != want[i] || summary.GroupID != "g1" || summary.Status != task.StatusCreated || summary.Task != tm.Tasks[want[i]] {
+			t.Errorf("summary %d = %+v, want task %s in creation order", i, summary, want[i])
+		}
+	}
+	if _, ok := tm.groupSnapshot(httptest.NewRequest(http.MethodGet, "/groups/missing", nil), "missing"); ok {
+		t.Fatal("unknown group exists")
+	}
+	if got := len(tm.snapshot()); got != 4 {
+		t.Fatalf("snapshot has %d tasks, want 4", got)
+	}
+}
+
+// lockedSnapshot is the approach snapshot replaced: every task's state is
+// read while tm.mutex is held.
+func (tm *TaskManager) lockedSnapshot() []taskSummary {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	summaries := make([]taskSummary, 0, len(tm.Tasks))
+	for _, t := range tm.Tasks {
+		summaries = append(summaries, taskSummary{ID: t.ID, GroupID: t.GroupID, Status: t.State(), Task: t})
+	}
+	return summaries
+}
+
+// BenchmarkSnapshotContention measures the cost of task lookups, which take
+// tm.mutex like every handler does, while other goroutines keep listing all
+// tasks, once with the old fully locked listing and once with snapshot.
+func BenchmarkSnapshotContention(b *testing.B) {
+	tm := NewTaskManager(&config.Config{MaxConcurrentTasks: 1}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for i := range 2000 {
+		t := task.NewTask()
+		t.GroupID = "g" + strconv.Itoa(i%10)
+		tm.Tasks[t.ID] = t
+	}
+	var ids []string
+	for id := range tm.Tasks {
+		ids = append(ids, id)
+	}
+
+	for _, bench := range []struct {
+		name string
+		list func() []taskSummary
+	}{
+		{"locked", tm.lockedSnapshot},
+		{"snapshot", tm.snapshot},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						bench.list()
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					tm.mutex.Lock()
+					_ = tm.Tasks[ids[i%len(ids)]]
+					tm.mutex.Unlock()
+					i++
+				}
+			})
+			b.StopTimer()
+			close(stop)
+			<-done
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	// errDraining is returned for tasks that would start while the server
+	// shuts down.
+	errDraining = errors.New("server is shutting down")
+	// errAlreadyStarted is returned for tasks whose processing was already
+	// started, by another request or on reaching their file count.
+	errAlreadyStarted = errors.New("task has already been started")
+	// errBusy is returned for tasks that cannot start because every one
+	// of the max_concurrent_tasks slots is taken.
+	errBusy = errors.New("server is busy, please try again later")
+)
+
+// StartTaskHandler starts processing a task before it has all its files
+// @Summary      Start processing a task
+// @Description  starts archiving the files added so far, for tasks with fewer files than max_files_per_task or their expected_files
+// @Tags         tasks
+// @Param        id   path      string  true  "Task ID"
+// @Success      202
+// @Failure      404 {string} string "task not found"
+// @Failure      409 {string} string "task has no files or is already processing or finished"
+// @Failure      503 {string} string "server is busy or shutting down"
+// @Header       503 {integer} Retry-After "seconds after which to try again"
+// @Router       /tasks/{id}/process [post]
+func (tm *TaskManager) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+	tm.requestLogger(r).Debug("StartTaskHandler called", "task_id", taskID)
+
+	tm.mutex.Lock()
+	t, ok := tm.Tasks[taskID]
+	tm.mutex.Unlock()
+	if !ok || !tm.ownedBy(r, t) {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+	if status := t.State(); status != task.StatusCreated {
+		http.Error(w, fmt.Sprintf("task is %s and cannot be started", status), http.StatusConflict)
+		return
+	}
+	if t.FileCount() == 0 {
+		http.Error(w, "task has no files", http.StatusConflict)
+		return
+	}
+
+	tm.requestLogger(r).Info("task was started", "task_id", t.ID, "files", t.FileCount())
+	err := tm.startProcessing(t)
+	switch {
+	case errors.Is(err, errBusy):
+		tm.writeBusy(w, err.Error())
+		return
+	case errors.Is(err, errDraining):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// startProcessing archives t in the background, or shares the run of an
+// identical task with task coalescing. Without a free task slot t stays
+// in StatusCreated and errBusy is returned, so it can be started again
+// later. Once started, later calls fail with errAlreadyStarted.
+func (tm *TaskManager) startProcessing(t *task.Task) error {
+	finished, err := tm.claimRun(t)
+	if err != nil {
+		return err
+	}
+
+	if leader := tm.coalesceLeader(t); leader != nil {
+		if err := t.MarkProcessing(); err != nil {
+			finished()
+			return err
+		}
+		tm.saveTask(t)
+		go func() {
+			defer finished()
+			t.Mirror(leader)
+			tm.saveTask(t)
+			tm.releaseUploads(t.ID)
+			tm.publishCompletion(t)
+		}()
+		return nil
+	}
+	select {
+	case tm.concurrentTaskSema <- struct{}{}:
+	default:
+		tm.dropCoalesceLeader(t)
+		finished()
+		return errBusy
+	}
+	tm.assignArchiveName(t)
+	// The processing status is saved up front, so a restart midway finds
+	// the task interrupted rather than still waiting for files.
+	if err := t.MarkProcessing(); err != nil {
+		tm.releaseArchiveName(t)
+		<-tm.concurrentTaskSema
+		finished()
+		return err
+	}
+	tm.saveTask(t)
+	if t.ProgressCallbackURL != "" {
+		tm.forwardProgress(t)
+	}
+	go func() {
+		defer finished()
+		defer func() { <-tm.concurrentTaskSema }()
+		t.Process(tm.env)
+		tm.saveTask(t)
+		tm.releaseUploads(t.ID)
+		tm.publishCompletion(t)
+	}()
+	return nil
+}
+
+// claimRun marks t as started and counts its run as in flight. It fails
+// with errDraining during shutdown and with errAlreadyStarted if t was
+// claimed before. The returned func ends the run.
+func (tm *TaskManager) claimRun(t *task.Task) (func(), error) {
+	// The in-flight count is raised under the mutex, so Drain either sees
+	// this task or has already stopped it from starting.
+	tm.mutex.Lock()
+	if tm.draining {
+		tm.mutex.Unlock()
+		return nil, errDraining
+	}
+	if tm.starting[t.ID] {
+		tm.mutex.Unlock()
+		return nil, errAlreadyStarted
+	}
+	tm.starting[t.ID] = true
+	tm.inFlight.Add(1)
+	tm.mutex.Unlock()
+	tm.activeTasks.Add(1)
+
+	return func() {
+		tm.activeTasks.Add(-1)
+		tm.mutex.Lock()
+		delete(tm.starting, t.ID)
+		tm.mutex.Unlock()
+		tm.inFlight.Done()
+	}, nil
+}
+
+// hasFreeTaskSlot reports whether a task could start processing now. The
+// slot is only probed the way startProcessing takes it, not kept.
+func (tm *TaskManager) hasFreeTaskSlot() bool {
+	select {
+	case tm.concurrentTaskSema <- struct{}{}:
+		<-tm.concurrentTaskSema
+		return true
+	default:
+		return false
+	}
+}
+
+// writeBusy answers 503 with a Retry-After of busy_retry_after_seconds.
+func (tm *TaskManager) writeBusy(w http.ResponseWriter, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(tm.config.BusyRetryAfterSeconds))
+	http.Error(w, message, http.StatusServiceUnavailable)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/task"
+	"maps"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestStartTask(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 3 })
+	id := createTask(t, tm, `{}`, nil)
+	vars := map[string]string{"id": id}
+	addFile(t, tm, id, files.URL+"/a.pdf")
+
+	if w := serve(tm.StartTaskHandler, http.MethodPost, "", vars, nil); w.Code != http.StatusAccepted {
+		t.Fatalf("start: status %d: %s", w.Code, w.Body)
+	}
+	waitDone(t, tm, id)
+	if w := serve(tm.StartTaskHandler, http.MethodPost, "", vars, nil); w.Code != http.StatusConflict {
+		t.Fatalf("second start: status %d, want %d", w.Code, http.StatusConflict)
+	}
+	want := map[string]string{"a.pdf": "%PDF-1.4 /a.pdf"}
+	if got := readArchive(t, id+".zip"); !maps.Equal(got, want) {
+		t.Fatalf("archive %q, want %q", got, want)
+	}
+}
+
+func TestStartTaskHandler(t *testing.T) {
+	tm := newTestManager(t, nil)
+	empty := createTask(t, tm, `{}`, nil)
+	withFile := createTask(t, tm, `{}`, nil)
+	addFile(t, tm, withFile, "https://example.com/a.pdf")
+	if err := tm.Tasks[withFile].Cancel("test"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		taskID string
+		want   int
+	}{
+		{"no files", empty, http.StatusConflict},
+		{"not created", withFile, http.StatusConflict},
+		{"unknown", "missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(tm.StartTaskHandler, http.MethodPost, "", map[string]string{"id": tt.taskID}, nil); w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestStartTaskBusy(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	id := createTask(t, tm, `{}`, nil)
+	vars := map[string]string{"id": id}
+	// Every task slot is taken.
+	for range cap(tm.concurrentTaskSema) {
+		tm.concurrentTaskSema <- struct{}{}
+	}
+
+	// The last file is added, but the task cannot start.
+	w := serve(tm.AddFileHandler, http.MethodPost, `{"url": "`+files.URL+`/a.pdf"}`, vars, nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("add file: status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	w = serve(tm.StartTaskHandler, http.MethodPost, "", vars, nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("start: status %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if got, want := w.Header().Get("Retry-After"), strconv.Itoa(tm.config.BusyRetryAfterSeconds); got != want {
+		t.Fatalf("Retry-After %q, want %q", got, want)
+	}
+	if state := tm.Tasks[id].State(); state != task.StatusCreated {
+		t.Fatalf("task is %s, want it left %s to be started again", state, task.StatusCreated)
+	}
+	if len(tm.archives) != 0 {
+		t.Fatalf("busy start reserved archive names: %v", tm.archives)
+	}
+
+	for range cap(tm.concurrentTaskSema) {
+		<-tm.concurrentTaskSema
+	}
+	if w := serve(tm.StartTaskHandler, http.MethodPost, "", vars, nil); w.Code != http.StatusAccepted {
+		t.Fatalf("start once a slot is free: status %d: %s", w.Code, w.Body)
+	}
+	waitDone(t, tm, id)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// StreamTaskHandler builds a task's archive straight into the response
+// @Summary      Stream a task's archive
+// @Description  archives the files added so far and streams the archive in the response as it is built, without storing it; the task ends up done without a result_url, or failed if the stream was cut short
+// @Tags         tasks
+// @Produce      application/zip
+// @Produce      application/gzip
+// @Param        id   path      string  true  "Task ID"
+// @Success      200 {file}  file "Archive"
+// @Failure      404 {string} string "task not found"
+// @Failure      409 {string} string "task has no files or is already processing or finished"
+// @Failure      503 {string} string "server is busy or shutting down"
+// @Header       503 {integer} Retry-After "seconds after which to try again"
+// @Router       /tasks/{id}/stream [post]
+func (tm *TaskManager) StreamTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+	tm.requestLogger(r).Debug("StreamTaskHandler called", "task_id", taskID)
+
+	tm.mutex.Lock()
+	t, ok := tm.Tasks[taskID]
+	tm.mutex.Unlock()
+	if !ok || !tm.ownedBy(r, t) {
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+	if status := t.State(); status != task.StatusCreated {
+		http.Error(w, fmt.Sprintf("task is %s and cannot be streamed", status), http.StatusConflict)
+		return
+	}
+	if t.FileCount() == 0 {
+		http.Error(w, "task has no files", http.StatusConflict)
+		return
+	}
+
+	finished, err := tm.claimRun(t)
+	switch {
+	case errors.Is(err, errDraining):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	defer finished()
+	select {
+	case tm.concurrentTaskSema <- struct{}{}:
+	default:
+		tm.writeBusy(w, errBusy.Error())
+		return
+	}
+	defer func() { <-tm.concurrentTaskSema }()
+	if err := t.MarkProcessing(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	tm.saveTask(t)
+	if t.ProgressCallbackURL != "" {
+		tm.forwardProgress(t)
+	}
+
+	format := t.Format()
+	w.Header().Set("Content-Type", task.FormatContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s%s\"", t.ID, task.FormatExtension(format)))
+	// Once the first bytes are out the status is sent, so a failure can
+	// only cut the archive short; the task records why.
+	t.Stream(r.Context(), tm.env, w)
+	tm.saveTask(t)
+	tm.releaseUploads(t.ID)
+	tm.publishCompletion(t)
+}
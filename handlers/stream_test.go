@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStreamTask(t *testing.T) {
+	files := fileServer(t)
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 3 })
+	id := createTask(t, tm, `{}`, nil)
+	vars := map[string]string{"id": id}
+	addFile(t, tm, id, files.URL+"/a.pdf")
+	addFile(t, tm, id, files.URL+"/b.pdf")
+
+	w := serve(tm.StreamTaskHandler, http.MethodPost, "", vars, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("stream: status %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/zip" {
+		t.Fatalf("Content-Type %q", got)
+	}
+	want := map[string]string{"a.pdf": "%PDF-1.4 /a.pdf", "b.pdf": "%PDF-1.4 /b.pdf"}
+	if got := readZip(t, w.Body.Bytes()); !maps.Equal(got, want) {
+		t.Fatalf("streamed archive %q, want %q", got, want)
+	}
+
+	status := getStatus(t, tm, id)
+	if status.Status != "done" || status.ResultURL != "" {
+		t.Fatalf("task is %s with result URL %q, want done without one", status.Status, status.ResultURL)
+	}
+	if stored, _ := filepath.Glob("*.zip*"); len(stored) != 0 {
+		t.Fatalf("streaming stored %v", stored)
+	}
+	if w := serve(tm.StreamTaskHandler, http.MethodPost, "", vars, nil); w.Code != http.StatusConflict {
+		t.Fatalf("second stream: status %d, want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestStreamTaskBusy(t *testing.T) {
+	tm := newTestManager(t, nil)
+	id := createTask(t, tm, `{}`, nil)
+	vars := map[string]string{"id": id}
+	if w := serve(tm.StreamTaskHandler, http.MethodPost, "", vars, nil); w.Code != http.StatusConflict {
+		t.Fatalf("stream without files: status %d, want %d", w.Code, http.StatusConflict)
+	}
+	addFile(t, tm, id, "https://example.com/a.pdf")
+	for range cap(tm.concurrentTaskSema) {
+		tm.concurrentTaskSema <- struct{}{}
+	}
+
+	w := serve(tm.StreamTaskHandler, http.MethodPost, "", vars, nil)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("status %d with Retry-After %q, want %d with one", w.Code, w.Header().Get("Retry-After"), http.StatusServiceUnavailable)
+	}
+	if status := getStatus(t, tm, id); status.Status != "created" {
+		t.Fatalf("task is %s after a busy stream", status.Status)
+	}
+	if _, err := os.Stat(id + ".zip"); !os.IsNotExist(err) {
+		t.Fatalf("archive stored: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestExtendTTLOnAccess(t *testing.T) {
+	tests := []struct {
+		name   string
+		extend bool
+	}{
+		{"enabled", true},
+		{"disabled", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := fileServer(t)
+			tm := newTestManager(t, func(cfg *config.Config) {
+				cfg.MaxFilesPerTask = 1
+				cfg.ArchiveMaxAgeSeconds = 3600
+				cfg.ExtendTTLOnAccess = tt.extend
+			})
+			id := createTask(t, tm, `{}`, nil)
+			addFile(t, tm, id, files.URL+"/a.pdf")
+			waitDone(t, tm, id)
+			waitIdle(t, tm)
+			modified := time.Now().Add(-30 * time.Minute).Truncate(time.Second)
+			if err := os.Chtimes(id+".zip", modified, modified); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, serveOnce := range []func() int{
+				func() int {
+					return serve(tm.ServeArchiveHandler, http.MethodGet, "", map[string]string{"filename": id + ".zip"}, nil).Code
+				},
+				func() int {
+					return serve(tm.ServeArchiveEntryHandler, http.MethodGet, "", map[string]string{"id": id, "name": "a.pdf"}, nil).Code
+				},
+			} {
+				if code := serveOnce(); code != http.StatusOK {
+					t.Fatalf("serve: status %d", code)
+				}
+			}
+
+			info, err := os.Stat(id + ".zip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if touched := info.ModTime().After(modified); touched != tt.extend {
+				t.Fatalf("archive modified at %v after serving, extend_ttl_on_access %v", info.ModTime(), tt.extend)
+			}
+			expiresAt := tm.Tasks[id].ExpiresAt
+			if tt.extend && (expiresAt == nil || expiresAt.Before(time.Now().Add(59*time.Minute))) {
+				t.Fatalf("expires_at %v, want about an hour from now", expiresAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"2025-08-02/task"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// tusVersion is the only tus protocol version supported.
+const tusVersion = "1.0.0"
+
+// upload is an in-progress tus upload targeting a task.
+type upload struct {
+	ID        string
+	TaskID    string
+	FileName  string
+	Length    int64
+	Offset    int64
+	UpdatedAt time.Time
+	// busy is set while a chunk is being copied, which happens without
+	// holding mutex.
+	busy  bool
+	mutex sync.Mutex
+}
+
+func (tm *TaskManager) uploadPath(uploadID string) string {
+	return filepath.Join(tm.config.UploadDir, uploadID)
+}
+
+// UploadOptionsHandler advertises tus protocol support
+// @Summary      Discover tus upload support
+// @Description  returns the supported tus version, extensions and maximum upload size
+// @Tags         uploads
+// @Param        id   path      string  true  "Task ID"
+// @Success      204
+// @Router       /tasks/{id}/uploads [options]
+func (tm *TaskManager) UploadOptionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", "creation")
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(tm.config.MaxUploadSize, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateUploadHandler starts a resumable upload for a task
+// @Summary      Create a resumable upload
+// @Description  creates a tus upload whose contents become an entry of the task's archive
+// @Tags         uploads
+// @Param        id               path      string  true  "Task ID"
+// @Param        Tus-Resumable    header    string  true  "tus protocol version (1.0.0)"
+// @Param        Upload-Length    header    int     true  "Total upload size in bytes"
+// @Param        Upload-Metadata  header    string  false "tus metadata, e.g. filename <base64>"
+// @Success      201
+// @Header       201 {string} Location "URL of the created upload"
+// @Failure      400 {string} string "invalid Upload-Length"
+// @Failure      404 {string} string "task not found"
+// @Failure      409 {string} string "task no longer accepts files, is full or has too many uploads in progress"
+// @Failure      412 {string} string "unsupported tus version"
+// @Failure      413 {string} string "upload exceeds max size"
+// @Router       /tasks/{id}/uploads [post]
+func (tm *TaskManager) CreateUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if !checkTusVersion(w, r) {
+		return
+	}
+
+	taskID := mux.Vars(r)["id"]
+	tm.requestLogger(r).Debug("CreateUploadHandler called", "task_id", taskID)
+
+	tm.mutex.Lock()
+	t, ok := tm.Tasks[taskID]
+	tm.mutex.Unlock()
+	if !ok || !tm.ownedBy(r, t) {
+		tm.requestLogger(r).Debug("task not found", "task_id", taskID)
+		http.Error(w, "task not found", http.StatusNotFound)
+		return
+	}
+	if t.State() != task.StatusCreated {
+		http.Error(w, "task no longer accepts files", http.StatusConflict)
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "invalid Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if length > tm.config.MaxUploadSize {
+		http.Error(w, "upload exceeds max size", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	u := &upload{
+		ID:        uuid.New().String(),
+		TaskID:    taskID,
+		Length:    length,
+		UpdatedAt: time.Now(),
+	}
+	u.FileName = task.SanitizeFileName(parseTusMetadata(r.Header.Get("Upload-Metadata"))["filename"])
+	if u.FileName == "" {
+		u.FileName = u.ID
+	}
+
+	if err := os.MkdirAll(tm.config.UploadDir, 0o755); err != nil {
+		tm.requestLogger(r).Error("failed to create upload directory", "error", err)
+		http.Error(w, "failed to create upload", http.StatusInternalServerError)
+		return
+	}
+	file, err := os.OpenFile(tm.uploadPath(u.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		tm.requestLogger(r).Error("failed to create upload file", "upload_id", u.ID, "error", err)
+		http.Error(w, "failed to create upload", http.StatusInternalServerError)
+		return
+	}
+	file.Close()
+
+	// The limits are checked while registering the upload, so concurrent
+	// creations cannot both take the last slot.
+	tm.mutex.Lock()
+	pending := tm.pendingUploads(taskID)
+	var reason string
+	switch {
+	case t.FileCount()+pending >= t.FileLimit(tm.config.MaxFilesPerTask):
+		reason = "task is full"
+	case pending >= tm.config.MaxUploadsPerTask:
+		reason = "too many uploads in progress"
+	default:
+		tm.uploads[u.ID] = u
+	}
+	tm.mutex.Unlock()
+	if reason != "" {
+		os.Remove(tm.uploadPath(u.ID))
+		http.Error(w, reason, http.StatusConflict)
+		return
+	}
+	tm.requestLogger(r).Info("created upload", "task_id", taskID, "upload_id", u.ID, "size", length)
+
+	if length == 0 {
+		tm.completeUpload(u)
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Location", "/uploads/"+u.ID)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// UploadOffsetHandler reports how much of an upload has been received
+// @Summary      Get upload offset
+// @Description  returns the current Upload-Offset so an interrupted upload can be resumed
+// @Tags         uploads
+// @Param        uid              path      string  true  "Upload ID"
+// @Param        Tus-Resumable    header    string  true  "tus protocol version (1.0.0)"
+// @Success      200
+// @Header       200 {int} Upload-Offset "Bytes received so far"
+// @Header       200 {int} Upload-Length "Total upload size"
+// @Failure      404 {string} string "upload not found"
+// @Router       /uploads/{uid} [head]
+func (tm *TaskManager) UploadOffsetHandler(w http.ResponseWriter, r *http.Request) {
+	if !checkTusVersion(w, r) {
+		return
+	}
+
+	u := tm.lookupUpload(r, mux.Vars(r)["uid"])
+	if u == nil {
+		http.Error(w, "upload not found", http.StatusNotFound)
+		return
+	}
+
+	u.mutex.Lock()
+	offset, length := u.Offset, u.Length
+	u.mutex.Unlock()
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(length, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// UploadPatchHandler appends a chunk to an upload
+// @Summary      Upload a chunk
+// @Description  appends the request body at Upload-Offset; the file is added to the task once complete
+// @Tags         uploads
+// @Accept       application/offset+octet-stream
+// @Param        uid              path      string  true  "Upload ID"
+// @Param        Tus-Resumable    header    string  true  "tus protocol version (1.0.0)"
+// @Param        Upload-Offset    header    int     true  "Offset the chunk starts at"
+// @Success      204
+// @Header       204 {int} Upload-Offset "Bytes received so far"
+// @Failure      404 {string} string "upload not found"
+// @Failure      409 {string} string "offset mismatch or upload in progress"
+// @Failure      415 {string} string "unsupported content type"
+// @Router       /uploads/{uid} [patch]
+func (tm *TaskManager) UploadPatchHandler(w http.ResponseWriter, r *http.Request) {
+	if !checkTusVersion(w, r) {
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	u := tm.lookupUpload(r, mux.Vars(r)["uid"])
+	if u == nil {
+		http.Error(w, "upload not found", http.StatusNotFound)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid Upload-Offset", http.StatusBadRequest)
+		return
+	}
+
+	if status, err := u.beginChunk(offset); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	written, copyErr := tm.appendChunk(u, offset, r.Body)
+	newOffset, complete := u.endChunk(written)
+	if copyErr != nil {
+		http.Error(w, copyErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if complete {
+		tm.completeUpload(u)
+	}
+
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// beginChunk claims u for a chunk starting at offset. The chunk is then
+// copied without holding u.mutex, so a slow client never blocks HEAD
+// requests or RemoveStaleUploads.
+func (u *upload) beginChunk(offset int64) (int, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	switch {
+	case u.busy:
+		return http.StatusConflict, fmt.Errorf("upload in progress")
+	case offset != u.Offset:
+		return http.StatusConflict, fmt.Errorf("offset mismatch")
+	case u.Offset == u.Length:
+		return http.StatusConflict, fmt.Errorf("upload already complete")
+	}
+	u.busy = true
+	return http.StatusOK, nil
+}
+
+// endChunk records the bytes written by a chunk, releases u and reports the
+// new offset and whether the upload is now complete.
+func (u *upload) endChunk(written int64) (int64, bool) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.Offset += written
+	u.UpdatedAt = time.Now()
+	u.busy = false
+	return u.Offset, u.Offset == u.Length
+}
+
+// appendChunk writes body to the upload file, which holds offset bytes so
+// far, and returns the number of bytes written. Callers must have claimed
+// u with beginChunk.
+func (tm *TaskManager) appendChunk(u *upload, offset int64, body io.Reader) (int64, error) {
+	file, err := os.OpenFile(tm.uploadPath(u.ID), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		tm.logger.Error("failed to open upload file", "upload_id", u.ID, "error", err)
+		return 0, fmt.Errorf("failed to write upload")
+	}
+
+	// Bytes that made it to disk before an interruption still count, so the
+	// client can resume from the offset reported by HEAD.
+	written, err := io.Copy(file, io.LimitReader(body, u.Length-offset))
+	file.Close()
+	if err != nil {
+		tm.logger.Warn("upload interrupted", "upload_id", u.ID, "offset", offset+written, "error", err)
+		return written, fmt.Errorf("upload interrupted")
+	}
+	return written, nil
+}
+
+// pendingUploads counts the incomplete uploads of a task. Callers must hold
+// tm.mutex.
+func (tm *TaskManager) pendingUploads(taskID string) int {
+	pending := 0
+	for _, u := range tm.uploads {
+		if u.TaskID != taskID {
+			continue
+		}
+		u.mutex.Lock()
+		if u.Offset < u.Length {
+			pending++
+		}
+		u.mutex.Unlock()
+	}
+	return pending
+}
+
+// lookupUpload returns the upload with uploadID, or nil when it does not
+// exist or its task belongs to another client.
+func (tm *TaskManager) lookupUpload(r *http.Request, uploadID string) *upload {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	u, ok := tm.uploads[uploadID]
+	if !ok {
+		return nil
+	}
+	if t, found := tm.Tasks[u.TaskID]; !found || !tm.ownedBy(r, t) {
+		return nil
+	}
+	return u
+}
+
+// completeUpload adds a fully received upload to its task as a file source.
+func (tm *TaskManager) completeUpload(u *upload) {
+	tm.mutex.Lock()
+	t, ok := tm.Tasks[u.TaskID]
+	tm.mutex.Unlock()
+	if !ok {
+		tm.logger.Warn("task of upload no longer exists", "task_id", u.TaskID, "upload_id", u.ID)
+		return
+	}
+
+	tm.logger.Info("upload complete, adding file", "task_id", u.TaskID, "upload_id", u.ID, "filename", u.FileName)
+	if err := t.AddFile(task.UploadURL(u.ID, u.FileName), "", nil, tm.config.MaxFilesPerTask, tm.config.MaxDistinctHostsPerTask); err != nil {
+		tm.logger.Warn("dropping upload", "task_id", u.TaskID, "upload_id", u.ID, "error", err)
+		tm.mutex.Lock()
+		delete(tm.uploads, u.ID)
+		tm.mutex.Unlock()
+		os.Remove(tm.uploadPath(u.ID))
+		return
+	}
+	tm.saveTask(t)
+	// A task that is busy stays created and is started later through
+	// POST /tasks/{id}/process; the upload itself is complete.
+	tm.maybeStartProcessing(t)
+}
+
+// RemoveStaleUploads deletes incomplete uploads that have not received data
+// within the configured expiry. Uploads receiving a chunk are never stale.
+func (tm *TaskManager) RemoveStaleUploads() {
+	maxAge := time.Duration(tm.config.UploadExpirySeconds) * time.Second
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	for id, u := range tm.uploads {
+		u.mutex.Lock()
+		stale := !u.busy && u.Offset < u.Length && time.Since(u.UpdatedAt) > maxAge
+		u.mutex.Unlock()
+		if !stale {
+			continue
+		}
+		tm.logger.Info("deleting abandoned upload", "upload_id", id)
+		os.Remove(tm.uploadPath(id))
+		delete(tm.uploads, id)
+	}
+}
+
+// releaseUploads forgets and deletes the uploads of a processed task.
+func (tm *TaskManager) releaseUploads(taskID string) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	for id, u := range tm.uploads {
+		if u.TaskID != taskID {
+			continue
+		}
+		os.Remove(tm.uploadPath(id))
+		delete(tm.uploads, id)
+	}
+}
+
+func checkTusVersion(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, fmt.Sprintf("unsupported tus version, expected %s", tusVersion), http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// parseTusMetadata decodes an Upload-Metadata header of comma-separated
+// "key base64value" pairs.
+func parseTusMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 {
+			continue
+		}
+		var value string
+		if len(fields) > 1 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				continue
+			}
+			value = string(decoded)
+		}
+		metadata[fields[0]] = value
+	}
+	return metadata
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"2025-08-02/config"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// interruptedReader returns data and then fails, like a dropped connection.
+type interruptedReader struct {
+	data io.Reader
+}
+
+func (r *interruptedReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+// tusRequest runs handler on a tus request for the upload uid.
+func tusRequest(handler http.HandlerFunc, method, uid string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", body)
+	r.Header.Set("Tus-Resumable", tusVersion)
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	r = mux.SetURLVars(r, map[string]string{"uid": uid})
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// createUpload starts an upload of length bytes named fileName for the task.
+func createUpload(t *testing.T, tm *TaskManager, taskID, fileName string, length int) string {
+	t.Helper()
+	metadata := "filename " + base64.StdEncoding.EncodeToString([]byte(fileName))
+	w := serve(tm.CreateUploadHandler, http.MethodPost, "", map[string]string{"id": taskID}, map[string]string{
+		"Tus-Resumable":   tusVersion,
+		"Upload-Length":   strconv.Itoa(length),
+		"Upload-Metadata": metadata,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create upload: status %d: %s", w.Code, w.Body)
+	}
+	return strings.TrimPrefix(w.Header().Get("Location"), "/uploads/")
+}
+
+func patchHeaders(offset int) map[string]string {
+	return map[string]string{
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": strconv.Itoa(offset),
+	}
+}
+
+func TestUploadResume(t *testing.T) {
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 1 })
+	id := createTask(t, tm, `{}`, nil)
+	uid := createUpload(t, tm, id, "notes.pdf", len("hello world"))
+
+	// The connection drops after the first chunk reached the server.
+	w := tusRequest(tm.UploadPatchHandler, http.MethodPatch, uid, &interruptedReader{strings.NewReader("hello ")}, patchHeaders(0))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("interrupted patch: status %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+
+	w = tusRequest(tm.UploadOffsetHandler, http.MethodHead, uid, nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("head: status %d", w.Code)
+	}
+	offset := w.Header().Get("Upload-Offset")
+	if offset != "6" {
+		t.Fatalf("Upload-Offset %q after the interruption, want 6", offset)
+	}
+
+	// Resuming from the start conflicts with what was already received.
+	if w := tusRequest(tm.UploadPatchHandler, http.MethodPatch, uid, strings.NewReader("hello world"), patchHeaders(0)); w.Code != http.StatusConflict {
+		t.Fatalf("patch at 0: status %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	w = tusRequest(tm.UploadPatchHandler, http.MethodPatch, uid, strings.NewReader("world"), patchHeaders(6))
+	if w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "11" {
+		t.Fatalf("resume: status %d, Upload-Offset %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	waitDone(t, tm, id)
+	entries := readArchive(t, id+".zip")
+	if got := entries["notes.pdf"]; got != "hello world" {
+		t.Fatalf("notes.pdf = %q, want %q", got, "hello world")
+	}
+}
+
+func TestCreateUploadGuards(t *testing.T) {
+	tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxUploadSize = 10 })
+	id := createTask(t, tm, `{}`, nil)
+	tests := []struct {
+		name    string
+		taskID  string
+		headers map[string]string
+		want    int
+	}{
+		{"no tus version", id, map[string]string{"Upload-Length": "5"}, http.StatusPreconditionFailed},
+		{"no length", id, map[string]string{"Tus-Resumable": tusVersion}, http.StatusBadRequest},
+		{"too large", id, map[string]string{"Tus-Resumable": tusVersion, "Upload-Length": "11"}, http.StatusRequestEntityTooLarge},
+		{"unknown task", "missing", map[string]string{"Tus-Resumable": tusVersion, "Upload-Length": "5"}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tm.CreateUploadHandler, http.MethodPost, "", map[string]string{"id": tt.taskID}, tt.headers)
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateUploadLimits(t *testing.T) {
+	createStatus := func(tm *TaskManager, id string) int {
+		return serve(tm.CreateUploadHandler, http.MethodPost, "", map[string]string{"id": id}, map[string]string{
+			"Tus-Resumable": tusVersion,
+			"Upload-Length": "4",
+		}).Code
+	}
+
+	t.Run("file limit", func(t *testing.T) {
+		tm := newTestManager(t, func(cfg *config.Config) { cfg.MaxFilesPerTask = 2 })
+		id := createTask(t, tm, `{}`, nil)
+		addFile(t, tm, id, "https://example.com/a.pdf")
+		createUpload(t, tm, id, "b.pdf", 4)
+		// The open upload takes the last file slot.
+		if code := createStatus(tm, id); code != http.StatusConflict {
+			t.Fatalf("upload past the file limit: status %d, want %d", code, http.StatusConflict)
+		}
+	})
+
+	t.Run("upload cap", func(t *testing.T) {
+		tm := newTestManager(t, func(cfg *config.Config) {
+			cfg.MaxFilesPerTask = 5
+			cfg.MaxUploadsPerTask = 2
+		})
+		id := createTask(t, tm, `{}`, nil)
+		uid := createUpload(t, tm, id, "a.pdf", 4)
+		createUpload(t, tm, id, "b.pdf", 4)
+		if code := createStatus(tm, id); code != http.StatusConflict {
+			t.Fatalf("upload past the cap: status %d, want %d", code, http.StatusConflict)
+		}
+
+		// An interrupted upload keeps its slot until it is resumed and
+		// completes.
+		tusRequest(tm.UploadPatchHandler, http.MethodPatch, uid, &interruptedReader{strings.NewReader("ab")}, patchHeaders(0))
+		if code := createStatus(tm, id); code != http.StatusConflict {
+			t.Fatalf("upload while one is interrupted: status %d, want %d", code, http.StatusConflict)
+		}
+		w := tusRequest(tm.UploadOffsetHandler, http.MethodHead, uid, nil, nil)
+		if offset := w.Header().Get("Upload-Offset"); offset != "2" {
+			t.Fatalf("Upload-Offset %q after the interruption, want 2", offset)
+		}
+		if w := tusRequest(tm.UploadPatchHandler, http.MethodPatch, uid, strings.NewReader("cd"), patchHeaders(2)); w.Code != http.StatusNoContent {
+			t.Fatalf("resume: status %d, want %d", w.Code, http.StatusNoContent)
+		}
+		if code := createStatus(tm, id); code != http.StatusCreated {
+			t.Fatalf("upload after one completed: status %d, want %d", code, http.StatusCreated)
+		}
+		if got := tm.Tasks[id].FileCount(); got != 1 {
+			t.Fatalf("task has %d files, want the completed upload", got)
+		}
+	})
+}
+
+func TestRemoveStaleUploads(t *testing.T) {
+	tm := newTestManager(t, nil)
+	id := createTask(t, tm, `{}`, nil)
+	stale := createUpload(t, tm, id, "a.pdf", 4)
+	fresh := createUpload(t, tm, id, "b.pdf", 4)
+	tm.uploads[stale].UpdatedAt = time.Now().Add(-2 * time.Duration(tm.config.UploadExpirySeconds) * time.Second)
+
+	tm.RemoveStaleUploads()
+	if _, ok := tm.uploads[stale]; ok {
+		t.Fatal("stale upload was kept")
+	}
+	if _, ok := tm.uploads[fresh]; !ok {
+		t.Fatal("fresh upload was removed")
+	}
+}
+
+func TestUploadChunkDoesNotBlockSweep(t *testing.T) {
+	tm := newTestManager(t, nil)
+	id := createTask(t, tm, `{}`, nil)
+	uid := createUpload(t, tm, id, "a.pdf", 4)
+	u := tm.uploads[uid]
+
+	body, client := io.Pipe()
+	patched := make(chan int)
+	go func() {
+		patched <- tusRequest(tm.UploadPatchHandler, http.MethodPatch, uid, body, patchHeaders(0)).Code
+	}()
+	client.Write([]byte("ab"))
+
+	// The chunk is still being received: a second chunk conflicts, and
+	// the sweep neither waits for it nor removes the upload.
+	if w := tusRequest(tm.UploadPatchHandler, http.MethodPatch, uid, strings.NewReader("ab"), patchHeaders(0)); w.Code != http.StatusConflict {
+		t.Fatalf("concurrent patch: status %d, want %d", w.Code, http.StatusConflict)
+	}
+	u.mutex.Lock()
+	u.UpdatedAt = time.Now().Add(-2 * time.Duration(tm.config.UploadExpirySeconds) * time.Second)
+	u.mutex.Unlock()
+	swept := make(chan struct{})
+	go func() {
+		tm.RemoveStaleUploads()
+		close(swept)
+	}()
+	select {
+	case <-swept:
+	case <-time.After(5 * time.Second):
+		t.Fatal("RemoveStaleUploads waited for the chunk")
+	}
+	if _, ok := tm.uploads[uid]; !ok {
+		t.Fatal("upload receiving a chunk was removed")
+	}
+
+	client.Write([]byte("cd"))
+	client.Close()
+	if code := <-patched; code != http.StatusNoContent {
+		t.Fatalf("patch: status %d, want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestParseTusMetadata(t *testing.T) {
+	got := parseTusMetadata("filename " + base64.StdEncoding.EncodeToString([]byte("a.pdf")) + ", flag, bad !!!")
+	if got["filename"] != "a.pdf" {
+		t.Fatalf("filename = %q, want a.pdf", got["filename"])
+	}
+	if _, ok := got["flag"]; !ok {
+		t.Fatal("key without a value was dropped")
+	}
+	if _, ok := got["bad"]; ok {
+		t.Fatal("key with an invalid value was kept")
+	}
+}
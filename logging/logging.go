@@ -0,0 +1,47 @@
+// Package logging builds the structured logger of the server.
+package logging
+
+import (
+	"2025-08-02/config"
+	"io"
+	"log/slog"
+)
+
+// EventKey is the key of the message of a record, which names the event
+// logged, such as "task finished".
+const EventKey = "event"
+
+// New returns a logger writing records of cfg.LogLevel and above to w, as
+// JSON objects or, with the text format, as key=value lines. Durations are
+// written in seconds in JSON and as text such as "1.5s" otherwise.
+func New(cfg *config.Config, w io.Writer) *slog.Logger {
+	text := cfg.LogFormat == config.LogFormatText
+	opts := &slog.HandlerOptions{
+		Level: level(cfg.LogLevel),
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.MessageKey {
+				a.Key = EventKey
+			}
+			if !text && a.Value.Kind() == slog.KindDuration {
+				a.Value = slog.Float64Value(a.Value.Duration().Seconds())
+			}
+			return a
+		},
+	}
+	if text {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+func level(name string) slog.Level {
+	switch name {
+	case config.LogLevelDebug:
+		return slog.LevelDebug
+	case config.LogLevelWarn:
+		return slog.LevelWarn
+	case config.LogLevelError:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"2025-08-02/config"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&config.Config{LogLevel: config.LogLevelInfo, LogFormat: config.LogFormatJSON}, &buf)
+	logger.Debug("not logged")
+	logger.Info("task finished", "task_id", "t1", "took", 1500*time.Millisecond)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("record %q: %v", buf.String(), err)
+	}
+	if record[EventKey] != "task finished" || record["task_id"] != "t1" || record["took"] != 1.5 {
+		t.Fatalf("record %v", record)
+	}
+	if _, ok := record["msg"]; ok {
+		t.Fatalf("record %v keeps msg next to %s", record, EventKey)
+	}
+}
+
+func TestNewText(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&config.Config{LogLevel: config.LogLevelWarn, LogFormat: config.LogFormatText}, &buf)
+	logger.Info("not logged")
+	logger.Warn("callback failed", "retry_in", 2*time.Second)
+
+	line := buf.String()
+	if strings.Count(line, "\n") != 1 || !strings.Contains(line, `event="callback failed"`) || !strings.Contains(line, "retry_in=2s") {
+		t.Fatalf("log %q", line)
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"2025-08-02/config"
 	_ "2025-08-02/docs"
 	"2025-08-02/handlers"
+	"2025-08-02/logging"
+	"2025-08-02/task"
 	"context"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -25,65 +29,177 @@ import (
 
 //go:generate swag init
 func main() {
-	cfg, err := config.LoadConfig("config.json")
+	profile := flag.String("profile", os.Getenv("ARCHIVER_PROFILE"), "config profile to use when config.json defines several")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig("config.json", *profile)
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
+	logger := logging.New(cfg, os.Stderr)
+	// Packages without a logger of their own, and the log package, write
+	// through it as well.
+	slog.SetDefault(logger)
+
+	if err := os.MkdirAll(cfg.ArchiveDir, 0o755); err != nil {
+		logger.Error("failed to create archive directory", "path", cfg.ArchiveDir, "error", err)
+		os.Exit(1)
+	}
 
-	taskManager := handlers.NewTaskManager(cfg)
+	taskManager := handlers.NewTaskManager(cfg, logger)
+	if cfg.RestoreArchivesOnStartup {
+		restored, err := taskManager.RestoreArchives(cfg.ArchiveDir)
+		if err != nil {
+			logger.Error("failed to restore archives", "error", err)
+		} else {
+			logger.Info("restored tasks from existing archives", "tasks", restored)
+		}
+	}
 
-	go cleanupOldArchives(10 * time.Minute)
+	sweepCtx, stopSweepers := context.WithCancel(context.Background())
+	cleanupDone := make(chan struct{})
+	uploadsDone := make(chan struct{})
+	go cleanupOldArchives(sweepCtx, cleanupDone, taskManager, cfg, logger)
+	go cleanupStaleUploads(sweepCtx, uploadsDone, taskManager, cfg, logger)
 
 	r := mux.NewRouter()
 	r.HandleFunc("/tasks", taskManager.CreateTaskHandler).Methods("POST")
+	r.HandleFunc("/tasks", taskManager.ListTasksHandler).Methods("GET")
+	r.HandleFunc("/batch", taskManager.BatchHandler).Methods("POST")
 	r.HandleFunc("/tasks/{id}/files", taskManager.AddFileHandler).Methods("POST")
+	r.HandleFunc("/tasks/{id}/files/{name:.+}", taskManager.ServeArchiveEntryHandler).Methods("GET")
+	r.HandleFunc("/tasks/{id}/process", taskManager.StartTaskHandler).Methods("POST")
+	r.HandleFunc("/tasks/{id}/stream", taskManager.StreamTaskHandler).Methods("POST")
 	r.HandleFunc("/tasks/{id}", taskManager.GetTaskStatusHandler).Methods("GET")
+	r.HandleFunc("/tasks/{id}", taskManager.DeleteTaskHandler).Methods("DELETE")
+	r.HandleFunc("/tasks/{id}/progress", taskManager.GetTaskProgressHandler).Methods("GET")
+	r.HandleFunc("/tasks/{id}/events", taskManager.TaskEventsHandler).Methods("GET")
+	r.HandleFunc("/tasks/{id}/contents", taskManager.ArchiveContentsHandler).Methods("GET")
+	r.HandleFunc("/tasks/{id}/archive", taskManager.ServeTaskArchiveHandler).Methods("GET")
+	r.HandleFunc("/tasks/{id}/download", taskManager.DownloadHandler).Methods("GET")
 	r.HandleFunc("/archives/{filename}", taskManager.ServeArchiveHandler).Methods("GET")
+	r.HandleFunc("/tasks/{id}/archive.sig", taskManager.ServeSignatureHandler).Methods("GET")
+	r.HandleFunc("/signing-key", taskManager.SigningKeyHandler).Methods("GET")
+	r.HandleFunc("/groups/{id}", taskManager.GetGroupStatusHandler).Methods("GET")
+	r.HandleFunc("/groups/{id}/archive", taskManager.ServeGroupArchiveHandler).Methods("GET")
+	r.HandleFunc("/tasks/{id}/uploads", taskManager.CreateUploadHandler).Methods("POST")
+	r.HandleFunc("/tasks/{id}/uploads", taskManager.UploadOptionsHandler).Methods("OPTIONS")
+	r.HandleFunc("/uploads/{uid}", taskManager.UploadOffsetHandler).Methods("HEAD")
+	r.HandleFunc("/uploads/{uid}", taskManager.UploadPatchHandler).Methods("PATCH")
+	r.HandleFunc("/admin/dead-letters", taskManager.DeadLettersHandler).Methods("GET")
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
+	r.Use(handlers.APIKey(cfg.APIKeys))
+	r.Use(handlers.SLO(cfg.SLODefaultMs, cfg.SLORoutesMs, taskManager.Metrics()))
+	r.NotFoundHandler = handlers.NotFoundHandler()
+	r.MethodNotAllowedHandler = handlers.MethodNotAllowedHandler(r)
+
+	// Health checks and metrics bypass the router's middleware, so probes
+	// need no credentials. Metrics expose task volumes and error rates and
+	// still require an API key unless public_metrics is set.
+	metricsHandler := taskManager.MetricsHandler()
+	if !cfg.PublicMetrics {
+		metricsHandler = handlers.APIKey(cfg.APIKeys)(metricsHandler)
+	}
+	root := http.NewServeMux()
+	root.HandleFunc("GET /healthz", taskManager.HealthHandler)
+	root.Handle("GET /metrics", metricsHandler)
+	root.Handle("/", r)
+
+	var handler http.Handler = root
+	handler = handlers.CORS(cfg.AllowedOrigins, cfg.AllowedMethods)(handler)
+	handler = handlers.ResponseHeaders(cfg.ResponseHeaders)(handler)
+	handler = handlers.Recover(handler)
+	handler = handlers.RequestID(handler)
 
 	srv := &http.Server{
 		Addr:    ":" + cfg.Port,
-		Handler: r,
+		Handler: handler,
 	}
 
 	go func() {
-		log.Printf("Server starting on port %s", cfg.Port)
+		logger.Info("server starting", "port", cfg.Port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
+			logger.Error("failed to listen", "port", cfg.Port, "error", err)
+			os.Exit(1)
 		}
 	}()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	logger.Info("shutting down server")
+
+	// New work is refused first and running tasks get the grace period to
+	// finish; the HTTP server goes last so status polls keep working while
+	// tasks drain.
+	grace := time.Duration(cfg.ShutdownGraceSeconds) * time.Second
+	if interrupted := taskManager.Drain(grace); interrupted > 0 {
+		logger.Warn("tasks were still processing after the grace period and were recorded as dead letters", "tasks", interrupted, "grace", grace)
+	}
+	// A sweep in progress is finished before the server goes away.
+	stopSweepers()
+	<-cleanupDone
+	<-uploadsDone
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown:", err)
+		logger.Error("server forced to shut down", "error", err)
+		os.Exit(1)
 	}
 
-	log.Println("Server exiting")
+	logger.Info("server exiting")
 }
 
-func cleanupOldArchives(maxAge time.Duration) {
-	ticker := time.NewTicker(1 * time.Minute)
+// cleanupOldArchives removes archives older than archive_max_age_seconds
+// every cleanup_interval_seconds until ctx is canceled, then closes done.
+func cleanupOldArchives(ctx context.Context, done chan<- struct{}, taskManager *handlers.TaskManager, cfg *config.Config, logger *slog.Logger) {
+	defer close(done)
+	maxAge := time.Duration(cfg.ArchiveMaxAgeSeconds) * time.Second
+	ticker := time.NewTicker(time.Duration(cfg.CleanupIntervalSeconds) * time.Second)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("archive cleanup stopped")
+			return
+		case <-ticker.C:
+		}
+		filepath.Walk(cfg.ArchiveDir, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
 
-			if !info.IsDir() && filepath.Ext(path) == ".zip" {
+			if _, ok := task.FormatOf(path); !info.IsDir() && ok {
 				if time.Since(info.ModTime()) > maxAge {
-					log.Printf("Deleting old archive: %s", path)
-					os.Remove(path)
+					logger.Info("deleting old archive", "path", path, "age", time.Since(info.ModTime()).Round(time.Second))
+					if os.Remove(path) == nil {
+						taskManager.ArchiveRemoved(path)
+					}
 				}
 			}
 			return nil
 		})
+		taskManager.PruneArchiveReaders()
+		taskManager.PurgeExpiredTasks()
+	}
+}
+
+// cleanupStaleUploads removes idle uploads every cleanup_interval_seconds
+// until ctx is canceled, then closes done.
+func cleanupStaleUploads(ctx context.Context, done chan<- struct{}, taskManager *handlers.TaskManager, cfg *config.Config, logger *slog.Logger) {
+	defer close(done)
+	ticker := time.NewTicker(time.Duration(cfg.CleanupIntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("upload cleanup stopped")
+			return
+		case <-ticker.C:
+		}
+		taskManager.RemoveStaleUploads()
 	}
 }
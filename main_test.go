@@ -0,0 +1,50 @@
+package main
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/handlers"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCleanupOldArchives(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{ArchiveDir: dir, ArchiveMaxAgeSeconds: 60, CleanupIntervalSeconds: 1}
+	old, fresh := filepath.Join(dir, "old.zip"), filepath.Join(dir, "fresh.zip")
+	for _, path := range []string{old, fresh} {
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	modified := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(old, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	go cleanupOldArchives(ctx, done, handlers.NewTaskManager(cfg, logger), cfg, logger)
+	deadline := time.Now().Add(5 * time.Second)
+	for _, err := os.Stat(old); err == nil; _, err = os.Stat(old) {
+		if time.Now().After(deadline) {
+			t.Fatal("old archive not removed")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Fatalf("fresh archive removed: %v", err)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cleanup did not stop after cancel")
+	}
+}
@@ -0,0 +1,53 @@
+// Package metrics reports measurements of task processing to monitoring
+// systems.
+package metrics
+
+import (
+	"2025-08-02/config"
+	"log/slog"
+	"time"
+)
+
+// Recorder receives counters and timings. Tags have the form "key:value".
+// Implementations must not block the caller.
+type Recorder interface {
+	Count(name string, value int64, tags ...string)
+	Timing(name string, d time.Duration, tags ...string)
+}
+
+// Nop discards every measurement.
+type Nop struct{}
+
+func (Nop) Count(string, int64, ...string) {}
+
+func (Nop) Timing(string, time.Duration, ...string) {}
+
+// Multi passes every measurement on to each of its recorders.
+type Multi []Recorder
+
+func (m Multi) Count(name string, value int64, tags ...string) {
+	for _, r := range m {
+		r.Count(name, value, tags...)
+	}
+}
+
+func (m Multi) Timing(name string, d time.Duration, tags ...string) {
+	for _, r := range m {
+		r.Timing(name, d, tags...)
+	}
+}
+
+// New returns a StatsD recorder when cfg.StatsDAddr is set and Nop
+// otherwise.
+func New(cfg *config.Config) Recorder {
+	if cfg.StatsDAddr == "" {
+		return Nop{}
+	}
+	statsd, err := NewStatsD(cfg.StatsDAddr, cfg.StatsDPrefix)
+	if err != nil {
+		slog.Error("failed to set up StatsD, metrics are disabled", "addr", cfg.StatsDAddr, "error", err)
+		return Nop{}
+	}
+	slog.Info("sending metrics to StatsD", "addr", cfg.StatsDAddr)
+	return statsd
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus keeps the measurements it knows in a registry of its own,
+// to be scraped from Handler. Other measurements are ignored.
+type Prometheus struct {
+	Registry *prometheus.Registry
+
+	tasksCreated    prometheus.Counter
+	tasksCompleted  prometheus.Counter
+	tasksErrored    prometheus.Counter
+	tasksInFlight   prometheus.Gauge
+	taskDuration    prometheus.Histogram
+	filesAdded      prometheus.Counter
+	filesDownloaded prometheus.Counter
+	downloadBytes   prometheus.Counter
+}
+
+// NewPrometheus registers the archiver metrics in a new registry.
+func NewPrometheus() *Prometheus {
+	p := &Prometheus{
+		Registry: prometheus.NewRegistry(),
+		tasksCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "archiver_tasks_created_total",
+			Help: "Tasks created.",
+		}),
+		tasksCompleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "archiver_tasks_completed_total",
+			Help: "Tasks whose archive was built.",
+		}),
+		tasksErrored: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "archiver_tasks_errored_total",
+			Help: "Tasks that failed while processed.",
+		}),
+		tasksInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "archiver_tasks_in_flight",
+			Help: "Tasks being processed.",
+		}),
+		taskDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "archiver_task_duration_seconds",
+			Help:    "Time taken to process a task.",
+			Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+		}),
+		filesAdded: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "archiver_files_added_total",
+			Help: "Files added to tasks.",
+		}),
+		filesDownloaded: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "archiver_files_downloaded_total",
+			Help: "Files downloaded into archives.",
+		}),
+		downloadBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "archiver_download_bytes_total",
+			Help: "Bytes of the files downloaded into archives.",
+		}),
+	}
+	p.Registry.MustRegister(p.tasksCreated, p.tasksCompleted, p.tasksErrored, p.tasksInFlight,
+		p.taskDuration, p.filesAdded, p.filesDownloaded, p.downloadBytes)
+	return p
+}
+
+func (p *Prometheus) Count(name string, value int64, tags ...string) {
+	switch name {
+	case "tasks.created":
+		p.tasksCreated.Add(float64(value))
+	case "tasks.started":
+		p.tasksInFlight.Add(float64(value))
+	case "tasks.finished":
+		p.tasksInFlight.Sub(float64(value))
+		if slices.Contains(tags, "status:done") {
+			p.tasksCompleted.Add(float64(value))
+		} else if slices.Contains(tags, "status:error") {
+			p.tasksErrored.Add(float64(value))
+		}
+	case "files.added":
+		p.filesAdded.Add(float64(value))
+	case "files.archived":
+		p.filesDownloaded.Add(float64(value))
+	case "files.bytes":
+		p.downloadBytes.Add(float64(value))
+	}
+}
+
+func (p *Prometheus) Timing(name string, d time.Duration, tags ...string) {
+	if name == "tasks.duration" {
+		p.taskDuration.Observe(d.Seconds())
+	}
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.Registry, promhttp.HandlerOpts{})
+}
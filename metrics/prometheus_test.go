@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheus(t *testing.T) {
+	p := NewPrometheus()
+	p.Count("tasks.created", 3)
+	p.Count("tasks.started", 2)
+	p.Count("tasks.finished", 1, "status:done")
+	p.Count("tasks.finished", 1, "status:error")
+	p.Count("files.added", 5)
+	p.Count("files.bytes", 1024)
+	p.Count("unknown.metric", 7)
+	p.Timing("tasks.duration", 1500*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	p.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		"archiver_tasks_created_total 3",
+		"archiver_tasks_in_flight 0",
+		"archiver_tasks_completed_total 1",
+		"archiver_tasks_errored_total 1",
+		"archiver_files_added_total 5",
+		"archiver_download_bytes_total 1024",
+		"archiver_task_duration_seconds_sum 1.5",
+		"archiver_task_duration_seconds_count 1",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics have no line %q", want)
+		}
+	}
+}
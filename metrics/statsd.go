@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// statsdQueueSize is the number of packets that may wait to be sent; more
+// are dropped rather than slowing the caller down.
+const statsdQueueSize = 1024
+
+// StatsD sends measurements over UDP in the StatsD line format, with tags
+// in the DogStatsD "|#tag,tag" extension.
+type StatsD struct {
+	conn    net.Conn
+	prefix  string
+	packets chan string
+}
+
+// NewStatsD sends to addr (host:port), prefixing every metric name with
+// prefix and a dot when prefix is non-empty.
+func NewStatsD(addr, prefix string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" {
+		prefix += "."
+	}
+	s := &StatsD{conn: conn, prefix: prefix, packets: make(chan string, statsdQueueSize)}
+	go s.send()
+	return s, nil
+}
+
+func (s *StatsD) Count(name string, value int64, tags ...string) {
+	s.enqueue(fmt.Sprintf("%s%s:%d|c", s.prefix, name, value), tags)
+}
+
+func (s *StatsD) Timing(name string, d time.Duration, tags ...string) {
+	s.enqueue(fmt.Sprintf("%s%s:%d|ms", s.prefix, name, d.Milliseconds()), tags)
+}
+
+func (s *StatsD) enqueue(packet string, tags []string) {
+	if len(tags) > 0 {
+		packet += "|#" + strings.Join(tags, ",")
+	}
+	select {
+	case s.packets <- packet:
+	default:
+	}
+}
+
+// send writes queued packets; UDP errors such as an absent listener are
+// ignored, as metrics are best effort.
+func (s *StatsD) send() {
+	for packet := range s.packets {
+		s.conn.Write([]byte(packet))
+	}
+}
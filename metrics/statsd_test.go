@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"2025-08-02/config"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsD(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s, err := NewStatsD(conn.LocalAddr().String(), "archiver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Count("tasks.started", 1)
+	s.Count("files.failed", 2, "code:TIMEOUT", "host:example.com")
+	s.Timing("tasks.duration", 1500*time.Millisecond, "status:done")
+
+	want := []string{
+		"archiver.tasks.started:1|c",
+		"archiver.files.failed:2|c|#code:TIMEOUT,host:example.com",
+		"archiver.tasks.duration:1500|ms|#status:done",
+	}
+	buf := make([]byte, 512)
+	for _, w := range want {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("waiting for %q: %v", w, err)
+		}
+		if got := string(buf[:n]); got != w {
+			t.Fatalf("packet %q, want %q", got, w)
+		}
+	}
+}
+
+func TestStatsDNoPrefix(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s, err := NewStatsD(conn.LocalAddr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Count("tasks.started", 1)
+	buf := make([]byte, 512)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil || string(buf[:n]) != "tasks.started:1|c" {
+		t.Fatalf("packet %q, %v", buf[:n], err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, ok := New(&config.Config{}).(Nop); !ok {
+		t.Fatal("New without statsd_addr is not Nop")
+	}
+	if _, ok := New(&config.Config{StatsDAddr: "127.0.0.1:8125"}).(*StatsD); !ok {
+		t.Fatal("New with statsd_addr is not StatsD")
+	}
+	if _, ok := New(&config.Config{StatsDAddr: "no port"}).(Nop); !ok {
+		t.Fatal("New with an invalid statsd_addr is not Nop")
+	}
+}
@@ -0,0 +1,113 @@
+package queue
+
+import (
+	"2025-08-02/config"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetter records a task that ended in the error state, so it can be
+// reviewed and resubmitted later.
+type DeadLetter struct {
+	TaskID       string    `json:"task_id"`
+	URLs         []string  `json:"urls"`
+	ErrorCodes   []string  `json:"error_codes,omitempty"`
+	ErrorDetails string    `json:"error_details,omitempty"`
+	FailedAt     time.Time `json:"failed_at"`
+}
+
+// DeadLetterSink stores dead-letter records.
+type DeadLetterSink interface {
+	RecordDeadLetter(d DeadLetter) error
+	// RecentDeadLetters returns up to limit records, newest first.
+	RecentDeadLetters(limit int) ([]DeadLetter, error)
+}
+
+// NopDeadLetterSink discards every record.
+type NopDeadLetterSink struct{}
+
+func (NopDeadLetterSink) RecordDeadLetter(DeadLetter) error { return nil }
+
+func (NopDeadLetterSink) RecentDeadLetters(int) ([]DeadLetter, error) { return nil, nil }
+
+// FileDeadLetterSink appends records to a file, one JSON object per line.
+type FileDeadLetterSink struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileDeadLetterSink(path string) *FileDeadLetterSink {
+	return &FileDeadLetterSink{path: path}
+}
+
+func (s *FileDeadLetterSink) RecordDeadLetter(d DeadLetter) error {
+	line, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (s *FileDeadLetterSink) RecentDeadLetters(limit int) ([]DeadLetter, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []DeadLetter{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Keep only the last limit records while scanning.
+	var records []DeadLetter
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var d DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			slog.Warn("skipping malformed dead-letter record", "path", s.path, "error", err)
+			continue
+		}
+		records = append(records, d)
+		if len(records) > limit {
+			records = records[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	recent := make([]DeadLetter, len(records))
+	for i, d := range records {
+		recent[len(records)-1-i] = d
+	}
+	return recent, nil
+}
+
+// NewDeadLetterSink returns a file sink when cfg.DeadLetterFile is set and
+// NopDeadLetterSink otherwise.
+func NewDeadLetterSink(cfg *config.Config) DeadLetterSink {
+	if cfg.DeadLetterFile == "" {
+		return NopDeadLetterSink{}
+	}
+	slog.Info("recording failed tasks", "path", cfg.DeadLetterFile)
+	return NewFileDeadLetterSink(cfg.DeadLetterFile)
+}
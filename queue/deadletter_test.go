@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFileDeadLetterSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	s := NewFileDeadLetterSink(path)
+	if recent, err := s.RecentDeadLetters(10); err != nil || len(recent) != 0 {
+		t.Fatalf("missing file: RecentDeadLetters = %v, %v", recent, err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if err := s.RecordDeadLetter(DeadLetter{TaskID: id, URLs: []string{"https://example.com/" + id}}); err != nil {
+			t.Fatalf("RecordDeadLetter: %v", err)
+		}
+	}
+	// A torn line, as a crash mid-write leaves, is skipped.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"task_id": "d", "ur` + "\n")
+	file.Close()
+
+	tests := []struct {
+		limit int
+		want  []string
+	}{
+		{10, []string{"c", "b", "a"}},
+		{2, []string{"c", "b"}},
+		{0, []string{}},
+	}
+	for _, tt := range tests {
+		recent, err := s.RecentDeadLetters(tt.limit)
+		if err != nil {
+			t.Fatalf("RecentDeadLetters(%d): %v", tt.limit, err)
+		}
+		got := make([]string, len(recent))
+		for i, d := range recent {
+			got[i] = d.TaskID
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Fatalf("RecentDeadLetters(%d) = %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+}
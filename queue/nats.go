@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const natsTimeout = 5 * time.Second
+
+// NATSPublisher publishes messages using the NATS text protocol. Each
+// message uses its own short-lived connection, which is plenty for the
+// rate at which tasks complete.
+type NATSPublisher struct {
+	addr    string
+	subject string
+}
+
+func NewNATSPublisher(addr, subject string) *NATSPublisher {
+	return &NATSPublisher{addr: addr, subject: subject}
+}
+
+func (p *NATSPublisher) PublishCompletion(c Completion) error {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", p.addr, natsTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(natsTimeout))
+
+	reader := bufio.NewReader(conn)
+	// The server greets every connection with an INFO line.
+	if line, err := reader.ReadString('\n'); err != nil {
+		return err
+	} else if !strings.HasPrefix(line, "INFO") {
+		return fmt.Errorf("unexpected NATS greeting: %q", strings.TrimSpace(line))
+	}
+
+	// PING after PUB makes the server answer PONG once the message has been
+	// processed, or -ERR if it was rejected.
+	msg := fmt.Sprintf("CONNECT {\"verbose\":false,\"pedantic\":false}\r\nPUB %s %d\r\n%s\r\nPING\r\n", p.subject, len(payload), payload)
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		return err
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(line, "PONG"):
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS error: %s", strings.TrimSpace(line))
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeNATS accepts one connection, answers it like a NATS server with
+// reply and sends the published payload on the returned channel.
+func fakeNATS(t *testing.T, reply string) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	published := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {}\r\n"))
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 3 && fields[0] == "PUB":
+				size, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, size+2)
+				if _, err := reader.Read(payload); err != nil {
+					return
+				}
+				published <- fields[1] + " " + strings.TrimSpace(string(payload))
+			case len(fields) == 1 && fields[0] == "PING":
+				conn.Write([]byte(reply))
+				return
+			}
+		}
+	}()
+	return ln.Addr().String(), published
+}
+
+func TestNATSPublisher(t *testing.T) {
+	addr, published := fakeNATS(t, "PONG\r\n")
+	p := NewNATSPublisher(addr, "tasks.done")
+	c := Completion{TaskID: "t1", Status: "done", ResultURL: "/archives/t1.zip"}
+	if err := p.PublishCompletion(c); err != nil {
+		t.Fatalf("PublishCompletion: %v", err)
+	}
+
+	subject, payload, _ := strings.Cut(<-published, " ")
+	if subject != "tasks.done" {
+		t.Fatalf("subject %q, want tasks.done", subject)
+	}
+	var got Completion
+	if err := json.Unmarshal([]byte(payload), &got); err != nil {
+		t.Fatalf("payload %q: %v", payload, err)
+	}
+	if got != c {
+		t.Fatalf("published %+v, want %+v", got, c)
+	}
+}
+
+func TestNATSPublisherError(t *testing.T) {
+	addr, _ := fakeNATS(t, "-ERR 'Permissions Violation'\r\n")
+	err := NewNATSPublisher(addr, "tasks.done").PublishCompletion(Completion{TaskID: "t1"})
+	if err == nil || !strings.Contains(err.Error(), "Permissions Violation") {
+		t.Fatalf("PublishCompletion = %v, want the server error", err)
+	}
+}
@@ -0,0 +1,40 @@
+// Package queue publishes task lifecycle messages to external message
+// brokers.
+package queue
+
+import (
+	"2025-08-02/config"
+	"log/slog"
+)
+
+// Completion is the message published when a task finishes processing.
+type Completion struct {
+	TaskID       string `json:"task_id"`
+	Status       string `json:"status"`
+	ResultURL    string `json:"result_url,omitempty"`
+	Checksum     string `json:"checksum,omitempty"`
+	ErrorDetails string `json:"error_details,omitempty"`
+}
+
+// Publisher delivers completion messages to a broker.
+type Publisher interface {
+	PublishCompletion(c Completion) error
+}
+
+// NopPublisher discards every message.
+type NopPublisher struct{}
+
+func (NopPublisher) PublishCompletion(Completion) error { return nil }
+
+// New returns the publisher selected by cfg.EventPublisher. Unknown or
+// empty backends fall back to NopPublisher; LoadConfig rejects unknown
+// names up front.
+func New(cfg *config.Config) Publisher {
+	switch cfg.EventPublisher {
+	case config.PublisherNATS:
+		slog.Info("publishing task completions to NATS", "addr", cfg.NATSAddr, "subject", cfg.NATSSubject)
+		return NewNATSPublisher(cfg.NATSAddr, cfg.NATSSubject)
+	default:
+		return NopPublisher{}
+	}
+}
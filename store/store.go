@@ -0,0 +1,173 @@
+// Package store persists tasks so they survive server restarts.
+package store
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/task"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ErrNotFound is returned by Load for unknown task IDs.
+var ErrNotFound = errors.New("task not found in store")
+
+// TaskStore keeps the latest saved state of every task.
+type TaskStore interface {
+	Save(t *task.Task) error
+	Load(id string) (*task.Task, error)
+	// List returns every stored task, ordered by ID.
+	List() ([]*task.Task, error)
+	// Delete forgets the task id; unknown IDs are not an error.
+	Delete(id string) error
+}
+
+// NopTaskStore keeps nothing; tasks live only in memory.
+type NopTaskStore struct{}
+
+func (NopTaskStore) Save(*task.Task) error { return nil }
+
+func (NopTaskStore) Load(string) (*task.Task, error) { return nil, ErrNotFound }
+
+func (NopTaskStore) List() ([]*task.Task, error) { return nil, nil }
+
+func (NopTaskStore) Delete(string) error { return nil }
+
+// FileTaskStore keeps all tasks in one JSON file, an object mapping task
+// IDs to their records. Every Save rewrites the file through a temporary
+// file and a rename, so a crash never leaves it half-written.
+type FileTaskStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileTaskStore(path string) *FileTaskStore {
+	return &FileTaskStore{path: path}
+}
+
+func (s *FileTaskStore) Save(t *task.Task) error {
+	data, err := t.MarshalRecord()
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	records, err := s.read()
+	if err != nil {
+		return err
+	}
+	records[t.ID] = data
+	return s.write(records)
+}
+
+func (s *FileTaskStore) Load(id string) (*task.Task, error) {
+	s.mutex.Lock()
+	records, err := s.read()
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	data, ok := records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return task.UnmarshalRecord(data)
+}
+
+func (s *FileTaskStore) List() ([]*task.Task, error) {
+	s.mutex.Lock()
+	records, err := s.read()
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(records))
+	for id := range records {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	tasks := make([]*task.Task, 0, len(ids))
+	for _, id := range ids {
+		t, err := task.UnmarshalRecord(records[id])
+		if err != nil {
+			slog.Warn("skipping malformed task record", "task_id", id, "path", s.path, "error", err)
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
+}
+
+func (s *FileTaskStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	records, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := records[id]; !ok {
+		return nil
+	}
+	delete(records, id)
+	return s.write(records)
+}
+
+// read returns the records in the file; a missing file holds none.
+// Callers must hold s.mutex.
+func (s *FileTaskStore) read() (map[string]json.RawMessage, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]json.RawMessage), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	return records, nil
+}
+
+// write replaces the file with records. Callers must hold s.mutex.
+func (s *FileTaskStore) write(records map[string]json.RawMessage) error {
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// New returns a file store when cfg.TaskStoreFile is set and NopTaskStore
+// otherwise.
+func New(cfg *config.Config) TaskStore {
+	if cfg.TaskStoreFile == "" {
+		return NopTaskStore{}
+	}
+	slog.Info("persisting tasks", "path", cfg.TaskStoreFile)
+	return NewFileTaskStore(cfg.TaskStoreFile)
+}
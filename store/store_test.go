@@ -0,0 +1,65 @@
+package store
+
+import (
+	"2025-08-02/task"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileTaskStore(t *testing.T) {
+	s := NewFileTaskStore(filepath.Join(t.TempDir(), "tasks.json"))
+	if tasks, err := s.List(); err != nil || len(tasks) != 0 {
+		t.Fatalf("empty store: List = %v, %v", tasks, err)
+	}
+
+	first, second := task.NewTask(), task.NewTask()
+	first.ClientID = "client"
+	first.SetArchiveName(filepath.Join("archives", first.ID+".zip"))
+	for _, tk := range []*task.Task{first, second, first} {
+		if err := s.Save(tk); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	loaded, err := s.Load(first.ID)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.ClientID != first.ClientID || loaded.ArchiveName() != first.ArchiveName() || loaded.ResultURL != first.ResultURL {
+		t.Fatalf("Load = %+v, want the saved task %+v", loaded, first)
+	}
+	if tasks, err := s.List(); err != nil || len(tasks) != 2 {
+		t.Fatalf("List = %d tasks, %v, want 2", len(tasks), err)
+	}
+
+	if err := s.Delete(first.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete(first.ID); err != nil {
+		t.Fatalf("second Delete: %v", err)
+	}
+	if _, err := s.Load(first.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Load after Delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileTaskStoreSkipsMalformedRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	if err := os.WriteFile(path, []byte(`{"bad": {"id": ""}, "worse": [1]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewFileTaskStore(path)
+	good := task.NewTask()
+	if err := s.Save(good); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	tasks, err := s.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != good.ID {
+		t.Fatalf("List = %v, want only %s", tasks, good.ID)
+	}
+}
@@ -0,0 +1,247 @@
+package task
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// FileInfo describes how one source file ended up in the archive.
+type FileInfo struct {
+	URL    string `json:"url"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+	// DuplicateOf names the entry holding identical content when the file
+	// was skipped by deduplication.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// Skipped is set when the file was deliberately left out of the
+	// archive, e.g. NOT_MODIFIED for files older than modified_since.
+	Skipped ErrorCode `json:"skipped,omitempty"`
+	// Bundle names the entry, BundleEntryName, that holds the file when
+	// small files are bundled.
+	Bundle string `json:"bundle,omitempty"`
+}
+
+// archiveSource adds the contents behind fileURL to archive.
+func (t *Task) archiveSource(ctx context.Context, env *Env, budget *budget, archive ArchiveWriter, fileURL string, contentHashes map[string]string, bundle *smallFiles, names entryNames) (FileInfo, error) {
+	if isGitSource(fileURL) {
+		return t.archiveGitRepo(ctx, env, archive, fileURL, budget)
+	}
+	if isPagedSource(fileURL) {
+		return t.archivePaged(ctx, env, archive, fileURL, budget)
+	}
+	return t.archiveFile(ctx, env, budget, archive, fileURL, contentHashes, bundle, names)
+}
+
+// archiveFile downloads fileURL and writes it into archive. When
+// contentHashes is non-nil it maps content hashes to entry names and
+// duplicate content is recorded instead of stored again. Files small
+// enough for bundle go into it instead of their own entry. names renames
+// the entry if an earlier file took its name. The download draws from
+// budget and fails once it is used up.
+func (t *Task) archiveFile(ctx context.Context, env *Env, budget *budget, archive ArchiveWriter, fileURL string, contentHashes map[string]string, bundle *smallFiles, names entryNames) (FileInfo, error) {
+	info, body, limit, err := t.openFile(ctx, env, fileURL)
+	if err != nil || body == nil {
+		return info, err
+	}
+	defer body.Close()
+
+	// Downloads that may be cut off midway, by the size limit, the byte
+	// budget, the download phase deadline or, for every remote file, the
+	// download timeout, are staged first so a truncated file never becomes
+	// an entry. Bundling needs the size before the file is written.
+	_, hasDeadline := ctx.Deadline()
+	_, hasByteBudget := budget.remaining()
+	// Archives to expand are read from the staged copy.
+	if contentHashes != nil || limit > 0 || hasByteBudget || hasDeadline || bundle != nil || !isUpload(fileURL) || env.expandsArchive(info.Name) {
+		info, staged, err := t.stageBody(budget.limit(limitSize(body, limit)), info, contentHashes != nil)
+		if err != nil {
+			return info, err
+		}
+		defer removeStaged(staged)
+		if env.expandsArchive(info.Name) {
+			return t.expandArchive(env, archive, staged, info)
+		}
+		return t.writeStaged(archive, staged, info, contentHashes, bundle, names)
+	}
+
+	info.Name = names.unique(info.Name)
+	zipEntry, err := archive.Create(info.Name)
+	if err != nil {
+		t.logger().Error("failed to create archive entry", "entry", info.Name, "error", err)
+		return info, newFileError(info.URL, CodeWriteFailed, "failed to create zip entry for %s: %v", info.Name, err)
+	}
+
+	info.Size, err = io.Copy(zipEntry, body)
+	if err != nil {
+		t.logger().Error("failed to write archive entry", "entry", info.Name, "error", err)
+		return info, newFileError(info.URL, CodeWriteFailed, "failed to write to zip entry for %s: %v", info.Name, err)
+	}
+	return info, nil
+}
+
+// openFile starts the download of fileURL and names its entry. It also
+// returns the size limit that applies to the entry. The body is nil for
+// files that are skipped as not modified.
+func (t *Task) openFile(ctx context.Context, env *Env, fileURL string) (FileInfo, io.ReadCloser, int64, error) {
+	info := FileInfo{URL: fileURL, Name: filepath.Base(fileURL)}
+	if err := checkExpiry(fileURL, time.Now()); err != nil {
+		t.logger().Warn("skipping file", "file_url", fileURL, "error", err)
+		return info, nil, 0, err
+	}
+
+	dir, err := entryDir(env, fileURL)
+	if err != nil {
+		t.logger().Warn("skipping file", "file_url", fileURL, "error", err)
+		return info, nil, 0, err
+	}
+
+	body, header, err := t.openPrefetched(ctx, env, fileURL)
+	if errors.Is(err, errNotModified) {
+		t.logger().Info("skipping unmodified file", "file_url", fileURL, "modified_since", t.modifiedSince().Format(time.RFC3339))
+		info.Skipped = CodeNotModified
+		return info, nil, 0, nil
+	}
+	if err != nil {
+		t.logger().Warn("failed to download file", "file_url", fileURL, "error", err)
+		return info, nil, 0, downloadError(fileURL, err)
+	}
+	body = t.keepPart(env, fileURL, body, header)
+	if filename := t.fileName(fileURL); filename != "" {
+		info.Name = dir + filename
+	} else {
+		info.Name = dir + entryName(env, fileURL, header)
+	}
+	if urlExtension(fileURL) == "" {
+		// Only the response tells what an extensionless URL serves.
+		ext, ok := allowedTypeExtension(env, header)
+		if !ok {
+			body.Close()
+			t.logger().Warn("file extension not allowed", "file_url", fileURL)
+			return info, nil, 0, newFileError(fileURL, CodeExtensionNotAllowed, "file extension not allowed: %s", fileURL)
+		}
+		if path.Ext(info.Name) == "" {
+			info.Name += ext
+		}
+	}
+	// The patterns of expanded archives apply to their inner entries.
+	if !env.expandsArchive(info.Name) && !t.entryFilter().keep(info.Name) {
+		body.Close()
+		t.logger().Info("skipping excluded file", "file_url", info.URL, "entry", info.Name)
+		info.Skipped = CodeExcluded
+		return info, nil, 0, nil
+	}
+	body = t.progress.countDownload(body)
+
+	// Reject files that announce an oversized body before an entry is
+	// created. Chunked responses and uploads have no usable Content-Length;
+	// for them, and for servers that understate it, the copy itself is
+	// bounded by the streamed byte count.
+	limit := env.maxFileSize(info.Name)
+	if limit > 0 && header != nil {
+		if length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil && length > limit {
+			body.Close()
+			t.logger().Warn("file over the size limit", "file_url", fileURL, "size", length, "limit", limit)
+			return info, nil, 0, newFileError(fileURL, CodeTooLarge, "file %s is %d bytes, over the %d byte limit", fileURL, length, limit)
+		}
+	}
+	if env.transformsText(info.Name, header) {
+		// The downloaded bytes are bounded before the transform, which
+		// could otherwise shrink an unbounded download below the limit.
+		body = transformText(limitedBody{limitSize(body, limit), body}, env.Config.TextTransforms, env.Config.TextTransformMaxLineBytes)
+	}
+	return info, body, limit, nil
+}
+
+// limitedBody is a bounded body that still closes the underlying one.
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// stageBody downloads body into a temporary file, positioned at its start,
+// and records the size and, with hashContent, the SHA-256 of the content.
+// The caller removes the file with removeStaged.
+func (t *Task) stageBody(body io.Reader, info FileInfo, hashContent bool) (FileInfo, *os.File, error) {
+	staged, err := os.CreateTemp("", "archiver-*")
+	if err != nil {
+		t.logger().Error("failed to stage file", "file_url", info.URL, "error", err)
+		return info, nil, newFileError(info.URL, CodeWriteFailed, "failed to stage file %s: %v", info.URL, err)
+	}
+
+	var dst io.Writer = staged
+	hash := sha256.New()
+	if hashContent {
+		dst = io.MultiWriter(staged, hash)
+	}
+	info.Size, err = io.Copy(dst, body)
+	if err != nil {
+		removeStaged(staged)
+		t.logger().Warn("failed to download file", "file_url", info.URL, "error", err)
+		return info, nil, downloadError(info.URL, err)
+	}
+	if hashContent {
+		info.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	if _, err := staged.Seek(0, io.SeekStart); err != nil {
+		removeStaged(staged)
+		return info, nil, newFileError(info.URL, CodeWriteFailed, "failed to stage file %s: %v", info.URL, err)
+	}
+	return info, staged, nil
+}
+
+func removeStaged(staged *os.File) {
+	staged.Close()
+	os.Remove(staged.Name())
+}
+
+// writeStaged writes the content staged for info as a new entry, or adds
+// it to bundle if it is small enough, renaming it if names already has an
+// entry of that name. With a non-nil contentHashes the content is only
+// stored if no earlier file had the same content.
+func (t *Task) writeStaged(archive ArchiveWriter, staged io.Reader, info FileInfo, contentHashes map[string]string, bundle *smallFiles, names entryNames) (FileInfo, error) {
+	if contentHashes != nil {
+		if original, ok := contentHashes[info.SHA256]; ok {
+			t.logger().Info("skipping duplicate file", "file_url", info.URL, "duplicate_of", original)
+			info.DuplicateOf = original
+			return info, nil
+		}
+	}
+
+	info.Name = names.unique(info.Name)
+	if bundle.accepts(info.Size) {
+		if err := bundle.add(info, staged); err != nil {
+			t.logger().Error("failed to bundle file", "entry", info.Name, "error", err)
+			return info, newFileError(info.URL, CodeWriteFailed, "failed to add %s to the small file bundle: %v", info.Name, err)
+		}
+		info.Bundle = BundleEntryName
+		if contentHashes != nil {
+			contentHashes[info.SHA256] = info.Name
+		}
+		return info, nil
+	}
+
+	zipEntry, err := archive.Create(info.Name)
+	if err != nil {
+		t.logger().Error("failed to create archive entry", "entry", info.Name, "error", err)
+		return info, newFileError(info.URL, CodeWriteFailed, "failed to create zip entry for %s: %v", info.Name, err)
+	}
+	if _, err := io.Copy(zipEntry, staged); err != nil {
+		t.logger().Error("failed to write archive entry", "entry", info.Name, "error", err)
+		return info, newFileError(info.URL, CodeWriteFailed, "failed to write to zip entry for %s: %v", info.Name, err)
+	}
+
+	if contentHashes != nil {
+		contentHashes[info.SHA256] = info.Name
+	}
+	return info, nil
+}
@@ -0,0 +1,64 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteStagedDeduped(t *testing.T) {
+	var buf bytes.Buffer
+	archive := newArchiveWriter(&Env{Config: &config.Config{}}, &buf, FormatZip)
+	contentHashes := make(map[string]string)
+
+	sources := []struct {
+		name string
+		data string
+		dup  string
+	}{
+		{"a.pdf", "same", ""},
+		{"b.pdf", "other", ""},
+		{"c.pdf", "same", "a.pdf"},
+	}
+	tk := NewTask()
+	var infos []FileInfo
+	for _, src := range sources {
+		info := FileInfo{URL: "https://example.com/" + src.name, Name: src.name}
+		info, staged, err := tk.stageBody(strings.NewReader(src.data), info, true)
+		if err != nil {
+			t.Fatalf("stageBody(%s): %v", src.name, err)
+		}
+		info, err = tk.writeStaged(archive, staged, info, contentHashes, nil, entryNames{})
+		removeStaged(staged)
+		if err != nil {
+			t.Fatalf("writeStaged(%s): %v", src.name, err)
+		}
+		if info.DuplicateOf != src.dup {
+			t.Errorf("%s: duplicate_of %q, want %q", src.name, info.DuplicateOf, src.dup)
+		}
+		if info.Size != int64(len(src.data)) || len(info.SHA256) != 64 {
+			t.Errorf("%s: size %d, sha256 %q", src.name, info.Size, info.SHA256)
+		}
+		infos = append(infos, info)
+	}
+	if infos[0].SHA256 != infos[2].SHA256 || infos[0].SHA256 == infos[1].SHA256 {
+		t.Errorf("hashes %q, %q, %q do not follow the contents", infos[0].SHA256, infos[1].SHA256, infos[2].SHA256)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, " "); got != "a.pdf b.pdf" {
+		t.Fatalf("entries %q, want the duplicate skipped", got)
+	}
+}
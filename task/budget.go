@@ -0,0 +1,97 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+var errBudgetExceeded = errors.New("task byte budget exceeded")
+
+// budget is the aggregate allowance of a task across all of its downloads.
+// A zero limit is unlimited. Downloads running in parallel share it.
+type budget struct {
+	maxBytes int64
+	deadline time.Time
+	used     atomic.Int64
+}
+
+// newBudget applies the task's overrides on top of the configured budget.
+func (t *Task) newBudget(env *Env, start time.Time) *budget {
+	b := &budget{maxBytes: env.Config.TaskByteBudget}
+	if t.ByteBudget > 0 {
+		b.maxBytes = t.ByteBudget
+	}
+	seconds := env.Config.TaskTimeBudgetSeconds
+	if t.TimeBudgetSeconds > 0 {
+		seconds = t.TimeBudgetSeconds
+	}
+	if seconds > 0 {
+		b.deadline = start.Add(time.Duration(seconds) * time.Second)
+	}
+	return b
+}
+
+// exhausted reports why no further file may be downloaded, if so.
+func (b *budget) exhausted(now time.Time) (string, bool) {
+	if used := b.used.Load(); b.maxBytes > 0 && used >= b.maxBytes {
+		return fmt.Sprintf("byte budget of %d exhausted after %d bytes", b.maxBytes, used), true
+	}
+	if !b.deadline.IsZero() && !now.Before(b.deadline) {
+		return "time budget exhausted", true
+	}
+	return "", false
+}
+
+// cutOff turns err, the failure of a download of fileURL, into a budget
+// error if the download was stopped because the time budget ran out.
+func (b *budget) cutOff(ctx context.Context, fileURL string, err error) error {
+	if ctx.Err() == nil || b.deadline.IsZero() || time.Now().Before(b.deadline) {
+		return err
+	}
+	return newFileError(fileURL, CodeBudgetExceeded, "stopped %s: time budget exhausted", fileURL)
+}
+
+// remaining returns the bytes left in the budget and whether it has a
+// byte limit at all.
+func (b *budget) remaining() (int64, bool) {
+	if b.maxBytes <= 0 {
+		return 0, false
+	}
+	return max(b.maxBytes-b.used.Load(), 0), true
+}
+
+// charge counts n bytes that were added without passing through limit.
+func (b *budget) charge(n int64) {
+	b.used.Add(n)
+}
+
+// limit returns r, counting every byte read against the budget and failing
+// with errBudgetExceeded once the budget is overdrawn.
+func (b *budget) limit(r io.Reader) io.Reader {
+	if b.maxBytes <= 0 {
+		return r
+	}
+	return &budgetReader{r: r, budget: b}
+}
+
+type budgetReader struct {
+	r      io.Reader
+	budget *budget
+}
+
+func (r *budgetReader) Read(p []byte) (int, error) {
+	// Never ask for more than one byte past what is left, so the budget is
+	// overdrawn by at most one byte per download.
+	if left, _ := r.budget.remaining(); int64(len(p)) > left+1 {
+		p = p[:left+1]
+	}
+	n, err := r.r.Read(p)
+	if r.budget.used.Add(int64(n)) > r.budget.maxBytes {
+		return n, fmt.Errorf("%w: limit is %d bytes", errBudgetExceeded, r.budget.maxBytes)
+	}
+	return n, err
+}
@@ -0,0 +1,216 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewBudget(t *testing.T) {
+	start := time.Date(2025, 8, 2, 12, 0, 0, 0, time.UTC)
+	env := &Env{Config: &config.Config{TaskByteBudget: 100, TaskTimeBudgetSeconds: 60}}
+	tests := []struct {
+		name         string
+		byteBudget   int64
+		timeBudget   int
+		wantBytes    int64
+		wantDeadline time.Time
+	}{
+		{"configured", 0, 0, 100, start.Add(time.Minute)},
+		{"task overrides", 10, 5, 10, start.Add(5 * time.Second)},
+	}
+	for _, tt := range tests {
+		tk := NewTask()
+		tk.ByteBudget, tk.TimeBudgetSeconds = tt.byteBudget, tt.timeBudget
+		b := tk.newBudget(env, start)
+		if b.maxBytes != tt.wantBytes || !b.deadline.Equal(tt.wantDeadline) {
+			t.Errorf("%s: budget %d bytes until %v, want %d until %v", tt.name, b.maxBytes, b.deadline, tt.wantBytes, tt.wantDeadline)
+		}
+	}
+	if b := NewTask().newBudget(&Env{Config: &config.Config{}}, start); b.maxBytes != 0 || !b.deadline.IsZero() {
+		t.Errorf("unconfigured budget %+v, want unlimited", b)
+	}
+}
+
+func TestBudgetExhausted(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		maxBytes int64
+		used     int64
+		deadline time.Time
+		want     string
+	}{
+		{"unlimited", 0, 1 << 40, time.Time{}, ""},
+		{"bytes left", 10, 9, time.Time{}, ""},
+		{"bytes used up", 10, 10, time.Time{}, "byte budget of 10 exhausted after 10 bytes"},
+		{"time left", 0, 0, now.Add(time.Second), ""},
+		{"time up", 0, 0, now, "time budget exhausted"},
+	}
+	for _, tt := range tests {
+		b := &budget{maxBytes: tt.maxBytes, deadline: tt.deadline}
+		b.used.Store(tt.used)
+		reason, ok := b.exhausted(now)
+		if reason != tt.want || ok != (tt.want != "") {
+			t.Errorf("%s: exhausted = %q, %v, want %q", tt.name, reason, ok, tt.want)
+		}
+	}
+}
+
+func TestBudgetLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int64
+		reads    []string
+		wantErr  bool
+	}{
+		{"unlimited", 0, []string{"0123456789", "abc"}, false},
+		{"within", 10, []string{"01234"}, false},
+		{"exact fit", 10, []string{"01234", "56789"}, false},
+		{"overdrawn by one file", 10, []string{"0123456789a"}, true},
+		{"overdrawn across files", 10, []string{"012345", "6789a"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &budget{maxBytes: tt.maxBytes}
+			var err error
+			for _, content := range tt.reads {
+				if _, err = io.Copy(io.Discard, b.limit(strings.NewReader(content))); err != nil {
+					break
+				}
+			}
+			if gotErr := errors.Is(err, errBudgetExceeded); gotErr != tt.wantErr {
+				t.Errorf("error = %v, want budget exceeded: %v", err, tt.wantErr)
+			}
+			if tt.maxBytes > 0 && b.used.Load() > tt.maxBytes+1 {
+				t.Errorf("used %d bytes of %d", b.used.Load(), tt.maxBytes)
+			}
+		})
+	}
+}
+
+// entryRecorder is an ArchiveWriter that keeps the entries in memory.
+type entryRecorder struct {
+	entries map[string]*bytes.Buffer
+}
+
+func (r *entryRecorder) Create(name string) (io.Writer, error) {
+	buf := &bytes.Buffer{}
+	r.entries[name] = buf
+	return buf, nil
+}
+
+func (r *entryRecorder) Close() error { return nil }
+
+func TestWriteArchiveByteBudget(t *testing.T) {
+	const fileSize = 64 << 10
+	var sent atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		n, _ := w.Write(bytes.Repeat([]byte("x"), fileSize))
+		sent.Add(int64(n))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		parallel int
+	}{
+		{"sequential", 1},
+		{"parallel", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent.Store(0)
+			cfg := &config.Config{
+				AllowedExtensions: []string{".pdf"},
+				TaskByteBudget:    fileSize + fileSize/2,
+				ParallelDownloads: tt.parallel,
+			}
+			env := NewEnv(cfg)
+			task := NewTask()
+			task.FileURLs = []string{server.URL + "/a.pdf", server.URL + "/b.pdf", server.URL + "/c.pdf", server.URL + "/d.pdf"}
+
+			archive := &entryRecorder{entries: map[string]*bytes.Buffer{}}
+			files, failures, err := task.writeArchive(context.Background(), env, archive)
+			if err != nil {
+				t.Fatalf("writeArchive: %v", err)
+			}
+
+			// In parallel, any of the concurrent downloads may be the one
+			// that overdraws the budget, including the first file.
+			wantFiles := 1
+			if tt.parallel > 1 {
+				wantFiles = len(files)
+			}
+			if len(files) != wantFiles || len(files) > 1 || len(archive.entries) != len(files) {
+				t.Errorf("archived %d files in %d entries, want 1", len(files), len(archive.entries))
+			}
+			for name, content := range archive.entries {
+				if content.Len() != fileSize {
+					t.Errorf("entry %s has %d bytes, want %d", name, content.Len(), fileSize)
+				}
+			}
+			if len(failures) != len(task.FileURLs)-len(files) {
+				t.Fatalf("got %d failures for %d files: %v", len(failures), len(task.FileURLs), failures)
+			}
+			for _, failure := range failures {
+				if failure.Code != CodeBudgetExceeded {
+					t.Errorf("failure %s: code %s, want %s", failure.URL, failure.Code, CodeBudgetExceeded)
+				}
+			}
+			// Downloads stop once the budget is overdrawn; the server may
+			// have written ahead into socket buffers, but no further file
+			// is fetched in full.
+			if got := sent.Load(); got > int64(tt.parallel+1)*fileSize {
+				t.Errorf("server sent %d bytes", got)
+			}
+		})
+	}
+}
+
+func TestProcessTimeBudgetCutsOffDownload(t *testing.T) {
+	// The server sends the start of every file and then stalls until the
+	// client goes away.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("%PDF-1.4 "))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	for _, parallel := range []int{1, 2} {
+		t.Run(fmt.Sprintf("parallel %d", parallel), func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			tk := NewTask()
+			tk.TimeBudgetSeconds = 1
+			tk.FileURLs = []string{server.URL + "/a.pdf", server.URL + "/b.pdf"}
+			start := time.Now()
+			tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, ParallelDownloads: parallel}))
+
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("processing took %v with a one second time budget", elapsed)
+			}
+			if len(tk.Errors) != 2 {
+				t.Fatalf("got %d errors, want both files stopped: %+v", len(tk.Errors), tk.Errors)
+			}
+			for _, e := range tk.Errors {
+				if e.Code != CodeBudgetExceeded {
+					t.Errorf("error %+v, want %s", e, CodeBudgetExceeded)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package task
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"time"
+)
+
+// BundleEntryName is the zip entry that holds the bundled small files.
+const BundleEntryName = "small_files.tar"
+
+// smallFiles collects files below a size threshold into one tar, written
+// as a single entry once every file is processed. A nil *smallFiles
+// bundles nothing.
+type smallFiles struct {
+	threshold int64
+	file      *os.File
+	tar       *tar.Writer
+}
+
+// newSmallFiles returns a bundle for files smaller than threshold bytes,
+// or nil when threshold is not positive.
+func newSmallFiles(threshold int64) *smallFiles {
+	if threshold <= 0 {
+		return nil
+	}
+	return &smallFiles{threshold: threshold}
+}
+
+// accepts reports whether a file of size bytes goes into the bundle.
+func (b *smallFiles) accepts(size int64) bool {
+	return b != nil && size < b.threshold
+}
+
+// add appends the content of info to the bundle under info.Name.
+func (b *smallFiles) add(info FileInfo, content io.Reader) error {
+	if b.file == nil {
+		file, err := os.CreateTemp("", "bundle-*")
+		if err != nil {
+			return err
+		}
+		b.file = file
+		b.tar = tar.NewWriter(file)
+	}
+	header := &tar.Header{Name: info.Name, Mode: 0644, Size: info.Size, ModTime: time.Now()}
+	if err := b.tar.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.Copy(b.tar, content)
+	return err
+}
+
+// writeTo adds the bundle to archive as BundleEntryName. It does nothing
+// if no file was bundled.
+func (b *smallFiles) writeTo(archive ArchiveWriter) error {
+	if b == nil || b.file == nil {
+		return nil
+	}
+	if err := b.tar.Close(); err != nil {
+		return err
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	entry, err := archive.Create(BundleEntryName)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, b.file)
+	return err
+}
+
+// discard removes the temporary file of the bundle.
+func (b *smallFiles) discard() {
+	if b != nil && b.file != nil {
+		removeStaged(b.file)
+	}
+}
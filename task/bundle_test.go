@@ -0,0 +1,94 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestProcessBundlesSmallFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/big.pdf" {
+			w.Write([]byte(strings.Repeat("x", 100)))
+			return
+		}
+		w.Write([]byte("small " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	tk := NewTask()
+	tk.FileURLs = []string{server.URL + "/a.pdf", server.URL + "/big.pdf", server.URL + "/b.pdf"}
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, SmallFileBundleThreshold: 50}))
+	if tk.Status != StatusDone || len(tk.Errors) != 0 {
+		t.Fatalf("task %s with errors %+v", tk.Status, tk.Errors)
+	}
+	for _, f := range tk.Files {
+		wantBundle := ""
+		if f.Name != "big.pdf" {
+			wantBundle = BundleEntryName
+		}
+		if f.Bundle != wantBundle {
+			t.Errorf("%s: bundle %q, want %q", f.Name, f.Bundle, wantBundle)
+		}
+	}
+
+	reader, err := zip.OpenReader(tk.ID + ".zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	var names []string
+	bundled := make(map[string]string)
+	for _, entry := range reader.File {
+		names = append(names, entry.Name)
+		if entry.Name != BundleEntryName {
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := tar.NewReader(rc)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := io.ReadAll(tr)
+			bundled[header.Name] = string(data)
+		}
+		rc.Close()
+	}
+	if got := strings.Join(names, " "); got != "big.pdf "+BundleEntryName {
+		t.Fatalf("entries %q, want big.pdf and the bundle", got)
+	}
+	if len(bundled) != 2 || bundled["a.pdf"] != "small /a.pdf" || bundled["b.pdf"] != "small /b.pdf" {
+		t.Fatalf("bundle holds %q", bundled)
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestNilSmallFiles(t *testing.T) {
+	b := newSmallFiles(0)
+	if b != nil || b.accepts(1) {
+		t.Fatal("a zero threshold bundles files")
+	}
+	if err := b.writeTo(nil); err != nil {
+		t.Fatal(err)
+	}
+	b.discard()
+}
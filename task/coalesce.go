@@ -0,0 +1,97 @@
+package task
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CoalesceKey identifies the work a task asks for: its normalized URL set
+// and the per-task options that could change the result. Tasks with equal
+// keys produce the same archive; file metadata counts, as it ends up in
+// the manifest. The client is part of the key, so tasks
+// never share an archive across client namespaces.
+func (t *Task) CoalesceKey() string {
+	t.mutex.Lock()
+	urls := make([]string, len(t.FileURLs))
+	for i, fileURL := range t.FileURLs {
+		urls[i] = normalizeURL(fileURL)
+	}
+	byteBudget, timeBudget := t.ByteBudget, t.TimeBudgetSeconds
+	since := t.modifiedSince()
+	clientID := t.ClientID
+	patterns := fmt.Sprintf("%q %q", t.Include, t.Exclude)
+	// Map keys are sorted when marshaled, so the encoding is stable.
+	metadata, _ := json.Marshal(t.Metadata)
+	fileNames, _ := json.Marshal(t.FileNames)
+	format := t.ArchiveFormat
+	t.mutex.Unlock()
+
+	sort.Strings(urls)
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%d\n%d\n%s\n%s\n%s\n%s\n%s", clientID, format, byteBudget, timeBudget, since.Format(time.RFC3339Nano), patterns, metadata, fileNames, strings.Join(urls, "\n"))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// normalizeURL lowercases the scheme and host of fileURL, which are case
+// insensitive. Unparsable URLs are used as given.
+func normalizeURL(fileURL string) string {
+	u, err := url.Parse(strings.TrimSpace(fileURL))
+	if err != nil {
+		return fileURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	return u.String()
+}
+
+// Mirror completes t with the outcome of leader, an identical task, instead
+// of processing it. It blocks until leader has finished.
+func (t *Task) Mirror(leader *Task) {
+	if err := t.MarkProcessing(); err != nil {
+		t.logger().Warn("not coalescing task", "error", err)
+		return
+	}
+	t.mutex.Lock()
+	t.CoalescedWith = leader.ID
+	t.mutex.Unlock()
+	t.logger().Info("task coalesced", "leader_id", leader.ID)
+
+	<-leader.Done()
+
+	leader.mutex.Lock()
+	status := leader.Status
+	archiveName := leader.archiveName
+	resultURL := leader.ResultURL
+	checksum := leader.ResultChecksum
+	signature := leader.signature
+	keyID := leader.SigningKeyID
+	files := append([]FileInfo(nil), leader.Files...)
+	errorDetails := leader.ErrorDetails
+	failures := append([]FileError(nil), leader.Errors...)
+	fileStatuses := append([]FileStatus(nil), leader.FileStatuses...)
+	expiresAt := leader.ExpiresAt
+	leader.mutex.Unlock()
+	t.progress.copyFrom(&leader.progress)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.archiveName = archiveName
+	t.ResultURL = resultURL
+	t.ResultChecksum = checksum
+	t.signature = signature
+	t.SigningKeyID = keyID
+	t.Files = files
+	t.ErrorDetails = errorDetails
+	t.Errors = failures
+	t.FileStatuses = fileStatuses
+	t.ExpiresAt = expiresAt
+	if err := t.setStatus(status); err != nil {
+		t.logger().Error("failed to complete coalesced task", "error", err)
+	}
+}
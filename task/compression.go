@@ -0,0 +1,89 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/flate"
+	"io"
+
+	dsnetbzip2 "github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Zip method IDs from APPNOTE.TXT 4.4.5 that archive/zip does not define.
+const (
+	methodBzip2 uint16 = 12
+	methodZstd  uint16 = 93
+)
+
+// zipMethod returns the zip method ID for the configured compression.
+// Level 0 stores entries as they are.
+func (env *Env) zipMethod() uint16 {
+	if env.compressionLevel() == flate.NoCompression {
+		return zip.Store
+	}
+	switch env.Config.CompressionMethod {
+	case config.CompressionBzip2:
+		return methodBzip2
+	case config.CompressionZstd:
+		return methodZstd
+	default:
+		return zip.Deflate
+	}
+}
+
+// compressionLevel returns the configured flate level, or the default.
+func (env *Env) compressionLevel() int {
+	if env.Config.CompressionLevel == nil {
+		return flate.DefaultCompression
+	}
+	return *env.Config.CompressionLevel
+}
+
+// newZipWriter returns a zip.Writer able to write entries with the
+// configured compression method and, for deflate, level. Entries are streamed with data
+// descriptors, and archive/zip switches to ZIP64 records by itself for
+// entries or offsets past 4 GiB and for more than 65535 entries, so no
+// size needs to be known up front.
+func newZipWriter(env *Env, w io.Writer) *zip.Writer {
+	zipWriter := zip.NewWriter(w)
+	switch env.zipMethod() {
+	case methodBzip2:
+		zipWriter.RegisterCompressor(methodBzip2, func(w io.Writer) (io.WriteCloser, error) {
+			return dsnetbzip2.NewWriter(w, nil)
+		})
+	case methodZstd:
+		zipWriter.RegisterCompressor(methodZstd, zstd.ZipCompressor())
+	case zip.Deflate:
+		level := env.compressionLevel()
+		zipWriter.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, level)
+		})
+	}
+	return zipWriter
+}
+
+// createEntry starts a new entry called name compressed with method.
+func createEntry(zipWriter *zip.Writer, name string, method uint16) (io.Writer, error) {
+	return zipWriter.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+}
+
+// OpenArchive opens the archive at path with decompressors registered for
+// every method archives may be written with.
+func OpenArchive(path string) (*zip.ReadCloser, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	registerDecompressors(&reader.Reader)
+	return reader, nil
+}
+
+// registerDecompressors adds the methods archive/zip lacks to reader.
+func registerDecompressors(reader *zip.Reader) {
+	reader.RegisterDecompressor(methodBzip2, func(r io.Reader) io.ReadCloser {
+		return io.NopCloser(bzip2.NewReader(r))
+	})
+	reader.RegisterDecompressor(methodZstd, zstd.ZipDecompressor())
+}
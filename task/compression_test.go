@@ -0,0 +1,97 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressionRoundTrip(t *testing.T) {
+	content := strings.Repeat("compressible text ", 1000)
+	tests := []struct {
+		method string
+		want   uint16
+	}{
+		{config.CompressionDeflate, zip.Deflate},
+		{config.CompressionBzip2, methodBzip2},
+		{config.CompressionZstd, methodZstd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			env := &Env{Config: &config.Config{CompressionMethod: tt.method}}
+			path := filepath.Join(t.TempDir(), "archive.zip")
+			file, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			zipWriter := newZipWriter(env, file)
+			entry, err := createEntry(zipWriter, "a.txt", env.zipMethod())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.WriteString(entry, content); err != nil {
+				t.Fatal(err)
+			}
+			if err := zipWriter.Close(); err != nil {
+				t.Fatal(err)
+			}
+			file.Close()
+
+			reader, err := OpenArchive(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+			f := reader.File[0]
+			if f.Method != tt.want {
+				t.Fatalf("method %d, want %d", f.Method, tt.want)
+			}
+			if f.CompressedSize64 >= f.UncompressedSize64 {
+				t.Fatalf("entry not compressed: %d of %d bytes", f.CompressedSize64, f.UncompressedSize64)
+			}
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil || string(data) != content {
+				t.Fatalf("read back %d bytes, %v", len(data), err)
+			}
+		})
+	}
+}
+
+func TestCompressionLevel(t *testing.T) {
+	content := strings.Repeat("compressible text ", 1000)
+	size := func(format string, level int) int {
+		var buf bytes.Buffer
+		archive := newArchiveWriter(&Env{Config: &config.Config{CompressionLevel: &level}}, &buf, format)
+		entry, err := archive.Create("a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(entry, content); err != nil {
+			t.Fatal(err)
+		}
+		if err := archive.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Len()
+	}
+	for _, format := range []string{FormatZip, FormatTarGz} {
+		if stored, best := size(format, 0), size(format, 9); stored <= len(content) || best >= stored {
+			t.Errorf("%s: %d bytes at level 0 and %d at level 9 for %d bytes of content", format, stored, best, len(content))
+		}
+	}
+
+	level := 0
+	if method := (&Env{Config: &config.Config{CompressionLevel: &level}}).zipMethod(); method != zip.Store {
+		t.Fatalf("method %d at level 0, want %d", method, zip.Store)
+	}
+}
@@ -0,0 +1,85 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"archive/zip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessDownloadPhaseDeadline(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+		if r.URL.Path == "/slow.pdf" {
+			// Send part of the body, then stall until the client gives up.
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	tk := NewTask()
+	tk.FileURLs = []string{server.URL + "/a.pdf", server.URL + "/slow.pdf", server.URL + "/c.pdf"}
+	start := time.Now()
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, MaxDownloadPhaseDuration: 1}))
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("processing took %v with a 1s download phase", elapsed)
+	}
+
+	if tk.Status != StatusPartial || len(tk.Files) != 1 || len(tk.Errors) != 2 {
+		t.Fatalf("task %s with %d files and errors %+v, want partial with 1 file and 2 errors", tk.Status, len(tk.Files), tk.Errors)
+	}
+	if e := tk.Errors[1]; e.Code != CodeTimeout || !strings.HasSuffix(e.URL, "/c.pdf") {
+		t.Fatalf("error %+v, want c.pdf skipped for the deadline", e)
+	}
+
+	// The stalled download was staged, so no truncated entry was written.
+	r, err := zip.OpenReader(tk.ID + ".zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if len(r.File) != 1 || r.File[0].Name != "a.pdf" {
+		t.Fatalf("archive has %d entries, want only a.pdf", len(r.File))
+	}
+}
+
+func TestProcessDownloadTimeout(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+		if r.URL.Path == "/slow.pdf" {
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	tk := NewTask()
+	tk.FileURLs = []string{server.URL + "/slow.pdf", server.URL + "/b.pdf"}
+	start := time.Now()
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, DownloadTimeout: 1}))
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("processing took %v with a 1s download timeout", elapsed)
+	}
+
+	// Only the hung download fails; the next one gets its own timeout.
+	if tk.Status != StatusPartial || len(tk.Files) != 1 || len(tk.Errors) != 1 {
+		t.Fatalf("task %s with %d files and errors %+v, want partial with 1 file and 1 error", tk.Status, len(tk.Files), tk.Errors)
+	}
+	if e := tk.Errors[0]; e.Code != CodeTimeout || !strings.HasSuffix(e.URL, "/slow.pdf") {
+		t.Fatalf("error %+v, want slow.pdf timed out", e)
+	}
+	r, err := zip.OpenReader(tk.ID + ".zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if len(r.File) != 1 || r.File[0].Name != "b.pdf" {
+		t.Fatalf("archive has %d entries, want only b.pdf", len(r.File))
+	}
+}
@@ -0,0 +1,62 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"2025-08-02/metrics"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Env carries the configuration and the state shared by all tasks while
+// they are processed.
+type Env struct {
+	Config *config.Config
+	Hosts  *HostPacer
+	// Client fetches remote sources.
+	Client *http.Client
+	// Metrics receives task and file measurements.
+	Metrics metrics.Recorder
+	// prefetchSlots bounds the number of concurrent prefetch downloads.
+	prefetchSlots chan struct{}
+}
+
+// NewEnv builds the processing environment for cfg.
+func NewEnv(cfg *config.Config) *Env {
+	env := &Env{Config: cfg, Client: newHTTPClient(cfg), Metrics: metrics.New(cfg)}
+	if cfg.PoliteMode {
+		env.Hosts = NewHostPacer(cfg.PoliteDelayMs, cfg.PoliteHostDelaysMs)
+	}
+	if cfg.PrefetchOnAdd {
+		env.prefetchSlots = make(chan struct{}, cfg.MaxPrefetches)
+	}
+	return env
+}
+
+// newHTTPClient returns the client for downloads. Response headers are
+// capped at MaxResponseHeaderBytes so an origin cannot exhaust memory with
+// huge headers, and each download is aborted after DownloadTimeout seconds
+// so a hung origin cannot hold a task forever. With DownloadProxy set,
+// requests to hosts outside NoProxy go through it; otherwise the proxy
+// environment variables apply. Redirects are only followed to http and
+// https URLs, and with BlockPrivateIPs neither they nor any connection may
+// reach an internal address.
+func newHTTPClient(cfg *config.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxResponseHeaderBytes = cfg.MaxResponseHeaderBytes
+	var proxyHost string
+	if cfg.DownloadProxy != "" {
+		// LoadConfig has validated the URL.
+		proxyURL, _ := url.Parse(cfg.DownloadProxy)
+		transport.Proxy = proxyFunc(proxyURL, cfg.NoProxy)
+		proxyHost = proxyURL.Hostname()
+	}
+	if cfg.BlockPrivateIPs {
+		transport.DialContext = guardedDial(proxyHost)
+	}
+	return &http.Client{
+		Transport:     transport,
+		Timeout:       time.Duration(cfg.DownloadTimeout) * time.Second,
+		CheckRedirect: checkRedirect(cfg),
+	}
+}
@@ -0,0 +1,150 @@
+package task
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// ErrorCode classifies why a file could not be archived.
+type ErrorCode string
+
+const (
+	CodeExtensionNotAllowed ErrorCode = "EXTENSION_NOT_ALLOWED"
+	CodeDownloadFailed      ErrorCode = "DOWNLOAD_FAILED"
+	CodeHTTPStatus          ErrorCode = "HTTP_STATUS"
+	CodeDNSFailure          ErrorCode = "DNS_FAILURE"
+	CodeConnRefused         ErrorCode = "CONN_REFUSED"
+	CodeTLSError            ErrorCode = "TLS_ERROR"
+	CodeTimeout             ErrorCode = "TIMEOUT"
+	CodeSourceDisabled      ErrorCode = "SOURCE_DISABLED"
+	CodeTooLarge            ErrorCode = "TOO_LARGE"
+	CodeWriteFailed         ErrorCode = "WRITE_FAILED"
+	CodeArchiveFailed       ErrorCode = "ARCHIVE_FAILED"
+	CodeBudgetExceeded      ErrorCode = "BUDGET_EXCEEDED"
+	CodeURLExpired          ErrorCode = "URL_EXPIRED"
+	CodeHeadersTooLarge     ErrorCode = "HEADERS_TOO_LARGE"
+	CodeInterrupted         ErrorCode = "INTERRUPTED"
+	CodeCanceled            ErrorCode = "CANCELED"
+	CodeInvalidArchive      ErrorCode = "INVALID_ARCHIVE"
+	// CodeInvalidPath marks URLs whose path climbs above the root with
+	// ".." segments when entries keep their paths.
+	CodeInvalidPath ErrorCode = "INVALID_PATH"
+	// CodeNestedTooLarge marks expanded archives over the entry or
+	// decompressed size limits.
+	CodeNestedTooLarge ErrorCode = "NESTED_ARCHIVE_TOO_LARGE"
+	// CodeNotModified marks files skipped because of modified_since; it is
+	// reported in FileInfo.Skipped rather than as an error.
+	CodeNotModified ErrorCode = "NOT_MODIFIED"
+	// CodeExcluded marks files left out by the task's include and exclude
+	// patterns, reported in FileInfo.Skipped.
+	CodeExcluded ErrorCode = "EXCLUDED"
+	// CodeInvalidURL marks URLs that are malformed or use a scheme other
+	// than http and https.
+	CodeInvalidURL ErrorCode = "INVALID_URL"
+	// CodeBlockedTarget marks URLs and redirects that lead to an internal
+	// address under block_private_ips.
+	CodeBlockedTarget ErrorCode = "BLOCKED_TARGET"
+	// CodeDomainNotAllowed marks URLs and redirects to hosts outside
+	// allowed_domains.
+	CodeDomainNotAllowed ErrorCode = "DOMAIN_NOT_ALLOWED"
+)
+
+// FileError is a structured failure reported in the task status. URL is
+// empty for failures that concern the archive as a whole.
+type FileError struct {
+	URL     string    `json:"url,omitempty"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+func (e *FileError) Error() string {
+	return e.Message
+}
+
+func newFileError(fileURL string, code ErrorCode, format string, args ...any) *FileError {
+	return &FileError{URL: fileURL, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// downloadError wraps a failed fetch of fileURL with a code describing the
+// kind of network failure.
+func downloadError(fileURL string, err error) *FileError {
+	return newFileError(fileURL, classifyDownloadError(err), "failed to download file: %s, error: %v", fileURL, err)
+}
+
+// asFileError returns err as a *FileError, classifying plain errors as
+// generic download failures.
+func asFileError(fileURL string, err error) FileError {
+	var fe *FileError
+	if errors.As(err, &fe) {
+		return *fe
+	}
+	return FileError{URL: fileURL, Code: CodeDownloadFailed, Message: err.Error()}
+}
+
+// classifyDownloadError distinguishes DNS, connection, TLS and timeout
+// failures from other download errors.
+func classifyDownloadError(err error) ErrorCode {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return CodeTimeout
+		}
+		return CodeDNSFailure
+	}
+
+	if errors.Is(err, errFileTooLarge) {
+		return CodeTooLarge
+	}
+	if errors.Is(err, errBudgetExceeded) {
+		return CodeBudgetExceeded
+	}
+	if errors.Is(err, errBlockedTarget) {
+		return CodeBlockedTarget
+	}
+	if errors.Is(err, errDomainNotAllowed) {
+		return CodeDomainNotAllowed
+	}
+
+	// net/http reports oversized response headers with an unexported
+	// error, so its message is the only way to tell.
+	if strings.Contains(err.Error(), "server response headers exceeded") {
+		return CodeHeadersTooLarge
+	}
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return CodeHTTPStatus
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return CodeConnRefused
+	}
+
+	var (
+		recordErr    tls.RecordHeaderError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		alertErr     tls.AlertError
+	)
+	if errors.As(err, &recordErr) || errors.As(err, &verifyErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) || errors.As(err, &alertErr) {
+		return CodeTLSError
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return CodeTimeout
+	}
+
+	return CodeDownloadFailed
+}
@@ -0,0 +1,85 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClassifyDownloadError(t *testing.T) {
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedURL := "http://" + closed.Addr().String() + "/a.pdf"
+	closed.Close()
+
+	untrusted := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer untrusted.Close()
+
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer hanging.Close()
+	defer close(release)
+
+	tests := []struct {
+		name string
+		url  string
+		want ErrorCode
+	}{
+		{"unresolvable host", "http://archiver-test.invalid/a.pdf", CodeDNSFailure},
+		{"closed port", closedURL, CodeConnRefused},
+		{"untrusted certificate", untrusted.URL + "/a.pdf", CodeTLSError},
+		{"hanging server", hanging.URL + "/a.pdf", CodeTimeout},
+	}
+	client := &http.Client{Timeout: 200 * time.Millisecond}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Get(tt.url)
+			if err == nil {
+				resp.Body.Close()
+				t.Fatal("request succeeded")
+			}
+			if got := classifyDownloadError(err); got != tt.want {
+				t.Fatalf("classifyDownloadError(%v) = %s, want %s", err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyWrappedErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"status", fmt.Errorf("get: %w", &statusError{status: "404 Not Found"}), CodeHTTPStatus},
+		{"dns timeout", &net.DNSError{Err: "timeout", IsTimeout: true}, CodeTimeout},
+		{"other", errors.New("unexpected EOF"), CodeDownloadFailed},
+	}
+	for _, tt := range tests {
+		if got := classifyDownloadError(tt.err); got != tt.want {
+			t.Errorf("%s: classifyDownloadError = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAsFileError(t *testing.T) {
+	fe := newFileError("https://example.com/a.pdf", CodeTooLarge, "too large")
+	if got := asFileError("other", fmt.Errorf("wrapped: %w", fe)); got != *fe {
+		t.Errorf("asFileError kept %+v, want %+v", got, *fe)
+	}
+	got := asFileError("https://example.com/b.pdf", errors.New("boom"))
+	if got.URL != "https://example.com/b.pdf" || got.Code != CodeDownloadFailed || got.Message != "boom" {
+		t.Errorf("asFileError of a plain error = %+v", got)
+	}
+}
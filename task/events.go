@@ -0,0 +1,98 @@
+package task
+
+import "errors"
+
+// subscriberBuffer is how many undelivered events a subscriber may queue
+// before it is considered too slow and dropped.
+const subscriberBuffer = 16
+
+// ErrTooManySubscribers is returned when a task already has the maximum
+// number of event subscribers.
+var ErrTooManySubscribers = errors.New("too many subscribers for task")
+
+// Event is a progress update published while a task is processed.
+type Event struct {
+	Type         string    `json:"type"` // "status" or "file"
+	Status       Status    `json:"status"`
+	File         string    `json:"file,omitempty"`
+	Name         string    `json:"name,omitempty"` // entry name of an archived file
+	Code         ErrorCode `json:"code,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	ErrorDetails string    `json:"error_details,omitempty"`
+}
+
+// Subscribe registers a new event subscriber, allowing at most limit per
+// task when limit is positive. The returned channel is closed when the task
+// finishes, when the subscriber falls behind, or after cancel is called.
+func (t *Task) Subscribe(limit int) (<-chan Event, func(), error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	ch := make(chan Event, subscriberBuffer)
+	if t.Status.Finished() {
+		close(ch)
+		return ch, func() {}, nil
+	}
+	if limit > 0 && len(t.subscribers) >= limit {
+		return nil, nil, ErrTooManySubscribers
+	}
+	if t.subscribers == nil {
+		t.subscribers = make(map[chan Event]struct{})
+	}
+	t.subscribers[ch] = struct{}{}
+
+	cancel := func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		t.dropSubscriberLocked(ch)
+	}
+	return ch, cancel, nil
+}
+
+func (t *Task) publish(e Event) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.publishLocked(e)
+}
+
+// publishLocked delivers e without blocking; subscribers whose buffer is
+// full are dropped so a slow client never stalls processing. Callers must
+// hold t.mutex.
+func (t *Task) publishLocked(e Event) {
+	for ch := range t.subscribers {
+		select {
+		case ch <- e:
+		default:
+			t.dropSubscriberLocked(ch)
+		}
+	}
+	if e.Type == "status" && e.Status.Finished() {
+		for ch := range t.subscribers {
+			t.dropSubscriberLocked(ch)
+		}
+		if t.done == nil {
+			t.done = make(chan struct{})
+		}
+		close(t.done)
+	}
+}
+
+// Done returns a channel that is closed once the task is done or failed.
+func (t *Task) Done() <-chan struct{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.done == nil {
+		t.done = make(chan struct{})
+		if t.Status.Finished() {
+			close(t.done)
+		}
+	}
+	return t.done
+}
+
+func (t *Task) dropSubscriberLocked(ch chan Event) {
+	if _, ok := t.subscribers[ch]; ok {
+		delete(t.subscribers, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,77 @@
+package task
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSubscribeLimit(t *testing.T) {
+	tk := NewTask()
+	for i := range 2 {
+		if _, _, err := tk.Subscribe(2); err != nil {
+			t.Fatalf("subscriber %d: %v", i+1, err)
+		}
+	}
+	if _, _, err := tk.Subscribe(2); !errors.Is(err, ErrTooManySubscribers) {
+		t.Fatalf("third subscriber: %v, want %v", err, ErrTooManySubscribers)
+	}
+	if _, _, err := tk.Subscribe(0); err != nil {
+		t.Fatalf("unlimited subscriber: %v", err)
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	tk := NewTask()
+	slow, _, err := tk.Subscribe(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, cancel, err := tk.Subscribe(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+
+	// The slow subscriber never reads; one event more than its buffer
+	// drops it while the fast one keeps up.
+	for range subscriberBuffer + 1 {
+		tk.publish(Event{Type: "file", Status: StatusDone})
+		if _, ok := <-fast; !ok {
+			t.Fatal("fast subscriber was dropped")
+		}
+	}
+	received := 0
+	for range slow {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Fatalf("slow subscriber got %d events before it was dropped, want %d", received, subscriberBuffer)
+	}
+	if len(tk.subscribers) != 1 {
+		t.Fatalf("%d subscribers left, want 1", len(tk.subscribers))
+	}
+}
+
+func TestSubscribersClosedWhenTaskFinishes(t *testing.T) {
+	tk := NewTask()
+	events, _, err := tk.Subscribe(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk.setError("failed")
+	e, ok := <-events
+	if !ok || e.Status != StatusError || e.ErrorDetails != "failed" {
+		t.Fatalf("final event %+v, %v", e, ok)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("channel still open after the task finished")
+	}
+
+	finished, _, err := tk.Subscribe(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-finished; ok {
+		t.Fatal("subscription to a finished task is open")
+	}
+}
@@ -0,0 +1,222 @@
+package task
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// expandSuffixes are the source names ExpandArchives unpacks.
+var expandSuffixes = []string{".zip", ".tar.gz", ".tgz"}
+
+// expandedFolder returns the folder the entries of an archive source called
+// name are written under, and whether name is an archive that can be
+// expanded at all.
+func expandedFolder(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	for _, suffix := range expandSuffixes {
+		if strings.HasSuffix(lower, suffix) && len(name) > len(suffix) {
+			return name[:len(name)-len(suffix)], true
+		}
+	}
+	return "", false
+}
+
+// expandsArchive reports whether the file named name is unpacked into the
+// output archive instead of stored as one entry.
+func (env *Env) expandsArchive(name string) bool {
+	if !env.Config.ExpandArchives {
+		return false
+	}
+	_, ok := expandedFolder(name)
+	return ok
+}
+
+// expandArchive writes the files inside the staged zip or gzipped tar
+// archive described by info as entries below a folder named after it. The
+// entry count and the declared sizes are checked against the configured
+// limits before anything is written, and the decompressed bytes are
+// counted again while the entries stream through, so an archive bomb never
+// reaches the output. Inner archives are not expanded further.
+func (t *Task) expandArchive(env *Env, archive ArchiveWriter, staged *os.File, info FileInfo) (FileInfo, error) {
+	folder, _ := expandedFolder(info.Name)
+	limits := &expandLimits{maxEntries: env.Config.MaxExpandedEntries, maxBytes: env.Config.MaxExpandedSize}
+	var err error
+	if strings.HasSuffix(strings.ToLower(info.Name), ".zip") {
+		info.Size, err = t.expandZip(archive, staged, info, folder, limits, t.entryFilter())
+	} else {
+		info.Size, err = t.expandTarGz(archive, staged, info, folder, limits, t.entryFilter())
+	}
+	info.Name = folder
+	if err != nil {
+		var fe *FileError
+		if errors.As(err, &fe) {
+			return info, fe
+		}
+		return info, newFileError(info.URL, CodeInvalidArchive, "failed to expand archive %s: %v", info.URL, err)
+	}
+	return info, nil
+}
+
+func (t *Task) expandZip(archive ArchiveWriter, staged *os.File, info FileInfo, folder string, limits *expandLimits, filter entryFilter) (int64, error) {
+	reader, err := zip.NewReader(staged, info.Size)
+	if err != nil {
+		return 0, err
+	}
+	registerDecompressors(reader)
+	for _, f := range reader.File {
+		// archive/zip fails reads past the declared size, so checking
+		// the declared sizes bounds what is decompressed.
+		if err := limits.add(info.URL, int64(f.UncompressedSize64)); err != nil {
+			return 0, err
+		}
+	}
+
+	var written int64
+	for _, f := range reader.File {
+		name, ok := innerName(folder, f.Name)
+		if !ok || !f.Mode().IsRegular() {
+			continue
+		}
+		body, err := f.Open()
+		if err != nil {
+			return written, err
+		}
+		size, err := t.writePage(archive, limits.stream(info.URL, body), FileInfo{URL: info.URL, Name: name}, filter)
+		body.Close()
+		if err != nil {
+			return written, limits.cause(err)
+		}
+		written += size
+	}
+	return written, nil
+}
+
+func (t *Task) expandTarGz(archive ArchiveWriter, staged *os.File, info FileInfo, folder string, limits *expandLimits, filter entryFilter) (int64, error) {
+	// Tar sizes are only known while the stream is read, so the headers
+	// are checked in a first pass that stops at the first one over a
+	// limit, before its content is decompressed.
+	err := walkTarGz(staged, func(header *tar.Header, _ io.Reader) error {
+		return limits.add(info.URL, header.Size)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := staged.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	var written int64
+	err = walkTarGz(staged, func(header *tar.Header, body io.Reader) error {
+		name, ok := innerName(folder, header.Name)
+		if !ok || header.Typeflag != tar.TypeReg {
+			return nil
+		}
+		size, err := t.writePage(archive, limits.stream(info.URL, body), FileInfo{URL: info.URL, Name: name}, filter)
+		written += size
+		return err
+	})
+	if err != nil {
+		return written, limits.cause(err)
+	}
+	return written, nil
+}
+
+// walkTarGz calls fn for every entry of the gzipped tar stream r.
+func walkTarGz(r io.Reader, fn func(*tar.Header, io.Reader) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// innerName places the inner path name below folder. Leading slashes and
+// ".." elements cannot climb out of it. ok is false for names that are
+// empty once cleaned.
+func innerName(folder, name string) (string, bool) {
+	cleaned := path.Clean("/" + strings.ReplaceAll(name, `\`, "/"))
+	if cleaned == "/" {
+		return "", false
+	}
+	return folder + cleaned, true
+}
+
+// expandLimits counts the entries and bytes of an expanded archive. Zero
+// maximums are unlimited.
+type expandLimits struct {
+	maxEntries int
+	maxBytes   int64
+	entries    int
+	bytes      int64
+	// streamed counts the bytes actually decompressed, which a corrupt
+	// or crafted archive may set apart from the declared sizes.
+	streamed int64
+	// err is the limit error a stream ran into; it is reported instead of
+	// the read failure it caused further up.
+	err error
+}
+
+func (l *expandLimits) add(fileURL string, size int64) error {
+	l.entries++
+	l.bytes += size
+	if l.maxEntries > 0 && l.entries > l.maxEntries {
+		return newFileError(fileURL, CodeNestedTooLarge, "archive %s has more than %d entries", fileURL, l.maxEntries)
+	}
+	if size < 0 || l.maxBytes > 0 && l.bytes > l.maxBytes {
+		return newFileError(fileURL, CodeNestedTooLarge, "archive %s expands to more than %d bytes", fileURL, l.maxBytes)
+	}
+	return nil
+}
+
+// stream counts what is read from the inner entry r against the byte
+// limit, failing the read once the archive has decompressed past it.
+func (l *expandLimits) stream(fileURL string, r io.Reader) io.Reader {
+	return &expandStream{limits: l, fileURL: fileURL, r: r}
+}
+
+// cause returns the limit error behind err, if a stream ran into one.
+func (l *expandLimits) cause(err error) error {
+	if l.err != nil {
+		return l.err
+	}
+	return err
+}
+
+type expandStream struct {
+	limits  *expandLimits
+	fileURL string
+	r       io.Reader
+}
+
+func (s *expandStream) Read(p []byte) (int, error) {
+	l := s.limits
+	if l.err != nil {
+		return 0, l.err
+	}
+	n, err := s.r.Read(p)
+	l.streamed += int64(n)
+	if l.maxBytes > 0 && l.streamed > l.maxBytes {
+		l.err = newFileError(s.fileURL, CodeNestedTooLarge, "archive %s expands to more than %d bytes", s.fileURL, l.maxBytes)
+		return n, l.err
+	}
+	return n, err
+}
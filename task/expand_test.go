@@ -0,0 +1,214 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestExpandedFolder(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"photos.zip", "photos", true},
+		{"Photos.ZIP", "Photos", true},
+		{"site.tar.gz", "site", true},
+		{"site.tgz", "site", true},
+		{".zip", "", false},
+		{"report.pdf", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := expandedFolder(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("expandedFolder(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestInnerName(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"a.txt", "photos/a.txt", true},
+		{"dir/a.txt", "photos/dir/a.txt", true},
+		{"/etc/passwd", "photos/etc/passwd", true},
+		{"../../a.txt", "photos/a.txt", true},
+		{`dir\..\..\a.txt`, "photos/a.txt", true},
+		{"dir/./b//a.txt", "photos/dir/b/a.txt", true},
+		{"", "", false},
+		{"../", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := innerName("photos", tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("innerName(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestExpandLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  expandLimits
+		sizes   []int64
+		wantErr bool
+	}{
+		{"unlimited", expandLimits{}, []int64{1 << 30, 1 << 30}, false},
+		{"within", expandLimits{maxEntries: 2, maxBytes: 10}, []int64{5, 5}, false},
+		{"too many entries", expandLimits{maxEntries: 2}, []int64{1, 1, 1}, true},
+		{"too many bytes", expandLimits{maxBytes: 10}, []int64{5, 6}, true},
+		{"negative size", expandLimits{}, []int64{-1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			for _, size := range tt.sizes {
+				if err = tt.limits.add("https://example.com/a.zip", size); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("add = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpandLimitsStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		sizes   []int
+		wantErr bool
+	}{
+		{"within", []int{4, 6}, false},
+		{"over in one entry", []int{11}, true},
+		{"over across entries", []int{6, 6}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := &expandLimits{maxBytes: 10}
+			var err error
+			for _, size := range tt.sizes {
+				// The declared sizes may lie; only the streamed bytes count.
+				r := limits.stream("https://example.com/a.zip", strings.NewReader(strings.Repeat("x", size)))
+				if _, err = io.Copy(io.Discard, r); err != nil {
+					break
+				}
+			}
+			err = limits.cause(err)
+			var fileErr *FileError
+			if tt.wantErr != errors.As(err, &fileErr) || tt.wantErr && fileErr.Code != CodeNestedTooLarge {
+				t.Fatalf("stream error %v, want limit error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testZip returns a zip archive holding files by name.
+func testZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testTarGz returns a gzipped tar archive holding files by name.
+func testTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessExpandsArchives(t *testing.T) {
+	inner := map[string]string{"a.txt": "a", "../dir/b.txt": "b"}
+	sources := map[string][]byte{
+		"/photos.zip":  testZip(t, inner),
+		"/site.tar.gz": testTarGz(t, inner),
+		"/bomb.zip":    testZip(t, map[string]string{"big.txt": strings.Repeat("x", 100)}),
+		"/broken.tgz":  []byte("not gzip"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(sources[r.URL.Path])
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		expand    bool
+		wantFiles map[string]string
+	}{
+		{"expanded", true, map[string]string{
+			"photos/a.txt": "a", "photos/dir/b.txt": "b",
+			"site/a.txt": "a", "site/dir/b.txt": "b",
+		}},
+		{"stored", false, map[string]string{
+			"photos.zip": string(sources["/photos.zip"]), "site.tar.gz": string(sources["/site.tar.gz"]),
+			"bomb.zip": string(sources["/bomb.zip"]), "broken.tgz": string(sources["/broken.tgz"]),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			cfg := &config.Config{
+				AllowedExtensions:  []string{".zip", ".gz", ".tgz"},
+				ExpandArchives:     tt.expand,
+				MaxExpandedEntries: 10,
+				MaxExpandedSize:    50,
+			}
+			tk := NewTask()
+			tk.FileURLs = []string{server.URL + "/photos.zip", server.URL + "/site.tar.gz", server.URL + "/bomb.zip", server.URL + "/broken.tgz"}
+			tk.Process(NewEnv(cfg))
+			if got := readZipFile(t, tk.ID+".zip"); !maps.Equal(got, tt.wantFiles) {
+				t.Fatalf("archive %q, want %q", got, tt.wantFiles)
+			}
+			if !tt.expand {
+				return
+			}
+			codes := make(map[string]ErrorCode)
+			for _, e := range tk.Errors {
+				codes[path.Base(e.URL)] = e.Code
+			}
+			if want := map[string]ErrorCode{"bomb.zip": CodeNestedTooLarge, "broken.tgz": CodeInvalidArchive}; !maps.Equal(codes, want) {
+				t.Fatalf("errors %v, want %v", codes, want)
+			}
+		})
+	}
+}
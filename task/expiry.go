@@ -0,0 +1,54 @@
+package task
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// amzDateFormat is the timestamp layout of X-Amz-Date and X-Goog-Date.
+const amzDateFormat = "20060102T150405Z"
+
+// URLExpiry returns when the presigned fileURL stops working, if it carries
+// an expiry: X-Amz-Date with X-Amz-Expires (AWS SigV4), X-Goog-Date with
+// X-Goog-Expires (GCS V4), or an absolute Expires timestamp (AWS SigV2,
+// GCS V2 and similar schemes).
+func URLExpiry(fileURL string) (time.Time, bool) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return time.Time{}, false
+	}
+	query := u.Query()
+	for _, prefix := range []string{"X-Amz-", "X-Goog-"} {
+		if expiry, ok := relativeExpiry(query.Get(prefix+"Date"), query.Get(prefix+"Expires")); ok {
+			return expiry, true
+		}
+	}
+	if seconds, err := strconv.ParseInt(query.Get("Expires"), 10, 64); err == nil {
+		return time.Unix(seconds, 0), true
+	}
+	return time.Time{}, false
+}
+
+// relativeExpiry adds expires seconds to the signing time date.
+func relativeExpiry(date, expires string) (time.Time, bool) {
+	signed, err := time.Parse(amzDateFormat, date)
+	if err != nil {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.Atoi(expires)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return signed.Add(time.Duration(seconds) * time.Second), true
+}
+
+// checkExpiry fails with URL_EXPIRED when fileURL has expired by now, so no
+// doomed download is attempted.
+func checkExpiry(fileURL string, now time.Time) error {
+	expiry, ok := URLExpiry(fileURL)
+	if !ok || now.Before(expiry) {
+		return nil
+	}
+	return newFileError(fileURL, CodeURLExpired, "URL %s expired at %s", fileURL, expiry.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,53 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestURLExpiry(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		want   time.Time
+		wantOK bool
+	}{
+		{"aws sigv4", "https://b.s3.amazonaws.com/a.pdf?X-Amz-Date=20250802T120000Z&X-Amz-Expires=3600&X-Amz-Signature=x", time.Date(2025, 8, 2, 13, 0, 0, 0, time.UTC), true},
+		{"gcs v4", "https://storage.googleapis.com/b/a.pdf?X-Goog-Date=20250802T120000Z&X-Goog-Expires=60", time.Date(2025, 8, 2, 12, 1, 0, 0, time.UTC), true},
+		{"absolute", "https://b.s3.amazonaws.com/a.pdf?Expires=1754136000&Signature=x", time.Unix(1754136000, 0), true},
+		{"bad date", "https://b.s3.amazonaws.com/a.pdf?X-Amz-Date=yesterday&X-Amz-Expires=3600", time.Time{}, false},
+		{"unsigned", "https://example.com/a.pdf", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := URLExpiry(tt.url)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Fatalf("URLExpiry = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCheckExpiry(t *testing.T) {
+	const url = "https://b.s3.amazonaws.com/a.pdf?X-Amz-Date=20250802T120000Z&X-Amz-Expires=3600"
+	tests := []struct {
+		name string
+		now  time.Time
+		want ErrorCode
+	}{
+		{"valid", time.Date(2025, 8, 2, 12, 59, 59, 0, time.UTC), ""},
+		{"at expiry", time.Date(2025, 8, 2, 13, 0, 0, 0, time.UTC), CodeURLExpired},
+		{"expired", time.Date(2025, 8, 3, 0, 0, 0, 0, time.UTC), CodeURLExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ErrorCode
+			if err := checkExpiry(url, tt.now); err != nil {
+				got = err.(*FileError).Code
+			}
+			if got != tt.want {
+				t.Fatalf("checkExpiry code %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProcessExtensionlessURLs(t *testing.T) {
+	t.Chdir(t.TempDir())
+	var heads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+		}
+		switch r.URL.Path {
+		case "/doc":
+			w.Header().Set("Content-Type", "application/pdf")
+		case "/photo":
+			w.Header().Set("Content-Type", "image/jpeg")
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+		w.Write([]byte("content " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	tk := NewTask()
+	tk.FileURLs = []string{server.URL + "/doc", server.URL + "/photo", server.URL + "/page", server.URL + "/a.html"}
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf", ".jpg"}}))
+
+	want := map[string]string{"doc.pdf": "content /doc", "photo.jpg": "content /photo"}
+	if got := readZipFile(t, tk.ID+".zip"); !maps.Equal(got, want) {
+		t.Fatalf("archive %q, want %q", got, want)
+	}
+	if len(tk.Errors) != 2 || tk.Errors[0].Code != CodeExtensionNotAllowed || tk.Errors[1].Code != CodeExtensionNotAllowed {
+		t.Fatalf("errors %+v, want page and a.html rejected", tk.Errors)
+	}
+	if n := heads.Load(); n != 0 {
+		t.Fatalf("%d HEAD requests, want the type taken from the GET", n)
+	}
+}
@@ -0,0 +1,60 @@
+package task
+
+import "encoding/json"
+
+// FileState is where one file of a task is in processing.
+type FileState string
+
+const (
+	FilePending     FileState = "pending"
+	FileDownloading FileState = "downloading"
+	FileDone        FileState = "done"
+	FileFailed      FileState = "failed"
+)
+
+// FileStatus reports the processing state of one file URL of a task.
+type FileStatus struct {
+	URL   string    `json:"url"`
+	State FileState `json:"state"`
+	Error string    `json:"error,omitempty"`
+}
+
+// MarshalJSON encodes t under its mutex, so a status response never reads
+// fields the processing goroutine is updating.
+func (t *Task) MarshalJSON() ([]byte, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return json.Marshal((*taskFields)(t))
+}
+
+// resetFileStatuses marks every file of t as pending.
+func (t *Task) resetFileStatuses() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.FileStatuses = make([]FileStatus, len(t.FileURLs))
+	for i, fileURL := range t.FileURLs {
+		t.FileStatuses[i] = FileStatus{URL: fileURL, State: FilePending}
+	}
+}
+
+// failUnfinishedFilesLocked marks every file of t that is not done as
+// failed with errMsg. Callers must hold t.mutex.
+func (t *Task) failUnfinishedFilesLocked(errMsg string) {
+	for i := range t.FileStatuses {
+		if s := &t.FileStatuses[i]; s.State != FileDone && s.State != FileFailed {
+			s.State = FileFailed
+			s.Error = errMsg
+		}
+	}
+}
+
+// setFileState records the state of the i-th file of t, with errMsg for
+// failed files.
+func (t *Task) setFileState(i int, state FileState, errMsg string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if i < len(t.FileStatuses) {
+		t.FileStatuses[i].State = state
+		t.FileStatuses[i].Error = errMsg
+	}
+}
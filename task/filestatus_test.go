@@ -0,0 +1,75 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestProcessFileStatuses(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.pdf" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer server.Close()
+
+	tk := NewTask()
+	for _, name := range []string{"/a.pdf", "/missing.pdf"} {
+		if err := tk.AddFile(server.URL+name, "", nil, 10, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := fileStates(tk); !slices.Equal(got, []FileState{FilePending, FilePending}) {
+		t.Fatalf("states before processing %v", got)
+	}
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}}))
+
+	if got := fileStates(tk); !slices.Equal(got, []FileState{FileDone, FileFailed}) {
+		t.Fatalf("states after processing %v, want done and failed", got)
+	}
+	if tk.FileStatuses[1].Error == "" {
+		t.Fatal("failed file has no error")
+	}
+
+	data, err := json.Marshal(tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		FileStatuses []FileStatus `json:"file_statuses"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(decoded.FileStatuses, tk.FileStatuses) {
+		t.Fatalf("file_statuses encoded as %+v", decoded.FileStatuses)
+	}
+}
+
+func TestCancelFailsPendingFiles(t *testing.T) {
+	tk := NewTask()
+	if err := tk.AddFile("https://example.com/a.pdf", "", nil, 10, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := tk.Cancel("task deleted"); err != nil {
+		t.Fatal(err)
+	}
+	if s := tk.FileStatuses[0]; s.State != FileFailed || s.Error != "task deleted" {
+		t.Fatalf("file status %+v after cancel", s)
+	}
+}
+
+func fileStates(tk *Task) []FileState {
+	var states []FileState
+	for _, s := range tk.FileStatuses {
+		states = append(states, s.State)
+	}
+	return states
+}
@@ -0,0 +1,54 @@
+package task
+
+import (
+	"path"
+	"strings"
+)
+
+// entryFilter selects archive entries by a task's include and exclude
+// globs. Exclude wins over include, and an empty include list admits
+// every name.
+type entryFilter struct {
+	include []string
+	exclude []string
+}
+
+func (t *Task) entryFilter() entryFilter {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return entryFilter{include: t.Include, exclude: t.Exclude}
+}
+
+// keep reports whether the entry name passes the filter.
+func (f entryFilter) keep(name string) bool {
+	if matchAny(f.exclude, name) {
+		return false
+	}
+	return len(f.include) == 0 || matchAny(f.include, name)
+}
+
+// matchAny reports whether name matches one of patterns. Patterns
+// containing a slash match the whole entry name, others only its last
+// element, so "*.tmp" matches "repo/build/x.tmp".
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		target := name
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(name)
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePatterns returns the first malformed glob among patterns.
+func ValidatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package task
+
+import "testing"
+
+func TestEntryFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter entryFilter
+		entry  string
+		want   bool
+	}{
+		{"no patterns", entryFilter{}, "repo/main.go", true},
+		{"include base name", entryFilter{include: []string{"*.go"}}, "repo/cmd/main.go", true},
+		{"include misses", entryFilter{include: []string{"*.go"}}, "repo/README.md", false},
+		{"include full path", entryFilter{include: []string{"repo/*.md"}}, "repo/README.md", true},
+		{"full path is not recursive", entryFilter{include: []string{"repo/*.md"}}, "repo/docs/guide.md", false},
+		{"exclude", entryFilter{exclude: []string{"*.tmp"}}, "repo/build/x.tmp", false},
+		{"exclude wins", entryFilter{include: []string{"*.tmp"}, exclude: []string{"x.*"}}, "x.tmp", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.keep(tt.entry); got != tt.want {
+				t.Fatalf("keep(%q) = %v, want %v", tt.entry, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePatterns(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		wantErr  bool
+	}{
+		{nil, false},
+		{[]string{"*.go", "docs/?.md", "[a-z]*"}, false},
+		{[]string{"*.go", "[a-"}, true},
+	}
+	for _, tt := range tests {
+		if err := ValidatePatterns(tt.patterns); (err != nil) != tt.wantErr {
+			t.Errorf("ValidatePatterns(%q) = %v, want error %v", tt.patterns, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package task
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"time"
+)
+
+// ArchiveWriter writes the entries of an archive one after the other.
+type ArchiveWriter interface {
+	// Create starts an entry called name. Its content is written to the
+	// returned writer until the next Create or Close.
+	Create(name string) (io.Writer, error)
+	// Close finishes the archive without closing the underlying writer.
+	Close() error
+}
+
+// newArchiveWriter returns the writer for archives in format on w.
+func newArchiveWriter(env *Env, w io.Writer, format string) ArchiveWriter {
+	if format == FormatTarGz {
+		// LoadConfig has validated the level.
+		gz, _ := gzip.NewWriterLevel(w, env.compressionLevel())
+		return &tarGzArchive{gz: gz, tar: tar.NewWriter(gz)}
+	}
+	return zipArchive{writer: newZipWriter(env, w), method: env.zipMethod()}
+}
+
+// zipArchive writes every entry with the configured compression method.
+type zipArchive struct {
+	writer *zip.Writer
+	method uint16
+}
+
+func (a zipArchive) Create(name string) (io.Writer, error) {
+	return createEntry(a.writer, name, a.method)
+}
+
+func (a zipArchive) Close() error {
+	return a.writer.Close()
+}
+
+// tarGzArchive writes a gzipped tar stream. A tar header holds the size of
+// its entry, which is not known while the entry is written, so the content
+// is buffered in a temporary file and written out by the next Create or
+// Close.
+type tarGzArchive struct {
+	gz     *gzip.Writer
+	tar    *tar.Writer
+	entry  *os.File
+	name   string
+	closed bool
+}
+
+func (a *tarGzArchive) Create(name string) (io.Writer, error) {
+	if err := a.flush(); err != nil {
+		return nil, err
+	}
+	entry, err := os.CreateTemp("", "archiver-entry-*")
+	if err != nil {
+		return nil, err
+	}
+	a.entry, a.name = entry, name
+	return entry, nil
+}
+
+// flush writes the buffered entry, if any, to the tar stream.
+func (a *tarGzArchive) flush() error {
+	if a.entry == nil {
+		return nil
+	}
+	entry := a.entry
+	a.entry = nil
+	defer removeStaged(entry)
+
+	size, err := entry.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := entry.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	header := &tar.Header{Typeflag: tar.TypeReg, Name: a.name, Mode: 0644, Size: size, ModTime: time.Now()}
+	if err := a.tar.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(a.tar, entry)
+	return err
+}
+
+// Close writes the last entry and the end of the stream. Calling it again
+// does nothing, so a build that failed midway can always release the
+// buffered entry.
+func (a *tarGzArchive) Close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	err := a.flush()
+	if closeErr := a.tar.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := a.gz.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Entry is a file stored in an archive.
+type Entry struct {
+	Name string
+	Size int64
+}
+
+// ListArchive returns the files in the archive at path, which is in
+// format, in the order they were written. Directories are left out.
+func ListArchive(path, format string) ([]Entry, error) {
+	if format == FormatTarGz {
+		return listTarGz(path)
+	}
+	reader, err := OpenArchive(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	entries := make([]Entry, 0, len(reader.File))
+	for _, f := range reader.File {
+		if !f.FileInfo().IsDir() {
+			entries = append(entries, Entry{Name: f.Name, Size: int64(f.UncompressedSize64)})
+		}
+	}
+	return entries, nil
+}
+
+func listTarGz(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []Entry{}
+	err = walkTarGz(file, func(header *tar.Header, _ io.Reader) error {
+		if header.Typeflag == tar.TypeReg {
+			entries = append(entries, Entry{Name: header.Name, Size: header.Size})
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// WalkTarGz calls fn with the name and content of every file in the
+// gzipped tar archive at path.
+func WalkTarGz(path string, fn func(name string, content io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return walkTarGz(file, func(header *tar.Header, body io.Reader) error {
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+		return fn(header.Name, body)
+	})
+}
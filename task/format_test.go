@@ -0,0 +1,74 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveWriterRoundTrip(t *testing.T) {
+	entries := []Entry{{Name: "a.txt", Size: 5}, {Name: "docs/b.txt", Size: 0}, {Name: "c.bin", Size: 3 << 10}}
+	for _, format := range []string{FormatZip, FormatTarGz} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "archive"+FormatExtension(format))
+			file, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			archive := newArchiveWriter(&Env{Config: &config.Config{}}, file, format)
+			for _, entry := range entries {
+				w, err := archive.Create(entry.Name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := w.Write([]byte(strings.Repeat("x", int(entry.Size)))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := archive.Close(); err != nil {
+				t.Fatal(err)
+			}
+			file.Close()
+
+			got, err := ListArchive(path, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, entries) {
+				t.Fatalf("ListArchive = %v, want %v", got, entries)
+			}
+		})
+	}
+}
+
+func TestProcessTarGz(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := delayedServer(t, func(string) time.Duration { return 0 })
+	tk := NewTask()
+	tk.ArchiveFormat = FormatTarGz
+	tk.FileURLs = []string{server.URL + "/a.pdf", server.URL + "/b.pdf"}
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, VerifyArchives: true}))
+	if tk.Status != StatusDone || len(tk.Errors) != 0 {
+		t.Fatalf("task %s with errors %+v", tk.Status, tk.Errors)
+	}
+
+	got := map[string]string{}
+	err := WalkTarGz(tk.ID+".tar.gz", func(name string, content io.Reader) error {
+		data, err := io.ReadAll(content)
+		got[name] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a.pdf": "%PDF-1.4 /a.pdf", "b.pdf": "%PDF-1.4 /b.pdf"}
+	if !maps.Equal(got, want) {
+		t.Fatalf("tar.gz entries %q, want %q", got, want)
+	}
+}
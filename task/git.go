@@ -0,0 +1,312 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"2025-08-02/config"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+var errRepoTooLarge = errors.New("repository exceeds max size")
+
+// isGitSource reports whether fileURL names a git repository, written as
+// git://host/repo, git+https://host/repo or git+http://host/repo with an
+// optional #ref suffix.
+func isGitSource(fileURL string) bool {
+	return strings.HasPrefix(fileURL, "git://") ||
+		strings.HasPrefix(fileURL, "git+https://") ||
+		strings.HasPrefix(fileURL, "git+http://")
+}
+
+// splitGitURL returns the clone URL and the requested ref of a git source.
+func splitGitURL(fileURL string) (string, string) {
+	repoURL, ref, _ := strings.Cut(strings.TrimPrefix(fileURL, "git+"), "#")
+	return repoURL, ref
+}
+
+// archiveGitRepo shallow-clones a git source into a temporary directory and
+// adds its files, as far as t's patterns admit them, under a folder named
+// after the repository. The repository may take up no more than what is
+// left of budget.
+func (t *Task) archiveGitRepo(ctx context.Context, env *Env, archive ArchiveWriter, fileURL string, budget *budget) (FileInfo, error) {
+	info := FileInfo{URL: fileURL}
+	if fileErr := checkGitSource(fileURL, env.Config); fileErr != nil {
+		return info, fileErr
+	}
+
+	repoURL, ref := splitGitURL(fileURL)
+	info.Name = strings.TrimSuffix(path.Base(repoURL), ".git")
+
+	dir, err := os.MkdirTemp("", "archiver-git-*")
+	if err != nil {
+		return info, newFileError(fileURL, CodeWriteFailed, "failed to clone repository: %s, error: %v", fileURL, err)
+	}
+	defer os.RemoveAll(dir)
+
+	if env.Config.DownloadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(env.Config.DownloadTimeout)*time.Second)
+		defer cancel()
+	}
+	useGitClient(env.Client)
+
+	maxSize, tooLarge := env.Config.MaxGitRepoSize, CodeTooLarge
+	if left, ok := budget.remaining(); ok && (maxSize <= 0 || left < maxSize) {
+		maxSize, tooLarge = max(left, 1), CodeBudgetExceeded
+	}
+
+	t.logger().Debug("cloning repository", "file_url", fileURL, "repo_url", repoURL, "ref", ref)
+	if err := cloneShallow(ctx, dir, repoURL, ref, maxSize); err != nil {
+		t.logger().Warn("failed to clone repository", "file_url", fileURL, "repo_url", repoURL, "error", err)
+		code := classifyDownloadError(err)
+		if errors.Is(err, errRepoTooLarge) {
+			code = tooLarge
+		}
+		return info, newFileError(fileURL, code, "failed to clone repository: %s, error: %v", fileURL, err)
+	}
+
+	info.Size, err = addTree(archive, dir, info.Name, maxSize, t.entryFilter())
+	if err != nil {
+		t.logger().Warn("failed to archive repository", "file_url", fileURL, "repo_url", repoURL, "error", err)
+		code := CodeWriteFailed
+		if errors.Is(err, errRepoTooLarge) {
+			code = tooLarge
+		}
+		return info, newFileError(fileURL, code, "failed to archive repository: %s, error: %v", fileURL, err)
+	}
+	budget.charge(info.Size)
+	return info, nil
+}
+
+// checkGitSource rejects git sources while EnableGitSources is off, and
+// git:// sources with BlockPrivateIPs, since go-git dials those without a
+// way to check the address.
+func checkGitSource(fileURL string, cfg *config.Config) *FileError {
+	if !cfg.EnableGitSources {
+		return newFileError(fileURL, CodeSourceDisabled, "git sources are disabled: %s", fileURL)
+	}
+	if cfg.BlockPrivateIPs && strings.HasPrefix(fileURL, "git://") {
+		return newFileError(fileURL, CodeBlockedTarget, "git:// sources are not allowed with block_private_ips: %s", fileURL)
+	}
+	return nil
+}
+
+var (
+	gitClientMu sync.Mutex
+	gitClient   *http.Client
+)
+
+// useGitClient makes go-git clone http and https repositories with c, so
+// clones get the proxy, timeout, redirect and address checks of downloads.
+// go-git only has process-wide transports, so the last client wins; the
+// server uses a single one.
+func useGitClient(c *http.Client) {
+	gitClientMu.Lock()
+	defer gitClientMu.Unlock()
+	if gitClient == c {
+		return
+	}
+	gitClient = c
+	transport := githttp.NewClient(&http.Client{
+		Transport:     cloneLimitTransport{c.Transport},
+		CheckRedirect: c.CheckRedirect,
+		Jar:           c.Jar,
+		Timeout:       c.Timeout,
+	})
+	client.InstallProtocol("http", transport)
+	client.InstallProtocol("https", transport)
+}
+
+// cloneLimitKey is the context key of the byte limit of a clone.
+type cloneLimitKey struct{}
+
+// cloneLimit counts the bytes a clone may still receive.
+type cloneLimit struct {
+	remaining atomic.Int64
+}
+
+// cloneLimitTransport cuts off response bodies once the clone of the
+// request has received more bytes than its cloneLimit allows.
+type cloneLimitTransport struct {
+	base http.RoundTripper
+}
+
+func (l cloneLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := l.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if limit, ok := req.Context().Value(cloneLimitKey{}).(*cloneLimit); ok {
+		resp.Body = &limitedCloneBody{ReadCloser: resp.Body, limit: limit}
+	}
+	return resp, nil
+}
+
+type limitedCloneBody struct {
+	io.ReadCloser
+	limit *cloneLimit
+}
+
+func (b *limitedCloneBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.limit.remaining.Add(-int64(n)) < 0 {
+		return n, errRepoTooLarge
+	}
+	return n, err
+}
+
+// cloneShallow clones the single commit at ref, trying it as a branch and
+// then as a tag; an empty ref clones the default branch. With a positive
+// maxSize, the clone stops once more than maxSize bytes were received, and
+// the files are only checked out if they add up to at most maxSize bytes.
+func cloneShallow(ctx context.Context, dir, repoURL, ref string, maxSize int64) error {
+	var limit *cloneLimit
+	if maxSize > 0 {
+		limit = &cloneLimit{}
+		limit.remaining.Store(maxSize)
+		ctx = context.WithValue(ctx, cloneLimitKey{}, limit)
+	}
+	repo, err := cloneRef(ctx, dir, repoURL, ref)
+	if limit != nil && limit.remaining.Load() < 0 {
+		// go-git does not always wrap the error of the body.
+		return errRepoTooLarge
+	}
+	if err != nil {
+		return err
+	}
+	return checkoutHead(repo, maxSize)
+}
+
+func cloneRef(ctx context.Context, dir, repoURL, ref string) (*git.Repository, error) {
+	opts := &git.CloneOptions{URL: repoURL, Depth: 1, SingleBranch: true, NoCheckout: true}
+	if ref == "" {
+		return git.PlainCloneContext(ctx, dir, false, opts)
+	}
+
+	opts.ReferenceName = plumbing.NewBranchReferenceName(ref)
+	repo, err := git.PlainCloneContext(ctx, dir, false, opts)
+	if err == nil || errors.Is(err, errRepoTooLarge) || ctx.Err() != nil {
+		return repo, err
+	}
+	os.RemoveAll(filepath.Join(dir, ".git"))
+
+	opts.ReferenceName = plumbing.NewTagReferenceName(ref)
+	return git.PlainCloneContext(ctx, dir, false, opts)
+}
+
+// checkoutHead writes the files of the cloned commit into the worktree,
+// unless they add up to more than maxSize bytes, when maxSize is positive.
+func checkoutHead(repo *git.Repository, maxSize int64) error {
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	var total int64
+	err = tree.Files().ForEach(func(f *object.File) error {
+		total += f.Size
+		if maxSize > 0 && total > maxSize {
+			return errRepoTooLarge
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return worktree.Reset(&git.ResetOptions{Mode: git.HardReset, Commit: head.Hash()})
+}
+
+// addTree writes the regular files under dir into archive below prefix,
+// skipping .git, anything matched by .gitignore files and entries filter
+// rejects. It stops once more than maxSize bytes would
+// be added, when maxSize is positive.
+func addTree(archive ArchiveWriter, dir, prefix string, maxSize int64, filter entryFilter) (int64, error) {
+	patterns, err := gitignore.ReadPatterns(osfs.New(dir), nil)
+	if err != nil {
+		return 0, err
+	}
+	matcher := gitignore.NewMatcher(patterns)
+
+	var total int64
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		parts := strings.Split(rel, "/")
+
+		if d.IsDir() {
+			if parts[0] == ".git" || matcher.Match(parts, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || matcher.Match(parts, false) {
+			return nil
+		}
+		name := prefix + "/" + rel
+		if !filter.keep(name) {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		total += fi.Size()
+		if maxSize > 0 && total > maxSize {
+			return errRepoTooLarge
+		}
+		return addFile(archive, p, name)
+	})
+	return total, err
+}
+
+func addFile(archive ArchiveWriter, src, name string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, file)
+	return err
+}
@@ -0,0 +1,249 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// serveGitRepo serves a bare repository with one commit holding files
+// over smart HTTP and returns its clone URL.
+func serveGitRepo(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	run := func(dir string, args ...string) {
+		t.Helper()
+		cmd := exec.Command(gitPath, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	if err := os.Mkdir(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	run(work, "init", "-q", "-b", "main")
+	for name, data := range files {
+		path := filepath.Join(work, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run(work, "add", "-f", ".")
+	run(work, "commit", "-q", "-m", "files")
+	run(root, "clone", "-q", "--bare", work, "repo.git")
+
+	server := httptest.NewServer(&cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	})
+	t.Cleanup(server.Close)
+	return server.URL + "/repo.git"
+}
+
+func TestArchiveGitRepo(t *testing.T) {
+	repoURL := serveGitRepo(t, map[string][]byte{
+		"readme.txt":     []byte("hello"),
+		"src/main.go":    []byte("package main"),
+		".gitignore":     []byte("*.log\n"),
+		"debug.log":      []byte("ignored"),
+		"build/data.bin": make([]byte, 1024),
+	})
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		maxSize  int64
+		exclude  []string
+		want     string
+		wantSize int64
+		wantErr  string
+	}{
+		{"clone", true, 0, nil, "repo/.gitignore repo/build/data.bin repo/readme.txt repo/src/main.go", 1047, ""},
+		{"excluded", true, 2048, []string{"*.bin", "repo/.*"}, "repo/readme.txt repo/src/main.go", 17, ""},
+		{"over max size", true, 512, nil, "", 0, errRepoTooLarge.Error()},
+		{"disabled", false, 0, nil, "", 0, "git sources are disabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewEnv(&config.Config{EnableGitSources: tt.enabled, MaxGitRepoSize: tt.maxSize})
+			var buf bytes.Buffer
+			zipWriter := zip.NewWriter(&buf)
+			tk := NewTask()
+			tk.Exclude = tt.exclude
+			info, err := tk.archiveGitRepo(context.Background(), env, zipWriter, "git+"+repoURL, &budget{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("archiveGitRepo = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("archiveGitRepo: %v", err)
+			}
+			if err := zipWriter.Close(); err != nil {
+				t.Fatal(err)
+			}
+			r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, f := range r.File {
+				names = append(names, f.Name)
+			}
+			sort.Strings(names)
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Fatalf("entries %q, want %q", got, tt.want)
+			}
+			if info.Name != "repo" || info.Size != tt.wantSize {
+				t.Fatalf("info %+v", info)
+			}
+		})
+	}
+}
+
+func TestCloneShallowUnknownRef(t *testing.T) {
+	repoURL := serveGitRepo(t, map[string][]byte{"readme.txt": []byte("hello")})
+	if err := cloneShallow(context.Background(), t.TempDir(), repoURL, "missing", 0); err == nil {
+		t.Fatal("clone of an unknown ref succeeded")
+	}
+	if err := cloneShallow(context.Background(), t.TempDir(), repoURL, "main", 0); err != nil {
+		t.Fatalf("clone of main: %v", err)
+	}
+}
+
+func TestSplitGitURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		isGit bool
+		repo  string
+		ref   string
+	}{
+		{"git://example.com/repo.git", true, "git://example.com/repo.git", ""},
+		{"git+https://example.com/repo.git#v1.0", true, "https://example.com/repo.git", "v1.0"},
+		{"git+http://example.com/repo#main", true, "http://example.com/repo", "main"},
+		{"https://example.com/repo.git", false, "", ""},
+	}
+	for _, tt := range tests {
+		if got := isGitSource(tt.url); got != tt.isGit {
+			t.Errorf("isGitSource(%q) = %v, want %v", tt.url, got, tt.isGit)
+		}
+		if !tt.isGit {
+			continue
+		}
+		if repo, ref := splitGitURL(tt.url); repo != tt.repo || ref != tt.ref {
+			t.Errorf("splitGitURL(%q) = %q, %q, want %q, %q", tt.url, repo, ref, tt.repo, tt.ref)
+		}
+	}
+}
+
+func TestCloneShallow(t *testing.T) {
+	big := make([]byte, 64<<10)
+	rand.Read(big)
+	repoURL := serveGitRepo(t, map[string][]byte{"readme.txt": []byte("hello"), "big.bin": big})
+	// Zeros compress well, so the pack stays below the limit that the
+	// checked-out files exceed.
+	zerosURL := serveGitRepo(t, map[string][]byte{"readme.txt": []byte("hello"), "big.bin": make([]byte, 1<<20)})
+
+	tests := []struct {
+		name         string
+		repoURL      string
+		blockPrivate bool
+		maxSize      int64
+		wantErr      error
+	}{
+		{"clone", repoURL, false, 0, nil},
+		{"within max size", repoURL, false, 1 << 20, nil},
+		{"download over max size", repoURL, false, 16 << 10, errRepoTooLarge},
+		{"files over max size", zerosURL, false, 64 << 10, errRepoTooLarge},
+		{"private address", repoURL, true, 0, errBlockedTarget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{BlockPrivateIPs: tt.blockPrivate}
+			useGitClient(newHTTPClient(cfg))
+			dir := t.TempDir()
+			err := cloneShallow(context.Background(), dir, tt.repoURL, "", tt.maxSize)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("cloneShallow: %v", err)
+				}
+				if data, err := os.ReadFile(filepath.Join(dir, "readme.txt")); err != nil || string(data) != "hello" {
+					t.Errorf("readme.txt = %q, %v", data, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("clone succeeded")
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("cloneShallow: %v, want %v", err, tt.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "big.bin")); err == nil {
+				t.Error("files were checked out")
+			}
+		})
+	}
+}
+
+func TestCloneShallowCancelled(t *testing.T) {
+	repoURL := serveGitRepo(t, map[string][]byte{"readme.txt": []byte("hello")})
+	useGitClient(newHTTPClient(&config.Config{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cloneShallow(ctx, t.TempDir(), repoURL, "", 0); err == nil {
+		t.Error("cancelled clone succeeded")
+	}
+}
+
+func TestCheckSourceURLGit(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		enabled      bool
+		blockPrivate bool
+		want         ErrorCode
+	}{
+		{"disabled", "git+https://example.com/repo.git", false, false, CodeSourceDisabled},
+		{"disabled git protocol", "git://example.com/repo.git", false, false, CodeSourceDisabled},
+		{"enabled", "git+https://example.com/repo.git", true, false, ""},
+		{"git protocol", "git://example.com/repo.git", true, false, ""},
+		{"git protocol with private IPs blocked", "git://example.com/repo.git", true, true, CodeBlockedTarget},
+		{"private address", "git+http://127.0.0.1/repo.git", true, true, CodeBlockedTarget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{EnableGitSources: tt.enabled, BlockPrivateIPs: tt.blockPrivate}
+			fileErr := CheckSourceURL(context.Background(), tt.url, cfg)
+			var got ErrorCode
+			if fileErr != nil {
+				got = fileErr.Code
+			}
+			if got != tt.want {
+				t.Errorf("CheckSourceURL(%q) = %v, want code %q", tt.url, fileErr, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProcessHeadersTooLarge(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/huge.pdf" {
+			w.Header().Set("X-Padding", strings.Repeat("x", 8<<10))
+		}
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	tk := NewTask()
+	tk.FileURLs = []string{server.URL + "/a.pdf", server.URL + "/huge.pdf"}
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, MaxResponseHeaderBytes: 4 << 10}))
+	if len(tk.Files) != 1 || len(tk.Errors) != 1 {
+		t.Fatalf("archived %d files with errors %+v, want 1 and 1", len(tk.Files), tk.Errors)
+	}
+	if e := tk.Errors[0]; e.Code != CodeHeadersTooLarge || !strings.HasSuffix(e.URL, "/huge.pdf") {
+		t.Fatalf("error %+v, want HEADERS_TOO_LARGE for huge.pdf", e)
+	}
+}
@@ -0,0 +1,27 @@
+package task
+
+import (
+	"net/url"
+	"strings"
+)
+
+// sourceHost returns the lowercased remote host of fileURL, or "" for
+// uploads and URLs without a host.
+func sourceHost(fileURL string) string {
+	u, err := url.Parse(fileURL)
+	if err != nil || u.Scheme == UploadScheme {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// taskHosts returns the set of distinct remote hosts among fileURLs.
+func taskHosts(fileURLs []string) map[string]bool {
+	hosts := make(map[string]bool)
+	for _, fileURL := range fileURLs {
+		if host := sourceHost(fileURL); host != "" {
+			hosts[host] = true
+		}
+	}
+	return hosts
+}
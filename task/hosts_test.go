@@ -0,0 +1,42 @@
+package task
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTaskHosts(t *testing.T) {
+	tests := []struct {
+		name string
+		urls []string
+		want map[string]bool
+	}{
+		{"none", nil, map[string]bool{}},
+		{"case and ports", []string{"https://CDN.example.com/a.pdf", "http://cdn.example.com:8080/b.pdf"}, map[string]bool{"cdn.example.com": true}},
+		{"distinct", []string{"https://a.example.com/x", "https://b.example.com/y"}, map[string]bool{"a.example.com": true, "b.example.com": true}},
+		{"uploads", []string{UploadURL("0f8fad5b", "a.pdf"), "https://a.example.com/x"}, map[string]bool{"a.example.com": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := taskHosts(tt.urls); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("taskHosts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddFileMaxHosts(t *testing.T) {
+	tk := NewTask()
+	for _, fileURL := range []string{"https://a.example.com/1.pdf", "https://b.example.com/2.pdf", "https://A.example.com/3.pdf", UploadURL("0f8fad5b", "4.pdf")} {
+		if err := tk.AddFile(fileURL, "", nil, 10, 2); err != nil {
+			t.Fatalf("AddFile(%s): %v", fileURL, err)
+		}
+	}
+	if err := tk.AddFile("https://c.example.com/5.pdf", "", nil, 10, 2); !errors.Is(err, ErrTooManyHosts) {
+		t.Fatalf("AddFile on a third host = %v, want ErrTooManyHosts", err)
+	}
+	if err := tk.AddFile("https://c.example.com/5.pdf", "", nil, 10, 0); err != nil {
+		t.Fatalf("AddFile without a host limit: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package task
+
+import (
+	"encoding/json"
+)
+
+// ManifestEntryName is the archive entry describing the archived files
+// and the metadata clients attached to them.
+const ManifestEntryName = "manifest.json"
+
+type manifest struct {
+	TaskID  string          `json:"task_id"`
+	Entries []manifestEntry `json:"entries"`
+}
+
+type manifestEntry struct {
+	Name        string          `json:"name"`
+	URL         string          `json:"url"`
+	Size        int64           `json:"size"`
+	SHA256      string          `json:"sha256,omitempty"`
+	DuplicateOf string          `json:"duplicate_of,omitempty"`
+	Bundle      string          `json:"bundle,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+}
+
+// writeManifest adds ManifestEntryName to archive, listing the files
+// that made it into the archive. It is only written when some file carries
+// metadata.
+func (t *Task) writeManifest(archive ArchiveWriter, files []FileInfo) error {
+	if len(t.Metadata) == 0 {
+		return nil
+	}
+	m := manifest{TaskID: t.ID, Entries: []manifestEntry{}}
+	for _, info := range files {
+		if info.Skipped != "" {
+			continue
+		}
+		m.Entries = append(m.Entries, manifestEntry{
+			Name:        info.Name,
+			URL:         info.URL,
+			Size:        info.Size,
+			SHA256:      info.SHA256,
+			DuplicateOf: info.DuplicateOf,
+			Bundle:      info.Bundle,
+			Metadata:    t.Metadata[info.URL],
+		})
+	}
+
+	entry, err := archive.Create(ManifestEntryName)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m)
+}
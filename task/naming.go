@@ -0,0 +1,135 @@
+package task
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Archive formats a task can be written in. Tasks without a format are
+// zipped.
+const (
+	FormatZip   = "zip"
+	FormatTarGz = "targz"
+)
+
+// formatExtensions are the file extensions of the archive formats.
+var formatExtensions = map[string]string{
+	FormatZip:   ".zip",
+	FormatTarGz: ".tar.gz",
+}
+
+// ValidFormat reports whether format is an archive format or empty.
+func ValidFormat(format string) bool {
+	_, ok := formatExtensions[format]
+	return ok || format == ""
+}
+
+// FormatExtension returns the file extension of archives in format.
+func FormatExtension(format string) string {
+	if ext, ok := formatExtensions[format]; ok {
+		return ext
+	}
+	return formatExtensions[FormatZip]
+}
+
+// FormatOf returns the archive format a file called name is in, judging
+// by its extension, and whether it is an archive at all.
+func FormatOf(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	for format, ext := range formatExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// FormatContentType returns the media type archives in format are served
+// with.
+func FormatContentType(format string) string {
+	if format == FormatTarGz {
+		return "application/gzip"
+	}
+	return "application/zip"
+}
+
+// ArchiveNameData is the data available to the archive name template.
+type ArchiveNameData struct {
+	TaskID string
+	Date   string
+	Time   string
+	Format string
+}
+
+// RenderArchiveName executes tmpl for the given task and returns a
+// filename-safe archive name that ends with the extension of format. The
+// extension of another format, as in "{{.TaskID}}.zip" for a tar.gz task,
+// is replaced. Format is the extension without its leading dot in the
+// template data, e.g. "tar.gz".
+func RenderArchiveName(tmpl *template.Template, taskID, format string, now time.Time) (string, error) {
+	now = now.UTC()
+	ext := FormatExtension(format)
+	data := ArchiveNameData{
+		TaskID: taskID,
+		Date:   now.Format("2006-01-02"),
+		Time:   now.Format("150405"),
+		Format: strings.TrimPrefix(ext, "."),
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	name := SanitizeFileName(buf.String())
+	if name == "" {
+		name = taskID
+	}
+	if other, ok := FormatOf(name); ok && FormatExtension(other) != ext {
+		name = name[:len(name)-len(FormatExtension(other))]
+	}
+	if !strings.HasSuffix(strings.ToLower(name), ext) {
+		name += ext
+	}
+	return name, nil
+}
+
+// SanitizeFileName replaces every character outside [A-Za-z0-9._-] with an
+// underscore and strips leading dots so the result is a plain file name.
+func SanitizeFileName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return strings.TrimLeft(b.String(), ".")
+}
+
+// entryNames tracks the entry names used in one archive and renames
+// repeats the way browsers name repeated downloads: report.pdf,
+// report (1).pdf, report (2).pdf.
+type entryNames map[string]bool
+
+func (n entryNames) unique(name string) string {
+	if !n[name] {
+		n[name] = true
+		return name
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if !n[candidate] {
+			n[candidate] = true
+			return candidate
+		}
+	}
+}
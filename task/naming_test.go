@@ -0,0 +1,153 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestRenderArchiveName(t *testing.T) {
+	const id = "0f8fad5b-d9cb-469f-a165-70867728950e"
+	now := time.Date(2025, 8, 2, 13, 4, 5, 0, time.FixedZone("CEST", 2*60*60))
+	tests := []struct {
+		name     string
+		template string
+		format   string
+		want     string
+	}{
+		{"default", "{{.TaskID}}.zip", FormatZip, id + ".zip"},
+		{"timestamp in UTC", "archive-{{.Date}}-{{.Time}}", FormatZip, "archive-2025-08-02-110405.zip"},
+		{"format", "{{.TaskID}}.{{.Format}}", FormatZip, id + ".zip"},
+		{"tar.gz format", "{{.TaskID}}.{{.Format}}", FormatTarGz, id + ".tar.gz"},
+		{"other extension replaced", "{{.TaskID}}.zip", FormatTarGz, id + ".tar.gz"},
+		{"extension kept", "report.ZIP", FormatZip, "report.ZIP"},
+		{"unsafe characters", "../my report/{{.Date}}", FormatZip, "_my_report_2025-08-02.zip"},
+		{"empty", "  ", FormatZip, id + ".zip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := template.Must(template.New("archive_name").Parse(tt.template))
+			got, err := RenderArchiveName(tmpl, id, tt.format, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("RenderArchiveName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderArchiveNameTemplateError(t *testing.T) {
+	tmpl := template.Must(template.New("archive_name").Parse("{{.Missing}}"))
+	if _, err := RenderArchiveName(tmpl, "id", FormatZip, time.Now()); err == nil {
+		t.Fatal("RenderArchiveName succeeded with an unknown field")
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{" report.pdf ", "report.pdf"},
+		{"..hidden", "hidden"},
+		{"a/b\\c", "a_b_c"},
+		{"отчет.pdf", "_____.pdf"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := SanitizeFileName(tt.name); got != tt.want {
+			t.Errorf("SanitizeFileName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEntryNamesUnique(t *testing.T) {
+	names := entryNames{}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"report.pdf", "report (1).pdf"},
+		{"report.pdf", "report (2).pdf"},
+		{"report (1).pdf", "report (1) (1).pdf"},
+		{"docs/report.pdf", "docs/report.pdf"},
+		{"README", "README"},
+		{"README", "README (1)"},
+	}
+	for _, tt := range tests {
+		if got := names.unique(tt.name); got != tt.want {
+			t.Errorf("unique(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProcessRenamesRepeatedEntries(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	tk := NewTask()
+	tk.FileURLs = []string{server.URL + "/2024/report.pdf", server.URL + "/2025/report.pdf", server.URL + "/report.pdf"}
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}}))
+
+	want := map[string]string{
+		"report.pdf":     "%PDF-1.4 /2024/report.pdf",
+		"report (1).pdf": "%PDF-1.4 /2025/report.pdf",
+		"report (2).pdf": "%PDF-1.4 /report.pdf",
+	}
+	if got := readZipFile(t, tk.ID+".zip"); !maps.Equal(got, want) {
+		t.Fatalf("archive %q, want %q", got, want)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"a.zip", FormatZip, true},
+		{"A.ZIP", FormatZip, true},
+		{"a.tar.gz", FormatTarGz, true},
+		{"archives/a.TAR.GZ", FormatTarGz, true},
+		{"a.gz", "", false},
+		{"a.pdf", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := FormatOf(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("FormatOf(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestFormatExtension(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+		valid  bool
+	}{
+		{"", ".zip", true},
+		{FormatZip, ".zip", true},
+		{FormatTarGz, ".tar.gz", true},
+		{"rar", ".zip", false},
+	}
+	for _, tt := range tests {
+		if got := FormatExtension(tt.format); got != tt.want {
+			t.Errorf("FormatExtension(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+		if valid := ValidFormat(tt.format); valid != tt.valid {
+			t.Errorf("ValidFormat(%q) = %v, want %v", tt.format, valid, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package task
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostPacer spaces out successive requests to the same host across all
+// tasks. A nil *HostPacer never delays.
+type HostPacer struct {
+	defaultDelay time.Duration
+	hostDelays   map[string]time.Duration
+	mutex        sync.Mutex
+	next         map[string]time.Time // earliest start of the next request per host
+}
+
+// NewHostPacer creates a pacer with a default delay and per-host overrides,
+// both in milliseconds.
+func NewHostPacer(defaultDelayMs int, hostDelaysMs map[string]int) *HostPacer {
+	hostDelays := make(map[string]time.Duration, len(hostDelaysMs))
+	for host, ms := range hostDelaysMs {
+		hostDelays[strings.ToLower(host)] = time.Duration(ms) * time.Millisecond
+	}
+	return &HostPacer{
+		defaultDelay: time.Duration(defaultDelayMs) * time.Millisecond,
+		hostDelays:   hostDelays,
+		next:         make(map[string]time.Time),
+	}
+}
+
+func (p *HostPacer) delayFor(host string) time.Duration {
+	if d, ok := p.hostDelays[host]; ok {
+		return d
+	}
+	return p.defaultDelay
+}
+
+// Wait blocks until a request to host may start and reserves the following
+// slot, so concurrent callers are spaced by the host's delay. It returns
+// ctx's error if ctx ends first.
+func (p *HostPacer) Wait(ctx context.Context, host string) error {
+	if p == nil {
+		return nil
+	}
+	host = strings.ToLower(host)
+
+	p.mutex.Lock()
+	now := time.Now()
+	start := now
+	if next := p.next[host]; next.After(start) {
+		start = next
+	}
+	reserved := start.Add(p.delayFor(host))
+	p.next[host] = reserved
+	p.mutex.Unlock()
+
+	if sleepContext(ctx, start.Sub(now)) {
+		return nil
+	}
+	// Hand the slot back unless a later caller has already queued behind
+	// it.
+	p.mutex.Lock()
+	if p.next[host].Equal(reserved) {
+		p.next[host] = start
+	}
+	p.mutex.Unlock()
+	return ctx.Err()
+}
+
+// Defer pushes the next request to host at least d, but no more than
+// maxRetryDelay, into the future.
+func (p *HostPacer) Defer(host string, d time.Duration) {
+	if p == nil || d <= 0 {
+		return
+	}
+	d = min(d, maxRetryDelay)
+	host = strings.ToLower(host)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if until := time.Now().Add(d); until.After(p.next[host]) {
+		p.next[host] = until
+	}
+}
+
+// Observe records throttling hints from a response, honoring Retry-After,
+// up to maxRetryDelay, even when the request itself succeeded.
+func (p *HostPacer) Observe(host string, resp *http.Response) {
+	if p == nil {
+		return
+	}
+	if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		p.Defer(host, d)
+	}
+}
+
+// parseRetryAfter parses a Retry-After value given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		return time.Until(when), true
+	}
+	return 0, false
+}
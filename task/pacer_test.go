@@ -0,0 +1,135 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHostPacerSpacesRequests(t *testing.T) {
+	p := NewHostPacer(30, map[string]int{"Fast.example": 0})
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Wait(context.Background(), "slow.example")
+		}()
+	}
+	wg.Wait()
+	// The third request waits for the two slots before it.
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("three requests took %v, want at least 60ms", elapsed)
+	}
+
+	start = time.Now()
+	for range 3 {
+		p.Wait(context.Background(), "fast.example")
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Millisecond {
+		t.Errorf("host without delay took %v", elapsed)
+	}
+}
+
+func TestHostPacerWaitCancelled(t *testing.T) {
+	p := NewHostPacer(0, nil)
+	p.Defer("example.com", time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := p.Wait(ctx, "example.com")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wait returned after %v", elapsed)
+	}
+}
+
+func TestHostPacerWaitReleasesCancelledSlot(t *testing.T) {
+	p := NewHostPacer(int(time.Hour/time.Millisecond), nil)
+	if err := p.Wait(context.Background(), "example.com"); err != nil {
+		t.Fatalf("first Wait: %v", err)
+	}
+
+	// The second caller gives up; its slot must not push the third one
+	// back by another hour.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.Wait(ctx, "example.com")
+
+	p.mutex.Lock()
+	next := p.next["example.com"]
+	p.mutex.Unlock()
+	if until := time.Until(next); until > time.Hour {
+		t.Errorf("next request in %v, want at most an hour", until)
+	}
+}
+
+func TestHostPacerDeferCapped(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		want       time.Duration
+	}{
+		{"seconds", "30", 30 * time.Second},
+		{"over the cap", "86400", maxRetryDelay},
+		{"far date", time.Now().Add(24 * time.Hour).UTC().Format(http.TimeFormat), maxRetryDelay},
+		{"past date", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewHostPacer(0, nil)
+			p.Observe("Example.com", &http.Response{Header: http.Header{"Retry-After": {tt.retryAfter}}})
+
+			p.mutex.Lock()
+			next := p.next["example.com"]
+			p.mutex.Unlock()
+			got := time.Until(next)
+			if next.IsZero() {
+				got = 0
+			}
+			if got > tt.want || got < tt.want-time.Second {
+				t.Errorf("next request in %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), time.Minute, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value)
+		if ok != tt.wantOK || got > tt.want || got < tt.want-time.Second {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNilHostPacer(t *testing.T) {
+	var p *HostPacer
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Wait(ctx, "example.com"); err != nil {
+		t.Errorf("Wait = %v, want nil", err)
+	}
+	p.Defer("example.com", time.Hour)
+	p.Observe("example.com", &http.Response{Header: http.Header{"Retry-After": {"1"}}})
+}
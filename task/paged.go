@@ -0,0 +1,197 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// pagedPrefix marks paginated API sources, written as paged+https://... or
+// paged+http://..., whose pages are linked by Link rel="next" headers.
+const pagedPrefix = "paged+"
+
+func isPagedSource(fileURL string) bool {
+	return strings.HasPrefix(fileURL, pagedPrefix+"https://") ||
+		strings.HasPrefix(fileURL, pagedPrefix+"http://")
+}
+
+// isMultiEntrySource reports whether fileURL expands to several entries.
+// Such sources are fetched while they are written, never staged or
+// prefetched, and are not subject to the extension allowlist.
+func isMultiEntrySource(fileURL string) bool {
+	return isGitSource(fileURL) || isPagedSource(fileURL)
+}
+
+// archivePaged follows the next links of a paginated source and stores
+// each page as a numbered entry, such as items/page-0001.json, up to
+// MaxPagesPerSource pages. The size limit applies to all pages together.
+// Once the task's budget or the download phase runs out, the pages
+// fetched so far are kept, the page that overdrew the budget is dropped
+// and no more are requested.
+func (t *Task) archivePaged(ctx context.Context, env *Env, archive ArchiveWriter, fileURL string, budget *budget) (FileInfo, error) {
+	info := FileInfo{URL: fileURL}
+	if !env.Config.EnablePagedSources {
+		return info, newFileError(fileURL, CodeSourceDisabled, "paged sources are disabled: %s", fileURL)
+	}
+
+	pageURL := strings.TrimPrefix(fileURL, pagedPrefix)
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return info, newFileError(fileURL, CodeDownloadFailed, "invalid paged source %s: %v", fileURL, err)
+	}
+	info.Name = SanitizeFileName(path.Base(u.Path))
+	if info.Name == "" || info.Name == "." || info.Name == "/" {
+		info.Name = SanitizeFileName(u.Hostname())
+	}
+
+	filter := t.entryFilter()
+	// One reader bounds the pages together; it is pointed at each body in
+	// turn.
+	var limit *sizeLimitReader
+	pages := 0
+	for pageURL != "" && pages < env.Config.MaxPagesPerSource {
+		if reason, ok := budget.exhausted(time.Now()); ok && pages > 0 {
+			t.logger().Info("stopping paged source", "file_url", fileURL, "pages", pages, "reason", reason)
+			return info, nil
+		}
+
+		body, header, err := t.openSource(ctx, pageURL, env, time.Time{})
+		if err != nil {
+			if ctx.Err() != nil && pages > 0 {
+				t.logger().Info("stopping paged source", "file_url", fileURL, "pages", pages, "reason", ctx.Err())
+				return info, nil
+			}
+			t.logger().Warn("failed to download page", "file_url", fileURL, "page_url", pageURL, "error", err)
+			return info, pageError(fileURL, pageURL, err)
+		}
+		pages++
+		name := fmt.Sprintf("%s/page-%04d%s", info.Name, pages, pageExtension(header))
+		var page io.Reader = body
+		if pages == 1 {
+			if max := env.maxFileSize(name); max > 0 {
+				limit = &sizeLimitReader{limit: max, remaining: max}
+			}
+		}
+		if limit != nil {
+			limit.r = body
+			page = limit
+		}
+		page = budget.limit(page)
+		size, err := t.writePage(archive, page, FileInfo{URL: pageURL, Name: name}, filter)
+		body.Close()
+		if err != nil {
+			t.logger().Warn("failed to archive page", "file_url", fileURL, "page_url", pageURL, "error", err)
+			if (ctx.Err() != nil || errors.Is(err, errBudgetExceeded)) && pages > 1 {
+				return info, nil
+			}
+			return info, pageError(fileURL, pageURL, err)
+		}
+		info.Size += size
+
+		pageURL, err = nextPage(header, pageURL)
+		if err != nil {
+			t.logger().Warn("not following next link", "file_url", fileURL, "error", err)
+			break
+		}
+	}
+	if pageURL != "" {
+		t.logger().Info("stopping paged source at the page limit", "file_url", fileURL, "pages", env.Config.MaxPagesPerSource)
+	}
+	return info, nil
+}
+
+// pageError reports the failure of one page as a failure of the paged
+// source fileURL.
+func pageError(fileURL, pageURL string, err error) *FileError {
+	var fe *FileError
+	if errors.As(err, &fe) {
+		return newFileError(fileURL, fe.Code, "%s", fe.Message)
+	}
+	return newFileError(fileURL, classifyDownloadError(err), "failed to download page %s: %v", pageURL, err)
+}
+
+// pageExtension picks the extension of page entries from their
+// Content-Type, defaulting to .json.
+func pageExtension(header http.Header) string {
+	if ext := extensionForType(header.Get("Content-Type"), []string{".json"}); ext != "" {
+		return ext
+	}
+	return ".json"
+}
+
+// writePage stages body and writes it as the entry named in info, unless
+// filter rejects the name, in which case it is downloaded but not stored.
+// The staging keeps a page cut off by the size limit out of the archive.
+func (t *Task) writePage(archive ArchiveWriter, body io.Reader, info FileInfo, filter entryFilter) (int64, error) {
+	info, staged, err := t.stageBody(body, info, false)
+	if err != nil {
+		return 0, err
+	}
+	defer removeStaged(staged)
+	if !filter.keep(info.Name) {
+		return 0, nil
+	}
+
+	entry, err := archive.Create(info.Name)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(entry, staged); err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+// nextPage returns the target of the rel="next" link in header, resolved
+// against pageURL, or "" on the last page. Links leaving the host of the
+// source are refused.
+func nextPage(header http.Header, pageURL string) (string, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			if !ok || !isNextRel(params) {
+				continue
+			}
+			target = strings.TrimSpace(target)
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			next, err := base.Parse(strings.Trim(target, "<>"))
+			if err != nil {
+				return "", err
+			}
+			if !strings.EqualFold(next.Host, base.Host) || (next.Scheme != "http" && next.Scheme != "https") {
+				return "", fmt.Errorf("next page %s is on another host", next)
+			}
+			return next.String(), nil
+		}
+	}
+	return "", nil
+}
+
+// isNextRel reports whether the parameters of a Link value, such as
+// `rel="next"; title="x"`, include the relation type next.
+func isNextRel(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "rel") {
+			continue
+		}
+		for _, rel := range strings.Fields(strings.Trim(strings.TrimSpace(value), `"`)) {
+			if strings.EqualFold(rel, "next") {
+				return true
+			}
+		}
+	}
+	return false
+}
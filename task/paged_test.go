@@ -0,0 +1,115 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// pagedServer serves /items?page=N for N from 1 to pages, linking each
+// page to the next.
+func pagedServer(t *testing.T, pages int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 || page > pages {
+			http.NotFound(w, r)
+			return
+		}
+		if page < pages {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=1>; rel="first"`, page+1))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"page": %d}`, page)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProcessPagedSource(t *testing.T) {
+	server := pagedServer(t, 3)
+	tests := []struct {
+		name     string
+		config   config.Config
+		want     []string
+		wantCode ErrorCode
+	}{
+		{"all pages", config.Config{EnablePagedSources: true, MaxPagesPerSource: 10}, []string{"items/page-0001.json", "items/page-0002.json", "items/page-0003.json"}, ""},
+		{"page limit", config.Config{EnablePagedSources: true, MaxPagesPerSource: 2}, []string{"items/page-0001.json", "items/page-0002.json"}, ""},
+		{"disabled", config.Config{MaxPagesPerSource: 10}, nil, CodeSourceDisabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			tk := NewTask()
+			tk.FileURLs = []string{"paged+" + server.URL + "/items?page=1"}
+			cfg := tt.config
+			tk.Process(NewEnv(&cfg))
+			if tt.wantCode != "" {
+				if len(tk.Errors) != 1 || tk.Errors[0].Code != tt.wantCode {
+					t.Fatalf("errors %+v, want %s", tk.Errors, tt.wantCode)
+				}
+				return
+			}
+			if len(tk.Errors) != 0 {
+				t.Fatalf("errors %+v", tk.Errors)
+			}
+			entries := readZipFile(t, tk.ID+".zip")
+			var names []string
+			for name := range entries {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			if !slices.Equal(names, tt.want) {
+				t.Fatalf("entries %q, want %q", names, tt.want)
+			}
+			if entries["items/page-0002.json"] != `{"page": 2}` {
+				t.Fatalf("second page holds %q", entries["items/page-0002.json"])
+			}
+		})
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	const pageURL = "https://api.example.com/v1/items?page=1"
+	tests := []struct {
+		name    string
+		links   []string
+		want    string
+		wantErr bool
+	}{
+		{"last page", nil, "", false},
+		{"relative", []string{`</v1/items?page=2>; rel="next"`}, "https://api.example.com/v1/items?page=2", false},
+		{"among others", []string{`<https://api.example.com/v1/items?page=9>; rel="last", <?page=2>; rel="next"`}, "https://api.example.com/v1/items?page=2", false},
+		{"several rels", []string{`<?page=1>; rel="prev"`, `<?page=2>; title="more"; rel="next last"`}, "https://api.example.com/v1/items?page=2", false},
+		{"unquoted rel", []string{`<?page=2>; rel=next`}, "https://api.example.com/v1/items?page=2", false},
+		{"other host", []string{`<https://evil.example.com/items?page=2>; rel="next"`}, "", true},
+		{"other scheme", []string{`<ftp://api.example.com/items>; rel="next"`}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"Link": tt.links}
+			got, err := nextPage(header, pageURL)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("nextPage = %q, %v, want %q (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsPagedSource(t *testing.T) {
+	for url, want := range map[string]bool{
+		"paged+https://api.example.com/items": true,
+		"paged+http://api.example.com/items":  true,
+		"paged+ftp://api.example.com/items":   false,
+		"https://api.example.com/items":       false,
+	} {
+		if got := isPagedSource(url); got != want {
+			t.Errorf("isPagedSource(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// taskFields has the fields of Task without its methods, so a record can
+// embed them.
+type taskFields Task
+
+// record is the persisted form of a task: its JSON fields plus the state
+// a status response leaves out.
+type record struct {
+	*taskFields
+	ArchiveName string `json:"archive_name,omitempty"`
+	ClientID    string `json:"client_id,omitempty"`
+	Signature   []byte `json:"signature,omitempty"`
+}
+
+// MarshalRecord encodes t, including its archive name, client and
+// signature, for a task store.
+func (t *Task) MarshalRecord() ([]byte, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return json.Marshal(record{
+		taskFields:  (*taskFields)(t),
+		ArchiveName: t.archiveName,
+		ClientID:    t.ClientID,
+		Signature:   t.signature,
+	})
+}
+
+// UnmarshalRecord rebuilds a task encoded by MarshalRecord.
+func UnmarshalRecord(data []byte) (*Task, error) {
+	t := &Task{}
+	r := record{taskFields: (*taskFields)(t)}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	if t.ID == "" {
+		return nil, errors.New("task record has no ID")
+	}
+	if t.FileURLs == nil {
+		t.FileURLs = []string{}
+	}
+	t.archiveName = r.ArchiveName
+	t.ClientID = r.ClientID
+	t.signature = r.Signature
+	return t, nil
+}
+
+// MarkProcessing moves t to StatusProcessing. Process does so itself;
+// callers call it first when the status must be recorded before the
+// processing goroutine runs. It fails if t can no longer be processed,
+// e.g. after Cancel.
+func (t *Task) MarkProcessing() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.Status == StatusProcessing {
+		return nil
+	}
+	return t.setStatus(StatusProcessing)
+}
+
+// Cancel moves a task that has not started processing to StatusCanceled
+// with code CANCELED, releasing its event subscribers. Tasks in any other status are left
+// unchanged and ErrInvalidTransition is returned.
+func (t *Task) Cancel(reason string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.Status != StatusCreated {
+		return fmt.Errorf("%w: task %s is %s, cannot be canceled", ErrInvalidTransition, t.ID, t.Status)
+	}
+	t.ErrorDetails = reason
+	t.Errors = append(t.Errors, FileError{Code: CodeCanceled, Message: reason})
+	t.failUnfinishedFilesLocked(reason)
+	return t.setStatus(StatusCanceled)
+}
+
+// Interrupt cancels a task that is still processing with code INTERRUPTED,
+// e.g. when the server stops before it finished. The processing run can
+// no longer complete the task afterwards. Tasks in any other status are
+// left unchanged and ErrInvalidTransition is returned.
+func (t *Task) Interrupt(reason string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.Status != StatusProcessing {
+		return fmt.Errorf("%w: task %s is %s, cannot be interrupted", ErrInvalidTransition, t.ID, t.Status)
+	}
+	t.ErrorDetails = reason
+	t.Errors = append(t.Errors, FileError{Code: CodeInterrupted, Message: reason})
+	t.failUnfinishedFilesLocked(reason)
+	return t.setStatus(StatusCanceled)
+}
+
+// RemovePartialArchives deletes the temporary files of archive builds of t
+// that have not been completed, and the downloads kept to resume them. A
+// build whose file is removed fails instead of producing the archive.
+func (t *Task) RemovePartialArchives() {
+	t.removeParts()
+	t.removeTempArchives()
+}
+
+// removeTempArchives deletes the temporary files of unfinished archive
+// builds of t.
+func (t *Task) removeTempArchives() {
+	zipFileName := t.ArchiveName()
+	entries, err := os.ReadDir(filepath.Dir(zipFileName))
+	if err != nil {
+		return
+	}
+	prefix := filepath.Base(zipFileName) + "."
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".tmp") {
+			path := filepath.Join(filepath.Dir(zipFileName), name)
+			t.logger().Info("removing partial archive", "path", path)
+			os.Remove(path)
+		}
+	}
+}
+
+// RecoverInterrupted settles a loaded task that was still processing when
+// the server stopped. If its archive was completed the task is done;
+// otherwise it is canceled with INTERRUPTED. It reports whether t was changed.
+func (t *Task) RecoverInterrupted() bool {
+	if t.State() != StatusProcessing {
+		return false
+	}
+	t.removeParts()
+	archiveName := t.ArchiveName()
+	if _, err := os.Stat(archiveName); err == nil {
+		checksum, err := fileChecksum(archiveName)
+		if err != nil {
+			t.logger().Error("failed to compute archive checksum", "error", err)
+		}
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		t.ResultChecksum = checksum
+		// Which files made it into the archive was not recorded.
+		t.FileStatuses = nil
+		return t.setStatus(StatusDone) == nil
+	}
+
+	const reason = "interrupted by restart"
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.ErrorDetails = reason
+	t.Errors = append(t.Errors, FileError{Code: CodeInterrupted, Message: reason})
+	t.failUnfinishedFilesLocked(reason)
+	return t.setStatus(StatusCanceled) == nil
+}
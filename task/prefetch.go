@@ -0,0 +1,144 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// prefetch is a remote file downloaded ahead of processing. done is closed
+// once path (or err) is set.
+type prefetch struct {
+	done   chan struct{}
+	path   string
+	header http.Header
+	err    error
+}
+
+// Prefetch starts downloading fileURL in the background so Process can
+// archive it from disk. It does nothing unless PrefetchOnAdd is set, and
+// skips uploads, git and paged sources and URLs that are already being prefetched.
+func (t *Task) Prefetch(env *Env, fileURL string) {
+	if env.prefetchSlots == nil || isMultiEntrySource(fileURL) {
+		return
+	}
+	if u, err := url.Parse(fileURL); err != nil || u.Scheme == UploadScheme {
+		return
+	}
+	if checkExpiry(fileURL, time.Now()) != nil {
+		return
+	}
+
+	t.mutex.Lock()
+	// Once processing has started the prefetch would neither be used nor
+	// cleaned up.
+	if _, ok := t.prefetches[fileURL]; ok || t.Status != StatusCreated {
+		t.mutex.Unlock()
+		return
+	}
+	if t.prefetches == nil {
+		t.prefetches = make(map[string]*prefetch)
+		t.prefetchCtx, t.cancelPrefetch = context.WithCancel(context.Background())
+	}
+	ctx := t.prefetchCtx
+	p := &prefetch{done: make(chan struct{})}
+	t.prefetches[fileURL] = p
+	t.mutex.Unlock()
+
+	go func() {
+		defer close(p.done)
+		select {
+		case env.prefetchSlots <- struct{}{}:
+			defer func() { <-env.prefetchSlots }()
+		case <-ctx.Done():
+			p.err = ctx.Err()
+			return
+		}
+		p.path, p.header, p.err = t.stageSource(ctx, env, fileURL, t.modifiedSince())
+		if p.err != nil {
+			t.logger().Warn("failed to prefetch file", "file_url", fileURL, "error", p.err)
+		}
+	}()
+}
+
+// stageSource downloads fileURL into a temporary file and returns its path.
+// The download stops once it exceeds the size limit of its entry, whether
+// or not the server announced a Content-Length.
+func (t *Task) stageSource(ctx context.Context, env *Env, fileURL string, since time.Time) (string, http.Header, error) {
+	body, header, err := t.openSource(ctx, fileURL, env, since)
+	if err != nil {
+		return "", nil, err
+	}
+	defer body.Close()
+
+	staged, err := os.CreateTemp("", "prefetch-*")
+	if err != nil {
+		return "", nil, err
+	}
+	limit := env.maxFileSize(entryName(env, fileURL, header))
+	_, err = io.Copy(staged, limitSize(body, limit))
+	if closeErr := staged.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(staged.Name())
+		return "", nil, err
+	}
+	return staged.Name(), header, nil
+}
+
+// openPrefetched opens fileURL from its prefetched copy, waiting for the
+// prefetch to finish if needed. Files that were not prefetched, or whose
+// prefetch was canceled, are downloaded as usual.
+func (t *Task) openPrefetched(ctx context.Context, env *Env, fileURL string) (io.ReadCloser, http.Header, error) {
+	t.mutex.Lock()
+	p, ok := t.prefetches[fileURL]
+	t.mutex.Unlock()
+	if !ok {
+		return t.openSource(ctx, fileURL, env, t.modifiedSince())
+	}
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+	if errors.Is(p.err, context.Canceled) {
+		return t.openSource(ctx, fileURL, env, t.modifiedSince())
+	}
+	if p.err != nil {
+		return nil, nil, p.err
+	}
+	file, err := os.Open(p.path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, p.header, nil
+}
+
+// DiscardPrefetches cancels pending prefetches of t and removes the files
+// they staged. It is called once processing is over or the task is dropped.
+func (t *Task) DiscardPrefetches() {
+	t.mutex.Lock()
+	prefetches := t.prefetches
+	cancel := t.cancelPrefetch
+	t.prefetches = nil
+	t.cancelPrefetch = nil
+	t.prefetchCtx = nil
+	t.mutex.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	for _, p := range prefetches {
+		<-p.done
+		if p.path != "" {
+			os.Remove(p.path)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStageSourceSizeLimit(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Flushing before the body is complete forces a chunked response
+		// without a Content-Length.
+		w.Write([]byte(strings.Repeat("x", 64)))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(strings.Repeat("x", 64)))
+	}))
+	defer server.Close()
+	env := NewEnv(&config.Config{MaxFileSizeByExtension: map[string]int64{".pdf": 100}})
+	tk := NewTask()
+
+	if _, _, err := tk.stageSource(context.Background(), env, server.URL+"/a.pdf", time.Time{}); !errors.Is(err, errFileTooLarge) {
+		t.Fatalf("stageSource = %v, want errFileTooLarge", err)
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Fatalf("staged file left behind: %v", entries)
+	}
+
+	// Files of other types are not limited.
+	path, _, err := tk.stageSource(context.Background(), env, server.URL+"/a.txt", time.Time{})
+	if err != nil {
+		t.Fatalf("stageSource of an unlimited file: %v", err)
+	}
+	defer os.Remove(path)
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) != 128 {
+		t.Fatalf("staged %d bytes, %v, want 128", len(data), err)
+	}
+}
@@ -0,0 +1,81 @@
+package task
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// progress aggregates how far processing of a task has come. Parallel
+// downloads update it concurrently, so every counter is atomic and readers
+// never take the task mutex.
+type progress struct {
+	filesDownloaded atomic.Int64
+	filesArchived   atomic.Int64
+	filesFailed     atomic.Int64
+	bytesDownloaded atomic.Int64
+}
+
+// Progress is a point-in-time view of a task's progress.
+type Progress struct {
+	FilesTotal      int   `json:"files_total"`
+	FilesDownloaded int64 `json:"files_downloaded"`
+	FilesArchived   int64 `json:"files_archived"`
+	FilesFailed     int64 `json:"files_failed"`
+	BytesDownloaded int64 `json:"bytes_downloaded"`
+}
+
+// Progress returns the current counters of t. FilesDownloaded and
+// BytesDownloaded grow as downloads run, in any order in parallel mode,
+// while FilesArchived and FilesFailed count files whose outcome is final.
+func (t *Task) Progress() Progress {
+	t.mutex.Lock()
+	total := len(t.FileURLs)
+	t.mutex.Unlock()
+	return Progress{
+		FilesTotal:      total,
+		FilesDownloaded: t.progress.filesDownloaded.Load(),
+		FilesArchived:   t.progress.filesArchived.Load(),
+		FilesFailed:     t.progress.filesFailed.Load(),
+		BytesDownloaded: t.progress.bytesDownloaded.Load(),
+	}
+}
+
+// reset clears every counter before the archive is built again.
+func (p *progress) reset() {
+	p.filesDownloaded.Store(0)
+	p.filesArchived.Store(0)
+	p.filesFailed.Store(0)
+	p.bytesDownloaded.Store(0)
+}
+
+// copyFrom sets p to the counters of other.
+func (p *progress) copyFrom(other *progress) {
+	p.filesDownloaded.Store(other.filesDownloaded.Load())
+	p.filesArchived.Store(other.filesArchived.Load())
+	p.filesFailed.Store(other.filesFailed.Load())
+	p.bytesDownloaded.Store(other.bytesDownloaded.Load())
+}
+
+// countDownload returns body, adding every byte read to the task's
+// downloaded bytes and counting the file as downloaded once it is read to
+// the end.
+func (p *progress) countDownload(body io.ReadCloser) io.ReadCloser {
+	return &progressReader{ReadCloser: body, progress: p}
+}
+
+type progressReader struct {
+	io.ReadCloser
+	progress *progress
+	finished bool
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.progress.bytesDownloaded.Add(int64(n))
+	if errors.Is(err, io.EOF) && !r.finished {
+		r.finished = true
+		r.progress.filesDownloaded.Add(1)
+	}
+	return n, err
+}
@@ -0,0 +1,40 @@
+package task
+
+import "2025-08-02/taskpb"
+
+// ToProto returns the Protocol Buffers form of the task status.
+func (t *Task) ToProto() *taskpb.Task {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	msg := &taskpb.Task{
+		Id:             t.ID,
+		Status:         string(t.Status),
+		FileUrls:       append([]string(nil), t.FileURLs...),
+		GroupId:        t.GroupID,
+		ResultUrl:      t.ResultURL,
+		ResultChecksum: t.ResultChecksum,
+		ErrorDetails:   t.ErrorDetails,
+		SigningKeyId:   t.SigningKeyID,
+		CoalescedWith:  t.CoalescedWith,
+	}
+	for _, f := range t.Files {
+		msg.Files = append(msg.Files, &taskpb.FileInfo{
+			Url:         f.URL,
+			Name:        f.Name,
+			Size:        f.Size,
+			Sha256:      f.SHA256,
+			DuplicateOf: f.DuplicateOf,
+			Skipped:     string(f.Skipped),
+			Bundle:      f.Bundle,
+		})
+	}
+	for _, e := range t.Errors {
+		msg.Errors = append(msg.Errors, &taskpb.FileError{
+			Url:     e.URL,
+			Code:    string(e.Code),
+			Message: e.Message,
+		})
+	}
+	return msg
+}
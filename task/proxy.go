@@ -0,0 +1,38 @@
+package task
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// proxyFunc routes requests through proxyURL unless their host matches
+// an entry of noProxy. Entries follow NO_PROXY conventions: "example.com"
+// and ".example.com" cover the domain and its subdomains, "*" every host.
+func proxyFunc(proxyURL *url.URL, noProxy []string) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}
+}
+
+func bypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "*" {
+			return true
+		}
+		// Empty entries, e.g. from a trailing comma, match no host.
+		domain := strings.TrimPrefix(strings.TrimPrefix(entry, "*"), ".")
+		if domain == "" {
+			continue
+		}
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestBypassProxy(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		noProxy []string
+		want    bool
+	}{
+		{"no entries", "example.com", nil, false},
+		{"empty entry", "example.com", []string{""}, false},
+		{"blank entry", "example.com", []string{"  ", "\t"}, false},
+		{"blank before match", "example.com", []string{" ", "example.com"}, true},
+		{"star", "example.com", []string{"*"}, true},
+		{"padded star", "example.com", []string{" * "}, true},
+		{"domain", "example.com", []string{"example.com"}, true},
+		{"subdomain", "files.example.com", []string{"example.com"}, true},
+		{"dot prefix", "files.example.com", []string{".example.com"}, true},
+		{"star dot prefix", "files.example.com", []string{"*.example.com"}, true},
+		{"case", "Files.Example.COM", []string{" EXAMPLE.com "}, true},
+		{"suffix only", "badexample.com", []string{"example.com"}, false},
+		{"lone dot", "example.com", []string{"."}, false},
+		{"other domain", "example.org", []string{"example.com"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bypassProxy(tt.host, tt.noProxy); got != tt.want {
+				t.Fatalf("bypassProxy(%q, %q) = %v, want %v", tt.host, tt.noProxy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessThroughProxy(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// The proxy answers for origin.invalid, which does not resolve, so only
+	// a download that went through it can succeed.
+	var mutex sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		proxied = append(proxied, r.URL.String())
+		mutex.Unlock()
+		if r.URL.Host != "origin.invalid" {
+			http.Error(w, "unexpected host", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("%PDF-1.4 via proxy"))
+	}))
+	defer proxy.Close()
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("%PDF-1.4 direct"))
+	}))
+	defer direct.Close()
+	directURL, _ := url.Parse(direct.URL)
+
+	tk := NewTask()
+	tk.FileURLs = []string{"http://origin.invalid/a.pdf", direct.URL + "/b.pdf"}
+	tk.Process(NewEnv(&config.Config{
+		AllowedExtensions: []string{".pdf"},
+		DownloadProxy:     proxy.URL,
+		NoProxy:           []string{directURL.Hostname()},
+	}))
+	if tk.Status != StatusDone || len(tk.Errors) != 0 {
+		t.Fatalf("task %s with errors %+v", tk.Status, tk.Errors)
+	}
+	if len(proxied) != 1 || proxied[0] != "http://origin.invalid/a.pdf" {
+		t.Fatalf("proxy saw %q, want only the origin.invalid download", proxied)
+	}
+	entries := readZipFile(t, tk.ID+".zip")
+	if entries["a.pdf"] != "%PDF-1.4 via proxy" || entries["b.pdf"] != "%PDF-1.4 direct" {
+		t.Fatalf("entries %q", entries)
+	}
+}
+
+// readZipFile returns the contents of the entries of the zip archive at
+// path by name.
+func readZipFile(t *testing.T, path string) map[string]string {
+	t.Helper()
+	reader, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	entries := make(map[string]string)
+	for _, f := range reader.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[f.Name] = string(data)
+	}
+	return entries
+}
@@ -0,0 +1,33 @@
+package task
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// Restore rebuilds a finished task from an archive found on disk at
+// archiveName. The file must be named "<task id>.zip" or
+// "<task id>.tar.gz"; other names yield an error.
+func Restore(archiveName string) (*Task, error) {
+	format, ok := FormatOf(archiveName)
+	if !ok {
+		return nil, fmt.Errorf("not an archive: %s", archiveName)
+	}
+	base := filepath.Base(archiveName)
+	id := base[:len(base)-len(FormatExtension(format))]
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("archive name is not a task ID: %s", archiveName)
+	}
+	t := &Task{
+		ID:       id,
+		Status:   StatusDone,
+		FileURLs: []string{},
+	}
+	if format != FormatZip {
+		t.ArchiveFormat = format
+	}
+	t.SetArchiveName(archiveName)
+	return t, nil
+}
@@ -0,0 +1,19 @@
+package task
+
+import "testing"
+
+func TestRestore(t *testing.T) {
+	const id = "0f8fad5b-d9cb-469f-a165-70867728950e"
+	tk, err := Restore(id + ".zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.ID != id || tk.State() != StatusDone || tk.ArchiveName() != id+".zip" || tk.ResultURL != "/archives/"+id+".zip" {
+		t.Fatalf("restored task %+v", tk)
+	}
+	for _, name := range []string{id + ".tar", "report.zip", id + ".zip.tmp"} {
+		if _, err := Restore(name); err == nil {
+			t.Errorf("Restore(%q) succeeded", name)
+		}
+	}
+}
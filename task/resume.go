@@ -0,0 +1,155 @@
+package task
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// partsDir is the directory that keeps the finished downloads of t with
+// ResumeInterruptedTasks, next to its archive.
+func (t *Task) partsDir() string {
+	return t.ArchiveName() + ".parts"
+}
+
+// partPath returns where the download of fileURL is kept in dir. Its
+// response header is kept next to it with a .header suffix.
+func partPath(dir, fileURL string) string {
+	sum := sha256.Sum256([]byte(fileURL))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8]))
+}
+
+// keepPart copies body into the parts directory of t as it is read. The
+// copy is only kept once body has been read to the end, so a part is
+// always a complete download. Bodies of uploads and of files that are
+// already kept are returned unchanged.
+func (t *Task) keepPart(env *Env, fileURL string, body io.ReadCloser, header http.Header) io.ReadCloser {
+	if !env.Config.ResumeInterruptedTasks {
+		return body
+	}
+	if u, err := url.Parse(fileURL); err != nil || u.Scheme == UploadScheme {
+		return body
+	}
+	dir := t.partsDir()
+	path := partPath(dir, fileURL)
+	if _, err := os.Stat(path); err == nil {
+		return body
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.logger().Warn("failed to keep download", "file_url", fileURL, "error", err)
+		return body
+	}
+	file, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		t.logger().Warn("failed to keep download", "file_url", fileURL, "error", err)
+		return body
+	}
+	return &partBody{ReadCloser: body, file: file, path: path, header: header, logger: t.logger()}
+}
+
+// partBody tees a download into a part file.
+type partBody struct {
+	io.ReadCloser
+	file   *os.File
+	path   string
+	header http.Header
+	logger *slog.Logger
+}
+
+func (b *partBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.file != nil && n > 0 {
+		if _, werr := b.file.Write(p[:n]); werr != nil {
+			b.discard()
+		}
+	}
+	if err == io.EOF && b.file != nil {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *partBody) Close() error {
+	if b.file != nil {
+		b.discard()
+	}
+	return b.ReadCloser.Close()
+}
+
+// finish moves the complete copy to its part path. The header is written
+// first, so a part that exists always has one.
+func (b *partBody) finish() {
+	file := b.file
+	b.file = nil
+	header, err := json.Marshal(b.header)
+	if err == nil {
+		err = os.WriteFile(b.path+".header", header, 0644)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), b.path)
+	}
+	if err != nil {
+		b.logger.Warn("failed to keep download", "path", b.path, "error", err)
+		os.Remove(file.Name())
+	}
+}
+
+func (b *partBody) discard() {
+	b.file.Close()
+	os.Remove(b.file.Name())
+	b.file = nil
+}
+
+// PrepareResume readies a loaded task that was still processing when the
+// server stopped to be processed again. The downloads kept in its parts
+// directory are used as prefetched files, so only the files that had not
+// finished are fetched again; without any, every file is. It reports false,
+// leaving t unchanged, if t was not processing or its archive was
+// completed, which RecoverInterrupted settles instead.
+func (t *Task) PrepareResume() bool {
+	if t.State() != StatusProcessing {
+		return false
+	}
+	if _, err := os.Stat(t.ArchiveName()); err == nil {
+		return false
+	}
+
+	// The build that was interrupted left its temporary file behind.
+	t.removeTempArchives()
+
+	dir := t.partsDir()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.prefetches = make(map[string]*prefetch)
+	t.prefetchCtx, t.cancelPrefetch = context.WithCancel(context.Background())
+	for _, fileURL := range t.FileURLs {
+		path := partPath(dir, fileURL)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		var header http.Header
+		if data, err := os.ReadFile(path + ".header"); err != nil || json.Unmarshal(data, &header) != nil {
+			continue
+		}
+		p := &prefetch{done: make(chan struct{}), path: path, header: header}
+		close(p.done)
+		t.prefetches[fileURL] = p
+	}
+	t.logger().Info("resuming task", "downloaded", len(t.prefetches), "files", len(t.FileURLs))
+	return true
+}
+
+// removeParts deletes the parts directory of t once it is no longer needed.
+func (t *Task) removeParts() {
+	os.RemoveAll(t.partsDir())
+}
@@ -0,0 +1,25 @@
+package task
+
+import "time"
+
+// MarkArchiveExpired records that the archive of t has been deleted while
+// the task itself is kept.
+func (t *Task) MarkArchiveExpired() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.ArchiveExpired = true
+}
+
+// IsArchiveExpired reports whether the archive of t has been deleted.
+func (t *Task) IsArchiveExpired() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.ArchiveExpired
+}
+
+// FinishedBefore reports whether t was done or failed before cutoff.
+func (t *Task) FinishedBefore(cutoff time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.FinishedAt != nil && t.FinishedAt.Before(cutoff)
+}
@@ -0,0 +1,73 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxRetryDelay caps the wait before a download is tried again, so a far
+// away Retry-After cannot hold a task for hours.
+const maxRetryDelay = time.Minute
+
+// retryableStatus reports whether a response with status code may succeed
+// when the request is repeated.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryableError reports whether err, returned by a request, is a network
+// failure that may not happen again, unlike a canceled download or a
+// redirect or response refused by the client.
+func retryableError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, errBlockedTarget) || errors.Is(err, errDomainNotAllowed) {
+		return false
+	}
+	// Every error of http.Client is a *url.Error, which is a net.Error
+	// itself, so the cause is what tells network failures apart.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryDelay returns how long to wait before retry number attempt, counted
+// from 1: the response's Retry-After if it has one, and RetryBackoffMs
+// doubled for every earlier retry otherwise.
+func retryDelay(env *Env, attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			// A date in the past allows the retry right away.
+			return min(max(d, 0), maxRetryDelay)
+		}
+	}
+	delay := time.Duration(env.Config.RetryBackoffMs) * time.Millisecond << (attempt - 1)
+	if delay <= 0 || delay > maxRetryDelay {
+		// A shift past the width of Duration wraps around.
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// sleepContext waits for d, or until ctx ends, and reports whether the full
+// wait passed.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
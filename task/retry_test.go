@@ -0,0 +1,123 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProcessRetriesDownloads(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int32
+		retries   int
+		wantCalls int32
+		wantErr   bool
+	}{
+		{"no retries", 1, 0, 1, true},
+		{"recovered", 2, 2, 3, false},
+		{"exhausted", 3, 2, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) <= tt.failures {
+					http.Error(w, "try later", http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte("%PDF-1.4"))
+			}))
+			defer server.Close()
+
+			tk := NewTask()
+			tk.FileURLs = []string{server.URL + "/a.pdf"}
+			tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, DownloadRetries: tt.retries, RetryBackoffMs: 1}))
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Fatalf("%d requests, want %d", got, tt.wantCalls)
+			}
+			if failed := len(tk.Errors) != 0; failed != tt.wantErr {
+				t.Fatalf("task %s with errors %+v, want failed %v", tk.Status, tk.Errors, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	env := &Env{Config: &config.Config{RetryBackoffMs: 100}}
+	tests := []struct {
+		name       string
+		attempt    int
+		retryAfter string
+		want       time.Duration
+	}{
+		{"first", 1, "", 100 * time.Millisecond},
+		{"third", 3, "", 400 * time.Millisecond},
+		{"capped", 20, "", maxRetryDelay},
+		{"shift overflow", 100, "", maxRetryDelay},
+		{"retry after", 1, "2", 2 * time.Second},
+		{"retry after capped", 1, "86400", maxRetryDelay},
+		{"retry after in the past", 1, "Mon, 02 Jan 2006 15:04:05 GMT", 0},
+		{"malformed retry after", 2, "soon", 200 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			if tt.retryAfter != "" {
+				resp = &http.Response{Header: http.Header{"Retry-After": {tt.retryAfter}}}
+			}
+			if got := retryDelay(env, tt.attempt, resp); got != tt.want {
+				t.Fatalf("retryDelay = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryableError(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"connection refused", context.Background(), &url.Error{Op: "Get", Err: &net.OpError{Op: "dial", Err: errors.New("refused")}}, true},
+		{"unexpected EOF", context.Background(), &url.Error{Op: "Get", Err: io.ErrUnexpectedEOF}, true},
+		{"canceled", canceled, &url.Error{Op: "Get", Err: context.Canceled}, false},
+		{"blocked redirect", context.Background(), &url.Error{Op: "Get", Err: fmt.Errorf("redirect: %w", errBlockedTarget)}, false},
+		{"other", context.Background(), errors.New("bad response"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryableError(tt.ctx, tt.err); got != tt.want {
+				t.Fatalf("retryableError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryableStatus(t *testing.T) {
+	for code, want := range map[int]bool{
+		http.StatusTooManyRequests:     true,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+		http.StatusGatewayTimeout:      true,
+		http.StatusNotFound:            false,
+		http.StatusInternalServerError: false,
+	} {
+		if got := retryableStatus(code); got != want {
+			t.Errorf("retryableStatus(%d) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package task
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// signingOptions selects Ed25519ph (RFC 8032), which signs a SHA-512
+// prehash so archives can be streamed instead of loaded into memory.
+var signingOptions = &ed25519.Options{Hash: crypto.SHA512}
+
+// SigningKeyID returns a short fingerprint identifying a public key.
+func SigningKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// signFile returns the Ed25519ph signature of the file at path.
+func signFile(key ed25519.PrivateKey, path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := sha512.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return key.Sign(nil, hash.Sum(nil), signingOptions)
+}
+
+// Signature returns the archive signature, or nil if it was not signed.
+func (t *Task) Signature() []byte {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.signature
+}
@@ -0,0 +1,59 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var errFileTooLarge = errors.New("file exceeds max size")
+
+// maxFileSize returns the size limit for an entry called name: the limit
+// configured for its extension if any, MaxFileSize otherwise. Zero means
+// unlimited.
+func (env *Env) maxFileSize(name string) int64 {
+	ext := strings.ToLower(path.Ext(name))
+	if limit, ok := env.Config.MaxFileSizeByExtension[ext]; ok {
+		return limit
+	}
+	return env.Config.MaxFileSize
+}
+
+// limitSize returns r, failing with errFileTooLarge once more than limit
+// bytes have been read. A non-positive limit leaves r unbounded.
+func limitSize(r io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return r
+	}
+	return &sizeLimitReader{r: r, limit: limit, remaining: limit}
+}
+
+type sizeLimitReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, l.err()
+	}
+	// Read one byte past the limit to tell an exact fit from an overflow.
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) <= l.remaining {
+		l.remaining -= int64(n)
+		return n, err
+	}
+	n = int(l.remaining)
+	l.remaining = -1
+	return n, l.err()
+}
+
+func (l *sizeLimitReader) err() error {
+	return fmt.Errorf("%w of %d bytes", errFileTooLarge, l.limit)
+}
@@ -0,0 +1,60 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMaxFileSize(t *testing.T) {
+	env := &Env{Config: &config.Config{
+		MaxFileSize:            100,
+		MaxFileSizeByExtension: map[string]int64{".mp4": 1000, ".txt": 0},
+	}}
+	tests := []struct {
+		name string
+		want int64
+	}{
+		{"report.pdf", 100},
+		{"movie.mp4", 1000},
+		{"MOVIE.MP4", 1000},
+		{"notes.txt", 0},
+		{"README", 100},
+	}
+	for _, tt := range tests {
+		if got := env.maxFileSize(tt.name); got != tt.want {
+			t.Errorf("maxFileSize(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLimitSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		limit   int64
+		wantErr bool
+	}{
+		{"unlimited", 10, 0, false},
+		{"under", 9, 10, false},
+		{"exact fit", 10, 10, false},
+		{"over", 11, 10, true},
+		{"far over", 1 << 16, 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := io.ReadAll(limitSize(strings.NewReader(strings.Repeat("x", tt.size)), tt.limit))
+			if gotErr := errors.Is(err, errFileTooLarge); gotErr != tt.wantErr {
+				t.Fatalf("err = %v, want too large %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(data) != tt.size {
+				t.Fatalf("read %d bytes, want %d", len(data), tt.size)
+			}
+			if tt.wantErr && int64(len(data)) != tt.limit {
+				t.Fatalf("read %d bytes before failing, want %d", len(data), tt.limit)
+			}
+		})
+	}
+}
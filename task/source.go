@@ -0,0 +1,194 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// UploadScheme marks files uploaded through the tus endpoints. Such sources
+// have the form upload://<upload id>/<file name>.
+const UploadScheme = "upload"
+
+// UploadURL returns the source URL for a completed upload.
+func UploadURL(uploadID, fileName string) string {
+	return fmt.Sprintf("%s://%s/%s", UploadScheme, uploadID, fileName)
+}
+
+// isUpload reports whether fileURL names a local upload.
+func isUpload(fileURL string) bool {
+	u, err := url.Parse(fileURL)
+	return err == nil && u.Scheme == UploadScheme
+}
+
+type statusError struct {
+	status string
+}
+
+func (e *statusError) Error() string {
+	return "status: " + e.status
+}
+
+// errNotModified reports a remote file that has not changed since the
+// task's modified_since time.
+var errNotModified = errors.New("not modified")
+
+// openSource opens the contents behind fileURL, which is either a remote
+// HTTP(S) URL or a local upload. The returned header is nil for uploads.
+// Canceling ctx aborts a remote download. When since is non-zero, remote
+// files last modified before it are not downloaded and errNotModified is
+// returned. Requests failing with a network error or a retryable status
+// are tried again up to DownloadRetries times; only the last failure is
+// returned.
+func (t *Task) openSource(ctx context.Context, fileURL string, env *Env, since time.Time) (io.ReadCloser, http.Header, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.Scheme == UploadScheme {
+		file, err := os.Open(filepath.Join(env.Config.UploadDir, filepath.Base(u.Host)))
+		return file, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !since.IsZero() {
+		req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+	}
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		if err := env.Hosts.Wait(ctx, u.Hostname()); err != nil {
+			return nil, nil, err
+		}
+		resp, err = env.Client.Do(req)
+		if err == nil {
+			env.Hosts.Observe(u.Hostname(), resp)
+		}
+		if attempt > env.Config.DownloadRetries || !(err != nil && retryableError(ctx, err) || err == nil && retryableStatus(resp.StatusCode)) {
+			break
+		}
+		delay := retryDelay(env, attempt, resp)
+		if err == nil {
+			resp.Body.Close()
+			err = &statusError{status: resp.Status}
+			resp = nil
+		}
+		t.logger().Warn("retrying download", "file_url", fileURL, "attempt", attempt, "retry_in", delay, "error", err)
+		env.Metrics.Count("files.retries", 1)
+		if !sleepContext(ctx, delay) {
+			return nil, nil, ctx.Err()
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && !since.IsZero() {
+		resp.Body.Close()
+		return nil, nil, errNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, nil, &statusError{status: resp.Status}
+	}
+	// Servers that ignore If-Modified-Since still report Last-Modified.
+	if !since.IsZero() {
+		if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil && modified.Before(since.Truncate(time.Second)) {
+			resp.Body.Close()
+			return nil, nil, errNotModified
+		}
+	}
+	return resp.Body, resp.Header, nil
+}
+
+// entryName picks the zip entry name for fileURL. By default it is the last
+// element of the URL; with ResolveEntryNames a Content-Disposition filename
+// takes precedence, and a name without an extension gets one derived from
+// the Content-Type.
+func entryName(env *Env, fileURL string, header http.Header) string {
+	name := filepath.Base(fileURL)
+	if !env.Config.ResolveEntryNames || header == nil {
+		return name
+	}
+
+	if u, err := url.Parse(fileURL); err == nil && u.Scheme != UploadScheme {
+		if base := path.Base(u.Path); base != "/" && base != "." {
+			name = base
+		}
+	}
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		if filename := SanitizeFileName(path.Base(params["filename"])); filename != "" {
+			return filename
+		}
+	}
+	if path.Ext(name) == "" {
+		name += extensionForType(header.Get("Content-Type"), env.Config.AllowedExtensions)
+	}
+	return name
+}
+
+// entryDir returns the folder, ending in a slash, that PreservePaths puts
+// the entry of fileURL in. Empty and "." segments of the URL path are
+// dropped and ".." removes the segment before it; a path that climbs above
+// the root is refused rather than clamped. Without PreservePaths, and for
+// uploads, the folder is empty.
+func entryDir(env *Env, fileURL string) (string, error) {
+	if !env.Config.PreservePaths {
+		return "", nil
+	}
+	u, err := url.Parse(fileURL)
+	if err != nil || u.Scheme == UploadScheme {
+		return "", nil
+	}
+	segments := strings.Split(u.Path, "/")
+	var dirs []string
+	for _, segment := range segments[:len(segments)-1] {
+		switch segment {
+		case "", ".":
+		case "..":
+			if len(dirs) == 0 {
+				return "", newFileError(fileURL, CodeInvalidPath, "path of %s climbs above the root", fileURL)
+			}
+			dirs = dirs[:len(dirs)-1]
+		default:
+			if dir := SanitizeFileName(segment); dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	if len(dirs) == 0 {
+		return "", nil
+	}
+	return strings.Join(dirs, "/") + "/", nil
+}
+
+// extensionForType maps a MIME type to a file extension, preferring one of
+// the allowed extensions when several are registered for the type.
+func extensionForType(contentType string, preferred []string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	exts, err := mime.ExtensionsByType(mediaType)
+	if err != nil || len(exts) == 0 {
+		return ""
+	}
+	for _, want := range preferred {
+		for _, ext := range exts {
+			if ext == want {
+				return ext
+			}
+		}
+	}
+	return exts[0]
+}
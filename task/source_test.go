@@ -0,0 +1,111 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEntryDir(t *testing.T) {
+	env := &Env{Config: &config.Config{PreservePaths: true}}
+	tests := []struct {
+		name    string
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{"root", "https://example.com/a.pdf", "", false},
+		{"nested", "https://example.com/docs/2025/a.pdf", "docs/2025/", false},
+		{"duplicate slashes", "https://example.com//docs///a.pdf", "docs/", false},
+		{"dot segments", "https://example.com/./docs/./a.pdf", "docs/", false},
+		{"parent segment", "https://example.com/docs/old/../a.pdf", "docs/", false},
+		{"unsafe characters", "https://example.com/my%20docs/a.pdf", "my_docs/", false},
+		{"climbs above root", "https://example.com/../a.pdf", "", true},
+		{"upload", UploadURL("0f8fad5b", "a.pdf"), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := entryDir(env, tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("entryDir(%q) error %v, want error %v", tt.url, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("entryDir(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntryDirWithoutPreservePaths(t *testing.T) {
+	env := &Env{Config: &config.Config{}}
+	if got, err := entryDir(env, "https://example.com/docs/a.pdf"); got != "" || err != nil {
+		t.Fatalf("entryDir = %q, %v, want no folder", got, err)
+	}
+}
+
+func TestProcessPreservePaths(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	tk := NewTask()
+	tk.FileURLs = []string{server.URL + "/docs/a.pdf", server.URL + "/other/a.pdf", server.URL + "/../b.pdf"}
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, PreservePaths: true}))
+
+	want := map[string]string{"docs/a.pdf": "%PDF-1.4 /docs/a.pdf", "other/a.pdf": "%PDF-1.4 /other/a.pdf"}
+	if got := readZipFile(t, tk.ID+".zip"); !maps.Equal(got, want) {
+		t.Fatalf("archive %q, want %q", got, want)
+	}
+	if len(tk.Errors) != 1 || tk.Errors[0].Code != CodeInvalidPath {
+		t.Fatalf("errors %+v, want one INVALID_PATH", tk.Errors)
+	}
+}
+
+func TestEntryName(t *testing.T) {
+	env := &Env{Config: &config.Config{ResolveEntryNames: true, AllowedExtensions: []string{".jpg", ".pdf"}}}
+	tests := []struct {
+		name        string
+		url         string
+		disposition string
+		contentType string
+		want        string
+	}{
+		{"url", "https://example.com/files/a.pdf?sig=x", "", "", "a.pdf"},
+		{"disposition", "https://example.com/download?id=1", `attachment; filename="report 2025.pdf"`, "application/pdf", "report_2025.pdf"},
+		{"disposition path", "https://example.com/download", `attachment; filename="../../etc/passwd"`, "", "passwd"},
+		{"content type", "https://example.com/files/photo", "", "image/jpeg", "photo.jpg"},
+		{"content type with params", "https://example.com/files/doc", "", "application/pdf; charset=binary", "doc.pdf"},
+		{"unknown type", "https://example.com/files/blob", "", "application/x-unknown", "blob"},
+		{"extension kept", "https://example.com/files/a.pdf", "", "image/jpeg", "a.pdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.disposition != "" {
+				header.Set("Content-Disposition", tt.disposition)
+			}
+			if tt.contentType != "" {
+				header.Set("Content-Type", tt.contentType)
+			}
+			if got := entryName(env, tt.url, header); got != tt.want {
+				t.Fatalf("entryName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntryNameDisabled(t *testing.T) {
+	env := &Env{Config: &config.Config{}}
+	header := http.Header{"Content-Disposition": {`attachment; filename="report.pdf"`}}
+	if got := entryName(env, "https://example.com/download", header); got != "download" {
+		t.Fatalf("entryName = %q, want the URL's last element", got)
+	}
+	env.Config.ResolveEntryNames = true
+	if got := entryName(env, UploadURL("0f8fad5b", "a.pdf"), nil); got != "a.pdf" {
+		t.Fatalf("upload entryName = %q, want a.pdf", got)
+	}
+}
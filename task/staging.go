@@ -0,0 +1,172 @@
+package task
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// stager downloads the files of a task concurrently into temporary files
+// while Process writes them to the archive in order. A file is only
+// started once fewer than ParallelDownloads files are downloading or
+// waiting to be written, which bounds both concurrency and temporary disk
+// use; since files start in order, the next file to write always holds a
+// slot and the pipeline cannot stall.
+type stager struct {
+	files  []*stagedFile
+	slots  chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// stagedFile is the download of one file. done is closed once info, path
+// and err are final; path is empty for git sources, which are cloned when
+// the archive is written, and for files that failed or were skipped.
+type stagedFile struct {
+	done    chan struct{}
+	started bool
+	info    FileInfo
+	path    string
+	err     error
+}
+
+// startStaging begins downloading t's files when ParallelDownloads is
+// above one. It returns nil otherwise; a nil stager stages nothing.
+func (t *Task) startStaging(ctx context.Context, env *Env, budget *budget) *stager {
+	if env.Config.ParallelDownloads <= 1 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &stager{
+		files:  make([]*stagedFile, len(t.FileURLs)),
+		slots:  make(chan struct{}, env.Config.ParallelDownloads),
+		cancel: cancel,
+	}
+	for i := range s.files {
+		s.files[i] = &stagedFile{done: make(chan struct{})}
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for i, fileURL := range t.FileURLs {
+			f := s.files[i]
+			select {
+			case s.slots <- struct{}{}:
+			case <-ctx.Done():
+				// Files that never started report the context error.
+				for _, rest := range s.files[i:] {
+					rest.err = ctx.Err()
+					close(rest.done)
+				}
+				return
+			}
+			f.started = true
+			t.setFileState(i, FileDownloading, "")
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer close(f.done)
+				f.info, f.path, f.err = t.stageFile(ctx, env, budget, fileURL)
+			}()
+		}
+	}()
+	return s
+}
+
+// stageFile downloads fileURL into a temporary file and returns its path.
+// The download draws from budget and is not started once it is used up.
+func (t *Task) stageFile(ctx context.Context, env *Env, budget *budget, fileURL string) (FileInfo, string, error) {
+	if isMultiEntrySource(fileURL) {
+		return FileInfo{URL: fileURL}, "", nil
+	}
+	if reason, ok := budget.exhausted(time.Now()); ok {
+		return FileInfo{URL: fileURL}, "", newFileError(fileURL, CodeBudgetExceeded, "skipped %s: %s", fileURL, reason)
+	}
+	if !isAllowedExtension(env, fileURL) {
+		t.logger().Warn("file extension not allowed", "file_url", fileURL)
+		return FileInfo{URL: fileURL}, "", newFileError(fileURL, CodeExtensionNotAllowed, "file extension not allowed: %s", fileURL)
+	}
+
+	info, body, limit, err := t.openFile(ctx, env, fileURL)
+	if err != nil || body == nil {
+		return info, "", err
+	}
+	defer body.Close()
+
+	info, staged, err := t.stageBody(budget.limit(limitSize(body, limit)), info, env.Config.DedupeContent)
+	if err != nil {
+		return info, "", err
+	}
+	staged.Close()
+	return info, staged.Name(), nil
+}
+
+// wait blocks until file i has been downloaded. It returns nil on a nil
+// stager.
+func (s *stager) wait(i int) *stagedFile {
+	if s == nil {
+		return nil
+	}
+	f := s.files[i]
+	<-f.done
+	return f
+}
+
+// release removes the temporary file of f and lets the next file start.
+func (s *stager) release(f *stagedFile) {
+	if s == nil || f == nil {
+		return
+	}
+	if f.path != "" {
+		os.Remove(f.path)
+		f.path = ""
+	}
+	if f.started {
+		f.started = false
+		<-s.slots
+	}
+}
+
+// close stops downloads still running and removes every temporary file
+// that was not released.
+func (s *stager) close() {
+	if s == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+	for _, f := range s.files {
+		if f.path != "" {
+			os.Remove(f.path)
+		}
+	}
+}
+
+// archiveStagedFile writes a file downloaded by the stager into archive.
+func (t *Task) archiveStagedFile(ctx context.Context, env *Env, budget *budget, archive ArchiveWriter, f *stagedFile, contentHashes map[string]string, bundle *smallFiles, names entryNames) (FileInfo, error) {
+	if f.err != nil {
+		return f.info, f.err
+	}
+	if isGitSource(f.info.URL) {
+		return t.archiveGitRepo(ctx, env, archive, f.info.URL, budget)
+	}
+	if isPagedSource(f.info.URL) {
+		return t.archivePaged(ctx, env, archive, f.info.URL, budget)
+	}
+	if f.info.Skipped != "" {
+		return f.info, nil
+	}
+
+	staged, err := os.Open(f.path)
+	if err != nil {
+		return f.info, newFileError(f.info.URL, CodeWriteFailed, "failed to stage file %s: %v", f.info.URL, err)
+	}
+	defer staged.Close()
+	if env.expandsArchive(f.info.Name) {
+		return t.expandArchive(env, archive, staged, f.info)
+	}
+	return t.writeStaged(archive, staged, f.info, contentHashes, bundle, names)
+}
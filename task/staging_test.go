@@ -0,0 +1,148 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"archive/zip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// delayedServer serves every path after the delay given by delay.
+func delayedServer(t testing.TB, delay func(path string) time.Duration) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.pdf" {
+			http.NotFound(w, r)
+			return
+		}
+		time.Sleep(delay(r.URL.Path))
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// zipEntryNames returns the entry names of the zip archive at path in order.
+func zipEntryNames(t testing.TB, path string) []string {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// stagedTempFiles lists the staging files left in dir.
+func stagedTempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "archiver-") {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+func TestStagingKeepsEntryOrder(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// Earlier files take longer, so downloads finish in reverse order.
+	server := delayedServer(t, func(path string) time.Duration {
+		var n int
+		fmt.Sscanf(path, "/%d.pdf", &n)
+		return time.Duration(5-n) * 20 * time.Millisecond
+	})
+
+	tk := NewTask()
+	var want []string
+	for i := range 5 {
+		tk.FileURLs = append(tk.FileURLs, fmt.Sprintf("%s/%d.pdf", server.URL, i))
+		want = append(want, fmt.Sprintf("%d.pdf", i))
+	}
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, ParallelDownloads: 3}))
+	if tk.Status != StatusDone || len(tk.Errors) != 0 {
+		t.Fatalf("task %s with errors %+v", tk.Status, tk.Errors)
+	}
+	if got := strings.Join(zipEntryNames(t, tk.ID+".zip"), " "); got != strings.Join(want, " ") {
+		t.Fatalf("entries %q, want %q", got, strings.Join(want, " "))
+	}
+	for i, f := range tk.Files {
+		if f.Name != want[i] {
+			t.Fatalf("file %d is %s, want %s", i, f.Name, want[i])
+		}
+	}
+}
+
+func TestStagingRemovesTempFiles(t *testing.T) {
+	tests := []struct {
+		name       string
+		paths      []string
+		config     config.Config
+		wantErrors int
+	}{
+		{"success", []string{"/a.pdf", "/b.pdf", "/c.pdf"}, config.Config{}, 0},
+		{"failed download", []string{"/a.pdf", "/missing.pdf", "/c.pdf"}, config.Config{}, 1},
+		{"duplicate content", []string{"/a.pdf", "/a.pdf", "/c.pdf"}, config.Config{DedupeContent: true}, 0},
+		{"too large", []string{"/a.pdf", "/b.pdf"}, config.Config{MaxFileSize: 4}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+			server := delayedServer(t, func(string) time.Duration { return 0 })
+
+			tk := NewTask()
+			for _, path := range tt.paths {
+				tk.FileURLs = append(tk.FileURLs, server.URL+path)
+			}
+			cfg := tt.config
+			cfg.AllowedExtensions = []string{".pdf"}
+			cfg.ParallelDownloads = 2
+			tk.Process(NewEnv(&cfg))
+			if len(tk.Errors) != tt.wantErrors {
+				t.Fatalf("errors %+v, want %d", tk.Errors, tt.wantErrors)
+			}
+			if left := stagedTempFiles(t, tmp); len(left) != 0 {
+				t.Fatalf("temporary files left behind: %v", left)
+			}
+		})
+	}
+}
+
+// BenchmarkStaging compares downloading files one at a time with staging
+// them in parallel, against a server that takes 10ms per file.
+func BenchmarkStaging(b *testing.B) {
+	b.Chdir(b.TempDir())
+	server := delayedServer(b, func(string) time.Duration { return 10 * time.Millisecond })
+	var urls []string
+	for i := range 8 {
+		urls = append(urls, fmt.Sprintf("%s/%d.pdf", server.URL, i))
+	}
+	for _, parallel := range []int{0, 4} {
+		b.Run(fmt.Sprintf("parallel=%d", parallel), func(b *testing.B) {
+			env := NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, ParallelDownloads: parallel})
+			for b.Loop() {
+				tk := NewTask()
+				tk.FileURLs = urls
+				tk.Process(env)
+				if tk.Status != StatusDone {
+					b.Fatalf("task %s: %s", tk.Status, tk.ErrorDetails)
+				}
+				os.Remove(tk.ID + ".zip")
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package task
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidTransition is returned when a task is moved to a status that
+// cannot follow its current one.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// transitions lists the statuses each status may move to. Done, partial,
+// error and canceled are final. A task may fail or be canceled before it
+// starts processing, e.g. when it is abandoned.
+var transitions = map[Status][]Status{
+	StatusCreated:    {StatusProcessing, StatusError, StatusCanceled},
+	StatusProcessing: {StatusDone, StatusPartial, StatusError, StatusCanceled},
+}
+
+// Finished reports whether s is a final status.
+func (s Status) Finished() bool {
+	_, ok := transitions[s]
+	return !ok
+}
+
+// HasArchive reports whether a task in status s produced an archive.
+func (s Status) HasArchive() bool {
+	return s == StatusDone || s == StatusPartial
+}
+
+// finishedStatus is the status of a task whose archive was built with the
+// given per-file failures.
+func finishedStatus(failures []FileError) Status {
+	if len(failures) > 0 {
+		return StatusPartial
+	}
+	return StatusDone
+}
+
+// setStatus moves t to next and publishes the change, or returns
+// ErrInvalidTransition and leaves t unchanged. Callers must hold t.mutex.
+func (t *Task) setStatus(next Status) error {
+	for _, allowed := range transitions[t.Status] {
+		if allowed == next {
+			t.Status = next
+			if next.Finished() {
+				finishedAt := time.Now()
+				t.FinishedAt = &finishedAt
+			}
+			t.publishLocked(Event{Type: "status", Status: next, ErrorDetails: t.ErrorDetails})
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: task %s is %s, cannot become %s", ErrInvalidTransition, t.ID, t.Status, next)
+}
@@ -0,0 +1,87 @@
+package task
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetStatus(t *testing.T) {
+	tests := []struct {
+		from    Status
+		to      Status
+		wantErr bool
+	}{
+		{StatusCreated, StatusProcessing, false},
+		{StatusCreated, StatusError, false},
+		{StatusCreated, StatusCanceled, false},
+		{StatusCreated, StatusDone, true},
+		{StatusCreated, StatusPartial, true},
+		{StatusProcessing, StatusDone, false},
+		{StatusProcessing, StatusPartial, false},
+		{StatusProcessing, StatusError, false},
+		{StatusProcessing, StatusCanceled, false},
+		{StatusProcessing, StatusCreated, true},
+		{StatusDone, StatusProcessing, true},
+		{StatusDone, StatusError, true},
+		{StatusPartial, StatusDone, true},
+		{StatusError, StatusProcessing, true},
+		{StatusCanceled, StatusProcessing, true},
+		{StatusCanceled, StatusError, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			tk := NewTask()
+			tk.Status = tt.from
+			tk.mutex.Lock()
+			err := tk.setStatus(tt.to)
+			tk.mutex.Unlock()
+			if errors.Is(err, ErrInvalidTransition) != tt.wantErr {
+				t.Fatalf("setStatus = %v, want invalid %v", err, tt.wantErr)
+			}
+			want := tt.to
+			if tt.wantErr {
+				want = tt.from
+			}
+			if tk.Status != want {
+				t.Fatalf("status %s, want %s", tk.Status, want)
+			}
+			if final := want != StatusCreated && want != StatusProcessing; !tt.wantErr && final != (tk.FinishedAt != nil) {
+				t.Fatalf("FinishedAt %v after moving to %s", tk.FinishedAt, want)
+			}
+		})
+	}
+}
+
+func TestCancelAndInterrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    Status
+		stop    func(*Task) error
+		code    ErrorCode
+		wantErr bool
+	}{
+		{"cancel created", StatusCreated, func(tk *Task) error { return tk.Cancel("deleted") }, CodeCanceled, false},
+		{"cancel processing", StatusProcessing, func(tk *Task) error { return tk.Cancel("deleted") }, CodeCanceled, true},
+		{"interrupt processing", StatusProcessing, func(tk *Task) error { return tk.Interrupt("shutdown") }, CodeInterrupted, false},
+		{"interrupt done", StatusDone, func(tk *Task) error { return tk.Interrupt("shutdown") }, CodeInterrupted, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tk := NewTask()
+			tk.Status = tt.from
+			err := tt.stop(tk)
+			if errors.Is(err, ErrInvalidTransition) != tt.wantErr {
+				t.Fatalf("error %v, want invalid %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if tk.Status != tt.from || len(tk.Errors) != 0 {
+					t.Fatalf("rejected stop changed the task to %s with %+v", tk.Status, tk.Errors)
+				}
+				return
+			}
+			if tk.Status != StatusCanceled || len(tk.Errors) != 1 || tk.Errors[0].Code != tt.code {
+				t.Fatalf("task %s with errors %+v, want canceled with %s", tk.Status, tk.Errors, tt.code)
+			}
+		})
+	}
+}
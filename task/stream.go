@@ -0,0 +1,67 @@
+package task
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Stream builds the archive of t straight into w instead of a file, for
+// clients that download it once. Nothing is kept in the archive directory:
+// t ends up done without a result URL, and failed if the archive could not
+// be completed or ctx ended first, in which case w holds a truncated
+// archive. It returns the error that failed t.
+func (t *Task) Stream(ctx context.Context, env *Env, w io.Writer) error {
+	if err := t.MarkProcessing(); err != nil {
+		return err
+	}
+	t.logger().Info("streaming task")
+	defer t.DiscardPrefetches()
+
+	started := time.Now()
+	env.Metrics.Count("tasks.started", 1)
+	defer func() {
+		status := "status:" + string(t.State())
+		env.Metrics.Count("tasks.finished", 1, status)
+		env.Metrics.Timing("tasks.duration", time.Since(started), status)
+	}()
+
+	hash := sha256.New()
+	if env.Config.ComputeArchiveChecksum {
+		w = io.MultiWriter(w, hash)
+	}
+	archive := newArchiveWriter(env, w, t.Format())
+	files, failures, err := t.writeArchive(ctx, env, archive)
+	if closeErr := archive.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to finish archive: %w", closeErr)
+	}
+	if err == nil && ctx.Err() != nil {
+		err = fmt.Errorf("client went away while the archive was streamed: %w", ctx.Err())
+	}
+	if err != nil {
+		t.logger().Error("failed to stream task", "error", err, "duration", time.Since(started))
+		t.setError(err.Error())
+		return err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if env.Config.ComputeArchiveChecksum {
+		t.ResultChecksum = hex.EncodeToString(hash.Sum(nil))
+	}
+	t.Files = files
+	t.Errors = failures
+	t.Streamed = true
+	if len(failures) > 0 {
+		t.ErrorDetails = joinFailures(failures)
+	}
+	if err := t.setStatus(finishedStatus(failures)); err != nil {
+		t.logger().Error("failed to finish task", "error", err)
+		return err
+	}
+	t.logger().Info("finished streaming task", "files", len(files), "failures", len(failures), "duration", time.Since(started))
+	return nil
+}
@@ -0,0 +1,26 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStreamCancelled(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := delayedServer(t, func(string) time.Duration { return 0 })
+	tk := NewTask()
+	tk.FileURLs = []string{server.URL + "/a.pdf"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	if err := tk.Stream(ctx, NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}}), &buf); err == nil {
+		t.Fatal("Stream succeeded after the client went away")
+	}
+	if tk.Status != StatusError || tk.Streamed {
+		t.Fatalf("task %s, streamed %v, want failed", tk.Status, tk.Streamed)
+	}
+}
@@ -0,0 +1,192 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"2025-08-02/config"
+)
+
+// errBlockedTarget is returned when a download would reach an address
+// that BlockPrivateIPs forbids.
+var errBlockedTarget = errors.New("target address is not allowed")
+
+// errDomainNotAllowed is returned when a download would reach a host
+// outside AllowedDomains.
+var errDomainNotAllowed = errors.New("host is not in allowed_domains")
+
+// lookupTimeout bounds the host lookup of CheckSourceURL.
+const lookupTimeout = 5 * time.Second
+
+// CheckSourceURL rejects file URLs clients may not add: anything but
+// http and https, including their git+ and paged+ forms and git://, URLs
+// without a host, git sources while they are disabled and, with
+// AllowedDomains set, URLs on other hosts. With
+// BlockPrivateIPs the host is resolved and URLs reaching a loopback,
+// link-local, private or unspecified address are refused too.
+func CheckSourceURL(ctx context.Context, fileURL string, cfg *config.Config) *FileError {
+	target := fileURL
+	if isGitSource(fileURL) {
+		if fileErr := checkGitSource(fileURL, cfg); fileErr != nil {
+			return fileErr
+		}
+		target, _ = splitGitURL(fileURL)
+	} else if isPagedSource(fileURL) {
+		target = strings.TrimPrefix(fileURL, pagedPrefix)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return newFileError(fileURL, CodeInvalidURL, "invalid URL %q: %v", fileURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "git" {
+		return newFileError(fileURL, CodeInvalidURL, "unsupported URL scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return newFileError(fileURL, CodeInvalidURL, "URL %q has no host", fileURL)
+	}
+	if !domainAllowed(u.Hostname(), cfg.AllowedDomains) {
+		return newFileError(fileURL, CodeDomainNotAllowed, "host %q is not in allowed_domains", u.Hostname())
+	}
+	if !cfg.BlockPrivateIPs {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	if err := checkHost(ctx, u.Hostname()); err != nil {
+		return newFileError(fileURL, CodeBlockedTarget, "%s: %v", fileURL, err)
+	}
+	return nil
+}
+
+// domainAllowed reports whether host matches an entry of allowed, or
+// allowed is empty. An entry with a leading dot, such as ".example.com",
+// matches the domain and all its subdomains; any other entry matches only
+// that host.
+func domainAllowed(host string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, entry := range allowed {
+		if domain, ok := strings.CutPrefix(entry, "."); ok {
+			if host == domain || strings.HasSuffix(host, entry) {
+				return true
+			}
+		} else if host == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHost resolves host and fails if any of its addresses is blocked.
+func checkHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		return checkIP(ip)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if err := checkIP(addr.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkIP fails for loopback, link-local (cloud metadata services among
+// them), RFC 1918 and unique local, and unspecified addresses.
+func checkIP(ip net.IP) error {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s", errBlockedTarget, ip)
+	}
+	return nil
+}
+
+// checkRedirect validates every redirect target like an added URL, so a
+// redirect cannot lead a download to another scheme, to a host outside
+// AllowedDomains or, with BlockPrivateIPs, to an internal address.
+func checkRedirect(cfg *config.Config) func(*http.Request, []*http.Request) error {
+	allowed := func(host string) bool { return domainAllowed(host, cfg.AllowedDomains) }
+	return redirectPolicy(cfg, allowed, errDomainNotAllowed)
+}
+
+// redirectPolicy returns a CheckRedirect function that stops after 10
+// redirects and refuses targets with a scheme other than http and https,
+// on a host allowed rejects, which fails with notAllowed, or, with
+// BlockPrivateIPs, resolving to an internal address.
+func redirectPolicy(cfg *config.Config, allowed func(host string) bool, notAllowed error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		// The limit of http.Client's default policy.
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to unsupported URL scheme %q", req.URL.Scheme)
+		}
+		if !allowed(req.URL.Hostname()) {
+			return fmt.Errorf("redirect to %s: %w", req.URL.Host, notAllowed)
+		}
+		if cfg.BlockPrivateIPs {
+			if err := checkHost(req.Context(), req.URL.Hostname()); err != nil {
+				return fmt.Errorf("redirect to %s: %w", req.URL.Host, err)
+			}
+		}
+		return nil
+	}
+}
+
+// errCallbackHostNotAllowed is returned when a callback is redirected to a
+// host outside CallbackAllowedHosts.
+var errCallbackHostNotAllowed = errors.New("host is not in callback_allowed_hosts")
+
+// NewCallbackClient returns the client for callback POSTs, which gives up
+// after timeout. Redirects are only followed to http and https URLs on
+// hosts allowed accepts, and with BlockPrivateIPs neither they nor any
+// connection may reach an internal address, as for downloads.
+func NewCallbackClient(cfg *config.Config, timeout time.Duration, allowed func(host string) bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.BlockPrivateIPs {
+		transport.DialContext = guardedDial("")
+	}
+	return &http.Client{
+		Transport:     transport,
+		Timeout:       timeout,
+		CheckRedirect: redirectPolicy(cfg, allowed, errCallbackHostNotAllowed),
+	}
+}
+
+// guardedDial checks the address every download connection is made to,
+// after name resolution, so a host that resolves to an internal address
+// only once the URL was checked is still refused. Connections to the
+// configured download proxy, named by proxyHost, are not checked.
+func guardedDial(proxyHost string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	guarded := *dialer
+	guarded.Control = func(_, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			return checkIP(ip)
+		}
+		return nil
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, _, err := net.SplitHostPort(addr); err == nil && proxyHost != "" && strings.EqualFold(host, proxyHost) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		return guarded.DialContext(ctx, network, addr)
+	}
+}
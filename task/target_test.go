@@ -0,0 +1,195 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckSourceURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		blockPrivate bool
+		want         ErrorCode
+	}{
+		{"http", "http://example.com/a.pdf", false, ""},
+		{"https", "https://example.com/a.pdf", false, ""},
+		{"paged", "paged+https://example.com/api/files", false, ""},
+		{"ftp", "ftp://example.com/a.pdf", false, CodeInvalidURL},
+		{"file", "file:///etc/passwd", false, CodeInvalidURL},
+		{"no host", "http:///a.pdf", false, CodeInvalidURL},
+		{"malformed", "http://%zz/a.pdf", false, CodeInvalidURL},
+		{"loopback allowed", "http://127.0.0.1/a.pdf", false, ""},
+		{"loopback", "http://127.0.0.1/a.pdf", true, CodeBlockedTarget},
+		{"metadata service", "http://169.254.169.254/latest/meta-data", true, CodeBlockedTarget},
+		{"private", "http://10.0.0.1/a.pdf", true, CodeBlockedTarget},
+		{"ipv6 loopback", "http://[::1]/a.pdf", true, CodeBlockedTarget},
+		{"unspecified", "http://0.0.0.0/a.pdf", true, CodeBlockedTarget},
+		{"public address", "http://93.184.216.34/a.pdf", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{BlockPrivateIPs: tt.blockPrivate}
+			fileErr := CheckSourceURL(context.Background(), tt.url, cfg)
+			var got ErrorCode
+			if fileErr != nil {
+				got = fileErr.Code
+			}
+			if got != tt.want {
+				t.Errorf("CheckSourceURL(%q) = %v, want code %q", tt.url, fileErr, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"fd00::1", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"8.8.8.8", false},
+		{"2606:4700::1111", false},
+	}
+	for _, tt := range tests {
+		err := checkIP(net.ParseIP(tt.ip))
+		if blocked := errors.Is(err, errBlockedTarget); blocked != tt.blocked {
+			t.Errorf("checkIP(%s) = %v, want blocked %v", tt.ip, err, tt.blocked)
+		}
+	}
+}
+
+func TestCheckRedirect(t *testing.T) {
+	tests := []struct {
+		name           string
+		target         string
+		allowedDomains []string
+		blockPrivate   bool
+		want           error
+	}{
+		{"allowed", "https://files.example.com/a.pdf", []string{".example.com"}, false, nil},
+		{"other domain", "https://example.org/a.pdf", []string{".example.com"}, false, errDomainNotAllowed},
+		{"private address", "http://10.0.0.1/a.pdf", nil, true, errBlockedTarget},
+		{"private address allowed", "http://10.0.0.1/a.pdf", nil, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{AllowedDomains: tt.allowedDomains, BlockPrivateIPs: tt.blockPrivate}
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			err := checkRedirect(cfg)(req, []*http.Request{req})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("checkRedirect(%s) = %v, want %v", tt.target, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRedirectRefuses(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		hops   int
+	}{
+		{"scheme", "file:///etc/passwd", 1},
+		{"too many hops", "https://example.com/a.pdf", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if err := checkRedirect(&config.Config{})(req, make([]*http.Request, tt.hops)); err == nil {
+				t.Fatalf("checkRedirect(%s) followed the redirect", tt.target)
+			}
+		})
+	}
+}
+
+func TestDomainAllowed(t *testing.T) {
+	tests := []struct {
+		host    string
+		allowed []string
+		want    bool
+	}{
+		{"example.com", nil, true},
+		{"example.com", []string{"example.com"}, true},
+		{"Example.COM.", []string{"example.com"}, true},
+		{"files.example.com", []string{"example.com"}, false},
+		{"files.example.com", []string{".example.com"}, true},
+		{"example.com", []string{".example.com"}, true},
+		{"badexample.com", []string{".example.com"}, false},
+		{"example.org", []string{"example.com", ".example.net"}, false},
+	}
+	for _, tt := range tests {
+		if got := domainAllowed(tt.host, tt.allowed); got != tt.want {
+			t.Errorf("domainAllowed(%q, %q) = %v, want %v", tt.host, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSourceURLAllowedDomains(t *testing.T) {
+	cfg := &config.Config{AllowedDomains: []string{".example.com"}}
+	tests := []struct {
+		url  string
+		want ErrorCode
+	}{
+		{"https://cdn.example.com/a.pdf", ""},
+		{"https://example.org/a.pdf", CodeDomainNotAllowed},
+	}
+	for _, tt := range tests {
+		fileErr := CheckSourceURL(context.Background(), tt.url, cfg)
+		var got ErrorCode
+		if fileErr != nil {
+			got = fileErr.Code
+		}
+		if got != tt.want {
+			t.Errorf("CheckSourceURL(%q) = %v, want code %q", tt.url, fileErr, tt.want)
+		}
+	}
+}
+
+func TestProcessBlocksPrivateConnections(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := delayedServer(t, func(string) time.Duration { return 0 })
+	tk := NewTask()
+	// The loopback URL was not checked when added; the connection is
+	// refused all the same.
+	tk.FileURLs = []string{server.URL + "/a.pdf"}
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, BlockPrivateIPs: true}))
+	if len(tk.Errors) != 1 || tk.Errors[0].Code != CodeBlockedTarget {
+		t.Fatalf("task %s with errors %+v, want the download blocked", tk.Status, tk.Errors)
+	}
+}
+
+func TestProcessRefusesRedirectOutsideAllowedDomains(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved.pdf" {
+			_, port, _ := net.SplitHostPort(r.Host)
+			http.Redirect(w, r, "http://localhost:"+port+"/a.pdf", http.StatusFound)
+			return
+		}
+		w.Write([]byte("%PDF-1.4"))
+	}))
+	defer server.Close()
+
+	tk := NewTask()
+	tk.FileURLs = []string{server.URL + "/moved.pdf"}
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, AllowedDomains: []string{"127.0.0.1"}}))
+	if len(tk.Errors) != 1 || tk.Errors[0].Code != CodeDomainNotAllowed {
+		t.Fatalf("task %s with errors %+v, want the redirect refused", tk.Status, tk.Errors)
+	}
+}
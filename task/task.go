@@ -1,16 +1,22 @@
 package task
 
 import (
-	"archive/zip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -21,16 +27,82 @@ const (
 	StatusCreated    Status = "created"
 	StatusProcessing Status = "processing"
 	StatusDone       Status = "done"
-	StatusError      Status = "error"
+	// StatusPartial is a task whose archive was built without some of its
+	// files.
+	StatusPartial  Status = "partial"
+	StatusError    Status = "error"
+	StatusCanceled Status = "canceled"
 )
 
 type Task struct {
-	ID           string   `json:"id"`
-	Status       Status   `json:"status"`
-	FileURLs     []string `json:"file_urls"`
-	ResultURL    string   `json:"result_url,omitempty"`
-	ErrorDetails string   `json:"error_details,omitempty"`
-	mutex        sync.Mutex
+	ID       string   `json:"id"`
+	Status   Status   `json:"status"`
+	FileURLs []string `json:"file_urls"`
+	GroupID  string   `json:"group_id,omitempty"`
+	// ByteBudget and TimeBudgetSeconds override the configured aggregate
+	// download budget of the task when positive.
+	ByteBudget        int64 `json:"byte_budget,omitempty"`
+	TimeBudgetSeconds int   `json:"time_budget_seconds,omitempty"`
+	// ModifiedSince, when set, limits the archive to files last modified
+	// at or after it; older files are listed with skipped NOT_MODIFIED.
+	ModifiedSince *time.Time `json:"modified_since,omitempty"`
+	// Include and Exclude are glob patterns applied to entry names before
+	// they are written; exclude wins over include.
+	Include        []string    `json:"include,omitempty"`
+	Exclude        []string    `json:"exclude,omitempty"`
+	Files          []FileInfo  `json:"files,omitempty"`
+	ResultURL      string      `json:"result_url,omitempty"`
+	ResultChecksum string      `json:"result_checksum,omitempty"`
+	SigningKeyID   string      `json:"signing_key_id,omitempty"`
+	ErrorDetails   string      `json:"error_details,omitempty"`
+	Errors         []FileError `json:"errors,omitempty"`
+	// CoalescedWith is the ID of an identical task whose processing run
+	// and archive this task shares.
+	CoalescedWith string `json:"coalesced_with,omitempty"`
+	// Metadata holds the metadata clients attached to file URLs, written
+	// to the archive's manifest.json.
+	Metadata map[string]json.RawMessage `json:"metadata,omitempty" swaggertype:"object"`
+	// FileStatuses tracks each file URL through processing, so polling
+	// the status shows how far the archive has come.
+	FileStatuses []FileStatus `json:"file_statuses,omitempty"`
+	// ExpiresAt is when the archive becomes due for cleanup.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ExpectedFiles, when positive, is the number of files after which
+	// the task starts processing, in place of the configured maximum.
+	ExpectedFiles int `json:"expected_files,omitempty"`
+	// CallbackURL is notified with a POST when the task is done or failed.
+	CallbackURL string `json:"callback_url,omitempty"`
+	// ProgressCallbackURL is sent the result of every file, in batches.
+	ProgressCallbackURL string `json:"progress_callback_url,omitempty"`
+	// FinishedAt is when the task became done or failed.
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// ArchiveExpired is set once the archive has been deleted; the task
+	// record itself is kept until task_retention_seconds have passed.
+	ArchiveExpired bool `json:"archive_expired,omitempty"`
+	// FileNames holds the entry names clients chose for file URLs, in
+	// place of the names derived from the URL or response.
+	FileNames map[string]string `json:"file_names,omitempty"`
+	// ArchiveFormat is FormatZip or FormatTarGz; empty means zip.
+	ArchiveFormat string `json:"archive_format,omitempty"`
+	// Streamed is set when the archive was sent in the response of a
+	// stream request and never stored.
+	Streamed bool `json:"streamed,omitempty"`
+	// RequestID is the X-Request-ID of the request that created the task.
+	RequestID string `json:"request_id,omitempty"`
+	// ClientID is the client that created the task when archives are
+	// namespaced per client. Only that client may download the archive.
+	ClientID       string `json:"-"`
+	archiveName    string
+	archiveDir     string
+	signature      []byte
+	subscribers    map[chan Event]struct{}
+	done           chan struct{}
+	progress       progress
+	prefetches     map[string]*prefetch
+	prefetchCtx    context.Context
+	cancelPrefetch context.CancelFunc
+	log            *slog.Logger
+	mutex          sync.Mutex
 }
 
 func NewTask() *Task {
@@ -41,120 +113,529 @@ func NewTask() *Task {
 	}
 }
 
-func (t *Task) AddFile(url string) {
+// SetLogger makes t log to logger, with its ID and the ID of the request
+// that created it on every record. It must be called before t is shared.
+func (t *Task) SetLogger(logger *slog.Logger) {
+	t.log = logger.With("task_id", t.ID)
+	if t.RequestID != "" {
+		t.log = t.log.With("request_id", t.RequestID)
+	}
+}
+
+// logger returns the logger set by SetLogger, or the default logger if
+// there is none.
+func (t *Task) logger() *slog.Logger {
+	if t.log == nil {
+		return slog.Default().With("task_id", t.ID)
+	}
+	return t.log
+}
+
+// modifiedSince returns the ModifiedSince threshold, or the zero time.
+func (t *Task) modifiedSince() time.Time {
+	if t.ModifiedSince == nil {
+		return time.Time{}
+	}
+	return *t.ModifiedSince
+}
+
+// ErrNotAccepting is returned when files are added to a task that has
+// already started processing.
+var ErrNotAccepting = errors.New("task is no longer accepting files")
+
+// ErrTooManyHosts is returned when a file would take a task over its limit
+// of distinct hosts.
+var ErrTooManyHosts = errors.New("too many distinct hosts")
+
+// ErrTaskFull is returned when files are added to a task that already has
+// all the files it expects.
+var ErrTaskFull = errors.New("task already has all its files")
+
+// AddFile appends url to the task, along with its entry name and metadata
+// if not empty. Only tasks in StatusCreated accept new files, and only as
+// many as they expect, or defaultCount if they declared no expected count.
+// A positive maxHosts caps the number of distinct hosts the task's URLs
+// may span; URLs on hosts already in the task are always accepted.
+func (t *Task) AddFile(url, filename string, metadata json.RawMessage, defaultCount, maxHosts int) error {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
+	if t.Status != StatusCreated {
+		return ErrNotAccepting
+	}
+	if want := t.fileLimit(defaultCount); len(t.FileURLs) >= want {
+		return fmt.Errorf("%w: the task has %d files", ErrTaskFull, len(t.FileURLs))
+	}
+	if host := sourceHost(url); maxHosts > 0 && host != "" {
+		if hosts := taskHosts(t.FileURLs); !hosts[host] && len(hosts) >= maxHosts {
+			return fmt.Errorf("%w: the task already spans %d hosts, the limit is %d", ErrTooManyHosts, len(hosts), maxHosts)
+		}
+	}
 	t.FileURLs = append(t.FileURLs, url)
+	t.FileStatuses = append(t.FileStatuses, FileStatus{URL: url, State: FilePending})
+	if len(metadata) > 0 {
+		// The map is replaced rather than updated so a status response
+		// encoding the task never reads it while it is written.
+		updated := make(map[string]json.RawMessage, len(t.Metadata)+1)
+		for u, m := range t.Metadata {
+			updated[u] = m
+		}
+		updated[url] = metadata
+		t.Metadata = updated
+	}
+	if filename != "" {
+		updated := make(map[string]string, len(t.FileNames)+1)
+		for u, name := range t.FileNames {
+			updated[u] = name
+		}
+		updated[url] = filename
+		t.FileNames = updated
+	}
+	return nil
 }
 
-func (t *Task) SetResultURL() {
+// fileName returns the entry name the client chose for fileURL, or "".
+func (t *Task) fileName(fileURL string) string {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	zipFileName := fmt.Sprintf("%s.zip", t.ID)
-	t.ResultURL = fmt.Sprintf("/archives/%s", zipFileName)
+	return t.FileNames[fileURL]
 }
 
-func (t *Task) Process(allowedExtensions []string) {
+// HasAllFiles reports whether t has as many files as it expects, or at
+// least defaultCount if it declared no expected count.
+func (t *Task) HasAllFiles(defaultCount int) bool {
 	t.mutex.Lock()
-	t.Status = StatusProcessing
-	t.mutex.Unlock()
-	log.Printf("Processing task %s", t.ID)
+	defer t.mutex.Unlock()
+	return len(t.FileURLs) >= t.fileLimit(defaultCount)
+}
 
-	zipFileName := fmt.Sprintf("%s.zip", t.ID)
-	zipFile, err := os.Create(zipFileName)
-	if err != nil {
-		log.Printf("Failed to create zip file for task %s: %v", t.ID, err)
-		t.setError(fmt.Sprintf("failed to create zip file: %v", err))
+// FileLimit returns how many files t accepts: the expected count it
+// declared, or defaultCount.
+func (t *Task) FileLimit(defaultCount int) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.fileLimit(defaultCount)
+}
+
+func (t *Task) fileLimit(defaultCount int) int {
+	if t.ExpectedFiles > 0 {
+		return t.ExpectedFiles
+	}
+	return defaultCount
+}
+
+// FileCount returns the number of files added to t.
+func (t *Task) FileCount() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.FileURLs)
+}
+
+// State returns the current status of the task.
+func (t *Task) State() Status {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.Status
+}
+
+// Result is a snapshot of the outcome of a task.
+type Result struct {
+	Status         Status
+	FileURLs       []string
+	Files          []FileInfo
+	ResultURL      string
+	ResultChecksum string
+	ErrorDetails   string
+	Errors         []FileError
+}
+
+// Result returns the current outcome fields of the task.
+func (t *Task) Result() Result {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return Result{
+		Status:         t.Status,
+		FileURLs:       append([]string(nil), t.FileURLs...),
+		Files:          append([]FileInfo(nil), t.Files...),
+		ResultURL:      t.ResultURL,
+		ResultChecksum: t.ResultChecksum,
+		ErrorDetails:   t.ErrorDetails,
+		Errors:         append([]FileError(nil), t.Errors...),
+	}
+}
+
+// SetArchiveName sets the on-disk archive path and the matching result URL.
+// The path may lead into the client's namespace directory, which is not
+// part of the URL.
+func (t *Task) SetArchiveName(name string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.archiveName = name
+	t.ResultURL = fmt.Sprintf("/archives/%s", filepath.Base(name))
+}
+
+// SetArchiveDir sets the directory of the default archive name, used
+// until SetArchiveName assigns one.
+func (t *Task) SetArchiveDir(dir string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.archiveDir = dir
+}
+
+// ArchiveName returns the archive file name, defaulting to "<id>.zip" or
+// "<id>.tar.gz" by the task's format in the directory set by
+// SetArchiveDir.
+func (t *Task) ArchiveName() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.archiveName == "" {
+		return filepath.Join(t.archiveDir, t.ID+FormatExtension(t.ArchiveFormat))
+	}
+	return t.archiveName
+}
+
+// Format returns the archive format of t, FormatZip unless it asked for
+// another.
+func (t *Task) Format() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.ArchiveFormat == "" {
+		return FormatZip
+	}
+	return t.ArchiveFormat
+}
+
+// ArchiveChecksum returns the hex-encoded SHA-256 of the finished archive,
+// or an empty string if it has not been computed.
+func (t *Task) ArchiveChecksum() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.ResultChecksum
+}
+
+// SetExpiresAt records when the archive of t is next due for cleanup.
+func (t *Task) SetExpiresAt(expiresAt time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.ExpiresAt = &expiresAt
+}
+
+func (t *Task) Process(env *Env) {
+	cfg := env.Config
+
+	if err := t.MarkProcessing(); err != nil {
+		t.logger().Warn("not processing task", "error", err)
 		return
 	}
-	defer zipFile.Close()
+	t.logger().Info("processing task")
+	defer t.DiscardPrefetches()
+	if cfg.ResumeInterruptedTasks {
+		defer t.removeParts()
+	}
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	started := time.Now()
+	env.Metrics.Count("tasks.started", 1)
+	defer func() {
+		status := "status:" + string(t.State())
+		env.Metrics.Count("tasks.finished", 1, status)
+		env.Metrics.Timing("tasks.duration", time.Since(started), status)
+	}()
 
-	var errors []string
+	zipFileName := t.ArchiveName()
+	if err := os.MkdirAll(filepath.Dir(zipFileName), 0755); err != nil {
+		t.logger().Error("failed to create archive directory", "error", err)
+		t.setError(fmt.Sprintf("failed to create archive directory: %v", err))
+		return
+	}
+	files, failures, err := t.buildArchive(env, zipFileName)
+	if errors.Is(err, errArchiveCorrupt) && cfg.RebuildOnVerifyFailure {
+		// Corruption may come from a transient disk fault, so the archive
+		// is built once more from scratch before the task fails.
+		t.logger().Warn("archive failed verification, rebuilding it", "error", err)
+		t.progress.reset()
+		files, failures, err = t.buildArchive(env, zipFileName)
+	}
+	if err != nil {
+		t.setError(err.Error())
+		return
+	}
 
-	for _, fileURL := range t.FileURLs {
-		log.Printf("Processing file %s for task %s", fileURL, t.ID)
-		if !isAllowedExtension(fileURL, allowedExtensions) {
-			log.Printf("File extension not allowed for %s", fileURL)
-			errors = append(errors, fmt.Sprintf("file extension not allowed: %s", fileURL))
-			continue
+	var checksum string
+	if cfg.ComputeArchiveChecksum {
+		checksum, err = fileChecksum(zipFileName)
+		if err != nil {
+			t.logger().Error("failed to compute archive checksum", "error", err)
+			failures = append(failures, *newFileError("", CodeArchiveFailed, "failed to compute archive checksum: %v", err))
 		}
+	}
 
-		resp, err := http.Get(fileURL)
+	var signature []byte
+	if cfg.SigningKey != nil {
+		signature, err = signFile(cfg.SigningKey, zipFileName)
 		if err != nil {
-			log.Printf("Failed to download file %s: %v", fileURL, err)
-			errors = append(errors, fmt.Sprintf("failed to download file: %s, error: %v", fileURL, err))
-			continue
+			t.logger().Error("failed to sign archive", "error", err)
+			failures = append(failures, *newFileError("", CodeArchiveFailed, "failed to sign archive: %v", err))
 		}
-		defer resp.Body.Close()
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			log.Printf("Failed to download file %s, status: %s", fileURL, resp.Status)
-			errors = append(errors, fmt.Sprintf("failed to download file: %s, status: %s", fileURL, resp.Status))
-			continue
-		}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.ResultChecksum = checksum
+	if signature != nil {
+		t.signature = signature
+		t.SigningKeyID = SigningKeyID(cfg.SigningKey.Public().(ed25519.PublicKey))
+	}
+	t.Files = files
+	t.Errors = failures
+	expiresAt := time.Now().Add(time.Duration(cfg.ArchiveMaxAgeSeconds) * time.Second)
+	t.ExpiresAt = &expiresAt
+	if len(failures) > 0 {
+		t.ErrorDetails = joinFailures(failures)
+	}
+
+	if err := t.setStatus(finishedStatus(failures)); err != nil {
+		t.logger().Error("failed to finish task", "error", err)
+		return
+	}
+	t.logger().Info("finished processing task", "files", len(files), "failures", len(failures), "duration", time.Since(started))
+}
+
+// buildArchive downloads the files of t and writes them to a temporary
+// file next to zipFileName, which is renamed to zipFileName once complete,
+// so a half-written zip is never served. It returns the archived files and
+// per-file failures; an error means no archive was produced.
+func (t *Task) buildArchive(env *Env, zipFileName string) ([]FileInfo, []FileError, error) {
+	cfg := env.Config
+
+	// The random suffix keeps concurrent builds apart even when they
+	// target the same archive name, e.g. a rebuild racing a stale run.
+	zipFile, err := os.CreateTemp(filepath.Dir(zipFileName), filepath.Base(zipFileName)+".*.tmp")
+	if err != nil {
+		t.logger().Error("failed to create archive file", "path", zipFileName, "error", err)
+		return nil, nil, fmt.Errorf("failed to create zip file: %w", err)
+	}
+	tmpFileName := zipFile.Name()
+	// CreateTemp makes the file private; archives stay world-readable as
+	// with os.Create.
+	zipFile.Chmod(0644)
+
+	archive := newArchiveWriter(env, zipFile, t.Format())
+	files, failures, err := t.writeArchive(context.Background(), env, archive)
+	if err != nil {
+		// Closing the archive releases the entry a tar.gz build buffers.
+		archive.Close()
+		zipFile.Close()
+		os.Remove(tmpFileName)
+		return nil, nil, err
+	}
+	if err := finalizeArchive(archive, zipFile, tmpFileName, zipFileName, t.Format(), cfg.VerifyArchives); err != nil {
+		t.logger().Error("failed to finish archive file", "path", zipFileName, "error", err)
+		return nil, nil, fmt.Errorf("failed to finalize zip file: %w", err)
+	}
+	return files, failures, nil
+}
+
+// writeArchive downloads the files of t and writes them to archive, which
+// it leaves open. The download phase ends early when ctx is done. It
+// returns the archived files and per-file failures; an error means the
+// archive could not be completed.
+func (t *Task) writeArchive(ctx context.Context, env *Env, archive ArchiveWriter) ([]FileInfo, []FileError, error) {
+	cfg := env.Config
+
+	var failures []FileError
+	files := []FileInfo{}
+	var contentHashes map[string]string
+	if cfg.DedupeContent {
+		contentHashes = make(map[string]string)
+	}
+
+	// The download phase as a whole is cut off after
+	// MaxDownloadPhaseDuration; the archive keeps whatever finished.
+	if cfg.MaxDownloadPhaseDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cfg.MaxDownloadPhaseDuration)*time.Second)
+		defer cancel()
+	}
+
+	t.resetFileStatuses()
+
+	// With ParallelDownloads the files are fetched ahead into temporary
+	// files; entries are still written one at a time in URL order. Every
+	// download draws from the byte budget as it is read, and the time
+	// budget cuts off downloads that are still running when it ends.
+	budget := t.newBudget(env, time.Now())
+	if !budget.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, budget.deadline)
+		defer cancel()
+	}
+	stager := t.startStaging(ctx, env, budget)
+	defer stager.close()
+
+	bundle := newSmallFiles(cfg.SmallFileBundleThreshold)
+	defer bundle.discard()
+	names := entryNames{}
 
-		fileName := filepath.Base(fileURL)
-		zipEntry, err := zipWriter.Create(fileName)
+	for i, fileURL := range t.FileURLs {
+		t.setFileState(i, FileDownloading, "")
+		staged := stager.wait(i)
+		info, err := t.archiveNext(ctx, env, budget, archive, fileURL, staged, contentHashes, bundle, names)
+		stager.release(staged)
 		if err != nil {
-			log.Printf("Failed to create zip entry for %s: %v", fileName, err)
-			errors = append(errors, fmt.Sprintf("failed to create zip entry for %s: %v", fileName, err))
+			failure := asFileError(fileURL, budget.cutOff(ctx, fileURL, err))
+			failures = append(failures, failure)
+			t.progress.filesFailed.Add(1)
+			t.setFileState(i, FileFailed, failure.Message)
+			env.Metrics.Count("files.failed", 1, "code:"+string(failure.Code))
+			t.publish(Event{Type: "file", Status: StatusError, File: fileURL, Code: failure.Code, Error: failure.Message})
 			continue
 		}
-
-		_, err = io.Copy(zipEntry, resp.Body)
-		if err != nil {
-			log.Printf("Failed to write to zip entry for %s: %v", fileName, err)
-			errors = append(errors, fmt.Sprintf("failed to write to zip entry for %s: %v", fileName, err))
+		t.progress.filesArchived.Add(1)
+		if info.Skipped != "" {
+			env.Metrics.Count("files.skipped", 1, "code:"+string(info.Skipped))
+		} else {
+			env.Metrics.Count("files.archived", 1)
+			env.Metrics.Count("files.bytes", info.Size)
 		}
+		files = append(files, info)
+		t.setFileState(i, FileDone, "")
+		t.publish(Event{Type: "file", Status: StatusDone, File: fileURL, Name: info.Name})
 	}
 
-	t.mutex.Lock()
-	defer t.mutex.Unlock()
+	if err := bundle.writeTo(archive); err != nil {
+		t.logger().Error("failed to write small file bundle", "error", err)
+		return nil, nil, fmt.Errorf("failed to write small file bundle: %w", err)
+	}
+	if err := t.writeManifest(archive, files); err != nil {
+		t.logger().Error("failed to write manifest", "error", err)
+		return nil, nil, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return files, failures, nil
+}
 
-	if len(errors) > 0 {
-		t.ErrorDetails = strings.Join(errors, "; ")
+// archiveNext adds fileURL to the archive unless the download phase or the
+// budget is over. staged is the file's prefetched download in parallel
+// mode and nil otherwise.
+func (t *Task) archiveNext(ctx context.Context, env *Env, budget *budget, archive ArchiveWriter, fileURL string, staged *stagedFile, contentHashes map[string]string, bundle *smallFiles, names entryNames) (FileInfo, error) {
+	// The budget goes first: its deadline also ends ctx.
+	if reason, ok := budget.exhausted(time.Now()); ok {
+		t.logger().Warn("skipping file", "file_url", fileURL, "reason", reason)
+		return FileInfo{}, newFileError(fileURL, CodeBudgetExceeded, "skipped %s: %s", fileURL, reason)
+	}
+	if ctx.Err() != nil {
+		t.logger().Warn("skipping file", "file_url", fileURL, "reason", "download phase deadline exceeded")
+		return FileInfo{}, newFileError(fileURL, CodeTimeout, "skipped %s: download phase deadline exceeded", fileURL)
+	}
+	t.logger().Debug("processing file", "file_url", fileURL)
+	if staged != nil {
+		return t.archiveStagedFile(ctx, env, budget, archive, staged, contentHashes, bundle, names)
+	}
+	if !isMultiEntrySource(fileURL) && !isAllowedExtension(env, fileURL) {
+		t.logger().Warn("file extension not allowed", "file_url", fileURL)
+		return FileInfo{}, newFileError(fileURL, CodeExtensionNotAllowed, "file extension not allowed: %s", fileURL)
 	}
+	return t.archiveSource(ctx, env, budget, archive, fileURL, contentHashes, bundle, names)
+}
 
-	t.Status = StatusDone
-	log.Printf("Finished processing task %s", t.ID)
+// joinFailures renders failures as the human-readable ErrorDetails string.
+func joinFailures(failures []FileError) string {
+	messages := make([]string, len(failures))
+	for i, f := range failures {
+		messages[i] = f.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// finalizeArchive finishes the archive, closes the temporary file and moves
+// it to its final name. With verify the entries of the archive, which is
+// in format, are checked first and a corrupt archive is removed instead.
+func finalizeArchive(archive ArchiveWriter, zipFile *os.File, tmpName, finalName, format string, verify bool) error {
+	if err := archive.Close(); err != nil {
+		zipFile.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := zipFile.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if verify {
+		if err := verifyArchive(tmpName, format); err != nil {
+			os.Remove(tmpName)
+			return fmt.Errorf("%w: %w", errArchiveCorrupt, err)
+		}
+	}
+	if err := os.Rename(tmpName, finalName); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// fileChecksum returns the hex-encoded SHA-256 of the file at path.
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
 func (t *Task) setError(errStr string) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	t.Status = StatusError
 	t.ErrorDetails = errStr
+	t.Errors = append(t.Errors, FileError{Code: CodeArchiveFailed, Message: errStr})
+	t.failUnfinishedFilesLocked(errStr)
+	if err := t.setStatus(StatusError); err != nil {
+		t.logger().Error("failed to record task error", "error", err)
+	}
 }
 
-func isAllowedExtension(fileURL string, allowedExtensions []string) bool {
+// isAllowedExtension rejects fileURL before it is requested when the
+// extension of its path is not allowed. URLs without one pass; their
+// response's Content-Type is checked by allowedTypeExtension instead.
+func isAllowedExtension(env *Env, fileURL string) bool {
 	u, err := url.Parse(fileURL)
 	if err != nil {
 		return false
 	}
 
-	path := u.Path
-	ext := strings.ToLower(filepath.Ext(path))
+	if _, ok := expandedFolder(u.Path); ok && env.Config.ExpandArchives {
+		return true
+	}
+	ext := urlExtension(fileURL)
+	return ext == "" || extensionAllowed(env, ext)
+}
+
+// urlExtension returns the lowercased extension of the path of fileURL.
+func urlExtension(fileURL string) string {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(filepath.Ext(u.Path))
+}
 
-	if ext == "" {
-		resp, err := http.Head(fileURL)
-		if err != nil {
-			return false
-		}
-		defer resp.Body.Close()
-
-		contentType := resp.Header.Get("Content-Type")
-		switch contentType {
-		case "application/pdf":
-			ext = ".pdf"
-		case "image/jpeg":
-			ext = ".jpeg"
-		}
+// allowedTypeExtension returns the allowed extension header's Content-Type
+// maps to, for files whose URL has no extension. ok is false when the type
+// maps to none of the allowed extensions.
+func allowedTypeExtension(env *Env, header http.Header) (string, bool) {
+	if header == nil {
+		return "", false
 	}
+	ext := extensionForType(header.Get("Content-Type"), env.Config.AllowedExtensions)
+	return ext, ext != "" && extensionAllowed(env, ext)
+}
 
-	for _, allowedExt := range allowedExtensions {
+func extensionAllowed(env *Env, ext string) bool {
+	for _, allowedExt := range env.Config.AllowedExtensions {
 		if ext == allowedExt {
 			return true
 		}
@@ -0,0 +1,44 @@
+package task
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAddFileOnlyWhileCreated(t *testing.T) {
+	tk := NewTask()
+	if err := tk.AddFile("https://example.com/a.pdf", "", nil, 10, 0); err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+	for _, status := range []Status{StatusProcessing, StatusDone, StatusError} {
+		tk.Status = status
+		if err := tk.AddFile("https://example.com/b.pdf", "", nil, 10, 0); !errors.Is(err, ErrNotAccepting) {
+			t.Errorf("AddFile while %s = %v, want %v", status, err, ErrNotAccepting)
+		}
+	}
+	if len(tk.FileURLs) != 1 {
+		t.Fatalf("task has %d files, want 1", len(tk.FileURLs))
+	}
+}
+
+func TestAddFileFull(t *testing.T) {
+	tk := NewTask()
+	if err := tk.AddFile("https://example.com/a.pdf", "", nil, 1, 0); err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+	if err := tk.AddFile("https://example.com/b.pdf", "", nil, 1, 0); !errors.Is(err, ErrTaskFull) {
+		t.Fatalf("AddFile past the default count = %v, want %v", err, ErrTaskFull)
+	}
+
+	// An expected count replaces the default.
+	tk = NewTask()
+	tk.ExpectedFiles = 2
+	for _, name := range []string{"a.pdf", "b.pdf"} {
+		if err := tk.AddFile("https://example.com/"+name, "", nil, 1, 0); err != nil {
+			t.Fatalf("AddFile(%s): %v", name, err)
+		}
+	}
+	if err := tk.AddFile("https://example.com/c.pdf", "", nil, 1, 0); !errors.Is(err, ErrTaskFull) {
+		t.Fatalf("AddFile past the expected count = %v, want %v", err, ErrTaskFull)
+	}
+}
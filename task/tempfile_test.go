@@ -0,0 +1,55 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentBuildsUseSeparateTempFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Keep both builds writing at the same time.
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	env := NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}})
+	var wg sync.WaitGroup
+	tasks := []*Task{NewTask(), NewTask()}
+	for _, tk := range tasks {
+		tk.FileURLs = []string{server.URL + "/a.pdf", server.URL + "/b.pdf"}
+		tk.SetArchiveName("shared.zip")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tk.Process(env)
+		}()
+	}
+	wg.Wait()
+
+	for _, tk := range tasks {
+		if tk.Status != StatusDone {
+			t.Fatalf("task is %s: %s", tk.Status, tk.ErrorDetails)
+		}
+	}
+	if got := readZipFile(t, "shared.zip"); len(got) != 2 {
+		t.Fatalf("archive has entries %q, want a.pdf and b.pdf", got)
+	}
+	info, err := os.Stat("shared.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o644 {
+		t.Fatalf("archive mode %v, want 0644", mode)
+	}
+	if leftovers, _ := filepath.Glob("*.tmp"); len(leftovers) != 0 {
+		t.Fatalf("temp files left behind: %v", leftovers)
+	}
+}
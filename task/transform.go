@@ -0,0 +1,101 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// transformsText reports whether the configured text transforms apply to
+// the entry name downloaded with header. Without a Content-Type, as for
+// uploads, the type is guessed from the extension of name.
+func (env *Env) transformsText(name string, header http.Header) bool {
+	if !env.Config.EnableTextTransforms || len(env.Config.TextTransforms) == 0 {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	return isTextType(contentType)
+}
+
+// isTextType reports whether contentType is text: text/* and the common
+// structured text formats such as JSON, XML and YAML.
+func isTextType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/yaml", "application/x-yaml", "application/x-ndjson":
+		return true
+	}
+	return false
+}
+
+// transformText returns body with rules applied to each line as it is
+// read. Lines are matched without their trailing newline; longer lines
+// than maxLine are matched in pieces of maxLine bytes, so at most that
+// much is buffered.
+func transformText(body io.ReadCloser, rules []config.TextTransform, maxLine int) io.ReadCloser {
+	return &textTransformer{
+		src:    bufio.NewReaderSize(body, maxLine),
+		closer: body,
+		rules:  rules,
+	}
+}
+
+type textTransformer struct {
+	src     *bufio.Reader
+	closer  io.Closer
+	rules   []config.TextTransform
+	pending []byte
+	err     error
+}
+
+func (t *textTransformer) Read(p []byte) (int, error) {
+	for len(t.pending) == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+		line, err := t.src.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			err = nil
+		}
+		t.pending = t.apply(line)
+		t.err = err
+	}
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+	return n, nil
+}
+
+// apply runs every rule over line, keeping its newline out of the match.
+func (t *textTransformer) apply(line []byte) []byte {
+	text, newline := bytes.CutSuffix(line, []byte("\n"))
+	out := bytes.Clone(text)
+	for _, rule := range t.rules {
+		out = rule.Regexp.ReplaceAll(out, []byte(rule.Replace))
+	}
+	if newline {
+		out = append(out, '\n')
+	}
+	return out
+}
+
+func (t *textTransformer) Close() error {
+	return t.closer.Close()
+}
@@ -0,0 +1,94 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTransformText(t *testing.T) {
+	rules := []config.TextTransform{
+		{Regexp: regexp.MustCompile(`token=\w+`), Replace: "token=REDACTED"},
+		{Regexp: regexp.MustCompile(`^\s+`), Replace: ""},
+	}
+	tests := []struct {
+		name    string
+		in      string
+		maxLine int
+		want    string
+	}{
+		{"lines", "a token=abc\n  b\n", 64, "a token=REDACTED\nb\n"},
+		{"no trailing newline", "token=x", 64, "token=REDACTED"},
+		{"newline not matched", "x\n\n", 64, "x\n\n"},
+		{"long line in pieces", "  " + strings.Repeat("y", 40) + "\n", 16, strings.Repeat("y", 40) + "\n"},
+		{"empty", "", 64, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(transformText(io.NopCloser(strings.NewReader(tt.in)), rules, tt.maxLine))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("transformText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTextType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"text/plain; charset=utf-8", true},
+		{"text/csv", true},
+		{"application/json", true},
+		{"application/ld+json", true},
+		{"image/svg+xml", true},
+		{"application/x-ndjson", true},
+		{"application/pdf", false},
+		{"image/png", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isTextType(tt.contentType); got != tt.want {
+			t.Errorf("isTextType(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestProcessTransformsText(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".txt") {
+			w.Header().Set("Content-Type", "text/plain")
+		} else {
+			w.Header().Set("Content-Type", "application/pdf")
+		}
+		w.Write([]byte("token=secret\n"))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		AllowedExtensions:         []string{".txt", ".pdf"},
+		EnableTextTransforms:      true,
+		TextTransforms:            []config.TextTransform{{Regexp: regexp.MustCompile(`token=\w+`), Replace: "token=REDACTED"}},
+		TextTransformMaxLineBytes: 64,
+	}
+	tk := NewTask()
+	tk.FileURLs = []string{server.URL + "/notes.txt", server.URL + "/doc.pdf"}
+	tk.Process(NewEnv(cfg))
+	if tk.Status != StatusDone {
+		t.Fatalf("task is %s: %+v", tk.Status, tk.Errors)
+	}
+	want := map[string]string{"notes.txt": "token=REDACTED\n", "doc.pdf": "token=secret\n"}
+	if got := readZipFile(t, tk.ID+".zip"); !maps.Equal(got, want) {
+		t.Fatalf("archive %q, want %q", got, want)
+	}
+}
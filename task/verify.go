@@ -0,0 +1,71 @@
+package task
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errArchiveCorrupt reports an archive that failed verification.
+var errArchiveCorrupt = errors.New("archive is corrupt")
+
+// verifyArchive reads every entry of the archive at path, which is in
+// format, in full, so the zip or gzip reader checks its CRC-32, and
+// reports the first entry that fails.
+func verifyArchive(path, format string) error {
+	if format == FormatTarGz {
+		return verifyTarGz(path)
+	}
+	reader, err := OpenArchive(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for _, entry := range reader.File {
+		rc, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("entry %s: %w", entry.Name, err)
+		}
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("entry %s: %w", entry.Name, err)
+		}
+	}
+	return nil
+}
+
+// verifyTarGz reads the gzipped tar stream at path to its end, past the
+// tar trailer, so the gzip reader checks the CRC-32 of the whole stream.
+func verifyTarGz(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, tr); err != nil {
+			return fmt.Errorf("entry %s: %w", header.Name, err)
+		}
+	}
+	_, err = io.Copy(io.Discard, gz)
+	return err
+}
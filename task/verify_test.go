@@ -0,0 +1,186 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// storedArchive writes an archive with one uncompressed entry holding
+// content and returns its bytes and the offset of the content.
+func storedArchive(t *testing.T, content string) ([]byte, int) {
+	t.Helper()
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	entry, err := zipWriter.CreateHeader(&zip.FileHeader{Name: "a.txt", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Write([]byte(content))
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), bytes.Index(buf.Bytes(), []byte(content))
+}
+
+func TestVerifyArchive(t *testing.T) {
+	data, offset := storedArchive(t, "archived content")
+	flipped := bytes.Clone(data)
+	flipped[offset] ^= 0xff
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"intact", data, false},
+		{"flipped byte", flipped, true},
+		{"truncated", data[:len(data)/2], true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "archive.zip")
+			if err := os.WriteFile(path, tt.data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := verifyArchive(path, FormatZip); (err != nil) != tt.wantErr {
+				t.Fatalf("verifyArchive = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFinalizeArchiveRemovesCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	tmpName, finalName := filepath.Join(dir, "a.zip.tmp"), filepath.Join(dir, "a.zip")
+	zipFile, err := os.Create(tmpName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipWriter := zip.NewWriter(zipFile)
+	entry, err := zipWriter.CreateHeader(&zip.FileHeader{Name: "a.txt", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Write([]byte("archived content"))
+	// Damage the entry on disk before the archive is finalized.
+	zipWriter.Flush()
+	if _, err := zipFile.WriteAt([]byte("X"), 40); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := finalizeArchive(zipWriter, zipFile, tmpName, finalName, FormatZip, true); err == nil {
+		t.Fatal("finalizeArchive accepted a corrupt archive")
+	}
+	for _, name := range []string{tmpName, finalName} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Fatalf("%s exists after a failed verification: %v", name, err)
+		}
+	}
+}
+
+func TestProcessVerifiesArchive(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := delayedServer(t, func(string) time.Duration { return 0 })
+	tk := NewTask()
+	tk.FileURLs = []string{server.URL + "/a.pdf", server.URL + "/b.pdf"}
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, VerifyArchives: true}))
+	if tk.Status != StatusDone || len(tk.Errors) != 0 {
+		t.Fatalf("task %s with errors %+v, want a verified archive", tk.Status, tk.Errors)
+	}
+	if err := verifyArchive(tk.ID+".zip", FormatZip); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// corruptingServer serves a.pdf as 64 KiB of random data. The first
+// corruptions requests for b.pdf flip a byte inside the a.pdf entry of the
+// archive being written in the working directory, which has reached the
+// disk by then. It returns the server and the number of b.pdf requests.
+func corruptingServer(t *testing.T, corruptions int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	content := make([]byte, 64<<10)
+	rand.Read(content)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/a.pdf" {
+			w.Write(content)
+			return
+		}
+		if int(requests.Add(1)) <= corruptions {
+			matches, _ := filepath.Glob("*.zip.*.tmp")
+			if len(matches) != 1 {
+				t.Errorf("archives being written: %v, want one", matches)
+			} else if f, err := os.OpenFile(matches[0], os.O_RDWR, 0); err == nil {
+				b := make([]byte, 1)
+				f.ReadAt(b, 1000)
+				f.WriteAt([]byte{b[0] ^ 0xff}, 1000)
+				f.Close()
+			}
+		}
+		w.Write([]byte("%PDF-1.4 " + r.URL.Path))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestProcessRebuildsCorruptArchive(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server, requests := corruptingServer(t, 1)
+	tk := NewTask()
+	tk.FileURLs = []string{server.URL + "/a.pdf", server.URL + "/b.pdf"}
+	tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, VerifyArchives: true, RebuildOnVerifyFailure: true}))
+
+	if tk.Status != StatusDone || len(tk.Errors) != 0 {
+		t.Fatalf("task %s (%s), want done after a rebuild", tk.Status, tk.ErrorDetails)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("b.pdf downloaded %d times, want 2 for one rebuild", n)
+	}
+	if err := verifyArchive(tk.ID+".zip", FormatZip); err != nil {
+		t.Fatalf("rebuilt archive: %v", err)
+	}
+	if p := tk.Progress(); p.FilesArchived != 2 || p.FilesDownloaded != 2 {
+		t.Fatalf("progress %+v counts the first build too", p)
+	}
+}
+
+func TestProcessFailsCorruptArchive(t *testing.T) {
+	tests := []struct {
+		name        string
+		rebuild     bool
+		corruptions int
+		wantBuilds  int32
+	}{
+		{"without rebuild", false, 1, 1},
+		{"corrupt again", true, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			server, requests := corruptingServer(t, tt.corruptions)
+			tk := NewTask()
+			tk.FileURLs = []string{server.URL + "/a.pdf", server.URL + "/b.pdf"}
+			tk.Process(NewEnv(&config.Config{AllowedExtensions: []string{".pdf"}, VerifyArchives: true, RebuildOnVerifyFailure: tt.rebuild}))
+
+			if tk.Status != StatusError || !strings.Contains(tk.ErrorDetails, "archive is corrupt") {
+				t.Fatalf("task %s (%s), want a corrupt archive error", tk.Status, tk.ErrorDetails)
+			}
+			if n := requests.Load(); n != tt.wantBuilds {
+				t.Fatalf("archive built %d times, want %d", n, tt.wantBuilds)
+			}
+			if _, err := os.Stat(tk.ID + ".zip"); !os.IsNotExist(err) {
+				t.Fatalf("corrupt archive was published: %v", err)
+			}
+		})
+	}
+}
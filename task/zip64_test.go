@@ -0,0 +1,114 @@
+package task
+
+import (
+	"2025-08-02/config"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// zeros reads as an endless run of zero bytes.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// writeTestArchive writes an archive through newZipWriter and createEntry,
+// calling fill to add the entries, and returns its path.
+func writeTestArchive(t *testing.T, env *Env, fill func(create func(name string) io.Writer)) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zipWriter := newZipWriter(env, file)
+	fill(func(name string) io.Writer {
+		entry, err := createEntry(zipWriter, name, env.zipMethod())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entry
+	})
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestZip64LargeEntry(t *testing.T) {
+	if testing.Short() {
+		t.Skip("streams more than 4 GiB")
+	}
+	// zstd squeezes the zeros to a few hundred KiB, so only the entry
+	// size, not the file on disk, goes past the 32-bit limit.
+	const size = 1<<32 + 1<<20
+	env := &Env{Config: &config.Config{CompressionMethod: config.CompressionZstd}}
+	path := writeTestArchive(t, env, func(create func(string) io.Writer) {
+		if _, err := io.CopyN(create("large.bin"), zeros{}, size); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(create("after.txt"), "after")
+	})
+
+	reader, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if len(reader.File) != 2 || reader.File[0].UncompressedSize64 != size {
+		t.Fatalf("read %d entries, first of %d bytes, want 2 and %d", len(reader.File), reader.File[0].UncompressedSize64, size)
+	}
+	rc, err := reader.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := io.Copy(io.Discard, rc)
+	rc.Close()
+	if err != nil || n != size {
+		t.Fatalf("read back %d bytes, %v", n, err)
+	}
+}
+
+func TestZip64ManyEntries(t *testing.T) {
+	const count = 1<<16 + 10
+	env := &Env{Config: &config.Config{}}
+	path := writeTestArchive(t, env, func(create func(string) io.Writer) {
+		for i := range count {
+			fmt.Fprintf(create(fmt.Sprintf("%05d.txt", i)), "%d", i)
+		}
+	})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("PK\x06\x06")) {
+		t.Fatal("archive has no ZIP64 end of central directory record")
+	}
+
+	reader, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if len(reader.File) != count {
+		t.Fatalf("read %d entries, want %d", len(reader.File), count)
+	}
+	last := reader.File[count-1]
+	rc, err := last.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = io.ReadAll(rc)
+	rc.Close()
+	if last.Name != fmt.Sprintf("%05d.txt", count-1) || string(data) != fmt.Sprint(count-1) || err != nil {
+		t.Fatalf("last entry %s reads %q, %v", last.Name, data, err)
+	}
+}
@@ -0,0 +1,4 @@
+// Package taskpb holds the Protocol Buffers form of the task status.
+package taskpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative task.proto